@@ -0,0 +1,94 @@
+// Package amount converts between the integer base units assets are transferred in (wei, token base units,
+// satoshi) and the decimal units they are displayed in. Conversions only shift the decimal point, so they are
+// exact and never depend on decimal.DivisionPrecision.
+package amount
+
+import (
+	"errors"
+	"github.com/shopspring/decimal"
+	"math/big"
+)
+
+// Unit is the number of decimal places between a display unit and its base unit.
+type Unit int32
+
+const (
+	Wei     Unit = 0
+	Gwei    Unit = 9
+	Ether   Unit = 18
+	Satoshi Unit = 0
+	Bitcoin Unit = 8
+)
+
+// Token is the display unit of an ERC20 token with the given decimals.
+func Token(decimals uint8) Unit {
+	return Unit(decimals)
+}
+
+// RoundingMode decides what happens to the digits below the base unit.
+type RoundingMode int32
+
+const (
+	RoundExact    RoundingMode = 0 // reject with ErrTooManyDecimals
+	RoundDown     RoundingMode = 1 // toward zero
+	RoundUp       RoundingMode = 2 // away from zero
+	RoundHalfUp   RoundingMode = 3 // to nearest, ties away from zero
+	RoundHalfEven RoundingMode = 4 // to nearest, ties to even
+)
+
+var (
+	ErrTooManyDecimals = errors.New("amount has more fractional digits than the unit supports")
+	ErrInvalidRounding = errors.New("unknown rounding mode")
+)
+
+// ToBase converts value, expressed in unit, to base units.
+func ToBase(value decimal.Decimal, unit Unit, mode RoundingMode) (*big.Int, error) {
+	shifted := value.Shift(int32(unit))
+	if shifted.IsInteger() {
+		return shifted.BigInt(), nil
+	}
+
+	switch mode {
+	case RoundExact:
+		return nil, ErrTooManyDecimals
+	case RoundDown:
+		shifted = shifted.Truncate(0)
+	case RoundUp:
+		if shifted.IsNegative() {
+			shifted = shifted.Floor()
+		} else {
+			shifted = shifted.Ceil()
+		}
+	case RoundHalfUp:
+		shifted = shifted.Round(0)
+	case RoundHalfEven:
+		shifted = shifted.RoundBank(0)
+	default:
+		return nil, ErrInvalidRounding
+	}
+	return shifted.BigInt(), nil
+}
+
+// Parse reads a decimal string expressed in unit and converts it to base units, rejecting excess precision.
+func Parse(value string, unit Unit) (*big.Int, error) {
+	d, err := decimal.NewFromString(value)
+	if err != nil {
+		return nil, err
+	}
+	return ToBase(d, unit, RoundExact)
+}
+
+// FromBase converts value, in base units, to unit.
+func FromBase(value *big.Int, unit Unit) decimal.Decimal {
+	return decimal.NewFromBigInt(value, -int32(unit))
+}
+
+// Convert re-expresses value from one unit of an asset in another, e.g. gwei in ether. Digits below the base
+// unit are handled according to mode.
+func Convert(value decimal.Decimal, from, to Unit, mode RoundingMode) (decimal.Decimal, error) {
+	base, err := ToBase(value, from, mode)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	return FromBase(base, to), nil
+}
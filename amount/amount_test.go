@@ -0,0 +1,87 @@
+package amount
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+)
+
+func Test_ToBase(t *testing.T) {
+	wei, err := Parse("1.5", Ether)
+	assert.NoError(t, err)
+	assert.Equal(t, "1500000000000000000", wei.String())
+
+	satoshi, err := Parse("0.00000001", Bitcoin)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", satoshi.String())
+
+	units, err := Parse("12.345678", Token(6))
+	assert.NoError(t, err)
+	assert.Equal(t, "12345678", units.String())
+
+	_, err = Parse("0.000000001", Bitcoin)
+	assert.ErrorIs(t, err, ErrTooManyDecimals)
+	_, err = Parse("1.0000001", Token(6))
+	assert.ErrorIs(t, err, ErrTooManyDecimals)
+	_, err = Parse("abc", Ether)
+	assert.Error(t, err)
+
+	// trailing zeros are not extra precision
+	units, err = Parse("1.500000000", Token(6))
+	assert.NoError(t, err)
+	assert.Equal(t, "1500000", units.String())
+}
+
+func Test_Rounding(t *testing.T) {
+	cases := []struct {
+		value    string
+		mode     RoundingMode
+		expected string
+	}{
+		{"1.25", RoundDown, "12"},
+		{"-1.25", RoundDown, "-12"},
+		{"1.21", RoundUp, "13"},
+		{"-1.21", RoundUp, "-13"},
+		{"1.25", RoundHalfUp, "13"},
+		{"1.24", RoundHalfUp, "12"},
+		{"1.25", RoundHalfEven, "12"},
+		{"1.35", RoundHalfEven, "14"},
+		{"1.2", RoundExact, "12"},
+	}
+	for _, c := range cases {
+		result, err := ToBase(decimal.RequireFromString(c.value), Token(1), c.mode)
+		assert.NoError(t, err, c.value)
+		assert.Equal(t, c.expected, result.String(), "%s mode %d", c.value, c.mode)
+	}
+
+	_, err := ToBase(decimal.RequireFromString("1.25"), Token(1), RoundExact)
+	assert.ErrorIs(t, err, ErrTooManyDecimals)
+	_, err = ToBase(decimal.RequireFromString("1.25"), Token(1), RoundingMode(9))
+	assert.ErrorIs(t, err, ErrInvalidRounding)
+}
+
+func Test_FromBase(t *testing.T) {
+	balance, _ := new(big.Int).SetString("123456789012345678901", 10)
+	assert.Equal(t, "123.456789012345678901", FromBase(balance, Ether).String())
+	assert.Equal(t, "0.00000001", FromBase(big.NewInt(1), Bitcoin).String())
+	assert.Equal(t, "0", FromBase(big.NewInt(0), Token(6)).String())
+
+	// the value stays exact however small the division precision is
+	precision := decimal.DivisionPrecision
+	decimal.DivisionPrecision = 2
+	defer func() { decimal.DivisionPrecision = precision }()
+	assert.Equal(t, "0.000000000000000001", FromBase(big.NewInt(1), Ether).String())
+}
+
+func Test_Convert(t *testing.T) {
+	ether, err := Convert(decimal.RequireFromString("1.5"), Gwei, Ether, RoundExact)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.0000000015", ether.String())
+
+	gwei, err := Convert(decimal.RequireFromString("0.0000000015000000001"), Ether, Gwei, RoundExact)
+	assert.ErrorIs(t, err, ErrTooManyDecimals)
+	gwei, err = Convert(decimal.RequireFromString("0.0000000015000000001"), Ether, Gwei, RoundDown)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.5", gwei.String())
+}
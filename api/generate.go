@@ -0,0 +1,4 @@
+// Package api holds the protobuf definitions of the wallet gRPC services and the code generated from them.
+package api
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative wallet.proto
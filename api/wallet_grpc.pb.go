@@ -0,0 +1,1013 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: wallet.proto
+
+package api
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	EthService_CreateAddress_FullMethodName        = "/wallet.EthService/CreateAddress"
+	EthService_AddressFromPublicKey_FullMethodName = "/wallet.EthService/AddressFromPublicKey"
+	EthService_Balance_FullMethodName              = "/wallet.EthService/Balance"
+	EthService_TokenInfo_FullMethodName            = "/wallet.EthService/TokenInfo"
+	EthService_SuggestGasPrice_FullMethodName      = "/wallet.EthService/SuggestGasPrice"
+	EthService_Nonce_FullMethodName                = "/wallet.EthService/Nonce"
+	EthService_BlockHeight_FullMethodName          = "/wallet.EthService/BlockHeight"
+	EthService_Block_FullMethodName                = "/wallet.EthService/Block"
+	EthService_ListBlocks_FullMethodName           = "/wallet.EthService/ListBlocks"
+	EthService_ListTransactions_FullMethodName     = "/wallet.EthService/ListTransactions"
+	EthService_Transaction_FullMethodName          = "/wallet.EthService/Transaction"
+	EthService_CreateTransaction_FullMethodName    = "/wallet.EthService/CreateTransaction"
+	EthService_SignTransaction_FullMethodName      = "/wallet.EthService/SignTransaction"
+	EthService_Broadcast_FullMethodName            = "/wallet.EthService/Broadcast"
+)
+
+// EthServiceClient is the client API for EthService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type EthServiceClient interface {
+	CreateAddress(ctx context.Context, in *CreateAddressRequest, opts ...grpc.CallOption) (*AddressResponse, error)
+	AddressFromPublicKey(ctx context.Context, in *PublicKeyRequest, opts ...grpc.CallOption) (*AddressResponse, error)
+	Balance(ctx context.Context, in *EthBalanceRequest, opts ...grpc.CallOption) (*BalanceResponse, error)
+	TokenInfo(ctx context.Context, in *TokenInfoRequest, opts ...grpc.CallOption) (*TokenInfoResponse, error)
+	SuggestGasPrice(ctx context.Context, in *SuggestGasPriceRequest, opts ...grpc.CallOption) (*GasPrice, error)
+	Nonce(ctx context.Context, in *NonceRequest, opts ...grpc.CallOption) (*NonceResponse, error)
+	BlockHeight(ctx context.Context, in *BlockHeightRequest, opts ...grpc.CallOption) (*BlockHeightResponse, error)
+	Block(ctx context.Context, in *BlockRequest, opts ...grpc.CallOption) (*EthBlock, error)
+	ListBlocks(ctx context.Context, in *ListBlocksRequest, opts ...grpc.CallOption) (*EthBlockList, error)
+	ListTransactions(ctx context.Context, in *ListTransactionsRequest, opts ...grpc.CallOption) (*EthTransactionList, error)
+	Transaction(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*EthTransaction, error)
+	CreateTransaction(ctx context.Context, in *EthCreateTransactionRequest, opts ...grpc.CallOption) (*UnsignedTransaction, error)
+	SignTransaction(ctx context.Context, in *EthSignTransactionRequest, opts ...grpc.CallOption) (*SignedTransaction, error)
+	Broadcast(ctx context.Context, in *BroadcastRequest, opts ...grpc.CallOption) (*BroadcastResponse, error)
+}
+
+type ethServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEthServiceClient(cc grpc.ClientConnInterface) EthServiceClient {
+	return &ethServiceClient{cc}
+}
+
+func (c *ethServiceClient) CreateAddress(ctx context.Context, in *CreateAddressRequest, opts ...grpc.CallOption) (*AddressResponse, error) {
+	out := new(AddressResponse)
+	err := c.cc.Invoke(ctx, EthService_CreateAddress_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethServiceClient) AddressFromPublicKey(ctx context.Context, in *PublicKeyRequest, opts ...grpc.CallOption) (*AddressResponse, error) {
+	out := new(AddressResponse)
+	err := c.cc.Invoke(ctx, EthService_AddressFromPublicKey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethServiceClient) Balance(ctx context.Context, in *EthBalanceRequest, opts ...grpc.CallOption) (*BalanceResponse, error) {
+	out := new(BalanceResponse)
+	err := c.cc.Invoke(ctx, EthService_Balance_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethServiceClient) TokenInfo(ctx context.Context, in *TokenInfoRequest, opts ...grpc.CallOption) (*TokenInfoResponse, error) {
+	out := new(TokenInfoResponse)
+	err := c.cc.Invoke(ctx, EthService_TokenInfo_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethServiceClient) SuggestGasPrice(ctx context.Context, in *SuggestGasPriceRequest, opts ...grpc.CallOption) (*GasPrice, error) {
+	out := new(GasPrice)
+	err := c.cc.Invoke(ctx, EthService_SuggestGasPrice_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethServiceClient) Nonce(ctx context.Context, in *NonceRequest, opts ...grpc.CallOption) (*NonceResponse, error) {
+	out := new(NonceResponse)
+	err := c.cc.Invoke(ctx, EthService_Nonce_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethServiceClient) BlockHeight(ctx context.Context, in *BlockHeightRequest, opts ...grpc.CallOption) (*BlockHeightResponse, error) {
+	out := new(BlockHeightResponse)
+	err := c.cc.Invoke(ctx, EthService_BlockHeight_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethServiceClient) Block(ctx context.Context, in *BlockRequest, opts ...grpc.CallOption) (*EthBlock, error) {
+	out := new(EthBlock)
+	err := c.cc.Invoke(ctx, EthService_Block_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethServiceClient) ListBlocks(ctx context.Context, in *ListBlocksRequest, opts ...grpc.CallOption) (*EthBlockList, error) {
+	out := new(EthBlockList)
+	err := c.cc.Invoke(ctx, EthService_ListBlocks_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethServiceClient) ListTransactions(ctx context.Context, in *ListTransactionsRequest, opts ...grpc.CallOption) (*EthTransactionList, error) {
+	out := new(EthTransactionList)
+	err := c.cc.Invoke(ctx, EthService_ListTransactions_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethServiceClient) Transaction(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*EthTransaction, error) {
+	out := new(EthTransaction)
+	err := c.cc.Invoke(ctx, EthService_Transaction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethServiceClient) CreateTransaction(ctx context.Context, in *EthCreateTransactionRequest, opts ...grpc.CallOption) (*UnsignedTransaction, error) {
+	out := new(UnsignedTransaction)
+	err := c.cc.Invoke(ctx, EthService_CreateTransaction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethServiceClient) SignTransaction(ctx context.Context, in *EthSignTransactionRequest, opts ...grpc.CallOption) (*SignedTransaction, error) {
+	out := new(SignedTransaction)
+	err := c.cc.Invoke(ctx, EthService_SignTransaction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ethServiceClient) Broadcast(ctx context.Context, in *BroadcastRequest, opts ...grpc.CallOption) (*BroadcastResponse, error) {
+	out := new(BroadcastResponse)
+	err := c.cc.Invoke(ctx, EthService_Broadcast_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// EthServiceServer is the server API for EthService service.
+// All implementations must embed UnimplementedEthServiceServer
+// for forward compatibility
+type EthServiceServer interface {
+	CreateAddress(context.Context, *CreateAddressRequest) (*AddressResponse, error)
+	AddressFromPublicKey(context.Context, *PublicKeyRequest) (*AddressResponse, error)
+	Balance(context.Context, *EthBalanceRequest) (*BalanceResponse, error)
+	TokenInfo(context.Context, *TokenInfoRequest) (*TokenInfoResponse, error)
+	SuggestGasPrice(context.Context, *SuggestGasPriceRequest) (*GasPrice, error)
+	Nonce(context.Context, *NonceRequest) (*NonceResponse, error)
+	BlockHeight(context.Context, *BlockHeightRequest) (*BlockHeightResponse, error)
+	Block(context.Context, *BlockRequest) (*EthBlock, error)
+	ListBlocks(context.Context, *ListBlocksRequest) (*EthBlockList, error)
+	ListTransactions(context.Context, *ListTransactionsRequest) (*EthTransactionList, error)
+	Transaction(context.Context, *TransactionRequest) (*EthTransaction, error)
+	CreateTransaction(context.Context, *EthCreateTransactionRequest) (*UnsignedTransaction, error)
+	SignTransaction(context.Context, *EthSignTransactionRequest) (*SignedTransaction, error)
+	Broadcast(context.Context, *BroadcastRequest) (*BroadcastResponse, error)
+	mustEmbedUnimplementedEthServiceServer()
+}
+
+// UnimplementedEthServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedEthServiceServer struct {
+}
+
+func (UnimplementedEthServiceServer) CreateAddress(context.Context, *CreateAddressRequest) (*AddressResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateAddress not implemented")
+}
+func (UnimplementedEthServiceServer) AddressFromPublicKey(context.Context, *PublicKeyRequest) (*AddressResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddressFromPublicKey not implemented")
+}
+func (UnimplementedEthServiceServer) Balance(context.Context, *EthBalanceRequest) (*BalanceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Balance not implemented")
+}
+func (UnimplementedEthServiceServer) TokenInfo(context.Context, *TokenInfoRequest) (*TokenInfoResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TokenInfo not implemented")
+}
+func (UnimplementedEthServiceServer) SuggestGasPrice(context.Context, *SuggestGasPriceRequest) (*GasPrice, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SuggestGasPrice not implemented")
+}
+func (UnimplementedEthServiceServer) Nonce(context.Context, *NonceRequest) (*NonceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Nonce not implemented")
+}
+func (UnimplementedEthServiceServer) BlockHeight(context.Context, *BlockHeightRequest) (*BlockHeightResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BlockHeight not implemented")
+}
+func (UnimplementedEthServiceServer) Block(context.Context, *BlockRequest) (*EthBlock, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Block not implemented")
+}
+func (UnimplementedEthServiceServer) ListBlocks(context.Context, *ListBlocksRequest) (*EthBlockList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListBlocks not implemented")
+}
+func (UnimplementedEthServiceServer) ListTransactions(context.Context, *ListTransactionsRequest) (*EthTransactionList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTransactions not implemented")
+}
+func (UnimplementedEthServiceServer) Transaction(context.Context, *TransactionRequest) (*EthTransaction, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Transaction not implemented")
+}
+func (UnimplementedEthServiceServer) CreateTransaction(context.Context, *EthCreateTransactionRequest) (*UnsignedTransaction, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateTransaction not implemented")
+}
+func (UnimplementedEthServiceServer) SignTransaction(context.Context, *EthSignTransactionRequest) (*SignedTransaction, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SignTransaction not implemented")
+}
+func (UnimplementedEthServiceServer) Broadcast(context.Context, *BroadcastRequest) (*BroadcastResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Broadcast not implemented")
+}
+func (UnimplementedEthServiceServer) mustEmbedUnimplementedEthServiceServer() {}
+
+// UnsafeEthServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to EthServiceServer will
+// result in compilation errors.
+type UnsafeEthServiceServer interface {
+	mustEmbedUnimplementedEthServiceServer()
+}
+
+func RegisterEthServiceServer(s grpc.ServiceRegistrar, srv EthServiceServer) {
+	s.RegisterService(&EthService_ServiceDesc, srv)
+}
+
+func _EthService_CreateAddress_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateAddressRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthServiceServer).CreateAddress(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EthService_CreateAddress_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthServiceServer).CreateAddress(ctx, req.(*CreateAddressRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthService_AddressFromPublicKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PublicKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthServiceServer).AddressFromPublicKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EthService_AddressFromPublicKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthServiceServer).AddressFromPublicKey(ctx, req.(*PublicKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthService_Balance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EthBalanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthServiceServer).Balance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EthService_Balance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthServiceServer).Balance(ctx, req.(*EthBalanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthService_TokenInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TokenInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthServiceServer).TokenInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EthService_TokenInfo_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthServiceServer).TokenInfo(ctx, req.(*TokenInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthService_SuggestGasPrice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SuggestGasPriceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthServiceServer).SuggestGasPrice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EthService_SuggestGasPrice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthServiceServer).SuggestGasPrice(ctx, req.(*SuggestGasPriceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthService_Nonce_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NonceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthServiceServer).Nonce(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EthService_Nonce_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthServiceServer).Nonce(ctx, req.(*NonceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthService_BlockHeight_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BlockHeightRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthServiceServer).BlockHeight(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EthService_BlockHeight_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthServiceServer).BlockHeight(ctx, req.(*BlockHeightRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthService_Block_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BlockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthServiceServer).Block(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EthService_Block_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthServiceServer).Block(ctx, req.(*BlockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthService_ListBlocks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListBlocksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthServiceServer).ListBlocks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EthService_ListBlocks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthServiceServer).ListBlocks(ctx, req.(*ListBlocksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthService_ListTransactions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTransactionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthServiceServer).ListTransactions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EthService_ListTransactions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthServiceServer).ListTransactions(ctx, req.(*ListTransactionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthService_Transaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthServiceServer).Transaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EthService_Transaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthServiceServer).Transaction(ctx, req.(*TransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthService_CreateTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EthCreateTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthServiceServer).CreateTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EthService_CreateTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthServiceServer).CreateTransaction(ctx, req.(*EthCreateTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthService_SignTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EthSignTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthServiceServer).SignTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EthService_SignTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthServiceServer).SignTransaction(ctx, req.(*EthSignTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EthService_Broadcast_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BroadcastRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EthServiceServer).Broadcast(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EthService_Broadcast_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EthServiceServer).Broadcast(ctx, req.(*BroadcastRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// EthService_ServiceDesc is the grpc.ServiceDesc for EthService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var EthService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "wallet.EthService",
+	HandlerType: (*EthServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateAddress",
+			Handler:    _EthService_CreateAddress_Handler,
+		},
+		{
+			MethodName: "AddressFromPublicKey",
+			Handler:    _EthService_AddressFromPublicKey_Handler,
+		},
+		{
+			MethodName: "Balance",
+			Handler:    _EthService_Balance_Handler,
+		},
+		{
+			MethodName: "TokenInfo",
+			Handler:    _EthService_TokenInfo_Handler,
+		},
+		{
+			MethodName: "SuggestGasPrice",
+			Handler:    _EthService_SuggestGasPrice_Handler,
+		},
+		{
+			MethodName: "Nonce",
+			Handler:    _EthService_Nonce_Handler,
+		},
+		{
+			MethodName: "BlockHeight",
+			Handler:    _EthService_BlockHeight_Handler,
+		},
+		{
+			MethodName: "Block",
+			Handler:    _EthService_Block_Handler,
+		},
+		{
+			MethodName: "ListBlocks",
+			Handler:    _EthService_ListBlocks_Handler,
+		},
+		{
+			MethodName: "ListTransactions",
+			Handler:    _EthService_ListTransactions_Handler,
+		},
+		{
+			MethodName: "Transaction",
+			Handler:    _EthService_Transaction_Handler,
+		},
+		{
+			MethodName: "CreateTransaction",
+			Handler:    _EthService_CreateTransaction_Handler,
+		},
+		{
+			MethodName: "SignTransaction",
+			Handler:    _EthService_SignTransaction_Handler,
+		},
+		{
+			MethodName: "Broadcast",
+			Handler:    _EthService_Broadcast_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "wallet.proto",
+}
+
+const (
+	BtcService_ValidateAddress_FullMethodName      = "/wallet.BtcService/ValidateAddress"
+	BtcService_AddressFromPublicKey_FullMethodName = "/wallet.BtcService/AddressFromPublicKey"
+	BtcService_BlockHeight_FullMethodName          = "/wallet.BtcService/BlockHeight"
+	BtcService_Block_FullMethodName                = "/wallet.BtcService/Block"
+	BtcService_ListBlocks_FullMethodName           = "/wallet.BtcService/ListBlocks"
+	BtcService_ListTransactions_FullMethodName     = "/wallet.BtcService/ListTransactions"
+	BtcService_Transaction_FullMethodName          = "/wallet.BtcService/Transaction"
+	BtcService_CreateTransaction_FullMethodName    = "/wallet.BtcService/CreateTransaction"
+	BtcService_SignTransaction_FullMethodName      = "/wallet.BtcService/SignTransaction"
+	BtcService_Broadcast_FullMethodName            = "/wallet.BtcService/Broadcast"
+)
+
+// BtcServiceClient is the client API for BtcService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BtcServiceClient interface {
+	ValidateAddress(ctx context.Context, in *ValidateAddressRequest, opts ...grpc.CallOption) (*ValidateAddressResponse, error)
+	AddressFromPublicKey(ctx context.Context, in *PublicKeyRequest, opts ...grpc.CallOption) (*AddressResponse, error)
+	BlockHeight(ctx context.Context, in *BlockHeightRequest, opts ...grpc.CallOption) (*BlockHeightResponse, error)
+	Block(ctx context.Context, in *BlockRequest, opts ...grpc.CallOption) (*BtcBlock, error)
+	ListBlocks(ctx context.Context, in *ListBlocksRequest, opts ...grpc.CallOption) (*BtcBlockList, error)
+	ListTransactions(ctx context.Context, in *ListTransactionsRequest, opts ...grpc.CallOption) (*BtcTransactionList, error)
+	Transaction(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*BtcTransaction, error)
+	CreateTransaction(ctx context.Context, in *BtcCreateTransactionRequest, opts ...grpc.CallOption) (*UnsignedTransaction, error)
+	SignTransaction(ctx context.Context, in *BtcSignTransactionRequest, opts ...grpc.CallOption) (*SignedTransaction, error)
+	Broadcast(ctx context.Context, in *BroadcastRequest, opts ...grpc.CallOption) (*BroadcastResponse, error)
+}
+
+type btcServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBtcServiceClient(cc grpc.ClientConnInterface) BtcServiceClient {
+	return &btcServiceClient{cc}
+}
+
+func (c *btcServiceClient) ValidateAddress(ctx context.Context, in *ValidateAddressRequest, opts ...grpc.CallOption) (*ValidateAddressResponse, error) {
+	out := new(ValidateAddressResponse)
+	err := c.cc.Invoke(ctx, BtcService_ValidateAddress_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *btcServiceClient) AddressFromPublicKey(ctx context.Context, in *PublicKeyRequest, opts ...grpc.CallOption) (*AddressResponse, error) {
+	out := new(AddressResponse)
+	err := c.cc.Invoke(ctx, BtcService_AddressFromPublicKey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *btcServiceClient) BlockHeight(ctx context.Context, in *BlockHeightRequest, opts ...grpc.CallOption) (*BlockHeightResponse, error) {
+	out := new(BlockHeightResponse)
+	err := c.cc.Invoke(ctx, BtcService_BlockHeight_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *btcServiceClient) Block(ctx context.Context, in *BlockRequest, opts ...grpc.CallOption) (*BtcBlock, error) {
+	out := new(BtcBlock)
+	err := c.cc.Invoke(ctx, BtcService_Block_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *btcServiceClient) ListBlocks(ctx context.Context, in *ListBlocksRequest, opts ...grpc.CallOption) (*BtcBlockList, error) {
+	out := new(BtcBlockList)
+	err := c.cc.Invoke(ctx, BtcService_ListBlocks_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *btcServiceClient) ListTransactions(ctx context.Context, in *ListTransactionsRequest, opts ...grpc.CallOption) (*BtcTransactionList, error) {
+	out := new(BtcTransactionList)
+	err := c.cc.Invoke(ctx, BtcService_ListTransactions_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *btcServiceClient) Transaction(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*BtcTransaction, error) {
+	out := new(BtcTransaction)
+	err := c.cc.Invoke(ctx, BtcService_Transaction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *btcServiceClient) CreateTransaction(ctx context.Context, in *BtcCreateTransactionRequest, opts ...grpc.CallOption) (*UnsignedTransaction, error) {
+	out := new(UnsignedTransaction)
+	err := c.cc.Invoke(ctx, BtcService_CreateTransaction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *btcServiceClient) SignTransaction(ctx context.Context, in *BtcSignTransactionRequest, opts ...grpc.CallOption) (*SignedTransaction, error) {
+	out := new(SignedTransaction)
+	err := c.cc.Invoke(ctx, BtcService_SignTransaction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *btcServiceClient) Broadcast(ctx context.Context, in *BroadcastRequest, opts ...grpc.CallOption) (*BroadcastResponse, error) {
+	out := new(BroadcastResponse)
+	err := c.cc.Invoke(ctx, BtcService_Broadcast_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BtcServiceServer is the server API for BtcService service.
+// All implementations must embed UnimplementedBtcServiceServer
+// for forward compatibility
+type BtcServiceServer interface {
+	ValidateAddress(context.Context, *ValidateAddressRequest) (*ValidateAddressResponse, error)
+	AddressFromPublicKey(context.Context, *PublicKeyRequest) (*AddressResponse, error)
+	BlockHeight(context.Context, *BlockHeightRequest) (*BlockHeightResponse, error)
+	Block(context.Context, *BlockRequest) (*BtcBlock, error)
+	ListBlocks(context.Context, *ListBlocksRequest) (*BtcBlockList, error)
+	ListTransactions(context.Context, *ListTransactionsRequest) (*BtcTransactionList, error)
+	Transaction(context.Context, *TransactionRequest) (*BtcTransaction, error)
+	CreateTransaction(context.Context, *BtcCreateTransactionRequest) (*UnsignedTransaction, error)
+	SignTransaction(context.Context, *BtcSignTransactionRequest) (*SignedTransaction, error)
+	Broadcast(context.Context, *BroadcastRequest) (*BroadcastResponse, error)
+	mustEmbedUnimplementedBtcServiceServer()
+}
+
+// UnimplementedBtcServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedBtcServiceServer struct {
+}
+
+func (UnimplementedBtcServiceServer) ValidateAddress(context.Context, *ValidateAddressRequest) (*ValidateAddressResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ValidateAddress not implemented")
+}
+func (UnimplementedBtcServiceServer) AddressFromPublicKey(context.Context, *PublicKeyRequest) (*AddressResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddressFromPublicKey not implemented")
+}
+func (UnimplementedBtcServiceServer) BlockHeight(context.Context, *BlockHeightRequest) (*BlockHeightResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BlockHeight not implemented")
+}
+func (UnimplementedBtcServiceServer) Block(context.Context, *BlockRequest) (*BtcBlock, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Block not implemented")
+}
+func (UnimplementedBtcServiceServer) ListBlocks(context.Context, *ListBlocksRequest) (*BtcBlockList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListBlocks not implemented")
+}
+func (UnimplementedBtcServiceServer) ListTransactions(context.Context, *ListTransactionsRequest) (*BtcTransactionList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTransactions not implemented")
+}
+func (UnimplementedBtcServiceServer) Transaction(context.Context, *TransactionRequest) (*BtcTransaction, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Transaction not implemented")
+}
+func (UnimplementedBtcServiceServer) CreateTransaction(context.Context, *BtcCreateTransactionRequest) (*UnsignedTransaction, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateTransaction not implemented")
+}
+func (UnimplementedBtcServiceServer) SignTransaction(context.Context, *BtcSignTransactionRequest) (*SignedTransaction, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SignTransaction not implemented")
+}
+func (UnimplementedBtcServiceServer) Broadcast(context.Context, *BroadcastRequest) (*BroadcastResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Broadcast not implemented")
+}
+func (UnimplementedBtcServiceServer) mustEmbedUnimplementedBtcServiceServer() {}
+
+// UnsafeBtcServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BtcServiceServer will
+// result in compilation errors.
+type UnsafeBtcServiceServer interface {
+	mustEmbedUnimplementedBtcServiceServer()
+}
+
+func RegisterBtcServiceServer(s grpc.ServiceRegistrar, srv BtcServiceServer) {
+	s.RegisterService(&BtcService_ServiceDesc, srv)
+}
+
+func _BtcService_ValidateAddress_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ValidateAddressRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BtcServiceServer).ValidateAddress(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BtcService_ValidateAddress_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BtcServiceServer).ValidateAddress(ctx, req.(*ValidateAddressRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BtcService_AddressFromPublicKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PublicKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BtcServiceServer).AddressFromPublicKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BtcService_AddressFromPublicKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BtcServiceServer).AddressFromPublicKey(ctx, req.(*PublicKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BtcService_BlockHeight_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BlockHeightRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BtcServiceServer).BlockHeight(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BtcService_BlockHeight_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BtcServiceServer).BlockHeight(ctx, req.(*BlockHeightRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BtcService_Block_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BlockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BtcServiceServer).Block(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BtcService_Block_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BtcServiceServer).Block(ctx, req.(*BlockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BtcService_ListBlocks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListBlocksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BtcServiceServer).ListBlocks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BtcService_ListBlocks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BtcServiceServer).ListBlocks(ctx, req.(*ListBlocksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BtcService_ListTransactions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTransactionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BtcServiceServer).ListTransactions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BtcService_ListTransactions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BtcServiceServer).ListTransactions(ctx, req.(*ListTransactionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BtcService_Transaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BtcServiceServer).Transaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BtcService_Transaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BtcServiceServer).Transaction(ctx, req.(*TransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BtcService_CreateTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BtcCreateTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BtcServiceServer).CreateTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BtcService_CreateTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BtcServiceServer).CreateTransaction(ctx, req.(*BtcCreateTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BtcService_SignTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BtcSignTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BtcServiceServer).SignTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BtcService_SignTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BtcServiceServer).SignTransaction(ctx, req.(*BtcSignTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BtcService_Broadcast_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BroadcastRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BtcServiceServer).Broadcast(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BtcService_Broadcast_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BtcServiceServer).Broadcast(ctx, req.(*BroadcastRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// BtcService_ServiceDesc is the grpc.ServiceDesc for BtcService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var BtcService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "wallet.BtcService",
+	HandlerType: (*BtcServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ValidateAddress",
+			Handler:    _BtcService_ValidateAddress_Handler,
+		},
+		{
+			MethodName: "AddressFromPublicKey",
+			Handler:    _BtcService_AddressFromPublicKey_Handler,
+		},
+		{
+			MethodName: "BlockHeight",
+			Handler:    _BtcService_BlockHeight_Handler,
+		},
+		{
+			MethodName: "Block",
+			Handler:    _BtcService_Block_Handler,
+		},
+		{
+			MethodName: "ListBlocks",
+			Handler:    _BtcService_ListBlocks_Handler,
+		},
+		{
+			MethodName: "ListTransactions",
+			Handler:    _BtcService_ListTransactions_Handler,
+		},
+		{
+			MethodName: "Transaction",
+			Handler:    _BtcService_Transaction_Handler,
+		},
+		{
+			MethodName: "CreateTransaction",
+			Handler:    _BtcService_CreateTransaction_Handler,
+		},
+		{
+			MethodName: "SignTransaction",
+			Handler:    _BtcService_SignTransaction_Handler,
+		},
+		{
+			MethodName: "Broadcast",
+			Handler:    _BtcService_Broadcast_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "wallet.proto",
+}
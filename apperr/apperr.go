@@ -0,0 +1,65 @@
+package apperr
+
+import (
+	"fmt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AppErr is an error of the wallet with a stable Code, the grpc Status it is sent with and the Details of the
+// failure. It does not depend on a chain, so that the eth and the btc services and the packages they share return
+// the same errors.
+type AppErr struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Status  codes.Code             `json:"status"`
+	Details map[string]interface{} `json:"details"`
+}
+
+func (e AppErr) Error() string {
+	return e.Message
+}
+
+// GRPCStatus lets grpc send e as a status with Status as the code, Unknown when it is not set, and an ErrorInfo
+// detail carrying Code and Details.
+func (e AppErr) GRPCStatus() *status.Status {
+	code := e.Status
+	if code == codes.OK {
+		code = codes.Unknown
+	}
+
+	metadata := make(map[string]string, len(e.Details))
+	for key, value := range e.Details {
+		metadata[key] = fmt.Sprint(value)
+	}
+
+	st := status.New(code, e.Message)
+	withDetails, err := st.WithDetails(&errdetails.ErrorInfo{Reason: e.Code, Domain: "wallet", Metadata: metadata})
+	if err != nil {
+		return st
+	}
+	return withDetails
+}
+
+// Is reports whether target is an AppErr with the code of e, so that a copy of an error variable carrying details
+// still matches the variable.
+func (e AppErr) Is(target error) bool {
+	switch t := target.(type) {
+	case *AppErr:
+		return t != nil && t.Code == e.Code
+	case AppErr:
+		return t.Code == e.Code
+	}
+	return false
+}
+
+// WithDetails returns a copy of e with details.
+func (e AppErr) WithDetails(details map[string]interface{}) *AppErr {
+	e.Details = details
+	return &e
+}
+
+func New(code, message string) AppErr {
+	return AppErr{Code: code, Message: message}
+}
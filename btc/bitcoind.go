@@ -3,40 +3,114 @@ package btc
 import (
 	"bytes"
 	"context"
+	"demo/amount"
+	"demo/failover"
+	"demo/logging"
+	"demo/metrics"
+	"demo/policy"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
-	"github.com/btcsuite/btcd/rpcclient"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 	"github.com/btcsuite/btcutil/base58"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/shopspring/decimal"
 	"math/big"
+	"sync/atomic"
 )
 
+const defaultConfirmations = 6
+
+var (
+	ErrInvalidAddress   = errors.New("invalid bitcoin address")
+	ErrAmountOutOfRange = errors.New("amount is negative or more than the bitcoin supply")
+)
+
+// networks are the chains a Service can be set to, by chaincfg name.
+var networks = map[string]*chaincfg.Params{
+	chaincfg.MainNetParams.Name:       &chaincfg.MainNetParams,
+	chaincfg.TestNet3Params.Name:      &chaincfg.TestNet3Params,
+	chaincfg.RegressionNetParams.Name: &chaincfg.RegressionNetParams,
+	chaincfg.SimNetParams.Name:        &chaincfg.SimNetParams,
+}
+
 type Service struct {
-	rpc *rpcclient.Client
+	rpc           Client
+	params        *chaincfg.Params
+	confirmations uint64
+	hook          metrics.Hook
+	logger        log.Logger
+	policy        *policy.Policy
 }
 
 func NewService(host, user, password string) (*Service, error) {
-	conn := &rpcclient.ConnConfig{
-		Host:         host,
-		User:         user,
-		Pass:         password,
-		HTTPPostMode: true,
-		DisableTLS:   true,
-	}
-	client, err := rpcclient.New(conn, nil)
+	client, err := Dial(Endpoint{Host: host, User: user, Password: password})
 	if err != nil {
 		return nil, err
 	}
 
-	return &Service{rpc: client}, nil
+	return NewClientService(client), nil
+}
+
+// NewClientService returns a service on client, such as a Pool of several nodes.
+func NewClientService(client Client) *Service {
+	return &Service{rpc: client, params: &chaincfg.TestNet3Params, confirmations: defaultConfirmations,
+		hook: metrics.Nop{}, logger: logging.Discard()}
+}
+
+// SetLogger makes the service log to logger, with chain=btc on every entry. The service logs nothing by default.
+// Call it before the service is in use.
+func (t *Service) SetLogger(logger log.Logger) {
+	t.logger = logging.With(logger, "chain", "btc")
+}
+
+// SetInstrumentation makes the service report its broadcasts and fee levels to hook, and the node calls of its
+// client too when the client is a Pool. Call it before the service is in use.
+func (t *Service) SetInstrumentation(hook metrics.Hook) {
+	if hook == nil {
+		hook = metrics.Nop{}
+	}
+	t.hook = hook
+	if pool, ok := t.rpc.(*Pool); ok {
+		pool.SetObserver(hook)
+	}
+}
+
+// NetworkParams returns the chaincfg parameters of a network name: mainnet, testnet3, regtest or simnet.
+func NetworkParams(name string) (*chaincfg.Params, error) {
+	params, ok := networks[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown bitcoin network %q", name)
+	}
+	return params, nil
+}
+
+// SetNetwork switches the addresses, keys and envelopes of the service to params, testnet3 by default.
+func (t *Service) SetNetwork(params *chaincfg.Params) {
+	t.params = params
+}
+
+func (t *Service) Client() Client {
+	return t.rpc
+}
+
+func (t *Service) Params() *chaincfg.Params {
+	return t.params
+}
+
+// SetConfirmations sets how many confirmations make a transaction successful, 6 by default. It is safe to call
+// while the service is in use.
+func (t *Service) SetConfirmations(confirmations uint64) {
+	atomic.StoreUint64(&t.confirmations, confirmations)
 }
 
 //检查比特币地址是否有效
@@ -56,13 +130,18 @@ func ValidateAddress(ctx context.Context, address string) bool {
 	return false
 }
 
+// ValidateAddress is the package level ValidateAddress, so that Service implements Server.
+func (t *Service) ValidateAddress(ctx context.Context, address string) bool {
+	return ValidateAddress(ctx, address)
+}
+
 func (t *Service) Block(ctx context.Context, index int64) (block *Block, err error) {
-	blockHash, err := t.rpc.GetBlockHash(index)
+	blockHash, err := t.rpc.GetBlockHash(ctx, index)
 	if err != nil {
 		return nil, err
 	}
 
-	msgBlock, err := t.rpc.GetBlock(blockHash)
+	msgBlock, err := t.rpc.GetBlock(ctx, blockHash)
 	if err != nil {
 		return nil, err
 	}
@@ -72,6 +151,11 @@ func (t *Service) Block(ctx context.Context, index int64) (block *Block, err err
 	for _, tx := range msgBlock.Transactions {
 		rawTransaction, _ := t.Transaction(ctx, tx.TxHash().String())
 		for _, outTx := range rawTransaction.Result.Vout {
+			value, err := btcutil.NewAmount(outTx.Value)
+			if err != nil {
+				return nil, err
+			}
+
 			transaction := TransactionInfo{
 				N:             outTx.N,
 				Address:       outTx.ScriptPubKey.Addresses,
@@ -80,7 +164,7 @@ func (t *Service) Block(ctx context.Context, index int64) (block *Block, err err
 				BlockTime:     rawTransaction.Result.Blocktime,
 				Confirmations: rawTransaction.Result.Confirmations,
 				Hex:           outTx.ScriptPubKey.Hex,
-				Value:         decimal.NewFromFloat(outTx.Value),
+				Value:         amount.FromBase(big.NewInt(int64(value)), amount.Bitcoin),
 				ReqSigs:       outTx.ScriptPubKey.ReqSigs,
 				Time:          rawTransaction.Result.Time,
 				State:         rawTransaction.State,
@@ -89,34 +173,139 @@ func (t *Service) Block(ctx context.Context, index int64) (block *Block, err err
 			block.Transactions = append(block.Transactions, &transaction)
 		}
 	}
+
+	t.logger.Debug("Read block", "block", index, "hash", blockHash, "transactions", len(msgBlock.Transactions))
 	return block, nil
 }
 
 func (t *Service) CurrentBlockHeight(ctx context.Context) (int64, error) {
-	currentBlockCount, err := t.rpc.GetBlockCount()
+	currentBlockCount, err := t.rpc.GetBlockCount(ctx)
 	return currentBlockCount, err
 }
 
+// Balance sums the unspent outputs of address with scantxoutset, which needs no wallet or address index on the node
+// but scans the whole UTXO set.
+func (t *Service) Balance(ctx context.Context, address string) (decimal.Decimal, error) {
+	if !ValidateAddress(ctx, address) {
+		return decimal.Zero, ErrInvalidAddress
+	}
+
+	params := []json.RawMessage{json.RawMessage(`"start"`), json.RawMessage(fmt.Sprintf(`["addr(%s)"]`, address))}
+	rawResult, err := t.rpc.RawRequest(ctx, "scantxoutset", params)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	var result struct {
+		Success     bool    `json:"success"`
+		TotalAmount float64 `json:"total_amount"`
+	}
+	if err = json.Unmarshal(rawResult, &result); err != nil {
+		return decimal.Zero, err
+	}
+
+	if !result.Success {
+		return decimal.Zero, fmt.Errorf("scantxoutset of %s did not complete", address)
+	}
+
+	balance, err := btcutil.NewAmount(result.TotalAmount)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return amount.FromBase(big.NewInt(int64(balance)), amount.Bitcoin), nil
+}
+
+// Transaction returns the transaction txId with the height of its block, once it is mined, and its fee, the value of
+// its inputs less that of its outputs. The fee looks the spent outputs up with getrawtransaction, which needs
+// -txindex once they are mined; a coinbase pays none.
 func (t *Service) Transaction(ctx context.Context, txId string) (transaction *RawTransactionInfo, err error) {
 	txHash, err := chainhash.NewHashFromStr(txId)
 	if err != nil {
 		return nil, err
 	}
 
-	rawResult, err := t.rpc.GetRawTransactionVerbose(txHash)
+	rawResult, err := t.rpc.GetRawTransactionVerbose(ctx, txHash)
 	if err != nil {
 		return nil, err
 	}
 
-	transaction = &RawTransactionInfo{State: TransactionSateDefault, Result: rawResult}
-	if rawResult.Confirmations > 5 {
+	transaction = &RawTransactionInfo{State: TransactionSateDefault, BlockHash: rawResult.BlockHash, Result: rawResult}
+	if rawResult.Confirmations >= atomic.LoadUint64(&t.confirmations) {
 		transaction.State = TransactionSateSuccess
-	} else if rawResult.Confirmations < 6 && rawResult.Confirmations > 0 {
+	} else if rawResult.Confirmations > 0 {
 		transaction.State = TransactionSatePending
 	}
+
+	if len(rawResult.BlockHash) > 0 {
+		if transaction.BlockHeight, err = t.blockHeight(ctx, rawResult.BlockHash); err != nil {
+			return nil, err
+		}
+	}
+
+	if transaction.Fee, err = t.fee(ctx, rawResult); err != nil {
+		return nil, err
+	}
 	return transaction, nil
 }
 
+// blockHeight returns the height of the block blockHash with getblockheader.
+func (t *Service) blockHeight(ctx context.Context, blockHash string) (int32, error) {
+	params := []json.RawMessage{json.RawMessage(fmt.Sprintf(`"%s"`, blockHash)), json.RawMessage(`true`)}
+	result, err := t.rpc.RawRequest(ctx, "getblockheader", params)
+	if err != nil {
+		return 0, err
+	}
+
+	var header btcjson.GetBlockHeaderVerboseResult
+	if err = json.Unmarshal(result, &header); err != nil {
+		return 0, err
+	}
+	return header.Height, nil
+}
+
+// fee returns the value of the inputs of tx less that of its outputs.
+func (t *Service) fee(ctx context.Context, tx *btcjson.TxRawResult) (decimal.Decimal, error) {
+	var fee btcutil.Amount
+	spent := map[string]*btcjson.TxRawResult{}
+	for _, in := range tx.Vin {
+		if in.IsCoinBase() {
+			return decimal.Zero, nil
+		}
+
+		prev, ok := spent[in.Txid]
+		if !ok {
+			prevHash, err := chainhash.NewHashFromStr(in.Txid)
+			if err != nil {
+				return decimal.Zero, err
+			}
+
+			if prev, err = t.rpc.GetRawTransactionVerbose(ctx, prevHash); err != nil {
+				return decimal.Zero, fmt.Errorf("input %s:%d: %w", in.Txid, in.Vout, err)
+			}
+			spent[in.Txid] = prev
+		}
+
+		if int(in.Vout) >= len(prev.Vout) {
+			return decimal.Zero, fmt.Errorf("input %s:%d: no such output", in.Txid, in.Vout)
+		}
+
+		value, err := btcutil.NewAmount(prev.Vout[in.Vout].Value)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		fee += value
+	}
+
+	for _, out := range tx.Vout {
+		value, err := btcutil.NewAmount(out.Value)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		fee -= value
+	}
+	return amount.FromBase(big.NewInt(int64(fee)), amount.Bitcoin), nil
+}
+
 func (t *Service) CreateAddressByPubKey(ctx context.Context, publicKey string) (string, error) {
 	decodePublicKey, err := hexutil.Decode(publicKey)
 	if err != nil {
@@ -128,25 +317,36 @@ func (t *Service) CreateAddressByPubKey(ctx context.Context, publicKey string) (
 		return "", err
 	}
 
-	mainAddress, err := btcutil.NewAddressPubKey(crypto.CompressPubkey(publicKey1), &chaincfg.TestNet3Params)
+	mainAddress, err := btcutil.NewAddressPubKey(crypto.CompressPubkey(publicKey1), t.params)
 	if err != nil {
 		return "", err
 	}
 	return mainAddress.EncodeAddress(), nil
 }
 
-func (t *Service) CreateTx(ctx context.Context, from, to string, amount, fee decimal.Decimal, utxOS TransactionOutPut) (*wire.MsgTx, []byte, error) {
-	baseCoin := decimal.NewFromFloat(100000000)
-	amountValue := amount.Mul(baseCoin).IntPart()
-	outputValue := utxOS.Value.Mul(baseCoin).IntPart()
-	feeValue := fee.Mul(baseCoin).IntPart()
+func (t *Service) CreateTx(ctx context.Context, from, to string, value, fee decimal.Decimal, utxOS TransactionOutPut) (*wire.MsgTx, []byte, error) {
+	amountValue, err := toSatoshi(value)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	outputValue, err := toSatoshi(utxOS.Value)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	feeValue, err := toSatoshi(fee)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	if outputValue+feeValue < amountValue {
 		return nil, nil, fmt.Errorf("the blance of the account is not sufficient,ouptValue[%v] + feeValue[%v] >amountValue[%v]", outputValue, feeValue, amountValue)
 	}
 
 	restValue := outputValue - amountValue - feeValue
 
-	destinationAddress, err := btcutil.DecodeAddress(to, &chaincfg.TestNet3Params)
+	destinationAddress, err := btcutil.DecodeAddress(to, t.params)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -156,7 +356,7 @@ func (t *Service) CreateTx(ctx context.Context, from, to string, amount, fee dec
 		return nil, nil, err
 	}
 
-	fromAddress, err := btcutil.DecodeAddress(from, &chaincfg.TestNet3Params)
+	fromAddress, err := btcutil.DecodeAddress(from, t.params)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -178,8 +378,17 @@ func (t *Service) CreateTx(ctx context.Context, from, to string, amount, fee dec
 	redeemTx.AddTxOut(wire.NewTxOut(amountValue, destinationAddressByte))
 	redeemTx.AddTxOut(wire.NewTxOut(restValue, fromAddressByte))
 	redeemTx, calcHash, err := SigTx(utxOS.Hex, redeemTx)
+	if err != nil {
+		return redeemTx, calcHash, err
+	}
+
+	if err = t.checkPolicy(ctx, redeemTx, fromAddress.EncodeAddress()); err != nil {
+		return nil, nil, err
+	}
 
-	return redeemTx, calcHash, err
+	t.logger.Debug("Created transaction", "from", from, "to", to, "amount", amountValue, "fee", feeValue,
+		"utxo", outPoint)
+	return redeemTx, calcHash, nil
 }
 
 func (t *Service) SigTx(ctx context.Context, publicKey string, r, s *big.Int, redeemTx *wire.MsgTx) (*wire.MsgTx, error) {
@@ -193,6 +402,18 @@ func (t *Service) SigTx(ctx context.Context, publicKey string, r, s *big.Int, re
 		return redeemTx, err
 	}
 
+	if t.policy != nil {
+		own, err := keyAddresses((*btcec.PublicKey)(publicKey1), t.params)
+		if err != nil {
+			return redeemTx, err
+		}
+
+		// the signature script carries the uncompressed key, so it spends from the address of that key
+		if err = t.checkPolicy(ctx, redeemTx, own[1], own...); err != nil {
+			return redeemTx, err
+		}
+	}
+
 	sig := btcec.Signature{R: r, S: s}
 	sigHash := append(sig.Serialize(), byte(txscript.SigHashAll))
 	signature, err := SignatureScript(sigHash, (*btcec.PublicKey)(publicKey1), false)
@@ -201,20 +422,33 @@ func (t *Service) SigTx(ctx context.Context, publicKey string, r, s *big.Int, re
 	}
 
 	redeemTx.TxIn[0].SignatureScript = signature
+	t.logger.Debug("Signed transaction", "tx", redeemTx.TxHash())
 	return redeemTx, nil
 }
 
 func (t *Service) BroadcastTx(ctx context.Context, tx *wire.MsgTx) (string, error) {
-	txHash, err := t.rpc.SendRawTransaction(tx, false)
+	txHash, err := t.rpc.SendRawTransaction(ctx, tx, false)
 	if err != nil {
+		if classify(err) == failover.Answer {
+			t.hook.Broadcast(metrics.Rejected)
+			t.logger.Warn("Transaction rejected", "tx", tx.TxHash(), "err", err)
+		} else {
+			t.hook.Broadcast(metrics.Failed)
+			t.logger.Error("Broadcast failed", "tx", tx.TxHash(), "err", err)
+		}
 		return "", err
 	}
+	t.hook.Broadcast(metrics.Accepted)
+	t.logger.Info("Broadcast transaction", "tx", txHash)
 
 	return txHash.String(), nil
 }
 
 func (t *Service) EstimateFee(ctx context.Context, numBlocks int64) (string, error) {
-	estimateFee, err := t.rpc.EstimateFee(numBlocks)
+	estimateFee, err := t.rpc.EstimateFee(ctx, numBlocks)
+	if err == nil && estimateFee > 0 {
+		t.hook.Fee("fee_rate", estimateFee*btcutil.SatoshiPerBitcoin) //estimatefee is in BTC per kvB
+	}
 	return fmt.Sprintf("%f", estimateFee), err
 }
 
@@ -241,3 +475,15 @@ func SignatureScript(sig []byte, pk *btcec.PublicKey, compress bool) ([]byte, er
 	}
 	return txscript.NewScriptBuilder().AddData(sig).AddData(pkData).Script()
 }
+
+func toSatoshi(value decimal.Decimal) (int64, error) {
+	satoshi, err := amount.ToBase(value, amount.Bitcoin, amount.RoundExact)
+	if err != nil {
+		return 0, fmt.Errorf("invalid btc amount %s: %w", value, err)
+	}
+
+	if satoshi.Sign() < 0 || satoshi.Cmp(big.NewInt(btcutil.MaxSatoshi)) > 0 {
+		return 0, fmt.Errorf("invalid btc amount %s: %w", value, ErrAmountOutOfRange)
+	}
+	return satoshi.Int64(), nil
+}
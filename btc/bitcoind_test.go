@@ -2,9 +2,10 @@ package btc
 
 import (
 	"context"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
-	"log"
 	"testing"
 )
 
@@ -17,11 +18,24 @@ func Test_ValidateBitcoinAddress(t *testing.T) {
 
 }
 
+func Test_ToSatoshi(t *testing.T) {
+	satoshi, err := toSatoshi(decimal.RequireFromString("21000000"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2100000000000000), satoshi)
+
+	_, err = toSatoshi(decimal.RequireFromString("92233720368.54775808"))
+	assert.ErrorIs(t, err, ErrAmountOutOfRange, "more than an int64 of satoshi")
+	_, err = toSatoshi(decimal.RequireFromString("21000000.00000001"))
+	assert.ErrorIs(t, err, ErrAmountOutOfRange)
+	_, err = toSatoshi(decimal.RequireFromString("-0.1"))
+	assert.ErrorIs(t, err, ErrAmountOutOfRange)
+}
+
 func Test_CurrentBlockHeight(t *testing.T) {
 	ctx := context.Background()
 	svc := getService()
 	blocks, _ := svc.CurrentBlockHeight(ctx)
-	log.Println(blocks)
+	t.Log(blocks)
 	assert.True(t, blocks > 1)
 }
 
@@ -41,11 +55,63 @@ func Test_GetRawTransaction(t *testing.T) {
 	assert.Equal(t, transaction.State, TransactionSateSuccess)
 }
 
+func Test_TransactionBlockHeightAndFee(t *testing.T) {
+	ctx := context.Background()
+	node := newFakeNode(t, 700010)
+	funding := "1111111111111111111111111111111111111111111111111111111111111111"
+	spend := "2222222222222222222222222222222222222222222222222222222222222222"
+	pending := "3333333333333333333333333333333333333333333333333333333333333333"
+	block := "00000000000000000001aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	node.addTransaction(btcjson.TxRawResult{
+		Txid: funding,
+		Vin:  []btcjson.Vin{{Coinbase: "03a0ae0a"}},
+		Vout: []btcjson.Vout{{Value: 0.5, N: 0}, {Value: 0.25, N: 1}},
+	})
+	node.addTransaction(btcjson.TxRawResult{
+		Txid:          spend,
+		BlockHash:     block,
+		Confirmations: 10,
+		Vin:           []btcjson.Vin{{Txid: funding, Vout: 0}, {Txid: funding, Vout: 1}},
+		Vout:          []btcjson.Vout{{Value: 0.6, N: 0}, {Value: 0.1499, N: 1}},
+	})
+	node.addTransaction(btcjson.TxRawResult{
+		Txid: pending,
+		Vin:  []btcjson.Vin{{Txid: spend, Vout: 1}},
+		Vout: []btcjson.Vout{{Value: 0.1498, N: 0}},
+	})
+	node.addBlock(block, 700001)
+	client, err := Dial(node.endpoint())
+	assert.NoError(t, err)
+	svc := NewClientService(client)
+
+	transaction, err := svc.Transaction(ctx, spend)
+	assert.NoError(t, err)
+	assert.Equal(t, TransactionSateSuccess, transaction.State)
+	assert.Equal(t, block, transaction.BlockHash)
+	assert.Equal(t, int32(700001), transaction.BlockHeight)
+	assert.Equal(t, "0.0001", transaction.Fee.String())
+
+	transaction, err = svc.Transaction(ctx, pending)
+	assert.NoError(t, err)
+	assert.Equal(t, TransactionSateDefault, transaction.State)
+	assert.Equal(t, "", transaction.BlockHash)
+	assert.Equal(t, int32(0), transaction.BlockHeight)
+	assert.Equal(t, "0.0001", transaction.Fee.String())
+
+	transaction, err = svc.Transaction(ctx, funding)
+	assert.NoError(t, err)
+	assert.True(t, transaction.Fee.IsZero())
+
+	node.addTransaction(btcjson.TxRawResult{Txid: pending, Vin: []btcjson.Vin{{Txid: spend, Vout: 2}}})
+	_, err = svc.Transaction(ctx, pending)
+	assert.Error(t, err)
+}
+
 func Test_GetBlock(t *testing.T) {
 	ctx := context.Background()
 	svc := getService()
 	cblock, _ := svc.Block(ctx, 2103994)
-	log.Println(len(cblock.Transactions))
+	t.Log(len(cblock.Transactions))
 	assert.True(t, len(cblock.Transactions) >= 1)
 
 	amount := "0.001"
@@ -53,27 +119,27 @@ func Test_GetBlock(t *testing.T) {
 
 	amountVaule := result.Mul(decimal.NewFromFloat(100000000)).IntPart()
 
-	log.Println("amountVaule ", amountVaule)
+	t.Log("amountVaule ", amountVaule)
 
 	//var balance float64=10000
 	outPut := decimal.NewFromInt(amountVaule)
 
 	outPutValue := outPut.Div(decimal.NewFromFloat(100000000))
 
-	log.Println("outPutValue ", outPutValue)
+	t.Log("outPutValue ", outPutValue)
 	//
 	//
 	for _, tx := range cblock.Transactions {
-		//log.Println(tx.State)
-		//log.Println(tx.Asm)
-		//log.Println(tx.Hex)
-		//log.Println(tx.TxID)
-		//log.Println(tx.BlockHash)
-		//log.Println(tx.Blocktime)
-		//log.Println(tx.Confirmations)
-		//log.Println(tx.N)
-		log.Println(tx.Address)
-		log.Println("value ", tx.Value)
+		//t.Log(tx.State)
+		//t.Log(tx.Asm)
+		//t.Log(tx.Hex)
+		//t.Log(tx.TxID)
+		//t.Log(tx.BlockHash)
+		//t.Log(tx.Blocktime)
+		//t.Log(tx.Confirmations)
+		//t.Log(tx.N)
+		t.Log(tx.Address)
+		t.Log("value ", tx.Value)
 	}
 }
 
@@ -82,10 +148,35 @@ func Test_EstimateFee(t *testing.T) {
 	svc := getService()
 	fee, _ := svc.EstimateFee(ctx, 210301)
 
-	log.Println(fee)
+	t.Log(fee)
 }
 
 func getService() *Service {
 	svc, _ := NewService("45.195.61.126:18332", "testbtc", "c2ckY1CvyU1WR97uWsoC")
 	return svc
 }
+
+func Test_Balance(t *testing.T) {
+	ctx := context.Background()
+	svc := getService()
+	_, err := svc.Balance(ctx, "mmrb4vg9bN79TRwFCZNTccwuNhhiKHVR6r1")
+	assert.ErrorIs(t, err, ErrInvalidAddress)
+	balance, _ := svc.Balance(ctx, "mmrb4vg9bN79TRwFCZNTccwuNhhiKHVR6r")
+	t.Log(balance)
+}
+
+func Test_SetNetwork(t *testing.T) {
+	ctx := context.Background()
+	params, err := NetworkParams("mainnet")
+	assert.NoError(t, err)
+	assert.Equal(t, &chaincfg.MainNetParams, params)
+	_, err = NetworkParams("testnet")
+	assert.Error(t, err)
+
+	svc := getService()
+	assert.Equal(t, &chaincfg.TestNet3Params, svc.Params())
+	svc.SetNetwork(params)
+	address, err := svc.CreateAddressByPubKey(ctx, "0x037359ebcc9ec202fa5ee736388ff8ed2aea29b1d3a1b049588e593696f3fc6355")
+	assert.NoError(t, err)
+	assert.Equal(t, "17LdmsbAnLftgKTdUzQ5nhjaWi71PeB2m4", address)
+}
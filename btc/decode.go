@@ -0,0 +1,111 @@
+package btc
+
+import (
+	"bytes"
+	"context"
+	"demo/amount"
+	"encoding/hex"
+	"fmt"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"math/big"
+)
+
+// DecodeRawTx parses a hex encoded serialized transaction, with or without witness data. It does not use the node,
+// so a transaction can be inspected before it is broadcast.
+func (t *Service) DecodeRawTx(ctx context.Context, rawTx string) (*DecodedTx, error) {
+	data, err := hex.DecodeString(rawTx)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	r := bytes.NewReader(data)
+	if err = tx.Deserialize(r); err != nil {
+		return nil, err
+	}
+
+	if r.Len() > 0 {
+		return nil, fmt.Errorf("%d bytes after the transaction", r.Len())
+	}
+
+	weight := tx.SerializeSizeStripped()*3 + tx.SerializeSize()
+	decoded := DecodedTx{
+		TxId:     tx.TxHash().String(),
+		WTxId:    tx.WitnessHash().String(),
+		Version:  tx.Version,
+		LockTime: tx.LockTime,
+		Size:     tx.SerializeSize(),
+		VSize:    (weight + 3) / 4,
+		Weight:   weight,
+	}
+
+	for _, txIn := range tx.TxIn {
+		input := DecodedTxIn{
+			PrevTxId:  txIn.PreviousOutPoint.Hash.String(),
+			PrevN:     txIn.PreviousOutPoint.Index,
+			Sequence:  txIn.Sequence,
+			ScriptSig: hex.EncodeToString(txIn.SignatureScript),
+			Address:   inputAddress(txIn, t.params),
+		}
+		input.ScriptSigAsm, _ = txscript.DisasmString(txIn.SignatureScript)
+		for _, item := range txIn.Witness {
+			input.Witness = append(input.Witness, hex.EncodeToString(item))
+		}
+		decoded.Inputs = append(decoded.Inputs, &input)
+	}
+
+	for i, txOut := range tx.TxOut {
+		output := DecodedTxOut{
+			N:      uint32(i),
+			Value:  amount.FromBase(big.NewInt(txOut.Value), amount.Bitcoin),
+			Script: hex.EncodeToString(txOut.PkScript),
+		}
+		output.ScriptAsm, _ = txscript.DisasmString(txOut.PkScript)
+
+		class, addresses, reqSigs, err := txscript.ExtractPkScriptAddrs(txOut.PkScript, t.params)
+		if err == nil {
+			output.Type, output.ReqSigs = class.String(), reqSigs
+			for _, address := range addresses {
+				output.Addresses = append(output.Addresses, address.EncodeAddress())
+			}
+		} else {
+			output.Type = txscript.NonStandardTy.String()
+		}
+		decoded.Outputs = append(decoded.Outputs, &output)
+	}
+	return &decoded, nil
+}
+
+// inputAddress returns the address a P2PKH, P2WPKH or P2SH-P2WPKH input spends from, or "" for other inputs.
+func inputAddress(txIn *wire.TxIn, params *chaincfg.Params) string {
+	pushes, err := txscript.PushedData(txIn.SignatureScript)
+	if err != nil {
+		return ""
+	}
+
+	var address btcutil.Address
+	switch {
+	case len(txIn.Witness) == 0 && len(pushes) == 2 && isPublicKey(pushes[1]):
+		address, err = btcutil.NewAddressPubKeyHash(btcutil.Hash160(pushes[1]), params)
+	case len(txIn.Witness) == 2 && isPublicKey(txIn.Witness[1]) && len(txIn.SignatureScript) == 0:
+		address, err = btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(txIn.Witness[1]), params)
+	case len(txIn.Witness) == 2 && isPublicKey(txIn.Witness[1]) && len(pushes) == 1 && txscript.IsPayToWitnessPubKeyHash(pushes[0]):
+		address, err = btcutil.NewAddressScriptHash(pushes[0], params)
+	default:
+		return ""
+	}
+
+	if err != nil {
+		return ""
+	}
+	return address.EncodeAddress()
+}
+
+func isPublicKey(data []byte) bool {
+	_, err := btcec.ParsePubKey(data, btcec.S256())
+	return err == nil
+}
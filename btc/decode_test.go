@@ -0,0 +1,85 @@
+package btc
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_DecodeRawTx(t *testing.T) {
+	ctx := context.Background()
+	svc := getService()
+	wif := getWIF(t, true)
+	from, utxo := getUTXO(t, wif)
+	to := "mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn"
+
+	envelope, err := svc.ExportTx(ctx, from, to, decimal.RequireFromString("0.004"), decimal.RequireFromString("0.0001"), utxo)
+	assert.NoError(t, err)
+	assert.NoError(t, SignEnvelope(envelope, wif))
+
+	decoded, err := svc.DecodeRawTx(ctx, envelope.SignedTx)
+	assert.NoError(t, err)
+	assert.Equal(t, decoded.TxId, decoded.WTxId)
+	assert.Equal(t, len(envelope.SignedTx)/2, decoded.Size)
+	assert.Equal(t, decoded.Size, decoded.VSize)
+	assert.Equal(t, decoded.Size*4, decoded.Weight)
+	assert.Len(t, decoded.Inputs, 1)
+	assert.Equal(t, utxo.TXId, decoded.Inputs[0].PrevTxId)
+	assert.Equal(t, utxo.N, decoded.Inputs[0].PrevN)
+	assert.Equal(t, from, decoded.Inputs[0].Address)
+	assert.Empty(t, decoded.Inputs[0].Witness)
+	assert.Len(t, decoded.Outputs, 2)
+	assert.Equal(t, "0.004", decoded.Outputs[0].Value.String())
+	assert.Equal(t, []string{to}, decoded.Outputs[0].Addresses)
+	assert.Equal(t, "pubkeyhash", decoded.Outputs[0].Type)
+	assert.Equal(t, 1, decoded.Outputs[0].ReqSigs)
+	assert.Contains(t, decoded.Outputs[0].ScriptAsm, "OP_DUP OP_HASH160")
+	assert.Equal(t, []string{from}, decoded.Outputs[1].Addresses)
+
+	_, err = svc.DecodeRawTx(ctx, envelope.SignedTx+"00")
+	assert.Error(t, err)
+	_, err = svc.DecodeRawTx(ctx, envelope.SignedTx[:20])
+	assert.Error(t, err)
+}
+
+func Test_DecodeRawTxWitness(t *testing.T) {
+	ctx := context.Background()
+	svc := getService()
+	decodeWIF, _ := btcutil.DecodeWIF(getWIF(t, true))
+	pubKeyHash := btcutil.Hash160(decodeWIF.SerializePubKey())
+	address, _ := btcutil.NewAddressWitnessPubKeyHash(pubKeyHash, &chaincfg.TestNet3Params)
+	legacyAddress, _ := btcutil.NewAddressPubKeyHash(pubKeyHash, &chaincfg.TestNet3Params)
+	subscript, _ := txscript.PayToAddrScript(legacyAddress)
+	opReturn, _ := txscript.NullDataScript([]byte("memo"))
+
+	tx := NewTx()
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{7}, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(90000, subscript))
+	tx.AddTxOut(wire.NewTxOut(0, opReturn))
+	witness, err := txscript.WitnessSignature(tx, txscript.NewTxSigHashes(tx), 0, 100000, subscript, txscript.SigHashAll,
+		decodeWIF.PrivKey, true)
+	assert.NoError(t, err)
+	tx.TxIn[0].Witness = witness
+
+	var buf bytes.Buffer
+	assert.NoError(t, tx.Serialize(&buf))
+	decoded, err := svc.DecodeRawTx(ctx, hex.EncodeToString(buf.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, tx.TxHash().String(), decoded.TxId)
+	assert.NotEqual(t, decoded.TxId, decoded.WTxId)
+	assert.Equal(t, buf.Len(), decoded.Size)
+	assert.Equal(t, tx.SerializeSizeStripped()*3+buf.Len(), decoded.Weight)
+	assert.Less(t, decoded.VSize, decoded.Size)
+	assert.Equal(t, address.EncodeAddress(), decoded.Inputs[0].Address)
+	assert.Len(t, decoded.Inputs[0].Witness, 2)
+	assert.Equal(t, "nulldata", decoded.Outputs[1].Type)
+	assert.Empty(t, decoded.Outputs[1].Addresses)
+}
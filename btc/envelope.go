@@ -0,0 +1,327 @@
+package btc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/shopspring/decimal"
+	"strings"
+)
+
+const envelopeVersion = 1
+
+var (
+	ErrEnvelopeTampered   = errors.New("the signing envelope does not match the request")
+	ErrPreviousTxMismatch = errors.New("the previous transaction does not hold the spent output")
+)
+
+// ExportTx creates the same transaction as CreateTx and wraps it in an envelope that can be signed offline with
+// SignEnvelope.
+func (t *Service) ExportTx(ctx context.Context, from, to string, value, fee decimal.Decimal, utxOS TransactionOutPut) (*SigningEnvelope, error) {
+	tx, _, err := t.CreateTx(ctx, from, to, value, fee, utxOS)
+	if err != nil {
+		return nil, err
+	}
+
+	prevOut, err := spentOutput(utxOS)
+	if err != nil {
+		return nil, err
+	}
+
+	prevTx, err := t.previousTx(ctx, utxOS, prevOut)
+	if err != nil {
+		return nil, err
+	}
+
+	psbt, err := encodePSBT(tx, []*wire.TxOut{prevOut}, []*wire.MsgTx{prevTx})
+	if err != nil {
+		return nil, err
+	}
+
+	change := btcutil.Amount(tx.TxOut[1].Value)
+	envelope := SigningEnvelope{
+		Version: envelopeVersion,
+		Network: t.params.Name,
+		From:    from,
+		To:      to,
+		Amount:  value.String(),
+		Fee:     fee.String(),
+		Summary: fmt.Sprintf("send %s BTC from %s to %s, fee %s BTC, change %s, spending %s:%d, network %s",
+			value, from, to, fee, change, utxOS.TXId, utxOS.N, t.params.Name),
+		PSBT: base64.StdEncoding.EncodeToString(psbt),
+	}
+	envelope.Checksum, err = envelopeChecksum(&envelope)
+	if err != nil {
+		return nil, err
+	}
+	return &envelope, nil
+}
+
+// SignEnvelope signs every input of the envelope's transaction with a WIF private key and stores the signed
+// transaction in SignedTx. It needs no node, so it can run on an air-gapped host.
+func SignEnvelope(envelope *SigningEnvelope, wif string) error {
+	tx, prevOuts, err := unsignedEnvelopeTx(envelope)
+	if err != nil {
+		return err
+	}
+
+	params, err := NetworkParams(envelope.Network)
+	if err != nil {
+		return err
+	}
+
+	envelope.SignedTx, err = signTx(tx, prevOuts, wif, params)
+	return err
+}
+
+// SignPSBT signs a base64 PSBT made by ExportTx with a WIF private key of the network params and returns the hex
+// encoded signed transaction. It evaluates no policy, as it runs offline; use the SignPSBT of the Service online.
+func SignPSBT(psbt, wif string, params *chaincfg.Params) (string, error) {
+	tx, prevOuts, err := parsePSBT(psbt)
+	if err != nil {
+		return "", err
+	}
+	return signTx(tx, prevOuts, wif, params)
+}
+
+// SignPSBT signs a base64 PSBT made by ExportTx with a WIF private key of the network of the service, once the
+// policy of the service allows the transaction, and returns the hex encoded signed transaction.
+func (t *Service) SignPSBT(ctx context.Context, psbt, wif string) (string, error) {
+	tx, prevOuts, err := parsePSBT(psbt)
+	if err != nil {
+		return "", err
+	}
+
+	if len(prevOuts) == 0 {
+		return "", fmt.Errorf("%w: no inputs", errInvalidPSBT)
+	}
+
+	own := []string{}
+	for _, prevOut := range prevOuts {
+		own = append(own, outputAddress(prevOut.PkScript, t.params))
+	}
+
+	if err = t.checkPolicy(ctx, tx, own[0], own...); err != nil {
+		return "", err
+	}
+	return signTx(tx, prevOuts, wif, t.params)
+}
+
+func parsePSBT(psbt string) (*wire.MsgTx, []*wire.TxOut, error) {
+	data, err := base64.StdEncoding.DecodeString(psbt)
+	if err != nil {
+		return nil, nil, err
+	}
+	return decodePSBT(data)
+}
+
+func signTx(tx *wire.MsgTx, prevOuts []*wire.TxOut, wif string, params *chaincfg.Params) (string, error) {
+	privateKey, err := btcutil.DecodeWIF(wif)
+	if err != nil {
+		return "", err
+	}
+
+	if !privateKey.IsForNet(params) {
+		return "", fmt.Errorf("the key is not for %s", params.Name)
+	}
+
+	for i, prevOut := range prevOuts {
+		tx.TxIn[i].SignatureScript, err = txscript.SignatureScript(tx, i, prevOut.PkScript, txscript.SigHashAll,
+			privateKey.PrivKey, privateKey.CompressPubKey)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if err = verifySignatures(tx, prevOuts); err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err = tx.Serialize(&buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf.Bytes()), nil
+}
+
+// VerifyEnvelope checks a signed envelope against the arguments it was exported with and returns the transaction to
+// broadcast. It fails with ErrEnvelopeTampered when the envelope or the transaction was changed after the export.
+func (t *Service) VerifyEnvelope(ctx context.Context, envelope *SigningEnvelope, from, to string, value, fee decimal.Decimal,
+	utxOS TransactionOutPut) (*wire.MsgTx, error) {
+	tx, prevOuts, err := unsignedEnvelopeTx(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	if envelope.Network != t.params.Name {
+		return nil, ErrEnvelopeTampered
+	}
+
+	expected, _, err := t.CreateTx(ctx, from, to, value, fee, utxOS)
+	if err != nil {
+		return nil, err
+	}
+
+	prevOut, err := spentOutput(utxOS)
+	if err != nil {
+		return nil, err
+	}
+
+	if !sameTx(tx, expected) || len(prevOuts) != 1 || prevOuts[0].Value != prevOut.Value || !bytes.Equal(prevOuts[0].PkScript, prevOut.PkScript) {
+		return nil, ErrEnvelopeTampered
+	}
+
+	signedData, err := hex.DecodeString(envelope.SignedTx)
+	if err != nil {
+		return nil, ErrEnvelopeTampered
+	}
+
+	signedTx := wire.NewMsgTx(wire.TxVersion)
+	if err = signedTx.Deserialize(bytes.NewReader(signedData)); err != nil {
+		return nil, ErrEnvelopeTampered
+	}
+
+	if !sameTx(signedTx, expected) {
+		return nil, ErrEnvelopeTampered
+	}
+
+	if err = verifySignatures(signedTx, prevOuts); err != nil {
+		return nil, err
+	}
+	return signedTx, nil
+}
+
+// unsignedEnvelopeTx checks the checksum of envelope and decodes its PSBT.
+func unsignedEnvelopeTx(envelope *SigningEnvelope) (*wire.MsgTx, []*wire.TxOut, error) {
+	if _, err := NetworkParams(envelope.Network); envelope.Version != envelopeVersion || err != nil {
+		return nil, nil, fmt.Errorf("unsupported envelope version %d on %s", envelope.Version, envelope.Network)
+	}
+
+	checksum, err := envelopeChecksum(envelope)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !strings.EqualFold(checksum, envelope.Checksum) {
+		return nil, nil, ErrEnvelopeTampered
+	}
+
+	psbt, err := base64.StdEncoding.DecodeString(envelope.PSBT)
+	if err != nil {
+		return nil, nil, ErrEnvelopeTampered
+	}
+
+	tx, prevOuts, err := decodePSBT(psbt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrEnvelopeTampered, err)
+	}
+	return tx, prevOuts, nil
+}
+
+// sameTx compares two transactions without their signature scripts.
+func sameTx(tx, other *wire.MsgTx) bool {
+	var txBytes, otherBytes bytes.Buffer
+	tx, other = tx.Copy(), other.Copy()
+	for _, txIn := range tx.TxIn {
+		txIn.SignatureScript, txIn.Witness = nil, nil
+	}
+
+	for _, txIn := range other.TxIn {
+		txIn.SignatureScript, txIn.Witness = nil, nil
+	}
+
+	if tx.SerializeNoWitness(&txBytes) != nil || other.SerializeNoWitness(&otherBytes) != nil {
+		return false
+	}
+	return bytes.Equal(txBytes.Bytes(), otherBytes.Bytes())
+}
+
+// verifySignatures runs the script of every input of tx against the output it spends.
+func verifySignatures(tx *wire.MsgTx, prevOuts []*wire.TxOut) error {
+	for i, prevOut := range prevOuts {
+		engine, err := txscript.NewEngine(prevOut.PkScript, tx, i, txscript.StandardVerifyFlags, nil, nil, prevOut.Value)
+		if err != nil {
+			return err
+		}
+
+		if err = engine.Execute(); err != nil {
+			return fmt.Errorf("input %d is not signed by its owner: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func spentOutput(utxOS TransactionOutPut) (*wire.TxOut, error) {
+	pkScript, err := hex.DecodeString(utxOS.Hex)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := toSatoshi(utxOS.Value)
+	if err != nil {
+		return nil, err
+	}
+	return wire.NewTxOut(value, pkScript), nil
+}
+
+// previousTx returns the transaction of utxOS, which the PSBT of a legacy input carries whole, or nil for a segwit
+// input. It is decoded from PrevTx, or fetched from the node, and must hold prevOut.
+func (t *Service) previousTx(ctx context.Context, utxOS TransactionOutPut, prevOut *wire.TxOut) (*wire.MsgTx, error) {
+	if txscript.IsWitnessProgram(prevOut.PkScript) {
+		return nil, nil
+	}
+
+	rawTx := utxOS.PrevTx
+	if len(rawTx) == 0 {
+		txHash, err := chainhash.NewHashFromStr(utxOS.TXId)
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := t.rpc.GetRawTransactionVerbose(ctx, txHash)
+		if err != nil {
+			return nil, err
+		}
+		rawTx = result.Hex
+	}
+
+	data, err := hex.DecodeString(rawTx)
+	if err != nil {
+		return nil, err
+	}
+
+	prevTx := wire.NewMsgTx(wire.TxVersion)
+	if err = prevTx.Deserialize(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+
+	if !strings.EqualFold(prevTx.TxHash().String(), utxOS.TXId) || int(utxOS.N) >= len(prevTx.TxOut) ||
+		prevTx.TxOut[utxOS.N].Value != prevOut.Value || !bytes.Equal(prevTx.TxOut[utxOS.N].PkScript, prevOut.PkScript) {
+		return nil, ErrPreviousTxMismatch
+	}
+	return prevTx, nil
+}
+
+// envelopeChecksum is the SHA-256 of envelope without its signed transaction. Anyone can recompute it, so
+// VerifyEnvelope also rebuilds the transaction from the original arguments.
+func envelopeChecksum(envelope *SigningEnvelope) (string, error) {
+	unsigned := *envelope
+	unsigned.SignedTx, unsigned.Checksum = "", ""
+	data, err := json.Marshal(unsigned)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
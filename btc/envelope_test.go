@@ -0,0 +1,131 @@
+package btc
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_SigningEnvelope(t *testing.T) {
+	ctx := context.Background()
+	svc := getService()
+	wif := getWIF(t, true)
+	from, utxo := getUTXO(t, wif)
+	to := "mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn"
+	value, fee := decimal.RequireFromString("0.004"), decimal.RequireFromString("0.0001")
+
+	envelope, err := svc.ExportTx(ctx, from, to, value, fee, utxo)
+	assert.NoError(t, err)
+	assert.Equal(t, "testnet3", envelope.Network)
+	assert.Contains(t, envelope.Summary, "send 0.004 BTC from "+from+" to "+to+", fee 0.0001 BTC, change 0.0059 BTC")
+
+	// the envelope travels as JSON to the offline host and back
+	data, err := json.Marshal(envelope)
+	assert.NoError(t, err)
+	offline := SigningEnvelope{}
+	assert.NoError(t, json.Unmarshal(data, &offline))
+	assert.Error(t, SignEnvelope(&offline, getWIF(t, false)))
+	assert.NoError(t, SignEnvelope(&offline, wif))
+
+	tx, err := svc.VerifyEnvelope(ctx, &offline, from, to, value, fee, utxo)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tx.TxIn[0].SignatureScript)
+	assert.Equal(t, int64(400000), tx.TxOut[0].Value)
+	assert.Equal(t, int64(590000), tx.TxOut[1].Value)
+
+	// the arguments on the online host are the reference
+	_, err = svc.VerifyEnvelope(ctx, &offline, from, to, decimal.RequireFromString("0.005"), fee, utxo)
+	assert.ErrorIs(t, err, ErrEnvelopeTampered)
+
+	edited := offline
+	edited.Summary = "send 0.0001 BTC"
+	_, err = svc.VerifyEnvelope(ctx, &edited, from, to, value, fee, utxo)
+	assert.ErrorIs(t, err, ErrEnvelopeTampered)
+	assert.ErrorIs(t, SignEnvelope(&edited, wif), ErrEnvelopeTampered)
+
+	// a consistent envelope for another recipient still does not match
+	forged, err := svc.ExportTx(ctx, from, from, value, fee, utxo)
+	assert.NoError(t, err)
+	assert.NoError(t, SignEnvelope(forged, wif))
+	_, err = svc.VerifyEnvelope(ctx, forged, from, to, value, fee, utxo)
+	assert.ErrorIs(t, err, ErrEnvelopeTampered)
+
+	swapped := offline
+	swapped.SignedTx = forged.SignedTx
+	_, err = svc.VerifyEnvelope(ctx, &swapped, from, to, value, fee, utxo)
+	assert.ErrorIs(t, err, ErrEnvelopeTampered)
+
+	unsigned := *envelope
+	_, err = svc.VerifyEnvelope(ctx, &unsigned, from, to, value, fee, utxo)
+	assert.ErrorIs(t, err, ErrEnvelopeTampered)
+
+	regtest := getService()
+	regtest.SetNetwork(&chaincfg.RegressionNetParams)
+	_, err = regtest.VerifyEnvelope(ctx, &offline, from, to, value, fee, utxo)
+	assert.ErrorIs(t, err, ErrEnvelopeTampered)
+}
+
+func Test_SignPSBT(t *testing.T) {
+	ctx := context.Background()
+	wif := getWIF(t, true)
+	from, utxo := getUTXO(t, wif)
+	envelope, err := getService().ExportTx(ctx, from, "mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn", decimal.RequireFromString("0.004"),
+		decimal.RequireFromString("0.0001"), utxo)
+	assert.NoError(t, err)
+
+	signedTx, err := SignPSBT(envelope.PSBT, wif, &chaincfg.TestNet3Params)
+	assert.NoError(t, err)
+	assert.NoError(t, SignEnvelope(envelope, wif))
+	assert.Equal(t, envelope.SignedTx, signedTx)
+
+	_, err = SignPSBT(envelope.PSBT, wif, &chaincfg.MainNetParams)
+	assert.Error(t, err)
+	_, err = SignPSBT(envelope.PSBT, getWIF(t, false), &chaincfg.TestNet3Params)
+	assert.Error(t, err)
+	_, err = SignPSBT("not base64", wif, &chaincfg.TestNet3Params)
+	assert.Error(t, err)
+
+	// the previous transaction must hold the output the request names
+	svc := getService()
+	wrong := utxo
+	wrong.N = 0
+	_, err = svc.ExportTx(ctx, from, "mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn", decimal.RequireFromString("0.004"),
+		decimal.RequireFromString("0.0001"), wrong)
+	assert.ErrorIs(t, err, ErrPreviousTxMismatch)
+}
+
+// getUTXO returns the P2PKH address of wif and an output of 0.01 BTC it can spend, with its previous transaction.
+func getUTXO(t *testing.T, wif string) (string, TransactionOutPut) {
+	decodeWIF, _ := btcutil.DecodeWIF(wif)
+	address, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(decodeWIF.SerializePubKey()), &chaincfg.TestNet3Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkScript, _ := txscript.PayToAddrScript(address)
+	prevTx := NewTx()
+	prevTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil))
+	prevTx.AddTxOut(wire.NewTxOut(5000, pkScript))
+	prevTx.AddTxOut(wire.NewTxOut(1000000, pkScript))
+	var buf bytes.Buffer
+	if err = prevTx.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	return address.EncodeAddress(), TransactionOutPut{
+		TXId:   prevTx.TxHash().String(),
+		Value:  decimal.RequireFromString("0.01"),
+		N:      1,
+		Hex:    hex.EncodeToString(pkScript),
+		PrevTx: hex.EncodeToString(buf.Bytes()),
+	}
+}
@@ -0,0 +1,98 @@
+package btc
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+const messageMagic = "Bitcoin Signed Message:\n"
+
+// MessageHash returns the double SHA-256 digest that Bitcoin Core signmessage/verifymessage use.
+func MessageHash(message string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := wire.WriteVarString(&buf, 0, messageMagic); err != nil {
+		return nil, err
+	}
+
+	if err := wire.WriteVarString(&buf, 0, message); err != nil {
+		return nil, err
+	}
+
+	return chainhash.DoubleHashB(buf.Bytes()), nil
+}
+
+// SignMessage signs message with a WIF private key and returns the base64 compact signature.
+func (t *Service) SignMessage(ctx context.Context, message, wif string) (string, error) {
+	privateKey, err := btcutil.DecodeWIF(wif)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := MessageHash(message)
+	if err != nil {
+		return "", err
+	}
+
+	signature, err := btcec.SignCompact(btcec.S256(), privateKey.PrivKey, hash, privateKey.CompressPubKey)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// RecoverMessage returns the P2PKH address that produced signature over message.
+func (t *Service) RecoverMessage(ctx context.Context, message, signature string) (string, error) {
+	decodeSignature, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := MessageHash(message)
+	if err != nil {
+		return "", err
+	}
+
+	publicKey, compressed, err := btcec.RecoverCompact(btcec.S256(), decodeSignature, hash)
+	if err != nil {
+		return "", err
+	}
+
+	var publicKeyByte []byte
+	if compressed {
+		publicKeyByte = publicKey.SerializeCompressed()
+	} else {
+		publicKeyByte = publicKey.SerializeUncompressed()
+	}
+
+	address, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(publicKeyByte), t.params)
+	if err != nil {
+		return "", err
+	}
+	return address.EncodeAddress(), nil
+}
+
+// VerifyMessage reports whether signature over message was made by the key behind the P2PKH address.
+func (t *Service) VerifyMessage(ctx context.Context, message, signature, address string) (bool, error) {
+	decodeAddress, err := btcutil.DecodeAddress(address, t.params)
+	if err != nil {
+		return false, err
+	}
+
+	if _, ok := decodeAddress.(*btcutil.AddressPubKeyHash); !ok {
+		return false, fmt.Errorf("only P2PKH addresses can verify messages,address:%s", address)
+	}
+
+	signer, err := t.RecoverMessage(ctx, message, signature)
+	if err != nil {
+		return false, err
+	}
+
+	return signer == decodeAddress.EncodeAddress(), nil
+}
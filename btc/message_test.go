@@ -0,0 +1,81 @@
+package btc
+
+import (
+	"context"
+	"encoding/hex"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_SignMessage(t *testing.T) {
+	ctx := context.Background()
+	svc := getService()
+	wif := getWIF(t, true)
+	message := "prove ownership: 7f3a9c"
+
+	signature, err := svc.SignMessage(ctx, message, wif)
+	assert.NoError(t, err)
+
+	decodeWIF, _ := btcutil.DecodeWIF(wif)
+	expected, _ := svc.CreateAddressByPubKey(ctx, "0x"+hex.EncodeToString(decodeWIF.SerializePubKey()))
+	address, err := svc.RecoverMessage(ctx, message, signature)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, address)
+
+	ok, err := svc.VerifyMessage(ctx, message, signature, address)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = svc.VerifyMessage(ctx, message+" ", signature, address)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// an uncompressed key signs for a different P2PKH address
+	signature, err = svc.SignMessage(ctx, message, getWIF(t, false))
+	assert.NoError(t, err)
+	ok, err = svc.VerifyMessage(ctx, message, signature, address)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = svc.VerifyMessage(ctx, message, "not base64", address)
+	assert.Error(t, err)
+}
+
+// Test_SignMessageVector checks a signature signmessage makes, RFC 6979 makes it deterministic.
+func Test_SignMessageVector(t *testing.T) {
+	ctx := context.Background()
+	svc := getService()
+	svc.SetNetwork(&chaincfg.MainNetParams)
+	message := "This is an example of a signed message."
+	expected := "H9L5yLFjti0QTHhPyFrZCT1V/MMnBtXKmoiKDZ78NDBjERki6ZTQZdSMCtkgoNmp17By9ItJr8o7ChX0XxY91nk="
+
+	signature, err := svc.SignMessage(ctx, message, "L4rK1yDtCWekvXuE6oXD9jCYfFNV2cWRpVuPLBcCU2z8TrisoyY1")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, signature)
+	ok, err := svc.VerifyMessage(ctx, message, expected, "1F3sAm6ZtwLAUnj7d38pGFxtP3RVEvtsbV")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func Test_MessageHash(t *testing.T) {
+	hash, err := MessageHash("hello")
+	assert.NoError(t, err)
+	assert.Equal(t, "cf0447ec85f0ce7150a257db32ebfcb7523dae17c36dbd1be598779fec0484f4", hex.EncodeToString(hash))
+
+	other, err := MessageHash("hello ")
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, other)
+}
+
+func getWIF(t *testing.T, compress bool) string {
+	keyByte, _ := hex.DecodeString("f1b3f8e0d52caec13491368449ab8d90f3d222a3e485aa7f02591bbceb5efba5")
+	privateKey, _ := btcec.PrivKeyFromBytes(btcec.S256(), keyByte)
+	wif, err := btcutil.NewWIF(privateKey, &chaincfg.TestNet3Params, compress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wif.String()
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/shopspring/decimal"
 	"math/big"
@@ -50,10 +51,62 @@ type RawTransactionInfo struct {
 }
 
 type TransactionOutPut struct {
-	TXId  string
-	Value decimal.Decimal
-	N     uint32
-	Hex   string
+	TXId   string
+	Value  decimal.Decimal
+	N      uint32
+	Hex    string
+	PrevTx string //hex of the whole transaction TXId, for ExportTx; fetched from the node when empty
+}
+
+// SigningEnvelope carries an unsigned transaction to an offline host and the signed transaction back. PSBT is
+// base64 and SignedTx hex encoded; Checksum covers every field but SignedTx and Checksum.
+type SigningEnvelope struct {
+	Version  int    `json:"version"`
+	Network  string `json:"network"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Amount   string `json:"amount"`
+	Fee      string `json:"fee"`
+	Summary  string `json:"summary"`
+	PSBT     string `json:"psbt"`
+	SignedTx string `json:"signedTx,omitempty"`
+	Checksum string `json:"checksum"`
+}
+
+// DecodedTx is what a raw transaction does, read without a node. Size is in bytes, Weight in weight units and
+// VSize in virtual bytes.
+type DecodedTx struct {
+	TxId     string
+	WTxId    string
+	Version  int32
+	LockTime uint32
+	Size     int
+	VSize    int
+	Weight   int
+	Inputs   []*DecodedTxIn
+	Outputs  []*DecodedTxOut
+}
+
+// DecodedTxIn is an input of a DecodedTx. Address is only known for P2PKH and P2WPKH spends, whose public key is
+// part of the input; the value of the spent output is not known at all.
+type DecodedTxIn struct {
+	PrevTxId     string
+	PrevN        uint32
+	Sequence     uint32
+	ScriptSig    string
+	ScriptSigAsm string
+	Witness      []string
+	Address      string
+}
+
+type DecodedTxOut struct {
+	N         uint32
+	Value     decimal.Decimal
+	Script    string
+	ScriptAsm string
+	Type      string
+	ReqSigs   int
+	Addresses []string
 }
 
 type Server interface {
@@ -65,6 +118,14 @@ type Server interface {
 	CreateTx(ctx context.Context, from, to string, amount, fee decimal.Decimal, utxOS TransactionOutPut) (*wire.MsgTx, []byte, error)
 	SigTx(ctx context.Context, publicKey string, r, s *big.Int, redeemTx *wire.MsgTx) (*wire.MsgTx, error)
 	BroadcastTx(ctx context.Context, tx *wire.MsgTx) (string, error)
+	SignMessage(ctx context.Context, message, wif string) (string, error)
+	RecoverMessage(ctx context.Context, message, signature string) (string, error)
+	VerifyMessage(ctx context.Context, message, signature, address string) (bool, error)
+	ExportTx(ctx context.Context, from, to string, amount, fee decimal.Decimal, utxOS TransactionOutPut) (*SigningEnvelope, error)
+	VerifyEnvelope(ctx context.Context, envelope *SigningEnvelope, from, to string, amount, fee decimal.Decimal, utxOS TransactionOutPut) (*wire.MsgTx, error)
+	SignPSBT(ctx context.Context, psbt, wif string) (string, error)
+	DecodeRawTx(ctx context.Context, rawTx string) (*DecodedTx, error)
+	Params() *chaincfg.Params
 }
 
 const CheckSumLength = 4
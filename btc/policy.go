@@ -0,0 +1,96 @@
+package btc
+
+import (
+	"context"
+	"demo/amount"
+	"demo/policy"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"math/big"
+)
+
+// SetPolicy makes the service evaluate every output of a transaction that does not pay back to the sender against
+// policy before CreateTx hands out the hash for an external signer and before SignPSBT signs, and again before SigTx
+// attaches the signature and VerifyEnvelope accepts an envelope signed offline. The asset of the withdrawals is
+// empty, for bitcoin. A nil policy allows everything, the default. Call it before the service is in use.
+func (t *Service) SetPolicy(policy *policy.Policy) {
+	t.policy = policy
+}
+
+// checkPolicy evaluates the outputs of tx, sent by from, against the policy of the service, all at once so that
+// they are counted only when the whole transaction is allowed. Outputs to from or to one of own are change.
+func (t *Service) checkPolicy(ctx context.Context, tx *wire.MsgTx, from string, own ...string) error {
+	if t.policy == nil {
+		return nil
+	}
+
+	change := map[string]bool{from: true}
+	for _, address := range own {
+		change[address] = true
+	}
+
+	txID := unsignedTxID(tx)
+	approvals := policy.ApprovalsFromContext(ctx)
+	withdrawals := []policy.Withdrawal{}
+	for _, out := range tx.TxOut {
+		to := outputAddress(out.PkScript, t.params)
+		if change[to] {
+			continue
+		}
+
+		withdrawals = append(withdrawals, policy.Withdrawal{
+			Hash:      txID,
+			Chain:     "btc",
+			From:      from,
+			To:        to,
+			Amount:    amount.FromBase(big.NewInt(out.Value), amount.Bitcoin),
+			Approvals: approvals,
+		})
+	}
+	if len(withdrawals) == 0 {
+		return nil
+	}
+
+	if err := t.policy.Evaluate(withdrawals...); err != nil {
+		t.logger.Warn("Withdrawal refused", "from", from, "tx", txID, "outputs", len(withdrawals), "err", err)
+		return err
+	}
+
+	t.logger.Info("Withdrawal allowed", "from", from, "tx", txID, "outputs", len(withdrawals),
+		"approvals", approvals)
+	return nil
+}
+
+// unsignedTxID returns the ID of tx without its signatures, the same before and after it is signed.
+func unsignedTxID(tx *wire.MsgTx) string {
+	unsigned := tx.Copy()
+	for _, in := range unsigned.TxIn {
+		in.SignatureScript, in.Witness = nil, nil
+	}
+	return unsigned.TxHash().String()
+}
+
+// outputAddress returns the address pkScript pays to, empty when it does not pay to a single address.
+func outputAddress(pkScript []byte, params *chaincfg.Params) string {
+	_, addresses, _, err := txscript.ExtractPkScriptAddrs(pkScript, params)
+	if err != nil || len(addresses) != 1 {
+		return ""
+	}
+	return addresses[0].EncodeAddress()
+}
+
+// keyAddresses returns the P2PKH addresses of the compressed and the uncompressed publicKey.
+func keyAddresses(publicKey *btcec.PublicKey, params *chaincfg.Params) ([]string, error) {
+	addresses := []string{}
+	for _, key := range [][]byte{publicKey.SerializeCompressed(), publicKey.SerializeUncompressed()} {
+		address, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(key), params)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address.EncodeAddress())
+	}
+	return addresses, nil
+}
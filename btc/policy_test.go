@@ -0,0 +1,85 @@
+package btc
+
+import (
+	"context"
+	"demo/apperr"
+	"demo/policy"
+	"errors"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_SetPolicy(t *testing.T) {
+	ctx := context.Background()
+	svc := getService()
+	wif := getWIF(t, true)
+	from, utxo := getUTXO(t, wif)
+	to := "mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn"
+	fee := decimal.RequireFromString("0.0001")
+
+	decisions := []policy.Decision{}
+	p := policy.New(policy.Rules{
+		Limits:    []policy.Limit{{PerTransaction: decimal.RequireFromString("0.005")}},
+		Approvals: []policy.Approval{{Threshold: decimal.RequireFromString("0.001"), Approvals: 1}},
+		Approvers: []string{"alice"},
+	})
+	p.SetAudit(func(decision policy.Decision) {
+		decisions = append(decisions, decision)
+	})
+	svc.SetPolicy(p)
+	approved := policy.WithApprovals(ctx, "alice")
+
+	_, err := svc.ExportTx(approved, from, to, decimal.RequireFromString("0.006"), fee, utxo)
+	assert.ErrorIs(t, err, policy.ErrLimitExceeded)
+	var appErr *apperr.AppErr
+	assert.True(t, errors.As(err, &appErr))
+	assert.Equal(t, "BTC", appErr.Details["asset"])
+
+	// an external signer gets the hash only once the policy allows it, and the signature is checked again
+	value := decimal.RequireFromString("0.004")
+	_, hash, err := svc.CreateTx(ctx, from, to, value, fee, utxo)
+	assert.ErrorIs(t, err, policy.ErrApprovalRequired)
+	assert.Nil(t, hash)
+	tx, hash, err := svc.CreateTx(approved, from, to, value, fee, utxo)
+	assert.NoError(t, err)
+	withdrawal := decisions[2].Withdrawal
+	assert.Equal(t, []string{"btc", from, to, "0.004", tx.TxHash().String()},
+		[]string{withdrawal.Chain, withdrawal.From, withdrawal.To, withdrawal.Amount.String(), withdrawal.Hash})
+	assert.Len(t, decisions, 3, "the change is not a withdrawal")
+
+	key, _ := btcutil.DecodeWIF(wif)
+	signature, err := key.PrivKey.Sign(hash)
+	assert.NoError(t, err)
+	publicKey := hexutil.Encode(key.PrivKey.PubKey().SerializeCompressed())
+	_, err = svc.SigTx(ctx, publicKey, signature.R, signature.S, tx)
+	assert.ErrorIs(t, err, policy.ErrApprovalRequired)
+	_, err = svc.SigTx(approved, publicKey, signature.R, signature.S, tx)
+	assert.NoError(t, err)
+
+	envelope, err := svc.ExportTx(approved, from, to, value, fee, utxo)
+	assert.NoError(t, err)
+	_, err = svc.SignPSBT(ctx, envelope.PSBT, wif)
+	assert.ErrorIs(t, err, policy.ErrApprovalRequired)
+	signedTx, err := svc.SignPSBT(approved, envelope.PSBT, wif)
+	assert.NoError(t, err)
+	offline, err := SignPSBT(envelope.PSBT, wif, svc.Params())
+	assert.NoError(t, err)
+	assert.Equal(t, offline, signedTx)
+
+	// outputs to the same destination add up, and nothing is counted when the transaction is refused
+	split := tx.Copy()
+	split.TxOut[0].Value = 300000
+	split.AddTxOut(wire.NewTxOut(300000, split.TxOut[0].PkScript))
+	err = svc.checkPolicy(approved, split, from)
+	assert.ErrorIs(t, err, policy.ErrLimitExceeded)
+	assert.Equal(t, "0.006", decisions[8].Withdrawal.Amount.String())
+
+	svc.SetPolicy(nil)
+	_, err = svc.SignPSBT(ctx, envelope.PSBT, wif)
+	assert.NoError(t, err)
+	assert.Len(t, decisions, 9)
+}
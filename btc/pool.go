@@ -0,0 +1,168 @@
+package btc
+
+import (
+	"context"
+	"demo/failover"
+	"encoding/json"
+	"errors"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Client is the bitcoind RPC interface used by Service. RPCClient and Pool satisfy it.
+type Client interface {
+	GetBlockCount(ctx context.Context) (int64, error)
+	GetBlockHash(ctx context.Context, blockHeight int64) (*chainhash.Hash, error)
+	GetBlock(ctx context.Context, blockHash *chainhash.Hash) (*wire.MsgBlock, error)
+	GetRawTransactionVerbose(ctx context.Context, txHash *chainhash.Hash) (*btcjson.TxRawResult, error)
+	SendRawTransaction(ctx context.Context, tx *wire.MsgTx, allowHighFees bool) (*chainhash.Hash, error)
+	EstimateFee(ctx context.Context, numBlocks int64) (float64, error)
+	RawRequest(ctx context.Context, method string, params []json.RawMessage) (json.RawMessage, error)
+}
+
+// Endpoint is the RPC address and credentials of a bitcoind node.
+type Endpoint struct {
+	Host     string
+	User     string
+	Password string
+}
+
+// Pool is a Client over several nodes. Calls go to the fastest healthy node in sync with the others and fail over
+// to the next one when the node can not be reached; RPC errors the node answers with are returned as they are.
+// Timeouts, a full work queue and nodes still warming up are retried under the policy of SetPolicy. Check or Watch
+// keeps the heights up to date and quarantines the nodes that lag behind.
+type Pool struct {
+	*failover.Pool
+}
+
+// NewPool returns an empty pool that quarantines nodes more than maxLag blocks behind.
+func NewPool(maxLag uint64) *Pool {
+	return &Pool{Pool: failover.New(probeBlockCount, classify, maxLag)}
+}
+
+// DialPool dials every endpoint into a pool, each called by its host.
+func DialPool(endpoints []Endpoint, maxLag uint64) (*Pool, error) {
+	pool := NewPool(maxLag)
+	for _, endpoint := range endpoints {
+		client, err := Dial(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		pool.Add(endpoint.Host, client)
+	}
+	return pool, nil
+}
+
+// Add adds a node called name, usually its host.
+func (p *Pool) Add(name string, client Client) {
+	p.Pool.Add(name, client)
+}
+
+func probeBlockCount(ctx context.Context, client interface{}) (uint64, error) {
+	count, err := client.(Client).GetBlockCount(ctx)
+	return uint64(count), err
+}
+
+// classify tells the answers of a node from transient errors and from errors reaching it.
+func classify(err error) failover.Class {
+	var rpcErr *btcjson.RPCError
+	var httpErr *HTTPError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &rpcErr):
+		if rpcErr.Code == btcjson.ErrRPCInWarmup || rpcErr.Code == btcjson.ErrRPCClientInInitialDownload {
+			return failover.Transient
+		}
+		return failover.Answer
+	case errors.As(err, &httpErr):
+		if httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode == http.StatusServiceUnavailable {
+			return failover.Transient
+		}
+		return failover.Unreachable
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return failover.Transient
+	}
+	return failover.Unreachable
+}
+
+// do calls fn under Call, as the RPC method it makes. The node calls of fn must use the ctx it is given, which ends
+// at the timeout of the policy.
+func (p *Pool) do(ctx context.Context, method string, fn func(ctx context.Context, c Client) error) error {
+	return p.Call(ctx, method, func(ctx context.Context, client interface{}) error {
+		return fn(ctx, client.(Client))
+	})
+}
+
+func (p *Pool) GetBlockCount(ctx context.Context) (count int64, err error) {
+	err = p.do(ctx, "getblockcount", func(ctx context.Context, c Client) (err error) {
+		count, err = c.GetBlockCount(ctx)
+		return
+	})
+	return
+}
+
+func (p *Pool) GetBlockHash(ctx context.Context, blockHeight int64) (hash *chainhash.Hash, err error) {
+	err = p.do(ctx, "getblockhash", func(ctx context.Context, c Client) (err error) {
+		hash, err = c.GetBlockHash(ctx, blockHeight)
+		return
+	})
+	return
+}
+
+func (p *Pool) GetBlock(ctx context.Context, blockHash *chainhash.Hash) (block *wire.MsgBlock, err error) {
+	err = p.do(ctx, "getblock", func(ctx context.Context, c Client) (err error) {
+		block, err = c.GetBlock(ctx, blockHash)
+		return
+	})
+	return
+}
+
+func (p *Pool) GetRawTransactionVerbose(ctx context.Context, txHash *chainhash.Hash) (result *btcjson.TxRawResult, err error) {
+	err = p.do(ctx, "getrawtransaction", func(ctx context.Context, c Client) (err error) {
+		result, err = c.GetRawTransactionVerbose(ctx, txHash)
+		return
+	})
+	return
+}
+
+// SendRawTransaction sends tx to the next node when a node can not be reached. A node that already has tx in its
+// mempool or chain, because an earlier attempt reached it or another node relayed it, counts as a success.
+func (p *Pool) SendRawTransaction(ctx context.Context, tx *wire.MsgTx, allowHighFees bool) (hash *chainhash.Hash, err error) {
+	err = p.do(ctx, "sendrawtransaction", func(ctx context.Context, c Client) (err error) {
+		hash, err = c.SendRawTransaction(ctx, tx, allowHighFees)
+		if alreadyKnown(err) {
+			txHash := tx.TxHash()
+			hash, err = &txHash, nil
+		}
+		return
+	})
+	return
+}
+
+func (p *Pool) EstimateFee(ctx context.Context, numBlocks int64) (fee float64, err error) {
+	err = p.do(ctx, "estimatefee", func(ctx context.Context, c Client) (err error) {
+		fee, err = c.EstimateFee(ctx, numBlocks)
+		return
+	})
+	return
+}
+
+func (p *Pool) RawRequest(ctx context.Context, method string, params []json.RawMessage) (result json.RawMessage, err error) {
+	err = p.do(ctx, method, func(ctx context.Context, c Client) (err error) {
+		result, err = c.RawRequest(ctx, method, params)
+		return
+	})
+	return
+}
+
+func alreadyKnown(err error) bool {
+	var rpcErr *btcjson.RPCError
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+	return rpcErr.Code == btcjson.ErrRPCTxAlreadyInChain || strings.Contains(rpcErr.Message, "txn-already-in-mempool")
+}
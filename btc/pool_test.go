@@ -0,0 +1,223 @@
+package btc
+
+import (
+	"bytes"
+	"context"
+	"demo/failover"
+	"demo/logging"
+	"demo/metrics"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakeNode is a bitcoind RPC server answering the few calls the pool tests make.
+type fakeNode struct {
+	*httptest.Server
+	mu      sync.Mutex
+	height  int64
+	sendErr *btcjson.RPCError
+	calls   map[string]int
+	txs     map[string]btcjson.TxRawResult
+	heights map[string]int32
+}
+
+func newFakeNode(t *testing.T, height int64) *fakeNode {
+	node := &fakeNode{
+		height:  height,
+		calls:   map[string]int{},
+		txs:     map[string]btcjson.TxRawResult{},
+		heights: map[string]int32{},
+	}
+	node.Server = httptest.NewServer(http.HandlerFunc(node.serve))
+	t.Cleanup(node.Close)
+	return node
+}
+
+func (n *fakeNode) serve(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.calls[request.Method]++
+	response := map[string]interface{}{"id": request.ID, "result": nil, "error": nil}
+	switch request.Method {
+	case "getblockcount":
+		response["result"] = n.height
+	case "getnetworkinfo":
+		response["result"] = map[string]interface{}{"version": 220000}
+	case "sendrawtransaction":
+		if n.sendErr != nil {
+			response["error"] = n.sendErr
+			break
+		}
+		response["result"] = "b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0"
+	case "getrawtransaction":
+		var txid string
+		_ = json.Unmarshal(request.Params[0], &txid)
+		tx, ok := n.txs[txid]
+		if !ok {
+			response["error"] = btcjson.NewRPCError(btcjson.ErrRPCNoTxInfo, "No such mempool or blockchain transaction")
+			break
+		}
+		response["result"] = tx
+	case "getblockheader":
+		var hash string
+		_ = json.Unmarshal(request.Params[0], &hash)
+		height, ok := n.heights[hash]
+		if !ok {
+			response["error"] = btcjson.NewRPCError(btcjson.ErrRPCBlockNotFound, "Block not found")
+			break
+		}
+		response["result"] = btcjson.GetBlockHeaderVerboseResult{Hash: hash, Height: height}
+	default:
+		response["error"] = btcjson.NewRPCError(btcjson.ErrRPCMethodNotFound.Code, "Method not found")
+	}
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func (n *fakeNode) endpoint() Endpoint {
+	return Endpoint{Host: n.Listener.Addr().String(), User: "wallet", Password: "secret"}
+}
+
+func (n *fakeNode) addTransaction(tx btcjson.TxRawResult) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.txs[tx.Txid] = tx
+}
+
+func (n *fakeNode) addBlock(hash string, height int32) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.heights[hash] = height
+}
+
+func (n *fakeNode) setSendErr(err *btcjson.RPCError) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sendErr = err
+}
+
+func (n *fakeNode) callCount(method string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.calls[method]
+}
+
+func Test_PoolReads(t *testing.T) {
+	down, lagging, synced := newFakeNode(t, 700000), newFakeNode(t, 699990), newFakeNode(t, 700001)
+	down.Close()
+	pool, err := DialPool([]Endpoint{down.endpoint(), lagging.endpoint(), synced.endpoint()}, 1)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	pool.Check(ctx)
+	statuses := pool.Status()
+	assert.False(t, statuses[0].Healthy)
+	assert.True(t, statuses[1].Lagging)
+	assert.False(t, statuses[2].Lagging)
+
+	count, err := pool.GetBlockCount(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(700001), count)
+	assert.Equal(t, 1, lagging.callCount("getblockcount"), "the lagging node is only probed")
+
+	_, err = pool.EstimateFee(ctx, 6)
+	var rpcErr *btcjson.RPCError
+	assert.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, btcjson.ErrRPCMethodNotFound.Code, rpcErr.Code)
+	assert.True(t, pool.Status()[2].Healthy, "an error answer does not fail the node over")
+}
+
+func Test_PoolSendRawTransaction(t *testing.T) {
+	first, second := newFakeNode(t, 100), newFakeNode(t, 100)
+	pool, _ := DialPool([]Endpoint{first.endpoint(), second.endpoint()}, 1)
+	tx := wire.NewMsgTx(wire.TxVersion)
+	ctx := context.Background()
+
+	first.setSendErr(btcjson.NewRPCError(btcjson.ErrRPCVerifyRejected, "bad-txns-inputs-missingorspent"))
+	_, err := pool.SendRawTransaction(ctx, tx, false)
+	assert.Error(t, err)
+	assert.Equal(t, 0, second.callCount("sendrawtransaction"))
+
+	first.setSendErr(btcjson.NewRPCError(btcjson.ErrRPCVerifyRejected, "txn-already-in-mempool"))
+	hash, err := pool.SendRawTransaction(ctx, tx, false)
+	assert.NoError(t, err)
+	assert.Equal(t, tx.TxHash(), *hash)
+
+	first.Close()
+	hash, err = pool.SendRawTransaction(ctx, tx, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0", hash.String())
+	assert.Equal(t, 1, second.callCount("sendrawtransaction"), "a write fails over to the next node")
+
+	svc := NewClientService(pool)
+	height, err := svc.rpc.GetBlockCount(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), height)
+}
+
+func Test_Classify(t *testing.T) {
+	for _, c := range []struct {
+		err   error
+		class failover.Class
+	}{
+		{btcjson.NewRPCError(btcjson.ErrRPCVerifyRejected, "bad-txns-inputs-missingorspent"), failover.Answer},
+		{btcjson.NewRPCError(btcjson.ErrRPCInWarmup, "Loading block index..."), failover.Transient},
+		{&HTTPError{StatusCode: http.StatusServiceUnavailable, Body: "Work queue depth exceeded"}, failover.Transient},
+		{&HTTPError{StatusCode: http.StatusUnauthorized}, failover.Unreachable},
+		{fmt.Errorf("getblockcount: %w", context.DeadlineExceeded), failover.Transient},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, failover.Unreachable},
+	} {
+		assert.Equal(t, c.class, classify(c.err), c.err.Error())
+	}
+}
+
+func Test_SetInstrumentation(t *testing.T) {
+	node := newFakeNode(t, 100)
+	pool, _ := DialPool([]Endpoint{node.endpoint()}, 1)
+	svc := NewClientService(pool)
+	measurements := metrics.New()
+	svc.SetInstrumentation(measurements.Chain("btc"))
+	ctx := context.Background()
+	tx := wire.NewMsgTx(wire.TxVersion)
+
+	var logs bytes.Buffer
+	logger, _ := logging.New(&logs, "logfmt", "info")
+	svc.SetLogger(logger)
+
+	_, err := svc.BroadcastTx(ctx, tx)
+	assert.NoError(t, err)
+	node.setSendErr(btcjson.NewRPCError(btcjson.ErrRPCVerifyRejected, "bad-txns-inputs-missingorspent"))
+	_, err = svc.BroadcastTx(ctx, tx)
+	assert.Error(t, err)
+	pool.Check(ctx)
+	assert.Contains(t, logs.String(), `chain=btc tx=b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0`)
+	assert.Contains(t, logs.String(), `chain=btc tx=`+tx.TxHash().String()+` err="-26: bad-txns-inputs-missingorspent"`)
+
+	recorder := httptest.NewRecorder()
+	measurements.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := recorder.Body.String()
+	endpoint := node.endpoint().Host
+	assert.Contains(t, body, `wallet_broadcasts_total{chain="btc",outcome="accepted"} 1`)
+	assert.Contains(t, body, `wallet_broadcasts_total{chain="btc",outcome="rejected"} 1`)
+	assert.Contains(t, body, `wallet_rpc_duration_seconds_count{chain="btc",endpoint="`+endpoint+`",method="sendrawtransaction"} 2`)
+	assert.Contains(t, body, `wallet_rpc_errors_total{chain="btc",endpoint="`+endpoint+`",method="sendrawtransaction"} 1`)
+	assert.Contains(t, body, `wallet_chain_head_height{chain="btc",endpoint="`+endpoint+`"} 100`)
+}
@@ -0,0 +1,217 @@
+package btc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"io"
+)
+
+// The subset of BIP-174 the signing envelope needs: the unsigned transaction and, for every input, what it spends
+// and the sighash type. A legacy input carries its whole previous transaction as PSBT_IN_NON_WITNESS_UTXO, since
+// its signature does not commit to the value it spends; a segwit input carries the output as PSBT_IN_WITNESS_UTXO.
+const (
+	psbtMagic            = "psbt\xff"
+	psbtGlobalUnsignedTx = 0x00
+	psbtInNonWitnessUtxo = 0x00
+	psbtInWitnessUtxo    = 0x01
+	psbtInSighashType    = 0x03
+	psbtMaxValueSize     = 4000000
+)
+
+var errInvalidPSBT = errors.New("invalid psbt")
+
+// encodePSBT serializes tx, whose inputs spend prevOuts of the transactions prevTxs, as a PSBT. The previous
+// transactions of segwit inputs may be nil.
+func encodePSBT(tx *wire.MsgTx, prevOuts []*wire.TxOut, prevTxs []*wire.MsgTx) ([]byte, error) {
+	if len(prevOuts) != len(tx.TxIn) || len(prevTxs) != len(tx.TxIn) {
+		return nil, errInvalidPSBT
+	}
+
+	var buf, unsignedTx bytes.Buffer
+	buf.WriteString(psbtMagic)
+	if err := tx.SerializeNoWitness(&unsignedTx); err != nil {
+		return nil, err
+	}
+
+	if err := writePSBTPair(&buf, psbtGlobalUnsignedTx, unsignedTx.Bytes()); err != nil {
+		return nil, err
+	}
+	buf.WriteByte(0x00)
+
+	for i, prevOut := range prevOuts {
+		if err := writePSBTUtxo(&buf, prevOut, prevTxs[i]); err != nil {
+			return nil, fmt.Errorf("input %d: %w", i, err)
+		}
+
+		sighashType := make([]byte, 4)
+		binary.LittleEndian.PutUint32(sighashType, uint32(txscript.SigHashAll))
+		if err := writePSBTPair(&buf, psbtInSighashType, sighashType); err != nil {
+			return nil, err
+		}
+		buf.WriteByte(0x00)
+	}
+
+	for range tx.TxOut {
+		buf.WriteByte(0x00)
+	}
+	return buf.Bytes(), nil
+}
+
+// decodePSBT returns the unsigned transaction of a PSBT and the outputs its inputs spend.
+func decodePSBT(data []byte) (*wire.MsgTx, []*wire.TxOut, error) {
+	r := bytes.NewReader(data)
+	magic := make([]byte, len(psbtMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != psbtMagic {
+		return nil, nil, errInvalidPSBT
+	}
+
+	global, err := readPSBTMap(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	unsignedTx, ok := global[psbtGlobalUnsignedTx]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: no unsigned transaction", errInvalidPSBT)
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	if err = tx.DeserializeNoWitness(bytes.NewReader(unsignedTx)); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", errInvalidPSBT, err)
+	}
+
+	prevOuts := make([]*wire.TxOut, 0, len(tx.TxIn))
+	for i, txIn := range tx.TxIn {
+		if len(txIn.SignatureScript) > 0 {
+			return nil, nil, fmt.Errorf("%w: input %d is signed", errInvalidPSBT, i)
+		}
+
+		input, err := readPSBTMap(r)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		prevOut, err := readPSBTUtxo(input, txIn.PreviousOutPoint)
+		if err != nil {
+			return nil, nil, fmt.Errorf("input %d: %w", i, err)
+		}
+
+		if sighashType, ok := input[psbtInSighashType]; ok {
+			if len(sighashType) != 4 || binary.LittleEndian.Uint32(sighashType) != uint32(txscript.SigHashAll) {
+				return nil, nil, fmt.Errorf("%w: input %d is not SIGHASH_ALL", errInvalidPSBT, i)
+			}
+		}
+		prevOuts = append(prevOuts, prevOut)
+	}
+
+	for range tx.TxOut {
+		if _, err = readPSBTMap(r); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if r.Len() > 0 {
+		return nil, nil, fmt.Errorf("%w: trailing data", errInvalidPSBT)
+	}
+	return tx, prevOuts, nil
+}
+
+// writePSBTUtxo writes what an input spends: the whole prevTx for a legacy input, only prevOut for a segwit one.
+func writePSBTUtxo(w io.Writer, prevOut *wire.TxOut, prevTx *wire.MsgTx) error {
+	if txscript.IsWitnessProgram(prevOut.PkScript) {
+		var txOut bytes.Buffer
+		if err := wire.WriteTxOut(&txOut, 0, 0, prevOut); err != nil {
+			return err
+		}
+		return writePSBTPair(w, psbtInWitnessUtxo, txOut.Bytes())
+	}
+
+	if prevTx == nil {
+		return fmt.Errorf("%w: a legacy input needs its previous transaction", errInvalidPSBT)
+	}
+
+	var data bytes.Buffer
+	if err := prevTx.Serialize(&data); err != nil {
+		return err
+	}
+	return writePSBTPair(w, psbtInNonWitnessUtxo, data.Bytes())
+}
+
+// readPSBTUtxo returns the output an input spends at outPoint, from its previous transaction for a legacy input.
+func readPSBTUtxo(input map[byte][]byte, outPoint wire.OutPoint) (*wire.TxOut, error) {
+	if data, ok := input[psbtInNonWitnessUtxo]; ok {
+		prevTx := wire.NewMsgTx(wire.TxVersion)
+		if err := prevTx.Deserialize(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidPSBT, err)
+		}
+
+		if prevTx.TxHash() != outPoint.Hash || int(outPoint.Index) >= len(prevTx.TxOut) {
+			return nil, fmt.Errorf("%w: the previous transaction does not hold the spent output", errInvalidPSBT)
+		}
+		return prevTx.TxOut[outPoint.Index], nil
+	}
+
+	prevOut, err := readTxOut(input[psbtInWitnessUtxo])
+	if err != nil {
+		return nil, fmt.Errorf("%w: no spent output", errInvalidPSBT)
+	}
+
+	if !txscript.IsWitnessProgram(prevOut.PkScript) {
+		return nil, fmt.Errorf("%w: a legacy input needs its previous transaction", errInvalidPSBT)
+	}
+	return prevOut, nil
+}
+
+func writePSBTPair(w io.Writer, keyType byte, value []byte) error {
+	if err := wire.WriteVarBytes(w, 0, []byte{keyType}); err != nil {
+		return err
+	}
+	return wire.WriteVarBytes(w, 0, value)
+}
+
+// readPSBTMap reads key-value pairs up to the separator. Keys with key data are skipped, none of the types used
+// here have any.
+func readPSBTMap(r io.Reader) (map[byte][]byte, error) {
+	pairs := map[byte][]byte{}
+	for {
+		key, err := wire.ReadVarBytes(r, 0, psbtMaxValueSize, "psbt key")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidPSBT, err)
+		}
+
+		if len(key) == 0 {
+			return pairs, nil
+		}
+
+		value, err := wire.ReadVarBytes(r, 0, psbtMaxValueSize, "psbt value")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidPSBT, err)
+		}
+
+		if len(key) > 1 {
+			continue
+		}
+
+		if _, ok := pairs[key[0]]; ok {
+			return nil, fmt.Errorf("%w: duplicate key 0x%02x", errInvalidPSBT, key[0])
+		}
+		pairs[key[0]] = value
+	}
+}
+
+func readTxOut(data []byte) (*wire.TxOut, error) {
+	if len(data) < 8 {
+		return nil, errInvalidPSBT
+	}
+
+	r := bytes.NewReader(data[8:])
+	pkScript, err := wire.ReadVarBytes(r, 0, psbtMaxValueSize, "pkScript")
+	if err != nil || r.Len() > 0 {
+		return nil, errInvalidPSBT
+	}
+	return wire.NewTxOut(int64(binary.LittleEndian.Uint64(data[:8])), pkScript), nil
+}
@@ -0,0 +1,74 @@
+package btc
+
+import (
+	"bytes"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_PSBT(t *testing.T) {
+	tx := NewTx()
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil))
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{2}, 3), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+	tx.AddTxOut(wire.NewTxOut(2000, []byte{0x52}))
+
+	// the legacy input carries its previous transaction, the segwit one only the output it spends
+	legacyOut := wire.NewTxOut(5000, []byte{0x76, 0xa9})
+	prevTx := NewTx()
+	prevTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{9}, 0), nil, nil))
+	prevTx.AddTxOut(legacyOut)
+	tx.TxIn[0].PreviousOutPoint.Hash = prevTx.TxHash()
+	witnessOut := wire.NewTxOut(6000, append([]byte{0x00, 0x14}, make([]byte, 20)...))
+	prevOuts := []*wire.TxOut{legacyOut, witnessOut}
+
+	data, err := encodePSBT(tx, prevOuts, []*wire.MsgTx{prevTx, nil})
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(data, []byte("psbt\xff")))
+
+	decoded, decodedOuts, err := decodePSBT(data)
+	assert.NoError(t, err)
+	assert.Equal(t, tx.TxHash(), decoded.TxHash())
+	assert.Equal(t, prevOuts, decodedOuts)
+
+	_, _, err = decodePSBT(data[1:])
+	assert.ErrorIs(t, err, errInvalidPSBT)
+	_, _, err = decodePSBT(data[:len(data)-1])
+	assert.ErrorIs(t, err, errInvalidPSBT)
+	_, _, err = decodePSBT(append(data, 0x00))
+	assert.ErrorIs(t, err, errInvalidPSBT)
+	_, err = encodePSBT(tx, nil, nil)
+	assert.ErrorIs(t, err, errInvalidPSBT)
+	_, err = encodePSBT(tx, prevOuts, []*wire.MsgTx{nil, nil})
+	assert.ErrorIs(t, err, errInvalidPSBT)
+
+	// the previous transaction must be the one the input spends
+	tx.TxIn[0].PreviousOutPoint.Hash = chainhash.Hash{1}
+	data, err = encodePSBT(tx, prevOuts, []*wire.MsgTx{prevTx, nil})
+	assert.NoError(t, err)
+	_, _, err = decodePSBT(data)
+	assert.ErrorIs(t, err, errInvalidPSBT)
+
+	// a legacy input given as a witness utxo, as PSBTs were written before
+	var buf bytes.Buffer
+	buf.WriteString(psbtMagic)
+	var unsignedTx, txOut bytes.Buffer
+	assert.NoError(t, tx.SerializeNoWitness(&unsignedTx))
+	assert.NoError(t, writePSBTPair(&buf, psbtGlobalUnsignedTx, unsignedTx.Bytes()))
+	buf.WriteByte(0x00)
+	assert.NoError(t, wire.WriteTxOut(&txOut, 0, 0, legacyOut))
+	assert.NoError(t, writePSBTPair(&buf, psbtInWitnessUtxo, txOut.Bytes()))
+	buf.WriteByte(0x00)
+	_, _, err = decodePSBT(buf.Bytes())
+	assert.ErrorIs(t, err, errInvalidPSBT)
+
+	// a signed input is not a PSBT input any more
+	tx.TxIn[0].PreviousOutPoint.Hash = prevTx.TxHash()
+	tx.TxIn[0].SignatureScript = []byte{0x00}
+	data, err = encodePSBT(tx, prevOuts, []*wire.MsgTx{prevTx, nil})
+	assert.NoError(t, err)
+	_, _, err = decodePSBT(data)
+	assert.ErrorIs(t, err, errInvalidPSBT)
+}
@@ -0,0 +1,219 @@
+package btc
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// bitcoindPost19 is the first bitcoind version whose sendrawtransaction takes a maximum fee rate instead of
+// allowhighfees.
+const bitcoindPost19 = 190000
+
+// HTTPError is a response of a node that is not JSON-RPC, such as the 401 of wrong credentials or the 503 of a full
+// work queue.
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("status code: %d, response: %q", e.StatusCode, e.Body)
+}
+
+// RPCClient is a bitcoind JSON-RPC client over HTTP. Unlike rpcclient, which sends one request at a time and never
+// times out, every call honours the deadline and the cancellation of its context.
+type RPCClient struct {
+	url      string
+	endpoint Endpoint
+	http     *http.Client
+	id       uint64
+
+	mu     sync.Mutex
+	legacy *bool //sendrawtransaction takes allowhighfees, known after the first send
+}
+
+// Dial returns a client of the node at endpoint. It does not connect until the first call.
+func Dial(endpoint Endpoint) (*RPCClient, error) {
+	if len(endpoint.Host) == 0 {
+		return nil, errors.New("the bitcoind endpoint has no host")
+	}
+	return &RPCClient{url: "http://" + endpoint.Host, endpoint: endpoint, http: &http.Client{}}, nil
+}
+
+// call sends method with params and decodes its result into result, unless result is nil. Errors of the node are
+// *btcjson.RPCError.
+func (c *RPCClient) call(ctx context.Context, method string, params []interface{}, result interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "1.0",
+		"id":      atomic.AddUint64(&c.id, 1),
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.SetBasicAuth(c.endpoint.User, c.endpoint.Password)
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	//bitcoind answers errors with a JSON-RPC body and a 4xx or 5xx status
+	var reply struct {
+		Result json.RawMessage   `json:"result"`
+		Error  *btcjson.RPCError `json:"error"`
+	}
+	if err = json.Unmarshal(data, &reply); err != nil {
+		return &HTTPError{StatusCode: response.StatusCode, Body: string(data)}
+	}
+	if reply.Error != nil {
+		return reply.Error
+	}
+	if response.StatusCode != http.StatusOK {
+		return &HTTPError{StatusCode: response.StatusCode, Body: string(data)}
+	}
+
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(reply.Result, result)
+}
+
+func (c *RPCClient) GetBlockCount(ctx context.Context) (int64, error) {
+	var count int64
+	err := c.call(ctx, "getblockcount", nil, &count)
+	return count, err
+}
+
+func (c *RPCClient) GetBlockHash(ctx context.Context, blockHeight int64) (*chainhash.Hash, error) {
+	var hash string
+	if err := c.call(ctx, "getblockhash", []interface{}{blockHeight}, &hash); err != nil {
+		return nil, err
+	}
+	return chainhash.NewHashFromStr(hash)
+}
+
+func (c *RPCClient) GetBlock(ctx context.Context, blockHash *chainhash.Hash) (*wire.MsgBlock, error) {
+	var blockHex string
+	if err := c.call(ctx, "getblock", []interface{}{blockHash.String(), 0}, &blockHex); err != nil {
+		return nil, err
+	}
+
+	data, err := hex.DecodeString(blockHex)
+	if err != nil {
+		return nil, err
+	}
+
+	var block wire.MsgBlock
+	if err = block.Deserialize(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+func (c *RPCClient) GetRawTransactionVerbose(ctx context.Context, txHash *chainhash.Hash) (*btcjson.TxRawResult, error) {
+	var result btcjson.TxRawResult
+	if err := c.call(ctx, "getrawtransaction", []interface{}{txHash.String(), 1}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SendRawTransaction broadcasts tx. Unless allowHighFees, the node rejects fees above its default maximum rate.
+func (c *RPCClient) SendRawTransaction(ctx context.Context, tx *wire.MsgTx, allowHighFees bool) (*chainhash.Hash, error) {
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return nil, err
+	}
+
+	legacy, err := c.legacySend(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	params := []interface{}{hex.EncodeToString(buf.Bytes())}
+	switch {
+	case legacy:
+		params = append(params, allowHighFees)
+	case allowHighFees:
+		params = append(params, 0) //no maximum fee rate
+	}
+
+	var hash string
+	if err = c.call(ctx, "sendrawtransaction", params, &hash); err != nil {
+		return nil, err
+	}
+	return chainhash.NewHashFromStr(hash)
+}
+
+// legacySend reports whether the node is btcd or a bitcoind older than 0.19, whose sendrawtransaction takes
+// allowhighfees. btcd has no getnetworkinfo.
+func (c *RPCClient) legacySend(ctx context.Context) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.legacy != nil {
+		return *c.legacy, nil
+	}
+
+	var info struct {
+		Version int32 `json:"version"`
+	}
+	legacy := false
+	err := c.call(ctx, "getnetworkinfo", nil, &info)
+	var rpcErr *btcjson.RPCError
+	switch {
+	case errors.As(err, &rpcErr) && rpcErr.Code == btcjson.ErrRPCMethodNotFound.Code:
+		legacy = true
+	case err != nil:
+		return false, err
+	default:
+		legacy = info.Version < bitcoindPost19
+	}
+
+	c.legacy = &legacy
+	return legacy, nil
+}
+
+func (c *RPCClient) EstimateFee(ctx context.Context, numBlocks int64) (float64, error) {
+	var fee float64
+	err := c.call(ctx, "estimatefee", []interface{}{numBlocks}, &fee)
+	return fee, err
+}
+
+func (c *RPCClient) RawRequest(ctx context.Context, method string, params []json.RawMessage) (json.RawMessage, error) {
+	values := make([]interface{}, len(params))
+	for i, param := range params {
+		values[i] = param
+	}
+
+	var result json.RawMessage
+	err := c.call(ctx, method, values, &result)
+	return result, err
+}
@@ -0,0 +1,146 @@
+package btc
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// rpcServer answers every request with handle, after checking the credentials of the fake endpoints.
+func rpcServer(t *testing.T, handle func(method string, params []json.RawMessage) (interface{}, *btcjson.RPCError)) Endpoint {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, _ := r.BasicAuth(); user != "wallet" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var request struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result, rpcErr := handle(request.Method, request.Params)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": request.ID, "result": result, "error": rpcErr})
+	}))
+	t.Cleanup(server.Close)
+	return Endpoint{Host: server.Listener.Addr().String(), User: "wallet", Password: "secret"}
+}
+
+func Test_RPCClientGetBlock(t *testing.T) {
+	genesis := chaincfg.MainNetParams.GenesisBlock
+	var buf bytes.Buffer
+	assert.NoError(t, genesis.Serialize(&buf))
+
+	endpoint := rpcServer(t, func(method string, params []json.RawMessage) (interface{}, *btcjson.RPCError) {
+		switch method {
+		case "getblockhash":
+			return genesis.BlockHash().String(), nil
+		case "getblock":
+			return hex.EncodeToString(buf.Bytes()), nil
+		}
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCMethodNotFound.Code, "Method not found")
+	})
+	client, err := Dial(endpoint)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	hash, err := client.GetBlockHash(ctx, 0)
+	assert.NoError(t, err)
+	block, err := client.GetBlock(ctx, hash)
+	assert.NoError(t, err)
+	assert.Equal(t, genesis.BlockHash(), block.BlockHash())
+
+	_, err = client.EstimateFee(ctx, 6)
+	var rpcErr *btcjson.RPCError
+	assert.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, btcjson.ErrRPCMethodNotFound.Code, rpcErr.Code)
+
+	endpoint.Password = "wrong"
+	client, _ = Dial(endpoint)
+	_, err = client.GetBlockCount(ctx)
+	var httpErr *HTTPError
+	assert.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.StatusUnauthorized, httpErr.StatusCode)
+
+	_, err = Dial(Endpoint{})
+	assert.Error(t, err)
+}
+
+func Test_RPCClientContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	endpoint := rpcServer(t, func(method string, params []json.RawMessage) (interface{}, *btcjson.RPCError) {
+		<-release
+		return 1, nil
+	})
+	client, _ := Dial(endpoint)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.GetBlockCount(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second), "a hung node does not outlast the deadline")
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = client.GetBlockCount(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func Test_RPCClientSendRawTransaction(t *testing.T) {
+	tx := wire.NewMsgTx(wire.TxVersion)
+	for _, c := range []struct {
+		version       int32 //of getnetworkinfo, 0 for a node without it
+		allowHighFees bool
+		params        int
+		feeParam      string
+	}{
+		{220000, false, 1, ""},
+		{220000, true, 2, "0"},
+		{180100, true, 2, "true"},
+		{0, false, 2, "false"},
+	} {
+		var sent []json.RawMessage
+		infos := 0
+		endpoint := rpcServer(t, func(method string, params []json.RawMessage) (interface{}, *btcjson.RPCError) {
+			switch method {
+			case "getnetworkinfo":
+				infos++
+				if c.version == 0 {
+					break
+				}
+				return map[string]interface{}{"version": c.version}, nil
+			case "sendrawtransaction":
+				sent = params
+				return tx.TxHash().String(), nil
+			}
+			return nil, btcjson.NewRPCError(btcjson.ErrRPCMethodNotFound.Code, "Method not found")
+		})
+		client, _ := Dial(endpoint)
+
+		for i := 0; i < 2; i++ {
+			hash, err := client.SendRawTransaction(context.Background(), tx, c.allowHighFees)
+			assert.NoError(t, err)
+			assert.Equal(t, tx.TxHash(), *hash)
+		}
+		assert.Equal(t, 1, infos, "the version of the node is asked once")
+		assert.Len(t, sent, c.params, "version %d", c.version)
+		if c.params > 1 {
+			assert.Equal(t, c.feeParam, string(sent[1]), "version %d", c.version)
+		}
+	}
+}
@@ -0,0 +1,396 @@
+package chain
+
+import (
+	"context"
+	"crypto/rand"
+	"demo/policy"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrWithdrawalNotFound = errors.New("the withdrawal was not found")
+	ErrNotApprover        = errors.New("not an approver of withdrawals")
+	ErrSelfApproval       = errors.New("the requester of a withdrawal can not approve it")
+	ErrAlreadyApproved    = errors.New("the approver already approved the withdrawal")
+	ErrWithdrawalClosed   = errors.New("the withdrawal is no longer pending")
+	ErrWithdrawalExpired  = errors.New("the withdrawal expired before it was released")
+	ErrQuorumNotReached   = errors.New("the withdrawal does not have the approvals it needs")
+	ErrReleaseInProgress  = errors.New("the withdrawal is being released")
+	ErrInvalidQuorum      = errors.New("the quorum must be between 1 and the number of approvers")
+	ErrKeyMismatch        = errors.New("the private key does not sign for the sender of the withdrawal")
+)
+
+type ApprovalState int32
+
+const (
+	ApprovalStatePending  ApprovalState = 0 //collecting approvals
+	ApprovalStateApproved ApprovalState = 1 //quorum reached, waiting for Release
+	ApprovalStateRejected ApprovalState = 2
+	ApprovalStateExpired  ApprovalState = 3
+	ApprovalStateReleased ApprovalState = 4 //signed and broadcast
+)
+
+// Actions of an ApprovalEvent.
+const (
+	ApprovalActionSubmit        = "submit"
+	ApprovalActionApprove       = "approve"
+	ApprovalActionReject        = "reject"
+	ApprovalActionExpire        = "expire"
+	ApprovalActionRelease       = "release"
+	ApprovalActionReleaseFailed = "release_failed"
+)
+
+// ApprovalPolicy is who approves withdrawals and how many of them must. A withdrawal that does not reach Quorum,
+// or is not released, within Expiry of its submission expires; zero for no expiry.
+type ApprovalPolicy struct {
+	Approvers []string
+	Quorum    int
+	Expiry    time.Duration
+}
+
+// Withdrawal is a TransferRequest waiting for approvals. Tx is set once it is built and signed, and keeps the
+// transaction a failed broadcast is retried with.
+type Withdrawal struct {
+	ID        string
+	Chain     string
+	Request   TransferRequest
+	Requester string
+	State     ApprovalState
+	Approvals []string
+	CreatedAt time.Time
+	ExpiresAt time.Time //zero when it does not expire
+	Tx        *Tx
+	History   []ApprovalEvent
+}
+
+// ApprovalEvent is the record of one action on a withdrawal. Reason is given with reject, TxID is set for release
+// and Err for release_failed. A reject has TxID too when the withdrawal was signed before its broadcast failed: that
+// transaction may still reach the network and must be watched for, or double spent.
+type ApprovalEvent struct {
+	Time       time.Time
+	Withdrawal string
+	Action     string
+	Actor      string
+	Reason     string
+	TxID       string
+	Err        error
+}
+
+// Approvals holds withdrawals until a quorum of named approvers signs them off, and only then signs and broadcasts
+// them. Withdrawals are kept in memory.
+type Approvals struct {
+	registry  *Registry
+	policy    ApprovalPolicy
+	approvers map[string]bool
+	now       func() time.Time
+
+	mu          sync.Mutex
+	withdrawals map[string]*Withdrawal
+	releasing   map[string]bool
+	audit       func(ApprovalEvent)
+}
+
+// NewApprovals fails with ErrInvalidQuorum unless policy asks for at least one approval and no more than it has
+// distinct approvers.
+func NewApprovals(registry *Registry, policy ApprovalPolicy) (*Approvals, error) {
+	approvers := make(map[string]bool, len(policy.Approvers))
+	for _, approver := range policy.Approvers {
+		approvers[approver] = true
+	}
+
+	if policy.Quorum < 1 || policy.Quorum > len(approvers) {
+		return nil, ErrInvalidQuorum
+	}
+
+	return &Approvals{
+		registry:    registry,
+		policy:      policy,
+		approvers:   approvers,
+		now:         time.Now,
+		withdrawals: map[string]*Withdrawal{},
+		releasing:   map[string]bool{},
+		audit:       func(ApprovalEvent) {},
+	}, nil
+}
+
+// SetAudit makes a pass every event to audit as well, in the order they happen. audit must not block. Call it
+// before a is in use.
+func (a *Approvals) SetAudit(audit func(ApprovalEvent)) {
+	a.audit = audit
+}
+
+// Submit puts request on chain in the pending state, on behalf of requester.
+func (a *Approvals) Submit(ctx context.Context, chain, requester string, request TransferRequest) (*Withdrawal, error) {
+	c, err := a.registry.Get(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.ValidateAddress(ctx, request.From) || !c.ValidateAddress(ctx, request.To) {
+		return nil, ErrInvalidAddress
+	}
+
+	id, err := withdrawalID()
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.now()
+	withdrawal := &Withdrawal{ID: id, Chain: chain, Request: request, Requester: requester, CreatedAt: now}
+	if a.policy.Expiry > 0 {
+		withdrawal.ExpiresAt = now.Add(a.policy.Expiry)
+	}
+	a.withdrawals[id] = withdrawal
+	a.record(withdrawal, ApprovalEvent{Time: now, Action: ApprovalActionSubmit, Actor: requester})
+	a.checkQuorum(withdrawal)
+	return withdrawal.copy(), nil
+}
+
+func withdrawalID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// Approve adds the approval of approver to the withdrawal id, which is approved once it has the quorum.
+func (a *Approvals) Approve(ctx context.Context, id, approver string) (*Withdrawal, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	withdrawal, err := a.pending(id)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case !a.approvers[approver]:
+		return nil, ErrNotApprover
+	case approver == withdrawal.Requester:
+		return nil, ErrSelfApproval
+	}
+
+	for _, approval := range withdrawal.Approvals {
+		if approval == approver {
+			return nil, ErrAlreadyApproved
+		}
+	}
+
+	withdrawal.Approvals = append(withdrawal.Approvals, approver)
+	a.record(withdrawal, ApprovalEvent{Time: a.now(), Action: ApprovalActionApprove, Actor: approver})
+	a.checkQuorum(withdrawal)
+	return withdrawal.copy(), nil
+}
+
+// Reject closes the withdrawal id for good. Any approver may reject it, and so may its requester, but not while it
+// is being released.
+func (a *Approvals) Reject(ctx context.Context, id, approver, reason string) (*Withdrawal, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	withdrawal, err := a.pending(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !a.approvers[approver] && approver != withdrawal.Requester {
+		return nil, ErrNotApprover
+	}
+
+	if a.releasing[id] {
+		return nil, ErrReleaseInProgress
+	}
+
+	withdrawal.State = ApprovalStateRejected
+	event := ApprovalEvent{Time: a.now(), Action: ApprovalActionReject, Actor: approver, Reason: reason}
+	if withdrawal.Tx != nil {
+		event.TxID = withdrawal.Tx.ID
+	}
+	a.record(withdrawal, event)
+	return withdrawal.copy(), nil
+}
+
+// Release builds the approved withdrawal id, signs it with privateKey and broadcasts it on behalf of operator. A key
+// that does not sign for the sender of the withdrawal is ErrKeyMismatch. The approvers are passed on to the building
+// and the signing, see policy.WithApprovals. When the broadcast fails the withdrawal stays approved, and the next
+// Release sends the same transaction again.
+func (a *Approvals) Release(ctx context.Context, id, operator, privateKey string) (*Withdrawal, error) {
+	a.mu.Lock()
+	withdrawal, err := a.pending(id)
+	if err == nil && withdrawal.State != ApprovalStateApproved {
+		err = ErrQuorumNotReached
+	}
+	if err == nil && a.releasing[id] {
+		err = ErrReleaseInProgress
+	}
+	if err == nil {
+		err = a.checkKey(ctx, withdrawal, privateKey)
+	}
+	if err != nil {
+		a.mu.Unlock()
+		return nil, err
+	}
+
+	a.releasing[id] = true
+	release := withdrawal.copy()
+	a.mu.Unlock()
+
+	tx, txID, err := a.release(ctx, release, privateKey)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.releasing, id)
+	if tx != nil {
+		withdrawal.Tx = tx
+	}
+
+	if err != nil {
+		a.record(withdrawal, ApprovalEvent{Time: a.now(), Action: ApprovalActionReleaseFailed, Actor: operator,
+			Err: err})
+		return nil, err
+	}
+
+	withdrawal.State = ApprovalStateReleased
+	a.record(withdrawal, ApprovalEvent{Time: a.now(), Action: ApprovalActionRelease, Actor: operator, TxID: txID})
+	return withdrawal.copy(), nil
+}
+
+// checkKey returns ErrKeyMismatch when privateKey does not sign for the sender of withdrawal.
+func (a *Approvals) checkKey(ctx context.Context, withdrawal *Withdrawal, privateKey string) error {
+	c, err := a.registry.Get(withdrawal.Chain)
+	if err != nil {
+		return err
+	}
+
+	signs, err := c.KeySignsFor(ctx, privateKey, withdrawal.Request.From)
+	if err != nil {
+		return err
+	}
+
+	if !signs {
+		return ErrKeyMismatch
+	}
+	return nil
+}
+
+// release returns the signed transaction of withdrawal, once there is one, and its ID when it was broadcast.
+func (a *Approvals) release(ctx context.Context, withdrawal *Withdrawal, privateKey string) (*Tx, string, error) {
+	c, err := a.registry.Get(withdrawal.Chain)
+	if err != nil {
+		return nil, "", err
+	}
+
+	tx := withdrawal.Tx
+	if tx == nil {
+		ctx := policy.WithApprovals(ctx, withdrawal.Approvals...)
+		tx, err = c.BuildTransfer(ctx, withdrawal.Request)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if err = c.Sign(ctx, tx, privateKey); err != nil {
+			return nil, "", err
+		}
+	}
+
+	txID, err := c.Broadcast(ctx, tx)
+	return tx, txID, err
+}
+
+// Get returns the withdrawal id.
+func (a *Approvals) Get(id string) (*Withdrawal, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	withdrawal, ok := a.withdrawals[id]
+	if !ok {
+		return nil, ErrWithdrawalNotFound
+	}
+
+	a.expire(withdrawal)
+	return withdrawal.copy(), nil
+}
+
+// Pending returns the withdrawals waiting for approvals or for their release, oldest first.
+func (a *Approvals) Pending() []*Withdrawal {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	withdrawals := []*Withdrawal{}
+	for _, withdrawal := range a.withdrawals {
+		a.expire(withdrawal)
+		if withdrawal.State == ApprovalStatePending || withdrawal.State == ApprovalStateApproved {
+			withdrawals = append(withdrawals, withdrawal.copy())
+		}
+	}
+
+	sort.Slice(withdrawals, func(i, j int) bool {
+		return withdrawals[i].CreatedAt.Before(withdrawals[j].CreatedAt)
+	})
+	return withdrawals
+}
+
+// pending returns the withdrawal id while it can still be approved, rejected or released.
+func (a *Approvals) pending(id string) (*Withdrawal, error) {
+	withdrawal, ok := a.withdrawals[id]
+	if !ok {
+		return nil, ErrWithdrawalNotFound
+	}
+
+	if a.expire(withdrawal) {
+		return nil, ErrWithdrawalExpired
+	}
+
+	if withdrawal.State != ApprovalStatePending && withdrawal.State != ApprovalStateApproved {
+		return nil, ErrWithdrawalClosed
+	}
+	return withdrawal, nil
+}
+
+// expire moves withdrawal to the expired state once it is past ExpiresAt without a signed transaction, and reports
+// whether it is expired.
+func (a *Approvals) expire(withdrawal *Withdrawal) bool {
+	switch withdrawal.State {
+	case ApprovalStateExpired:
+		return true
+	case ApprovalStatePending, ApprovalStateApproved:
+	default:
+		return false
+	}
+
+	now := a.now()
+	if withdrawal.ExpiresAt.IsZero() || withdrawal.Tx != nil || now.Before(withdrawal.ExpiresAt) ||
+		a.releasing[withdrawal.ID] {
+		return false
+	}
+
+	withdrawal.State = ApprovalStateExpired
+	a.record(withdrawal, ApprovalEvent{Time: now, Action: ApprovalActionExpire})
+	return true
+}
+
+func (a *Approvals) checkQuorum(withdrawal *Withdrawal) {
+	if withdrawal.State == ApprovalStatePending && len(withdrawal.Approvals) >= a.policy.Quorum {
+		withdrawal.State = ApprovalStateApproved
+	}
+}
+
+func (a *Approvals) record(withdrawal *Withdrawal, event ApprovalEvent) {
+	event.Withdrawal = withdrawal.ID
+	withdrawal.History = append(withdrawal.History, event)
+	a.audit(event)
+}
+
+func (w *Withdrawal) copy() *Withdrawal {
+	withdrawal := *w
+	withdrawal.Approvals = append([]string(nil), w.Approvals...)
+	withdrawal.History = append([]ApprovalEvent(nil), w.History...)
+	if w.Tx != nil {
+		tx := *w.Tx
+		withdrawal.Tx = &tx
+	}
+	return &withdrawal
+}
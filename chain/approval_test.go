@@ -0,0 +1,199 @@
+package chain
+
+import (
+	"context"
+	"demo/eth"
+	"demo/policy"
+	"encoding/hex"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func getApprovals(t *testing.T, policy ApprovalPolicy) (*Approvals, *ETH, *eth.SimulatedBackend, *[]ApprovalEvent) {
+	c, backend := getETH(t)
+	events := []ApprovalEvent{}
+	approvals, err := NewApprovals(NewRegistry(c), policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	approvals.SetAudit(func(event ApprovalEvent) {
+		events = append(events, event)
+	})
+	return approvals, c, backend, &events
+}
+
+func approvalActions(events []ApprovalEvent) []string {
+	actions := []string{}
+	for _, event := range events {
+		actions = append(actions, event.Action+" "+event.Actor)
+	}
+	return actions
+}
+
+func Test_NewApprovals(t *testing.T) {
+	registry := NewRegistry()
+	for _, policy := range []ApprovalPolicy{
+		{Approvers: []string{"alice", "bob"}},
+		{Approvers: []string{"alice", "bob"}, Quorum: 3},
+		{Approvers: []string{"alice", "alice"}, Quorum: 2},
+		{Quorum: 1},
+	} {
+		_, err := NewApprovals(registry, policy)
+		assert.ErrorIs(t, err, ErrInvalidQuorum, "%v", policy)
+	}
+
+	_, err := NewApprovals(registry, ApprovalPolicy{Approvers: []string{"alice", "bob"}, Quorum: 2})
+	assert.NoError(t, err)
+}
+
+func Test_Approvals(t *testing.T) {
+	ctx := context.Background()
+	approvals, c, backend, events := getApprovals(t, ApprovalPolicy{Approvers: []string{"alice", "bob", "carol"},
+		Quorum: 2, Expiry: time.Hour})
+	c.svc.SetPolicy(policy.New(policy.Rules{Approvals: []policy.Approval{
+		{Threshold: decimal.NewFromInt(1), Approvals: 2}}, Approvers: []string{"alice", "bob"}}))
+
+	request := TransferRequest{From: ethOwner1Addr, To: ethOwner2Addr, Amount: decimal.RequireFromString("1.5")}
+	_, err := approvals.Submit(ctx, "sol", "dave", request)
+	assert.ErrorIs(t, err, ErrUnknownChain)
+	_, err = approvals.Submit(ctx, "eth", "dave", TransferRequest{From: ethOwner1Addr, To: "0x68dB32"})
+	assert.ErrorIs(t, err, ErrInvalidAddress)
+
+	withdrawal, err := approvals.Submit(ctx, "eth", "dave", request)
+	assert.NoError(t, err)
+	assert.Equal(t, ApprovalStatePending, withdrawal.State)
+	assert.Equal(t, withdrawal.CreatedAt.Add(time.Hour), withdrawal.ExpiresAt)
+	_, err = approvals.Release(ctx, withdrawal.ID, "carol", ethOwner1PrivateKey)
+	assert.ErrorIs(t, err, ErrQuorumNotReached)
+
+	_, err = approvals.Approve(ctx, withdrawal.ID, "mallory")
+	assert.ErrorIs(t, err, ErrNotApprover)
+	_, err = approvals.Approve(ctx, "unknown", "alice")
+	assert.ErrorIs(t, err, ErrWithdrawalNotFound)
+	withdrawal, err = approvals.Approve(ctx, withdrawal.ID, "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, ApprovalStatePending, withdrawal.State)
+	_, err = approvals.Approve(ctx, withdrawal.ID, "alice")
+	assert.ErrorIs(t, err, ErrAlreadyApproved)
+	withdrawal, err = approvals.Approve(ctx, withdrawal.ID, "bob")
+	assert.NoError(t, err)
+	assert.Equal(t, ApprovalStateApproved, withdrawal.State)
+	assert.Equal(t, []string{"alice", "bob"}, withdrawal.Approvals)
+	assert.Len(t, approvals.Pending(), 1)
+
+	withdrawal, err = approvals.Release(ctx, withdrawal.ID, "carol", ethOwner1PrivateKey)
+	assert.NoError(t, err, "the approvals are passed on to the policy of the service")
+	assert.Equal(t, ApprovalStateReleased, withdrawal.State)
+	assert.NotEmpty(t, withdrawal.Tx.ID)
+	backend.Commit()
+	balance, err := c.Balance(ctx, ethOwner2Addr, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "101.5", balance.String())
+
+	_, err = approvals.Approve(ctx, withdrawal.ID, "carol")
+	assert.ErrorIs(t, err, ErrWithdrawalClosed)
+	assert.Empty(t, approvals.Pending())
+	assert.Equal(t, []string{"submit dave", "approve alice", "approve bob", "release carol"},
+		approvalActions(withdrawal.History))
+	assert.Equal(t, withdrawal.History, *events)
+	assert.Equal(t, withdrawal.Tx.ID, withdrawal.History[3].TxID)
+
+	withdrawal, err = approvals.Submit(ctx, "eth", "alice", request)
+	assert.NoError(t, err)
+	_, err = approvals.Approve(ctx, withdrawal.ID, "alice")
+	assert.ErrorIs(t, err, ErrSelfApproval)
+	withdrawal, err = approvals.Reject(ctx, withdrawal.ID, "bob", "unknown destination")
+	assert.NoError(t, err)
+	assert.Equal(t, ApprovalStateRejected, withdrawal.State)
+	assert.Equal(t, "unknown destination", withdrawal.History[1].Reason)
+	_, err = approvals.Release(ctx, withdrawal.ID, "carol", ethOwner1PrivateKey)
+	assert.ErrorIs(t, err, ErrWithdrawalClosed)
+}
+
+func Test_ApprovalsRelease(t *testing.T) {
+	ctx := context.Background()
+	approvals, c, backend, _ := getApprovals(t, ApprovalPolicy{Approvers: []string{"alice", "bob"}, Quorum: 1})
+	c.svc.SetPolicy(policy.New(policy.Rules{Approvals: []policy.Approval{
+		{Threshold: decimal.NewFromInt(1), Approvals: 2}}, Approvers: []string{"alice", "bob"}}))
+
+	withdrawal, err := approvals.Submit(ctx, "eth", "dave", TransferRequest{From: ethOwner1Addr, To: ethOwner2Addr,
+		Amount: decimal.NewFromInt(2)})
+	assert.NoError(t, err)
+	assert.True(t, withdrawal.ExpiresAt.IsZero())
+	_, err = approvals.Approve(ctx, withdrawal.ID, "alice")
+	assert.NoError(t, err)
+
+	// the policy of the service asks for more approvals than the quorum
+	_, err = approvals.Release(ctx, withdrawal.ID, "carol", ethOwner1PrivateKey)
+	assert.ErrorIs(t, err, policy.ErrApprovalRequired)
+	withdrawal, err = approvals.Get(withdrawal.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, ApprovalStateApproved, withdrawal.State)
+	assert.Nil(t, withdrawal.Tx)
+	assert.Equal(t, ApprovalActionReleaseFailed, withdrawal.History[2].Action)
+	assert.ErrorIs(t, withdrawal.History[2].Err, policy.ErrApprovalRequired)
+
+	withdrawal, err = approvals.Approve(ctx, withdrawal.ID, "bob")
+	assert.NoError(t, err)
+	key, _ := crypto.GenerateKey()
+	_, err = approvals.Release(ctx, withdrawal.ID, "carol", hex.EncodeToString(crypto.FromECDSA(key)))
+	assert.ErrorIs(t, err, ErrKeyMismatch, "the key does not sign for the sender")
+	withdrawal, err = approvals.Release(ctx, withdrawal.ID, "carol", ethOwner1PrivateKey)
+	assert.NoError(t, err)
+	backend.Commit()
+	transaction, err := c.Transaction(ctx, withdrawal.Tx.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", transaction.Transfers[0].Amount.String())
+}
+
+func Test_ApprovalsReject(t *testing.T) {
+	ctx := context.Background()
+	approvals, _, _, _ := getApprovals(t, ApprovalPolicy{Approvers: []string{"alice", "bob"}, Quorum: 1})
+	request := TransferRequest{From: ethOwner1Addr, To: ethOwner2Addr, Amount: decimal.NewFromInt(1)}
+
+	withdrawal, err := approvals.Submit(ctx, "eth", "dave", request)
+	assert.NoError(t, err)
+	approvals.releasing[withdrawal.ID] = true
+	_, err = approvals.Reject(ctx, withdrawal.ID, "bob", "")
+	assert.ErrorIs(t, err, ErrReleaseInProgress)
+
+	// the transaction of a failed broadcast may still be sent
+	delete(approvals.releasing, withdrawal.ID)
+	approvals.withdrawals[withdrawal.ID].Tx = &Tx{Chain: "eth", ID: "0x01", Signed: "0x02"}
+	withdrawal, err = approvals.Reject(ctx, withdrawal.ID, "bob", "wrong amount")
+	assert.NoError(t, err)
+	assert.Equal(t, ApprovalStateRejected, withdrawal.State)
+	assert.Equal(t, "0x01", withdrawal.History[1].TxID)
+}
+
+func Test_ApprovalsExpiry(t *testing.T) {
+	ctx := context.Background()
+	approvals, _, _, events := getApprovals(t, ApprovalPolicy{Approvers: []string{"alice", "bob"}, Quorum: 2,
+		Expiry: time.Hour})
+	clock := time.Unix(1700000000, 0)
+	approvals.now = func() time.Time { return clock }
+
+	request := TransferRequest{From: ethOwner1Addr, To: ethOwner2Addr, Amount: decimal.NewFromInt(1)}
+	withdrawal, err := approvals.Submit(ctx, "eth", "dave", request)
+	assert.NoError(t, err)
+	_, err = approvals.Approve(ctx, withdrawal.ID, "alice")
+	assert.NoError(t, err)
+	later, err := approvals.Submit(ctx, "eth", "dave", request)
+	assert.NoError(t, err)
+
+	clock = clock.Add(time.Hour)
+	_, err = approvals.Approve(ctx, withdrawal.ID, "bob")
+	assert.ErrorIs(t, err, ErrWithdrawalExpired)
+	withdrawal, err = approvals.Get(withdrawal.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, ApprovalStateExpired, withdrawal.State)
+	assert.Empty(t, approvals.Pending())
+
+	_, err = approvals.Reject(ctx, later.ID, "bob", "")
+	assert.ErrorIs(t, err, ErrWithdrawalExpired)
+	assert.Equal(t, []string{"submit dave", "approve alice", "submit dave", "expire ", "expire "},
+		approvalActions(*events))
+}
@@ -0,0 +1,242 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"demo/amount"
+	"demo/btc"
+	"encoding/hex"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/shopspring/decimal"
+	"math/big"
+	"time"
+)
+
+// BTC is the Chain of a btc.Service. Only the bitcoin balance of an address is known, and BuildTransfer spends the
+// UTXOs of the request since the node does not index addresses.
+type BTC struct {
+	svc       *btc.Service
+	feePolicy FeePolicy
+}
+
+func NewBTC(svc *btc.Service) *BTC {
+	return &BTC{svc: svc}
+}
+
+// SetFeePolicy bounds the whole fee of BuildTransfer; policy.Default is paid by requests without a fee.
+func (c *BTC) SetFeePolicy(policy FeePolicy) {
+	c.feePolicy = policy
+}
+
+func (c *BTC) Name() string {
+	return "btc"
+}
+
+func (c *BTC) ValidateAddress(ctx context.Context, address string) bool {
+	return btc.ValidateAddress(ctx, address)
+}
+
+func (c *BTC) AddressFromPublicKey(ctx context.Context, publicKey string) (string, error) {
+	return c.svc.CreateAddressByPubKey(ctx, publicKey)
+}
+
+// KeySignsFor reports whether address is the P2PKH address of the compressed or the uncompressed public key of the
+// WIF privateKey, the addresses SignPSBT spends from.
+func (c *BTC) KeySignsFor(ctx context.Context, privateKey, address string) (bool, error) {
+	wif, err := btcutil.DecodeWIF(privateKey)
+	if err != nil {
+		return false, err
+	}
+
+	publicKey := wif.PrivKey.PubKey()
+	for _, key := range [][]byte{publicKey.SerializeCompressed(), publicKey.SerializeUncompressed()} {
+		keyAddress, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(key), c.svc.Params())
+		if err != nil {
+			return false, err
+		}
+
+		if keyAddress.EncodeAddress() == address {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Balance sums the unspent outputs of address with scantxoutset. Bitcoin has no other assets, so a non-empty asset
+// is ErrNotSupported.
+func (c *BTC) Balance(ctx context.Context, address, asset string) (decimal.Decimal, error) {
+	if len(asset) > 0 {
+		return decimal.Zero, ErrNotSupported
+	}
+
+	if !c.ValidateAddress(ctx, address) {
+		return decimal.Zero, ErrInvalidAddress
+	}
+	return c.svc.Balance(ctx, address)
+}
+
+func (c *BTC) BlockHeight(ctx context.Context) (uint64, error) {
+	height, err := c.svc.CurrentBlockHeight(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(height), nil
+}
+
+func (c *BTC) Block(ctx context.Context, height uint64) (*Block, error) {
+	block, err := c.svc.Block(ctx, int64(height))
+	if err != nil {
+		return nil, err
+	}
+	return btcBlock(height, block), nil
+}
+
+func (c *BTC) Transaction(ctx context.Context, id string) (*Transaction, error) {
+	transaction, err := c.svc.Transaction(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return btcTransaction(transaction)
+}
+
+// BuildTransfer spends the single UTXO of request, paying the fee and the change back to request.From.
+func (c *BTC) BuildTransfer(ctx context.Context, request TransferRequest) (*Tx, error) {
+	if !c.ValidateAddress(ctx, request.From) || !c.ValidateAddress(ctx, request.To) {
+		return nil, ErrInvalidAddress
+	}
+
+	fee, err := c.feePolicy.fee(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(request.Asset) > 0 || len(request.UTXOs) != 1 || fee.IsZero() {
+		return nil, ErrNotSupported
+	}
+
+	utxo := request.UTXOs[0]
+	envelope, err := c.svc.ExportTx(ctx, request.From, request.To, request.Amount, fee, btc.TransactionOutPut{
+		TXId:   utxo.TxID,
+		Value:  utxo.Value,
+		N:      utxo.N,
+		Hex:    utxo.Script,
+		PrevTx: utxo.PrevTx,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Chain: c.Name(), Summary: envelope.Summary, Unsigned: envelope.PSBT}, nil
+}
+
+func (c *BTC) Sign(ctx context.Context, tx *Tx, privateKey string) error {
+	signed, err := c.svc.SignPSBT(ctx, tx.Unsigned, privateKey)
+	if err != nil {
+		return err
+	}
+
+	msgTx, err := decodeBTCTx(signed)
+	if err != nil {
+		return err
+	}
+
+	tx.Signed, tx.ID = signed, msgTx.TxHash().String()
+	return nil
+}
+
+func (c *BTC) Broadcast(ctx context.Context, tx *Tx) (string, error) {
+	if len(tx.Signed) == 0 {
+		return "", ErrNotSigned
+	}
+
+	msgTx, err := decodeBTCTx(tx.Signed)
+	if err != nil {
+		return "", err
+	}
+	return c.svc.BroadcastTx(ctx, msgTx)
+}
+
+func decodeBTCTx(rawTx string) (*wire.MsgTx, error) {
+	data, err := hex.DecodeString(rawTx)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	if err = tx.Deserialize(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// btcBlock groups the outputs of block by transaction, keeping the order of the block.
+func btcBlock(height uint64, block *btc.Block) *Block {
+	result := Block{
+		Height: height,
+		Hash:   block.Block.BlockHash().String(),
+		Time:   block.Block.Header.Timestamp,
+	}
+
+	transactions := map[string]*Transaction{}
+	for _, output := range block.Transactions {
+		transaction, ok := transactions[output.TxId]
+		if !ok {
+			transaction = &Transaction{
+				ID:          output.TxId,
+				BlockHeight: height,
+				BlockHash:   result.Hash,
+				Time:        time.Unix(output.BlockTime, 0),
+				State:       btcState(output.State),
+				Fee:         output.Fee,
+			}
+			transactions[output.TxId] = transaction
+			result.Transactions = append(result.Transactions, transaction)
+		}
+		transaction.Transfers = append(transaction.Transfers, &Transfer{To: firstAddress(output.Address), Amount: output.Value})
+	}
+	return &result
+}
+
+// btcState maps the state of a btc transaction, unknown states to TransactionStateDefault.
+func btcState(state btc.TransactionSate) TransactionState {
+	switch state {
+	case btc.TransactionSateSuccess:
+		return TransactionStateSuccess
+	case btc.TransactionSateFail:
+		return TransactionStateFail
+	case btc.TransactionSatePending:
+		return TransactionStatePending
+	}
+	return TransactionStateDefault
+}
+
+func btcTransaction(transaction *btc.RawTransactionInfo) (*Transaction, error) {
+	result := Transaction{
+		ID:          transaction.Result.Txid,
+		BlockHeight: uint64(transaction.BlockHeight),
+		BlockHash:   transaction.Result.BlockHash,
+		Time:        time.Unix(transaction.Result.Blocktime, 0),
+		State:       btcState(transaction.State),
+		Fee:         transaction.Fee,
+	}
+
+	for _, output := range transaction.Result.Vout {
+		value, err := btcutil.NewAmount(output.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Transfers = append(result.Transfers, &Transfer{
+			To:     firstAddress(output.ScriptPubKey.Addresses),
+			Amount: amount.FromBase(big.NewInt(int64(value)), amount.Bitcoin),
+		})
+	}
+	return &result, nil
+}
+
+func firstAddress(addresses []string) string {
+	if len(addresses) == 0 {
+		return ""
+	}
+	return addresses[0]
+}
@@ -0,0 +1,221 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"demo/btc"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_BTCTransfer(t *testing.T) {
+	ctx := context.Background()
+	// no connection is made until a node call
+	svc, _ := btc.NewService("127.0.0.1:18332", "user", "password")
+	c := NewBTC(svc)
+	var wallet Chain = c
+	wif, from, utxo := getBTCKey(t)
+	to := "mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn"
+
+	assert.True(t, wallet.ValidateAddress(ctx, to))
+	assert.False(t, wallet.ValidateAddress(ctx, "mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRf"))
+	signs, err := wallet.KeySignsFor(ctx, wif, from)
+	assert.NoError(t, err)
+	assert.True(t, signs)
+	signs, err = wallet.KeySignsFor(ctx, wif, to)
+	assert.NoError(t, err)
+	assert.False(t, signs)
+	_, err = wallet.Balance(ctx, to, "omni")
+	assert.ErrorIs(t, err, ErrNotSupported)
+	_, err = wallet.Balance(ctx, to+"x", "")
+	assert.ErrorIs(t, err, ErrInvalidAddress)
+
+	request := TransferRequest{
+		From:   from,
+		To:     to,
+		Amount: decimal.RequireFromString("0.004"),
+		Fee:    decimal.RequireFromString("0.0001"),
+		UTXOs:  []UTXO{utxo},
+	}
+	tx, err := wallet.BuildTransfer(ctx, request)
+	assert.NoError(t, err)
+	assert.Equal(t, "btc", tx.Chain)
+	assert.Contains(t, tx.Summary, "send 0.004 BTC")
+	assert.Empty(t, tx.Signed)
+
+	assert.NoError(t, wallet.Sign(ctx, tx, wif))
+	decoded, err := svc.DecodeRawTx(ctx, tx.Signed)
+	assert.NoError(t, err)
+	assert.Equal(t, decoded.TxId, tx.ID)
+	assert.Equal(t, []string{to}, decoded.Outputs[0].Addresses)
+	assert.Equal(t, "0.0059", decoded.Outputs[1].Value.String())
+
+	c.SetFeePolicy(FeePolicy{Default: decimal.RequireFromString("0.0002"), Max: decimal.RequireFromString("0.0005")})
+	request.Fee = decimal.Zero
+	tx, err = wallet.BuildTransfer(ctx, request)
+	assert.NoError(t, err)
+	assert.Contains(t, tx.Summary, "fee 0.0002 BTC")
+	request.Fee = decimal.RequireFromString("0.001")
+	_, err = wallet.BuildTransfer(ctx, request)
+	assert.ErrorIs(t, err, ErrFeeTooHigh)
+	c.SetFeePolicy(FeePolicy{})
+
+	request.Asset = "omni"
+	_, err = wallet.BuildTransfer(ctx, request)
+	assert.ErrorIs(t, err, ErrNotSupported)
+	request.Asset, request.UTXOs = "", nil
+	_, err = wallet.BuildTransfer(ctx, request)
+	assert.ErrorIs(t, err, ErrNotSupported)
+	request.To = "0x68dB32D26d9529B2a142927c6f1af248fc6Ba7e9"
+	_, err = wallet.BuildTransfer(ctx, request)
+	assert.ErrorIs(t, err, ErrInvalidAddress)
+	_, err = wallet.Broadcast(ctx, &Tx{})
+	assert.ErrorIs(t, err, ErrNotSigned)
+}
+
+// scanClient answers scantxoutset with a fixed total, other calls are not made.
+type scanClient struct {
+	btc.Client
+	total string
+}
+
+func (c scanClient) RawRequest(ctx context.Context, method string, params []json.RawMessage) (json.RawMessage, error) {
+	if method != "scantxoutset" {
+		return nil, errors.New("unexpected call " + method)
+	}
+	return json.RawMessage(`{"success":true,"total_amount":` + c.total + `}`), nil
+}
+
+func Test_BTCBalance(t *testing.T) {
+	wallet := NewBTC(btc.NewClientService(scanClient{total: "0.0123"}))
+	balance, err := wallet.Balance(context.Background(), "mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.0123", balance.String())
+}
+
+func Test_BTCState(t *testing.T) {
+	assert.Equal(t, TransactionStateSuccess, btcState(btc.TransactionSateSuccess))
+	assert.Equal(t, TransactionStateFail, btcState(btc.TransactionSateFail))
+	assert.Equal(t, TransactionStatePending, btcState(btc.TransactionSatePending))
+	assert.Equal(t, TransactionStateDefault, btcState(btc.TransactionSate(9)))
+}
+
+// txClient answers getrawtransaction from txs and getblockheader with height, other calls are not made.
+type txClient struct {
+	btc.Client
+	txs    map[string]*btcjson.TxRawResult
+	height int32
+}
+
+func (c txClient) GetRawTransactionVerbose(ctx context.Context, txHash *chainhash.Hash) (*btcjson.TxRawResult, error) {
+	tx, ok := c.txs[txHash.String()]
+	if !ok {
+		return nil, errors.New("no such transaction " + txHash.String())
+	}
+	return tx, nil
+}
+
+func (c txClient) RawRequest(ctx context.Context, method string, params []json.RawMessage) (json.RawMessage, error) {
+	if method != "getblockheader" {
+		return nil, errors.New("unexpected call " + method)
+	}
+	header, err := json.Marshal(btcjson.GetBlockHeaderVerboseResult{Height: c.height})
+	return header, err
+}
+
+func Test_BTCTransaction(t *testing.T) {
+	funding := "1111111111111111111111111111111111111111111111111111111111111111"
+	spend := "4a5e1e4b4a5e1e4b4a5e1e4b4a5e1e4b4a5e1e4b4a5e1e4b4a5e1e4b4a5e1e4b"
+	wallet := NewBTC(btc.NewClientService(txClient{height: 7, txs: map[string]*btcjson.TxRawResult{
+		funding: {Txid: funding, Vin: []btcjson.Vin{{Coinbase: "03a0ae0a"}}, Vout: []btcjson.Vout{{Value: 0.01, N: 0}}},
+		spend: {
+			Txid:          spend,
+			BlockHash:     "0000abcd",
+			Blocktime:     1600000000,
+			Confirmations: 6,
+			Vin:           []btcjson.Vin{{Txid: funding, Vout: 0}},
+			Vout: []btcjson.Vout{
+				{Value: 0.004, N: 0, ScriptPubKey: btcjson.ScriptPubKeyResult{Addresses: []string{"mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn"}}},
+				{Value: 0.0059, N: 1},
+			},
+		},
+	}}))
+
+	transaction, err := wallet.Transaction(context.Background(), spend)
+	assert.NoError(t, err)
+	assert.Equal(t, spend, transaction.ID)
+	assert.Equal(t, uint64(7), transaction.BlockHeight)
+	assert.Equal(t, "0000abcd", transaction.BlockHash)
+	assert.Equal(t, "0.0001", transaction.Fee.String())
+	assert.Equal(t, TransactionStateSuccess, transaction.State)
+	assert.Equal(t, time.Unix(1600000000, 0), transaction.Time)
+	assert.Len(t, transaction.Transfers, 2)
+	assert.Equal(t, "mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn", transaction.Transfers[0].To)
+	assert.Equal(t, "0.004", transaction.Transfers[0].Amount.String())
+	assert.Empty(t, transaction.Transfers[1].To)
+	assert.Equal(t, "0.0059", transaction.Transfers[1].Amount.String())
+}
+
+func Test_BTCBlock(t *testing.T) {
+	msgBlock := wire.NewMsgBlock(&wire.BlockHeader{Timestamp: time.Unix(1600000000, 0)})
+	block := btcBlock(9, &btc.Block{
+		Block: msgBlock,
+		Transactions: []*btc.TransactionInfo{
+			{TxId: "a", Value: decimal.RequireFromString("1"), Address: []string{"x"}, State: btc.TransactionSatePending},
+			{TxId: "b", Value: decimal.RequireFromString("2")},
+			{TxId: "a", Value: decimal.RequireFromString("3"), Address: []string{"y"}, State: btc.TransactionSatePending},
+		},
+	})
+	assert.Equal(t, uint64(9), block.Height)
+	assert.Equal(t, msgBlock.BlockHash().String(), block.Hash)
+	assert.Equal(t, time.Unix(1600000000, 0), block.Time)
+	assert.Len(t, block.Transactions, 2)
+	assert.Equal(t, "a", block.Transactions[0].ID)
+	assert.Equal(t, TransactionStatePending, block.Transactions[0].State)
+	assert.Equal(t, block.Hash, block.Transactions[0].BlockHash)
+	assert.Len(t, block.Transactions[0].Transfers, 2)
+	assert.Equal(t, "y", block.Transactions[0].Transfers[1].To)
+	assert.Equal(t, "3", block.Transactions[0].Transfers[1].Amount.String())
+	assert.Equal(t, "b", block.Transactions[1].ID)
+}
+
+// getBTCKey returns a testnet WIF, its P2PKH address and an output of 0.01 BTC it can spend.
+func getBTCKey(t *testing.T) (string, string, UTXO) {
+	keyByte, _ := hex.DecodeString("f1b3f8e0d52caec13491368449ab8d90f3d222a3e485aa7f02591bbceb5efba5")
+	privateKey, _ := btcec.PrivKeyFromBytes(btcec.S256(), keyByte)
+	wif, err := btcutil.NewWIF(privateKey, &chaincfg.TestNet3Params, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	address, _ := btcutil.NewAddressPubKeyHash(btcutil.Hash160(wif.SerializePubKey()), &chaincfg.TestNet3Params)
+	pkScript, _ := txscript.PayToAddrScript(address)
+	prevTx := btc.NewTx()
+	prevTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil))
+	prevTx.AddTxOut(wire.NewTxOut(5000, pkScript))
+	prevTx.AddTxOut(wire.NewTxOut(1000000, pkScript))
+	var buf bytes.Buffer
+	if err = prevTx.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	return wif.String(), address.EncodeAddress(), UTXO{
+		TxID:   prevTx.TxHash().String(),
+		N:      1,
+		Value:  decimal.RequireFromString("0.01"),
+		Script: hex.EncodeToString(pkScript),
+		PrevTx: hex.EncodeToString(buf.Bytes()),
+	}
+}
@@ -0,0 +1,158 @@
+// Package chain puts the btc and eth services behind one wallet interface, so that application code does not
+// branch on the chain. Each chain is an adapter implementing Chain; supporting a new chain means adding one.
+package chain
+
+import (
+	"context"
+	"errors"
+	"github.com/shopspring/decimal"
+	"sort"
+	"time"
+)
+
+var (
+	ErrNotSupported   = errors.New("the operation is not supported on this chain")
+	ErrInvalidAddress = errors.New("invalid address")
+	ErrUnknownChain   = errors.New("unknown chain")
+	ErrNotSigned      = errors.New("the transaction is not signed")
+	ErrFeeTooHigh     = errors.New("the fee is above the maximum of the fee policy")
+)
+
+type TransactionState int32
+
+const (
+	TransactionStateDefault TransactionState = 0
+	TransactionStateSuccess TransactionState = 1
+	TransactionStateFail    TransactionState = 2
+	TransactionStatePending TransactionState = 3
+)
+
+// Chain is a wallet over one blockchain. Amounts are in the display unit of the asset, ETH, BTC or the token.
+type Chain interface {
+	// Name is the key of the chain in a Registry, e.g. "eth" or "btc".
+	Name() string
+	ValidateAddress(ctx context.Context, address string) bool
+	// AddressFromPublicKey derives the address of a 0x prefixed compressed public key.
+	AddressFromPublicKey(ctx context.Context, publicKey string) (string, error)
+	// KeySignsFor reports whether privateKey, in the format of Sign, signs for address.
+	KeySignsFor(ctx context.Context, privateKey, address string) (bool, error)
+	// Balance of address in the native coin, or in the token at asset.
+	Balance(ctx context.Context, address, asset string) (decimal.Decimal, error)
+	BlockHeight(ctx context.Context) (uint64, error)
+	Block(ctx context.Context, height uint64) (*Block, error)
+	Transaction(ctx context.Context, id string) (*Transaction, error)
+	// BuildTransfer creates an unsigned transaction for request.
+	BuildTransfer(ctx context.Context, request TransferRequest) (*Tx, error)
+	// Sign signs tx with a private key in the chain's usual format: hex for eth, WIF for btc.
+	Sign(ctx context.Context, tx *Tx, privateKey string) error
+	// Broadcast sends a signed tx and returns its ID.
+	Broadcast(ctx context.Context, tx *Tx) (string, error)
+}
+
+type TransferRequest struct {
+	From   string
+	To     string
+	Asset  string //token contract, empty for the native coin
+	Amount decimal.Decimal
+	Fee    decimal.Decimal //btc: the whole fee; eth: max fee per gas, suggested by the node when zero
+	UTXOs  []UTXO          //outputs to spend, UTXO chains only
+}
+
+// FeePolicy bounds the fees of the transfers a Chain builds, in the unit of TransferRequest.Fee. Default is used for
+// requests without a fee; zero values do not apply.
+type FeePolicy struct {
+	Default decimal.Decimal
+	Max     decimal.Decimal
+}
+
+// fee returns the fee of request under p, or ErrFeeTooHigh.
+func (p FeePolicy) fee(request TransferRequest) (decimal.Decimal, error) {
+	fee := request.Fee
+	if fee.IsZero() {
+		fee = p.Default
+	}
+
+	if p.Max.IsPositive() && fee.GreaterThan(p.Max) {
+		return fee, ErrFeeTooHigh
+	}
+	return fee, nil
+}
+
+type UTXO struct {
+	TxID   string
+	N      uint32
+	Value  decimal.Decimal
+	Script string //hex pkScript
+	PrevTx string //hex of the whole transaction TxID, fetched from the node when empty
+}
+
+// Tx is a transaction between BuildTransfer and Broadcast. Unsigned is the chain's portable unsigned form (RLP hex
+// for eth, a base64 PSBT for btc) and Signed the hex raw transaction.
+type Tx struct {
+	Chain    string
+	Summary  string
+	Unsigned string
+	Signed   string
+	ID       string
+}
+
+type Block struct {
+	Height       uint64
+	Hash         string
+	Time         time.Time
+	Transactions []*Transaction
+}
+
+// Transaction is a transaction as read from the chain. An eth transaction has one transfer, a btc transaction one
+// per output.
+type Transaction struct {
+	ID          string
+	BlockHeight uint64
+	BlockHash   string
+	Time        time.Time
+	State       TransactionState
+	Fee         decimal.Decimal
+	Transfers   []*Transfer
+}
+
+type Transfer struct {
+	From   string //empty when the chain does not say, e.g. btc inputs
+	To     string
+	Asset  string
+	Amount decimal.Decimal
+}
+
+// Registry finds the Chain for a name.
+type Registry struct {
+	chains map[string]Chain
+}
+
+func NewRegistry(chains ...Chain) *Registry {
+	registry := Registry{chains: map[string]Chain{}}
+	for _, chain := range chains {
+		registry.Register(chain)
+	}
+	return &registry
+}
+
+// Register adds chain, replacing a chain with the same name.
+func (r *Registry) Register(chain Chain) {
+	r.chains[chain.Name()] = chain
+}
+
+func (r *Registry) Get(name string) (Chain, error) {
+	chain, ok := r.chains[name]
+	if !ok {
+		return nil, ErrUnknownChain
+	}
+	return chain, nil
+}
+
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.chains))
+	for name := range r.chains {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,27 @@
+package chain
+
+import (
+	"demo/btc"
+	"demo/eth"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_Registry(t *testing.T) {
+	btcSvc, _ := btc.NewService("127.0.0.1:18332", "user", "password")
+	registry := NewRegistry(NewETH(eth.NewService(nil, 0, 1)), NewBTC(btcSvc))
+	assert.Equal(t, []string{"btc", "eth"}, registry.Names())
+
+	c, err := registry.Get("eth")
+	assert.NoError(t, err)
+	assert.Equal(t, "eth", c.Name())
+
+	_, err = registry.Get("sol")
+	assert.ErrorIs(t, err, ErrUnknownChain)
+
+	other := NewETH(eth.NewService(nil, 0, 1))
+	registry.Register(other)
+	c, _ = registry.Get("eth")
+	assert.Same(t, other, c)
+	assert.Len(t, registry.Names(), 2)
+}
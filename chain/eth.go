@@ -0,0 +1,220 @@
+package chain
+
+import (
+	"context"
+	"demo/eth"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/shopspring/decimal"
+)
+
+const ethTransferGasLimit = 21000
+
+// ETH is the Chain of an eth.Service.
+type ETH struct {
+	svc       *eth.Service
+	feePolicy FeePolicy
+}
+
+func NewETH(svc *eth.Service) *ETH {
+	return &ETH{svc: svc}
+}
+
+// SetFeePolicy bounds the max fee per gas of BuildTransfer. Without a default fee the node's gas price is used, and
+// it is held to policy.Max as well.
+func (c *ETH) SetFeePolicy(policy FeePolicy) {
+	c.feePolicy = policy
+}
+
+func (c *ETH) Name() string {
+	return "eth"
+}
+
+func (c *ETH) ValidateAddress(ctx context.Context, address string) bool {
+	return common.IsHexAddress(address)
+}
+
+func (c *ETH) AddressFromPublicKey(ctx context.Context, publicKey string) (string, error) {
+	return c.svc.CreateAddressByPubKey(ctx, publicKey)
+}
+
+func (c *ETH) KeySignsFor(ctx context.Context, privateKey, address string) (bool, error) {
+	key, err := eth.ParsePrivateKey(privateKey)
+	if err != nil {
+		return false, err
+	}
+	return common.IsHexAddress(address) && crypto.PubkeyToAddress(key.PublicKey) == common.HexToAddress(address), nil
+}
+
+func (c *ETH) Balance(ctx context.Context, address, asset string) (decimal.Decimal, error) {
+	if !c.ValidateAddress(ctx, address) {
+		return decimal.Zero, ErrInvalidAddress
+	}
+
+	var balance *decimal.Decimal
+	var err error
+	if len(asset) > 0 {
+		balance, err = c.svc.BalanceERC20(ctx, asset, address)
+	} else {
+		balance, err = c.svc.BalanceETH(ctx, address)
+	}
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return *balance, nil
+}
+
+func (c *ETH) BlockHeight(ctx context.Context) (uint64, error) {
+	return c.svc.CurrentBlockHeight(ctx)
+}
+
+func (c *ETH) Block(ctx context.Context, height uint64) (*Block, error) {
+	blockInfo, err := c.svc.Block(ctx, height)
+	if err != nil {
+		return nil, err
+	}
+
+	block := Block{Height: blockInfo.BlockNumber, Hash: blockInfo.Hash, Time: blockInfo.Time}
+	for _, txInfo := range blockInfo.Transactions {
+		transaction := ethTransaction(txInfo)
+		transaction.BlockHash = blockInfo.Hash
+		block.Transactions = append(block.Transactions, transaction)
+	}
+	return &block, nil
+}
+
+func (c *ETH) Transaction(ctx context.Context, id string) (*Transaction, error) {
+	txInfo, err := c.svc.Transaction(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return ethTransaction(txInfo), nil
+}
+
+// BuildTransfer creates a legacy transaction at the next nonce of request.From. Token transfers have their gas
+// limit estimated.
+func (c *ETH) BuildTransfer(ctx context.Context, request TransferRequest) (*Tx, error) {
+	if !c.ValidateAddress(ctx, request.From) || !c.ValidateAddress(ctx, request.To) {
+		return nil, ErrInvalidAddress
+	}
+
+	if len(request.UTXOs) > 0 {
+		return nil, ErrNotSupported
+	}
+
+	nonce, err := c.svc.Nonce(ctx, request.From)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Fee.IsZero() && c.feePolicy.Default.IsZero() {
+		gasPrice, err := c.svc.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, err
+		}
+		request.Fee = *gasPrice
+	}
+
+	maxFee, err := c.feePolicy.fee(request)
+	if err != nil {
+		return nil, err
+	}
+
+	createRequest := eth.CreateTransactionRequest{
+		TokenAddress: request.Asset,
+		From:         request.From,
+		To:           request.To,
+		Amount:       request.Amount.String(),
+		GasMaxFee:    maxFee.String(),
+		Nonce:        nonce,
+	}
+	if len(request.Asset) == 0 {
+		createRequest.GasLimit = ethTransferGasLimit
+	}
+
+	envelope, err := c.svc.ExportTransaction(ctx, createRequest)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Chain: c.Name(), Summary: envelope.Summary, Unsigned: envelope.UnsignedTx}, nil
+}
+
+func (c *ETH) Sign(ctx context.Context, tx *Tx, privateKey string) error {
+	unsigned, err := decodeETHTx(tx.Unsigned)
+	if err != nil {
+		return err
+	}
+
+	signed, err := c.svc.SignTransaction(ctx, unsigned, privateKey)
+	if err != nil {
+		return err
+	}
+
+	data, err := signed.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	tx.Signed, tx.ID = hexutil.Encode(data), signed.Hash().Hex()
+	return nil
+}
+
+func (c *ETH) Broadcast(ctx context.Context, tx *Tx) (string, error) {
+	if len(tx.Signed) == 0 {
+		return "", ErrNotSigned
+	}
+
+	signed, err := decodeETHTx(tx.Signed)
+	if err != nil {
+		return "", err
+	}
+
+	if err = c.svc.Broadcast(ctx, signed); err != nil {
+		return "", err
+	}
+	return signed.Hash().Hex(), nil
+}
+
+func decodeETHTx(rawTx string) (*types.Transaction, error) {
+	data, err := hexutil.Decode(rawTx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx types.Transaction
+	if err = tx.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// ethState maps the state of an eth transaction, unknown states to TransactionStateDefault.
+func ethState(state eth.TransactionSate) TransactionState {
+	switch state {
+	case eth.TransactionSateSuccess:
+		return TransactionStateSuccess
+	case eth.TransactionSateFail:
+		return TransactionStateFail
+	case eth.TransactionSatePending:
+		return TransactionStatePending
+	}
+	return TransactionStateDefault
+}
+
+func ethTransaction(txInfo *eth.TransactionInfo) *Transaction {
+	return &Transaction{
+		ID:          txInfo.ID,
+		BlockHeight: txInfo.BlockNumber,
+		Time:        txInfo.Time,
+		State:       ethState(txInfo.State),
+		Fee:         txInfo.Fee,
+		Transfers: []*Transfer{{
+			From:   txInfo.From,
+			To:     txInfo.To,
+			Asset:  txInfo.TokenAddress,
+			Amount: txInfo.Amount,
+		}},
+	}
+}
@@ -0,0 +1,157 @@
+package chain
+
+import (
+	"context"
+	"demo/eth"
+	"demo/token"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+const (
+	ethOwner1Addr       = "0xE280029a7867BA5C9154434886c241775ea87e53"
+	ethOwner1PrivateKey = "f1b3f8e0d52caec13491368449ab8d90f3d222a3e485aa7f02591bbceb5efba5"
+	ethOwner2Addr       = "0x68dB32D26d9529B2a142927c6f1af248fc6Ba7e9"
+)
+
+func Test_ETHTransfer(t *testing.T) {
+	ctx := context.Background()
+	c, backend := getETH(t)
+	var wallet Chain = c
+
+	assert.True(t, wallet.ValidateAddress(ctx, ethOwner2Addr))
+	assert.False(t, wallet.ValidateAddress(ctx, "0x68dB32"))
+	_, err := wallet.Balance(ctx, "0x68dB32", "")
+	assert.ErrorIs(t, err, ErrInvalidAddress)
+
+	tx, err := wallet.BuildTransfer(ctx, TransferRequest{
+		From:   ethOwner1Addr,
+		To:     ethOwner2Addr,
+		Amount: decimal.RequireFromString("1.5"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "eth", tx.Chain)
+	assert.Contains(t, tx.Summary, "send 1.5 ETH")
+	_, err = wallet.Broadcast(ctx, tx)
+	assert.ErrorIs(t, err, ErrNotSigned)
+
+	assert.NoError(t, wallet.Sign(ctx, tx, ethOwner1PrivateKey))
+	id, err := wallet.Broadcast(ctx, tx)
+	assert.NoError(t, err)
+	assert.Equal(t, tx.ID, id)
+	backend.Commit()
+
+	balance, err := wallet.Balance(ctx, ethOwner2Addr, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "101.5", balance.String())
+
+	height, err := wallet.BlockHeight(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), height)
+
+	transaction, err := wallet.Transaction(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, TransactionStatePending, transaction.State)
+	backend.Commit()
+	transaction, err = wallet.Transaction(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, id, transaction.ID)
+	assert.Equal(t, TransactionStateSuccess, transaction.State)
+	assert.True(t, transaction.Fee.IsPositive())
+	assert.Len(t, transaction.Transfers, 1)
+	assert.True(t, strings.EqualFold(ethOwner2Addr, transaction.Transfers[0].To))
+	assert.Equal(t, "1.5", transaction.Transfers[0].Amount.String())
+
+	block, err := wallet.Block(ctx, height)
+	assert.NoError(t, err)
+	assert.Equal(t, height, block.Height)
+	assert.Len(t, block.Transactions, 1)
+	assert.Equal(t, id, block.Transactions[0].ID)
+	assert.Equal(t, block.Hash, block.Transactions[0].BlockHash)
+
+	_, err = wallet.BuildTransfer(ctx, TransferRequest{From: ethOwner1Addr, To: ethOwner2Addr, UTXOs: []UTXO{{}}})
+	assert.ErrorIs(t, err, ErrNotSupported)
+}
+
+func Test_ETHState(t *testing.T) {
+	assert.Equal(t, TransactionStateSuccess, ethState(eth.TransactionSateSuccess))
+	assert.Equal(t, TransactionStateFail, ethState(eth.TransactionSateFail))
+	assert.Equal(t, TransactionStatePending, ethState(eth.TransactionSatePending))
+	assert.Equal(t, TransactionStateDefault, ethState(eth.TransactionSate(9)))
+}
+
+func Test_ETHFeePolicy(t *testing.T) {
+	ctx := context.Background()
+	c, _ := getETH(t)
+	request := TransferRequest{From: ethOwner1Addr, To: ethOwner2Addr, Amount: decimal.RequireFromString("1")}
+
+	// the simulated node suggests 1 gwei
+	c.SetFeePolicy(FeePolicy{Max: decimal.RequireFromString("0.0000000005")})
+	_, err := c.BuildTransfer(ctx, request)
+	assert.ErrorIs(t, err, ErrFeeTooHigh)
+
+	c.SetFeePolicy(FeePolicy{Default: decimal.RequireFromString("0.000000003"), Max: decimal.RequireFromString("0.000000005")})
+	tx, err := c.BuildTransfer(ctx, request)
+	assert.NoError(t, err)
+	unsigned, _ := decodeETHTx(tx.Unsigned)
+	assert.Equal(t, "3000000000", unsigned.GasPrice().String())
+
+	request.Fee = decimal.RequireFromString("0.000000006")
+	_, err = c.BuildTransfer(ctx, request)
+	assert.ErrorIs(t, err, ErrFeeTooHigh)
+}
+
+func Test_ETHTokenTransfer(t *testing.T) {
+	ctx := context.Background()
+	c, backend := getETH(t)
+
+	privateKey, _ := crypto.HexToECDSA(ethOwner1PrivateKey)
+	auth, _ := bind.NewKeyedTransactorWithChainID(privateKey, big.NewInt(1337))
+	tokenAddress, _, _, err := token.DeployToken(auth, backend, "gavin", "GV")
+	assert.NoError(t, err)
+	backend.Commit()
+
+	tx, err := c.BuildTransfer(ctx, TransferRequest{
+		From:   ethOwner1Addr,
+		To:     ethOwner2Addr,
+		Asset:  tokenAddress.Hex(),
+		Amount: decimal.RequireFromString("2.25"),
+		Fee:    decimal.RequireFromString("0.00000005"),
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, tx.Summary, "GV")
+	assert.NoError(t, c.Sign(ctx, tx, ethOwner1PrivateKey))
+	_, err = c.Broadcast(ctx, tx)
+	assert.NoError(t, err)
+	backend.Commit()
+
+	balance, err := c.Balance(ctx, ethOwner2Addr, tokenAddress.Hex())
+	assert.NoError(t, err)
+	assert.Equal(t, "2.25", balance.String())
+
+	transaction, err := c.Transaction(ctx, tx.ID)
+	assert.NoError(t, err)
+	assert.True(t, strings.EqualFold(tokenAddress.Hex(), transaction.Transfers[0].Asset))
+	assert.Equal(t, "2.25", transaction.Transfers[0].Amount.String())
+}
+
+// getETH returns an ETH chain over an in-memory chain where both owners hold 100 ETH.
+func getETH(t *testing.T) (*ETH, *eth.SimulatedBackend) {
+	balance, _ := new(big.Int).SetString("100000000000000000000", 10)
+	backend := eth.NewSimulatedBackend(backends.NewSimulatedBackend(core.GenesisAlloc{
+		common.HexToAddress(ethOwner1Addr): {Balance: balance},
+		common.HexToAddress(ethOwner2Addr): {Balance: balance},
+	}, 8000000))
+	t.Cleanup(func() {
+		backend.Close()
+	})
+	return NewETH(eth.NewService(backend, 0, 1.2)), backend
+}
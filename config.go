@@ -0,0 +1,74 @@
+package main
+
+import (
+	"demo/config"
+	"errors"
+	"gopkg.in/urfave/cli.v1"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const (
+	privateKeyEnv = "WALLET_PRIVATE_KEY"
+	mnemonicEnv   = "WALLET_MNEMONIC"
+)
+
+var (
+	errNoPrivateKey = errors.New("no private key, use --key-file or set " + privateKeyEnv)
+	errNoMnemonic   = errors.New("no mnemonic, use --mnemonic-file or set " + mnemonicEnv)
+)
+
+// loadConfig loads the configuration of --config into a config.Store kept in the app metadata. The --eth-rpc and
+// --btc-host flags override the endpoints of the file and the environment.
+func loadConfig(ctx *cli.Context) error {
+	ethRPC, btcHost := ctx.String(ethRPCFlag.Name), ctx.String(btcHostFlag.Name)
+	store, err := config.NewStore(ctx.String(configFlag.Name), func(c *config.Config) {
+		if len(ethRPC) > 0 {
+			c.ETH.Endpoints = []string{ethRPC}
+		}
+		if len(btcHost) > 0 {
+			if len(c.BTC.Endpoints) == 0 {
+				c.BTC.Endpoints = []config.Endpoint{{}}
+			}
+			c.BTC.Endpoints[0].Host = btcHost
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx.App.Metadata[configKey] = store
+	return nil
+}
+
+func configStore(ctx *cli.Context) *config.Store {
+	return ctx.App.Metadata[configKey].(*config.Store)
+}
+
+// privateKey reads the signing key from --key-file, or from the environment.
+func privateKey(ctx *cli.Context) (string, error) {
+	return readSecret(ctx.GlobalString(keyFileFlag.Name), privateKeyEnv, errNoPrivateKey)
+}
+
+// mnemonic reads the mnemonic from the --mnemonic-file of the command, or from the environment.
+func mnemonic(ctx *cli.Context) (string, error) {
+	return readSecret(ctx.String(mnemonicFileFlag.Name), mnemonicEnv, errNoMnemonic)
+}
+
+func readSecret(path, env string, errMissing error) (string, error) {
+	secret := os.Getenv(env)
+	if len(path) > 0 {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		secret = string(data)
+	}
+
+	secret = strings.TrimSpace(secret)
+	if len(secret) == 0 {
+		return "", errMissing
+	}
+	return secret, nil
+}
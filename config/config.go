@@ -0,0 +1,269 @@
+// Package config is the typed configuration of the wallet: the chains it talks to, their RPC endpoints,
+// confirmations, fee policies and the token registry.
+//
+// A configuration is built from Default, then a YAML or TOML file, then WALLET_* environment variables, and is
+// validated before use. Store keeps the current configuration and reloads it while the wallet runs.
+package config
+
+import (
+	"bytes"
+	"demo/btc"
+	"demo/failover"
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var ErrUnknownFormat = errors.New("the config file is neither .yaml, .yml nor .toml")
+
+type Config struct {
+	ETH ETH `yaml:"eth" toml:"eth"`
+	BTC BTC `yaml:"btc" toml:"btc"`
+}
+
+type ETH struct {
+	Endpoints     []string `yaml:"endpoints" toml:"endpoints"` //JSON-RPC URLs
+	MaxLag        uint64   `yaml:"max_lag" toml:"max_lag"`     //blocks an endpoint may fall behind the others
+	RPC           RPC      `yaml:"rpc" toml:"rpc"`
+	Confirmations uint64   `yaml:"confirmations" toml:"confirmations"`
+	Fee           ETHFee   `yaml:"fee" toml:"fee"`
+	Tokens        []Token  `yaml:"tokens" toml:"tokens"`
+}
+
+// ETHFee is the fee policy of eth transfers. Gas prices are in ETH per gas, zero for no bound.
+type ETHFee struct {
+	GasMultiplier float64 `yaml:"gas_multiplier" toml:"gas_multiplier"` //applied to gas estimates
+	GasPrice      Amount  `yaml:"gas_price" toml:"gas_price"`           //used instead of the node's suggestion
+	MaxGasPrice   Amount  `yaml:"max_gas_price" toml:"max_gas_price"`
+}
+
+type Token struct {
+	Symbol  string `yaml:"symbol" toml:"symbol"`
+	Address string `yaml:"address" toml:"address"`
+}
+
+type BTC struct {
+	Network       string     `yaml:"network" toml:"network"` //mainnet, testnet3, regtest or simnet
+	Endpoints     []Endpoint `yaml:"endpoints" toml:"endpoints"`
+	MaxLag        uint64     `yaml:"max_lag" toml:"max_lag"`
+	RPC           RPC        `yaml:"rpc" toml:"rpc"`
+	Confirmations uint64     `yaml:"confirmations" toml:"confirmations"`
+	Fee           BTCFee     `yaml:"fee" toml:"fee"`
+}
+
+type Endpoint struct {
+	Host     string `yaml:"host" toml:"host"`
+	User     string `yaml:"user" toml:"user"`
+	Password string `yaml:"password" toml:"password"`
+}
+
+// BTCFee is the fee policy of btc transfers, whole fees in BTC, zero for none.
+type BTCFee struct {
+	Default Amount `yaml:"default" toml:"default"`
+	Max     Amount `yaml:"max" toml:"max"`
+}
+
+// RPC is how the calls to the endpoints of a chain are retried and limited, see failover.Policy.
+type RPC struct {
+	Timeout     Duration `yaml:"timeout" toml:"timeout"` //of each attempt of a call, 0 for the deadline of the caller only
+	Retries     int      `yaml:"retries" toml:"retries"`
+	Backoff     Duration `yaml:"backoff" toml:"backoff"` //before the first retry, doubled for every next one
+	MaxBackoff  Duration `yaml:"max_backoff" toml:"max_backoff"`
+	RateLimit   float64  `yaml:"rate_limit" toml:"rate_limit"`   //calls per second to each endpoint, 0 for no limit
+	Concurrency int      `yaml:"concurrency" toml:"concurrency"` //calls in flight to each endpoint, 0 for no limit
+}
+
+func (r RPC) Policy() failover.Policy {
+	return failover.Policy{
+		Timeout:     r.Timeout.Duration,
+		Retries:     r.Retries,
+		Backoff:     r.Backoff.Duration,
+		MaxBackoff:  r.MaxBackoff.Duration,
+		RateLimit:   r.RateLimit,
+		Concurrency: r.Concurrency,
+	}
+}
+
+// Duration is a time.Duration written as a string such as "250ms".
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) (err error) {
+	d.Duration, err = time.ParseDuration(string(text))
+	return err
+}
+
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var text string
+	if err := unmarshal(&text); err != nil {
+		return err
+	}
+	return d.UnmarshalText([]byte(text))
+}
+
+// Amount is a decimal written as a string or a number.
+type Amount struct {
+	decimal.Decimal
+}
+
+func (a *Amount) UnmarshalText(text []byte) error {
+	return a.Decimal.UnmarshalText(text)
+}
+
+func (a *Amount) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var text string
+	if err := unmarshal(&text); err != nil {
+		return err
+	}
+	return a.UnmarshalText([]byte(text))
+}
+
+// Default is a node on localhost for each chain, btc on testnet3.
+func Default() *Config {
+	rpc := RPC{Timeout: Duration{30 * time.Second}, Retries: 3, Backoff: Duration{250 * time.Millisecond}, MaxBackoff: Duration{5 * time.Second}}
+	return &Config{
+		ETH: ETH{
+			Endpoints:     []string{"http://localhost:8545"},
+			MaxLag:        3,
+			RPC:           rpc,
+			Confirmations: 12,
+			Fee:           ETHFee{GasMultiplier: 1.2},
+		},
+		BTC: BTC{
+			Network:       "testnet3",
+			Endpoints:     []Endpoint{{Host: "localhost:18332"}},
+			MaxLag:        1,
+			RPC:           rpc,
+			Confirmations: 6,
+		},
+	}
+}
+
+// Load reads the configuration at path over Default, expanding ${VAR} references to the environment, applies the
+// environment overrides and then overrides, and validates the result. An empty path loads no file.
+func Load(path string, overrides ...func(*Config)) (*Config, error) {
+	config := Default()
+	if len(path) > 0 {
+		if err := decodeFile(path, config); err != nil {
+			return nil, fmt.Errorf("config %s: %w", path, err)
+		}
+	}
+
+	if err := applyEnv(config); err != nil {
+		return nil, err
+	}
+
+	for _, override := range overrides {
+		override(config)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// decodeFile decodes path into config by its extension. Lists in the file replace the defaults, and settings the
+// model does not know are errors.
+func decodeFile(path string, config *Config) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	data = []byte(os.ExpandEnv(string(data)))
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return yaml.UnmarshalStrict(data, config)
+	case ".toml":
+		metadata, err := toml.NewDecoder(bytes.NewReader(data)).Decode(config)
+		if err != nil {
+			return err
+		}
+
+		if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("unknown setting %q", undecoded[0].String())
+		}
+		return nil
+	}
+	return ErrUnknownFormat
+}
+
+// Validate reports every problem of c at once.
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(len(c.ETH.Endpoints) > 0, "eth.endpoints is empty")
+	for _, endpoint := range c.ETH.Endpoints {
+		u, err := url.Parse(endpoint)
+		check(err == nil && len(u.Scheme) > 0 && len(u.Host) > 0, "eth.endpoints: %q is not a URL", endpoint)
+	}
+	check(c.ETH.Fee.GasMultiplier >= 1, "eth.fee.gas_multiplier must be at least 1")
+	check(!c.ETH.Fee.GasPrice.IsNegative(), "eth.fee.gas_price is negative")
+	check(!c.ETH.Fee.MaxGasPrice.IsNegative(), "eth.fee.max_gas_price is negative")
+	check(c.ETH.Fee.MaxGasPrice.IsZero() || c.ETH.Fee.GasPrice.LessThanOrEqual(c.ETH.Fee.MaxGasPrice.Decimal),
+		"eth.fee.gas_price is above eth.fee.max_gas_price")
+
+	symbols := map[string]bool{}
+	for _, token := range c.ETH.Tokens {
+		check(len(token.Symbol) > 0, "eth.tokens: a token has no symbol")
+		check(!symbols[strings.ToUpper(token.Symbol)], "eth.tokens: %s is listed twice", token.Symbol)
+		check(common.IsHexAddress(token.Address), "eth.tokens: the address of %s is invalid", token.Symbol)
+		symbols[strings.ToUpper(token.Symbol)] = true
+	}
+
+	for i, rpc := range []RPC{c.ETH.RPC, c.BTC.RPC} {
+		chain := []string{"eth", "btc"}[i]
+		check(rpc.Timeout.Duration >= 0, "%s.rpc.timeout is negative", chain)
+		check(rpc.Retries >= 0, "%s.rpc.retries is negative", chain)
+		check(rpc.Backoff.Duration >= 0 && rpc.MaxBackoff.Duration >= 0, "%s.rpc: a backoff is negative", chain)
+		check(rpc.RateLimit >= 0, "%s.rpc.rate_limit is negative", chain)
+		check(rpc.Concurrency >= 0, "%s.rpc.concurrency is negative", chain)
+	}
+
+	_, err := btc.NetworkParams(c.BTC.Network)
+	check(err == nil, "btc.network: %q is not mainnet, testnet3, regtest or simnet", c.BTC.Network)
+	check(len(c.BTC.Endpoints) > 0, "btc.endpoints is empty")
+	for _, endpoint := range c.BTC.Endpoints {
+		check(len(endpoint.Host) > 0, "btc.endpoints: an endpoint has no host")
+	}
+	check(c.BTC.Confirmations > 0, "btc.confirmations must be at least 1")
+	check(!c.BTC.Fee.Default.IsNegative(), "btc.fee.default is negative")
+	check(!c.BTC.Fee.Max.IsNegative(), "btc.fee.max is negative")
+	check(c.BTC.Fee.Max.IsZero() || c.BTC.Fee.Default.LessThanOrEqual(c.BTC.Fee.Max.Decimal),
+		"btc.fee.default is above btc.fee.max")
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// TokenAddress resolves token, a symbol of the registry or a contract address, to a contract address.
+func (e *ETH) TokenAddress(token string) (string, error) {
+	if common.IsHexAddress(token) {
+		return token, nil
+	}
+
+	for _, t := range e.Tokens {
+		if strings.EqualFold(t.Symbol, token) {
+			return t.Address, nil
+		}
+	}
+	return "", fmt.Errorf("%q is neither a token address nor in eth.tokens", token)
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"demo/failover"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const yamlConfig = `
+eth:
+  endpoints: [http://node1:8545, http://node2:8545]
+  max_lag: 5
+  rpc:
+    timeout: 10s
+    retries: 5
+    backoff: 100ms
+    rate_limit: 25
+  confirmations: 3
+  fee:
+    max_gas_price: 0.0000002
+  tokens:
+    - symbol: USDT
+      address: 0xdAC17F958D2ee523a2206206994597C13D831ec7
+btc:
+  network: regtest
+  endpoints:
+    - host: btc:18443
+      user: wallet
+      password: ${WALLET_TEST_PASSWORD}
+  fee:
+    default: "0.0001"
+    max: 0.001
+`
+
+const tomlConfig = `
+[eth]
+endpoints = ["http://node1:8545"]
+confirmations = 3
+
+[eth.fee]
+gas_price = "0.00000001"
+
+[[eth.tokens]]
+symbol = "USDT"
+address = "0xdAC17F958D2ee523a2206206994597C13D831ec7"
+
+[btc]
+network = "mainnet"
+
+[btc.rpc]
+backoff = "1s"
+concurrency = 4
+
+[[btc.endpoints]]
+host = "btc:8332"
+`
+
+func Test_LoadYAML(t *testing.T) {
+	os.Setenv("WALLET_TEST_PASSWORD", "secret")
+	defer os.Unsetenv("WALLET_TEST_PASSWORD")
+
+	config, err := Load(writeFile(t, "wallet.yaml", yamlConfig))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http://node1:8545", "http://node2:8545"}, config.ETH.Endpoints)
+	assert.Equal(t, uint64(5), config.ETH.MaxLag)
+	assert.Equal(t, failover.Policy{Timeout: 10 * time.Second, Retries: 5, Backoff: 100 * time.Millisecond,
+		MaxBackoff: 5 * time.Second, RateLimit: 25}, config.ETH.RPC.Policy())
+	assert.Equal(t, Default().BTC.RPC, config.BTC.RPC)
+	assert.Equal(t, uint64(3), config.ETH.Confirmations)
+	assert.Equal(t, 1.2, config.ETH.Fee.GasMultiplier)
+	assert.Equal(t, "0.0000002", config.ETH.Fee.MaxGasPrice.String())
+	assert.True(t, config.ETH.Fee.GasPrice.IsZero())
+	assert.Equal(t, "regtest", config.BTC.Network)
+	assert.Equal(t, []Endpoint{{Host: "btc:18443", User: "wallet", Password: "secret"}}, config.BTC.Endpoints)
+	assert.Equal(t, uint64(1), config.BTC.MaxLag)
+	assert.Equal(t, uint64(6), config.BTC.Confirmations)
+	assert.Equal(t, "0.0001", config.BTC.Fee.Default.String())
+	assert.Equal(t, "0.001", config.BTC.Fee.Max.String())
+}
+
+func Test_LoadTOML(t *testing.T) {
+	config, err := Load(writeFile(t, "wallet.toml", tomlConfig), func(c *Config) {
+		c.ETH.Confirmations = 30
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http://node1:8545"}, config.ETH.Endpoints)
+	assert.Equal(t, uint64(30), config.ETH.Confirmations)
+	assert.Equal(t, "0.00000001", config.ETH.Fee.GasPrice.String())
+	assert.Equal(t, "USDT", config.ETH.Tokens[0].Symbol)
+	assert.Equal(t, "mainnet", config.BTC.Network)
+	assert.Equal(t, []Endpoint{{Host: "btc:8332"}}, config.BTC.Endpoints)
+	assert.Equal(t, time.Second, config.BTC.RPC.Backoff.Duration)
+	assert.Equal(t, 4, config.BTC.RPC.Concurrency)
+}
+
+func Test_LoadErrors(t *testing.T) {
+	config, err := Load("")
+	assert.NoError(t, err)
+	assert.Equal(t, Default(), config)
+
+	_, err = Load(writeFile(t, "wallet.yaml", "eth:\n  confirmation: 3\n"))
+	assert.Error(t, err)
+	_, err = Load(writeFile(t, "wallet.toml", "[eth]\nconfirmation = 3\n"))
+	assert.Contains(t, err.Error(), `wallet.toml: unknown setting "eth.confirmation"`)
+	_, err = Load(writeFile(t, "wallet.json", "{}"))
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+	_, err = Load(writeFile(t, "wallet.yaml", "eth:\n  fee:\n    gas_price: cheap\n"))
+	assert.Error(t, err)
+	_, err = Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func Test_Validate(t *testing.T) {
+	config := Default()
+	assert.NoError(t, config.Validate())
+
+	config.ETH.Endpoints = []string{"localhost:8545"}
+	config.ETH.Fee.GasMultiplier = 0.5
+	config.ETH.Fee.GasPrice = amountOf(t, "2")
+	config.ETH.Fee.MaxGasPrice = amountOf(t, "1")
+	config.ETH.Tokens = []Token{{Symbol: "USDT", Address: "0x12"}, {Symbol: "usdt", Address: "0xdAC17F958D2ee523a2206206994597C13D831ec7"}}
+	config.ETH.RPC.Retries = -1
+	config.BTC.RPC.RateLimit = -1
+	config.BTC.Network = "testnet"
+	config.BTC.Endpoints = []Endpoint{{User: "wallet"}}
+	config.BTC.Confirmations = 0
+	assert.EqualError(t, config.Validate(), "invalid config: "+
+		`eth.endpoints: "localhost:8545" is not a URL; `+
+		"eth.fee.gas_multiplier must be at least 1; "+
+		"eth.fee.gas_price is above eth.fee.max_gas_price; "+
+		"eth.tokens: the address of USDT is invalid; "+
+		"eth.tokens: usdt is listed twice; "+
+		"eth.rpc.retries is negative; "+
+		"btc.rpc.rate_limit is negative; "+
+		`btc.network: "testnet" is not mainnet, testnet3, regtest or simnet; `+
+		"btc.endpoints: an endpoint has no host; "+
+		"btc.confirmations must be at least 1")
+}
+
+func Test_TokenAddress(t *testing.T) {
+	eth := ETH{Tokens: []Token{{Symbol: "USDT", Address: "0xdAC17F958D2ee523a2206206994597C13D831ec7"}}}
+	address, err := eth.TokenAddress("usdt")
+	assert.NoError(t, err)
+	assert.Equal(t, "0xdAC17F958D2ee523a2206206994597C13D831ec7", address)
+
+	address, err = eth.TokenAddress("0x68dB32D26d9529B2a142927c6f1af248fc6Ba7e9")
+	assert.NoError(t, err)
+	assert.Equal(t, "0x68dB32D26d9529B2a142927c6f1af248fc6Ba7e9", address)
+
+	_, err = eth.TokenAddress("DAI")
+	assert.Error(t, err)
+}
+
+func amountOf(t *testing.T, value string) Amount {
+	var amount Amount
+	if err := amount.UnmarshalText([]byte(value)); err != nil {
+		t.Fatal(err)
+	}
+	return amount
+}
+
+// writeFile writes content to name in a directory removed after the test, and returns its path.
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// envOverrides are the environment variables that override settings of the file. WALLET_BTC_HOST, _USER and
+// _PASSWORD set the first btc endpoint, so that credentials can stay out of the file.
+var envOverrides = []struct {
+	name string
+	set  func(c *Config, value string) error
+}{
+	{"WALLET_ETH_ENDPOINTS", func(c *Config, value string) error {
+		c.ETH.Endpoints = splitList(value)
+		return nil
+	}},
+	{"WALLET_ETH_CONFIRMATIONS", func(c *Config, value string) (err error) {
+		c.ETH.Confirmations, err = strconv.ParseUint(value, 10, 64)
+		return err
+	}},
+	{"WALLET_ETH_FEE_GAS_MULTIPLIER", func(c *Config, value string) (err error) {
+		c.ETH.Fee.GasMultiplier, err = strconv.ParseFloat(value, 64)
+		return err
+	}},
+	{"WALLET_ETH_FEE_GAS_PRICE", func(c *Config, value string) error {
+		return c.ETH.Fee.GasPrice.UnmarshalText([]byte(value))
+	}},
+	{"WALLET_ETH_FEE_MAX_GAS_PRICE", func(c *Config, value string) error {
+		return c.ETH.Fee.MaxGasPrice.UnmarshalText([]byte(value))
+	}},
+	{"WALLET_BTC_NETWORK", func(c *Config, value string) error {
+		c.BTC.Network = value
+		return nil
+	}},
+	{"WALLET_BTC_HOST", func(c *Config, value string) error {
+		firstEndpoint(c).Host = value
+		return nil
+	}},
+	{"WALLET_BTC_USER", func(c *Config, value string) error {
+		firstEndpoint(c).User = value
+		return nil
+	}},
+	{"WALLET_BTC_PASSWORD", func(c *Config, value string) error {
+		firstEndpoint(c).Password = value
+		return nil
+	}},
+	{"WALLET_BTC_CONFIRMATIONS", func(c *Config, value string) (err error) {
+		c.BTC.Confirmations, err = strconv.ParseUint(value, 10, 64)
+		return err
+	}},
+	{"WALLET_BTC_FEE_DEFAULT", func(c *Config, value string) error {
+		return c.BTC.Fee.Default.UnmarshalText([]byte(value))
+	}},
+	{"WALLET_BTC_FEE_MAX", func(c *Config, value string) error {
+		return c.BTC.Fee.Max.UnmarshalText([]byte(value))
+	}},
+}
+
+func applyEnv(c *Config) error {
+	for _, override := range envOverrides {
+		value, ok := os.LookupEnv(override.name)
+		if !ok {
+			continue
+		}
+
+		if err := override.set(c, strings.TrimSpace(value)); err != nil {
+			return fmt.Errorf("%s: %w", override.name, err)
+		}
+	}
+	return nil
+}
+
+func firstEndpoint(c *Config) *Endpoint {
+	if len(c.BTC.Endpoints) == 0 {
+		c.BTC.Endpoints = []Endpoint{{}}
+	}
+	return &c.BTC.Endpoints[0]
+}
+
+// splitList splits a comma separated list, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
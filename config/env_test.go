@@ -0,0 +1,49 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func Test_ApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"WALLET_ETH_ENDPOINTS":          "http://node1:8545, http://node2:8545,",
+		"WALLET_ETH_CONFIRMATIONS":      "20",
+		"WALLET_ETH_FEE_GAS_MULTIPLIER": "1.5",
+		"WALLET_ETH_FEE_MAX_GAS_PRICE":  "0.0000001",
+		"WALLET_BTC_NETWORK":            "regtest",
+		"WALLET_BTC_USER":               "wallet",
+		"WALLET_BTC_PASSWORD":           "secret",
+		"WALLET_BTC_FEE_MAX":            "0.01",
+	}
+	for name, value := range env {
+		os.Setenv(name, value)
+		defer os.Unsetenv(name)
+	}
+
+	config := Default()
+	config.BTC.Endpoints = nil
+	assert.NoError(t, applyEnv(config))
+	assert.Equal(t, []string{"http://node1:8545", "http://node2:8545"}, config.ETH.Endpoints)
+	assert.Equal(t, uint64(20), config.ETH.Confirmations)
+	assert.Equal(t, 1.5, config.ETH.Fee.GasMultiplier)
+	assert.Equal(t, "0.0000001", config.ETH.Fee.MaxGasPrice.String())
+	assert.Equal(t, "regtest", config.BTC.Network)
+	assert.Equal(t, []Endpoint{{User: "wallet", Password: "secret"}}, config.BTC.Endpoints)
+	assert.Equal(t, "0.01", config.BTC.Fee.Max.String())
+
+	// the endpoint has no host
+	assert.Error(t, config.Validate())
+	os.Setenv("WALLET_BTC_HOST", "btc:18443")
+	defer os.Unsetenv("WALLET_BTC_HOST")
+	config, err := Load(writeFile(t, "wallet.yaml", "eth:\n  confirmations: 3\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(20), config.ETH.Confirmations)
+	assert.Equal(t, []Endpoint{{Host: "btc:18443", User: "wallet", Password: "secret"}}, config.BTC.Endpoints)
+
+	os.Setenv("WALLET_ETH_CONFIRMATIONS", "many")
+	_, err = Load("")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "WALLET_ETH_CONFIRMATIONS")
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+)
+
+// Store holds the current configuration of a running wallet. Reload swaps in a new configuration only when it is
+// valid, so a bad edit keeps the wallet on the last good one. Subscribers apply what a running service can change:
+// the confirmations and the gas multiplier. Fee policies and tokens are read from Config by each command that uses
+// them. Endpoints and the btc network are read when a service is built, and need a restart.
+type Store struct {
+	path      string
+	overrides []func(*Config)
+
+	reload sync.Mutex //serializes Reload, so the subscribers see the configurations in the order they were loaded
+
+	mu          sync.RWMutex
+	config      *Config
+	modTime     time.Time
+	subscribers []func(*Config)
+}
+
+// NewStore loads the configuration at path with Load; overrides are applied again on every reload.
+func NewStore(path string, overrides ...func(*Config)) (*Store, error) {
+	s := Store{path: path, overrides: overrides}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Config returns the current configuration. It must not be modified.
+func (s *Store) Config() *Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config
+}
+
+// Subscribe calls fn with every configuration a reload swaps in.
+func (s *Store) Subscribe(fn func(*Config)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribers = append(s.subscribers, fn)
+}
+
+// Reload loads the configuration again and, when it is valid, makes it current and passes it to the subscribers.
+// Concurrent reloads run one after the other.
+func (s *Store) Reload() error {
+	s.reload.Lock()
+	defer s.reload.Unlock()
+
+	modTime := s.fileModTime()
+	config, err := Load(s.path, s.overrides...)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.config, s.modTime = config, modTime
+	subscribers := append([]func(*Config){}, s.subscribers...)
+	s.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(config)
+	}
+	return nil
+}
+
+// Watch reloads the configuration whenever the file changes, checking every interval until ctx is done. Failed
+// reloads are passed to onError and retried at the next change.
+func (s *Store) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.RLock()
+		changed := !s.fileModTime().Equal(s.modTime)
+		s.mu.RUnlock()
+		if !changed {
+			continue
+		}
+
+		if err := s.Reload(); err != nil {
+			s.mu.Lock()
+			s.modTime = s.fileModTime()
+			s.mu.Unlock()
+			onError(err)
+		}
+	}
+}
+
+func (s *Store) fileModTime() time.Time {
+	if len(s.path) == 0 {
+		return time.Time{}
+	}
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
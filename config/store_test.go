@@ -0,0 +1,98 @@
+package config
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_StoreReload(t *testing.T) {
+	path := writeFile(t, "wallet.yaml", "eth:\n  confirmations: 3\n")
+	store, err := NewStore(path, func(c *Config) {
+		c.BTC.Confirmations = 2
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), store.Config().ETH.Confirmations)
+
+	var reloaded []*Config
+	store.Subscribe(func(c *Config) {
+		reloaded = append(reloaded, c)
+	})
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("eth:\n  confirmations: 5\n"), 0600))
+	assert.NoError(t, store.Reload())
+	assert.Equal(t, uint64(5), store.Config().ETH.Confirmations)
+	assert.Equal(t, uint64(2), store.Config().BTC.Confirmations)
+	assert.Equal(t, []*Config{store.Config()}, reloaded)
+
+	// an invalid file keeps the last good configuration
+	assert.NoError(t, ioutil.WriteFile(path, []byte("eth:\n  endpoints: []\n"), 0600))
+	assert.Error(t, store.Reload())
+	assert.Equal(t, uint64(5), store.Config().ETH.Confirmations)
+	assert.Len(t, reloaded, 1)
+
+	_, err = NewStore(path)
+	assert.Error(t, err)
+}
+
+func Test_StoreConcurrentReload(t *testing.T) {
+	path := writeFile(t, "wallet.yaml", "eth:\n  confirmations: 3\n")
+	store, err := NewStore(path)
+	assert.NoError(t, err)
+	reloads := 0
+	store.Subscribe(func(c *Config) {
+		reloads++
+	})
+
+	done := make(chan error)
+	for i := 0; i < 4; i++ {
+		go func() {
+			done <- store.Reload()
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, <-done)
+	}
+	assert.Equal(t, 4, reloads, "the subscribers are called one reload at a time")
+}
+
+func Test_StoreWatch(t *testing.T) {
+	path := writeFile(t, "wallet.yaml", "eth:\n  confirmations: 3\n")
+	store, err := NewStore(path)
+	assert.NoError(t, err)
+
+	reloaded := make(chan *Config, 1)
+	store.Subscribe(func(c *Config) {
+		reloaded <- c
+	})
+	errs := make(chan error, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go store.Watch(ctx, 10*time.Millisecond, func(err error) {
+		errs <- err
+	})
+
+	later := time.Now().Add(time.Second)
+	assert.NoError(t, ioutil.WriteFile(path, []byte("eth:\n  confirmations: 4\n"), 0600))
+	assert.NoError(t, os.Chtimes(path, later, later))
+	select {
+	case c := <-reloaded:
+		assert.Equal(t, uint64(4), c.ETH.Confirmations)
+	case <-time.After(time.Second):
+		t.Fatal("the change was not reloaded")
+	}
+
+	later = later.Add(time.Second)
+	assert.NoError(t, ioutil.WriteFile(path, []byte("eth:\n  confirmations: -1\n"), 0600))
+	assert.NoError(t, os.Chtimes(path, later, later))
+	select {
+	case err := <-errs:
+		assert.Error(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("the invalid change was not reported")
+	}
+	assert.Equal(t, uint64(4), store.Config().ETH.Confirmations)
+}
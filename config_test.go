@@ -0,0 +1,76 @@
+package main
+
+import (
+	"demo/config"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/urfave/cli.v1"
+	"os"
+	"testing"
+)
+
+func Test_LoadConfig(t *testing.T) {
+	file := writeFile(t, "wallet.yaml", `
+eth:
+  endpoints: [http://node:8545]
+  confirmations: 3
+  tokens:
+    - {symbol: USDT, address: "0xdAC17F958D2ee523a2206206994597C13D831ec7"}
+btc:
+  endpoints: [{host: "btc:18332", user: alice}]
+`)
+	os.Setenv("WALLET_BTC_USER", "bob")
+	defer os.Unsetenv("WALLET_BTC_USER")
+
+	var settings *config.Config
+	app := newApp()
+	app.Commands = []cli.Command{{
+		Name: "settings",
+		Action: func(ctx *cli.Context) error {
+			settings = configStore(ctx).Config()
+			return nil
+		},
+	}}
+
+	err := app.Run([]string{"wallet", "--config", file, "--btc-host", "flag:18332", "settings"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http://node:8545"}, settings.ETH.Endpoints)                        // file
+	assert.Equal(t, uint64(3), settings.ETH.Confirmations)                                       // file
+	assert.Equal(t, 1.2, settings.ETH.Fee.GasMultiplier)                                         // default
+	assert.Equal(t, config.Endpoint{Host: "flag:18332", User: "bob"}, settings.BTC.Endpoints[0]) // flag and environment over file
+
+	os.Unsetenv("WALLET_BTC_USER")
+	err = app.Run([]string{"wallet", "--config", writeFile(t, "wallet.yaml", "btc:\n  endpoints: []\n"), "--btc-host", "flag:18332", "settings"})
+	assert.NoError(t, err)
+	assert.Equal(t, []config.Endpoint{{Host: "flag:18332"}}, settings.BTC.Endpoints) // flag without any endpoint
+
+	_, err = runApp(t, nil, "--config", writeFile(t, "wallet.yaml", "eth:\n  rpc: http://node:8545\n"), "height")
+	assert.Error(t, err)
+	_, err = runApp(t, nil, "--config", writeFile(t, "wallet.yaml", "eth:\n  confirmations: many\n"), "height")
+	assert.Error(t, err)
+
+	svc, _ := getSimulatedService(t)
+	out, err := runApp(t, svc, "--config", file, "tokens")
+	assert.NoError(t, err)
+	assert.Equal(t, "USDT\t0xdAC17F958D2ee523a2206206994597C13D831ec7\n", out)
+	_, err = runApp(t, svc, "--config", file, "balance", "--token", "DAI", owner1Addr)
+	assert.EqualError(t, err, `"DAI" is neither a token address nor in eth.tokens`)
+}
+
+func Test_ReadSecret(t *testing.T) {
+	errMissing := errNoPrivateKey
+	_, err := readSecret("", "WALLET_TEST_SECRET", errMissing)
+	assert.Equal(t, errMissing, err)
+
+	os.Setenv("WALLET_TEST_SECRET", " env-secret\n")
+	defer os.Unsetenv("WALLET_TEST_SECRET")
+	secret, err := readSecret("", "WALLET_TEST_SECRET", errMissing)
+	assert.NoError(t, err)
+	assert.Equal(t, "env-secret", secret)
+
+	secret, err = readSecret(writeFile(t, "secret", "file-secret\n"), "WALLET_TEST_SECRET", errMissing)
+	assert.NoError(t, err)
+	assert.Equal(t, "file-secret", secret)
+
+	_, err = readSecret(writeFile(t, "secret", "\n"), "WALLET_TEST_SECRET", errMissing)
+	assert.Equal(t, errMissing, err)
+}
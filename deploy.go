@@ -0,0 +1,104 @@
+package main
+
+import (
+	"demo/eth"
+	"demo/store"
+	"demo/token"
+	"errors"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"gopkg.in/urfave/cli.v1"
+	"strings"
+)
+
+var errInvalidPrivateKey = errors.New("the private key is not a hex secp256k1 key")
+
+// deployment is the output of the deploy commands.
+type deployment struct {
+	Address string
+	TxID    string
+}
+
+var deployCommand = cli.Command{
+	Name:  "deploy",
+	Usage: "deploy a bundled contract with the key of --key-file or $" + privateKeyEnv,
+	Subcommands: []cli.Command{
+		{
+			Name:      "erc20",
+			Usage:     "deploy the ERC-20 token",
+			ArgsUsage: " ",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "name", Usage: "token name", Value: "Token"},
+				cli.StringFlag{Name: "symbol", Usage: "token symbol", Value: "TKN"},
+			},
+			Action: func(ctx *cli.Context) error {
+				return deploy(ctx, func(auth *bind.TransactOpts, backend bind.ContractBackend) (common.Address, *types.Transaction, error) {
+					address, tx, _, err := token.DeployToken(auth, backend, ctx.String("name"), ctx.String("symbol"))
+					return address, tx, err
+				})
+			},
+		},
+		{
+			Name:      "store",
+			Usage:     "deploy the Store contract",
+			ArgsUsage: " ",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "version", Usage: "version stored in the contract", Value: "1.0"},
+			},
+			Action: func(ctx *cli.Context) error {
+				return deploy(ctx, func(auth *bind.TransactOpts, backend bind.ContractBackend) (common.Address, *types.Transaction, error) {
+					address, tx, _, err := store.DeployStore(auth, backend, ctx.String("version"))
+					return address, tx, err
+				})
+			},
+		},
+	},
+}
+
+// deploy sends the contract creation of deployFn signed by the configured key and prints where the contract will
+// live; it does not wait for the transaction to be mined.
+func deploy(ctx *cli.Context, deployFn func(*bind.TransactOpts, bind.ContractBackend) (common.Address, *types.Transaction, error)) error {
+	svc, err := ethService(ctx)
+	if err != nil {
+		return err
+	}
+
+	auth, err := transactor(ctx, svc)
+	if err != nil {
+		return err
+	}
+
+	address, tx, err := deployFn(auth, svc.Backend())
+	if err != nil {
+		return err
+	}
+	return printJSON(ctx, deployment{Address: address.Hex(), TxID: tx.Hash().Hex()})
+}
+
+// transactor signs for the configured key on the chain of svc. Nonce and gas are left to the node.
+func transactor(ctx *cli.Context, svc *eth.Service) (*bind.TransactOpts, error) {
+	key, err := privateKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ecdsaKey, err := crypto.HexToECDSA(strings.TrimPrefix(key, "0x"))
+	if err != nil {
+		return nil, errInvalidPrivateKey
+	}
+
+	chainID, err := svc.Backend().NetworkID(nodeContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := bind.NewKeyedTransactorWithChainID(ecdsaKey, chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	auth.Context = nodeContext(ctx)
+	return auth, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"demo/store"
+	"demo/token"
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_Deploy(t *testing.T) {
+	svc, backend := getSimulatedService(t)
+	keyFile := writeFile(t, "key", owner1PrivateKey)
+
+	out, err := runApp(t, svc, "--key-file", keyFile, "deploy", "erc20", "--name", "Gold", "--symbol", "GLD")
+	assert.NoError(t, err)
+	var erc20 deployment
+	assert.NoError(t, json.Unmarshal([]byte(out), &erc20))
+
+	out, err = runApp(t, svc, "--key-file", keyFile, "deploy", "store", "--version", "2.0")
+	assert.NoError(t, err)
+	var storeDeployment deployment
+	assert.NoError(t, json.Unmarshal([]byte(out), &storeDeployment))
+	backend.Commit()
+
+	tokenContract, _ := token.NewToken(common.HexToAddress(erc20.Address), backend)
+	symbol, err := tokenContract.Symbol(&bind.CallOpts{})
+	assert.NoError(t, err)
+	assert.Equal(t, "GLD", symbol)
+
+	storeContract, _ := store.NewStore(common.HexToAddress(storeDeployment.Address), backend)
+	version, err := storeContract.Version(&bind.CallOpts{})
+	assert.NoError(t, err)
+	assert.Equal(t, "2.0", version)
+
+	receipt, _ := backend.TransactionReceipt(context.Background(), common.HexToHash(storeDeployment.TxID))
+	assert.Equal(t, uint64(1), receipt.Status)
+
+	_, err = runApp(t, svc, "--key-file", writeFile(t, "key", "0x12"), "deploy", "store")
+	assert.Equal(t, errInvalidPrivateKey, err)
+}
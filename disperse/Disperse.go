@@ -0,0 +1,244 @@
+// Code generated - DO NOT EDIT.
+// This file is a generated binding and any manual changes will be lost.
+
+package disperse
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var (
+	_ = errors.New
+	_ = big.NewInt
+	_ = strings.NewReader
+	_ = ethereum.NotFound
+	_ = bind.Bind
+	_ = common.Big1
+	_ = types.BloomLookup
+	_ = event.NewSubscription
+)
+
+// DisperseMetaData contains all meta data concerning the Disperse contract.
+var DisperseMetaData = &bind.MetaData{
+	ABI: "[{\"inputs\":[{\"internalType\":\"address[]\",\"name\":\"recipients\",\"type\":\"address[]\"},{\"internalType\":\"uint256[]\",\"name\":\"values\",\"type\":\"uint256[]\"}],\"name\":\"disperseEther\",\"outputs\":[],\"stateMutability\":\"payable\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"token\",\"type\":\"address\"},{\"internalType\":\"address[]\",\"name\":\"recipients\",\"type\":\"address[]\"},{\"internalType\":\"uint256[]\",\"name\":\"values\",\"type\":\"uint256[]\"}],\"name\":\"disperseToken\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"}]",
+	Bin: "0x61022880600c6000396000f360003560e01c8063e63d38ed146300000024578063c73a2d6014630000008457600080fd5b6300000034600460246300000156565b60005b81811015630000006f578060010160051b8084013590850135600060006000600085855af11563000001c95750506001016300000037565b600060006000600047335af11563000001c957005b6300000094602460446300000156565b600060005b8281101563000000bb578060010160051b840135820191506001016300000099565b507f23b872dd00000000000000000000000000000000000000000000000000000000600052336004523060245260445263000000f960646300000172565b60005b81811015630000019d578060010160051b7fa9059cbb0000000000000000000000000000000000000000000000000000000060005280850135600452830135602452630000014c60446300000172565b60010163000000fc565b3560040190356004019081358135811415630000019f57909192565b6020600082600060006004355af11563000001c9573d15630000019a576000511563000001c9575b50565b005b7f44697370657273653a206c656e677468206d69736d6174636800000000000000601963000001f3565b7f44697370657273653a2063616c6c206661696c65640000000000000000000000601563000001f3565b7f08c379a000000000000000000000000000000000000000000000000000000000600052602060045260245260445260646000fd",
+}
+
+// DisperseABI is the input ABI used to generate the binding from.
+// Deprecated: Use DisperseMetaData.ABI instead.
+var DisperseABI = DisperseMetaData.ABI
+
+// DisperseBin is the compiled bytecode used for deploying new contracts.
+// Deprecated: Use DisperseMetaData.Bin instead.
+var DisperseBin = DisperseMetaData.Bin
+
+// DeployDisperse deploys a new Ethereum contract, binding an instance of Disperse to it.
+func DeployDisperse(auth *bind.TransactOpts, backend bind.ContractBackend) (common.Address, *types.Transaction, *Disperse, error) {
+	parsed, err := DisperseMetaData.GetAbi()
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	if parsed == nil {
+		return common.Address{}, nil, nil, errors.New("GetABI returned nil")
+	}
+
+	address, tx, contract, err := bind.DeployContract(auth, *parsed, common.FromHex(DisperseBin), backend)
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	return address, tx, &Disperse{DisperseCaller: DisperseCaller{contract: contract}, DisperseTransactor: DisperseTransactor{contract: contract}, DisperseFilterer: DisperseFilterer{contract: contract}}, nil
+}
+
+// Disperse is an auto generated Go binding around an Ethereum contract.
+type Disperse struct {
+	DisperseCaller     // Read-only binding to the contract
+	DisperseTransactor // Write-only binding to the contract
+	DisperseFilterer   // Log filterer for contract events
+}
+
+// DisperseCaller is an auto generated read-only Go binding around an Ethereum contract.
+type DisperseCaller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// DisperseTransactor is an auto generated write-only Go binding around an Ethereum contract.
+type DisperseTransactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// DisperseFilterer is an auto generated log filtering Go binding around an Ethereum contract events.
+type DisperseFilterer struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// DisperseSession is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type DisperseSession struct {
+	Contract     *Disperse         // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts     // Call options to use throughout this session
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// DisperseCallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type DisperseCallerSession struct {
+	Contract *DisperseCaller // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts   // Call options to use throughout this session
+}
+
+// DisperseTransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type DisperseTransactorSession struct {
+	Contract     *DisperseTransactor // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts   // Transaction auth options to use throughout this session
+}
+
+// DisperseRaw is an auto generated low-level Go binding around an Ethereum contract.
+type DisperseRaw struct {
+	Contract *Disperse // Generic contract binding to access the raw methods on
+}
+
+// DisperseCallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type DisperseCallerRaw struct {
+	Contract *DisperseCaller // Generic read-only contract binding to access the raw methods on
+}
+
+// DisperseTransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type DisperseTransactorRaw struct {
+	Contract *DisperseTransactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewDisperse creates a new instance of Disperse, bound to a specific deployed contract.
+func NewDisperse(address common.Address, backend bind.ContractBackend) (*Disperse, error) {
+	contract, err := bindDisperse(address, backend, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &Disperse{DisperseCaller: DisperseCaller{contract: contract}, DisperseTransactor: DisperseTransactor{contract: contract}, DisperseFilterer: DisperseFilterer{contract: contract}}, nil
+}
+
+// NewDisperseCaller creates a new read-only instance of Disperse, bound to a specific deployed contract.
+func NewDisperseCaller(address common.Address, caller bind.ContractCaller) (*DisperseCaller, error) {
+	contract, err := bindDisperse(address, caller, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &DisperseCaller{contract: contract}, nil
+}
+
+// NewDisperseTransactor creates a new write-only instance of Disperse, bound to a specific deployed contract.
+func NewDisperseTransactor(address common.Address, transactor bind.ContractTransactor) (*DisperseTransactor, error) {
+	contract, err := bindDisperse(address, nil, transactor, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &DisperseTransactor{contract: contract}, nil
+}
+
+// NewDisperseFilterer creates a new log filterer instance of Disperse, bound to a specific deployed contract.
+func NewDisperseFilterer(address common.Address, filterer bind.ContractFilterer) (*DisperseFilterer, error) {
+	contract, err := bindDisperse(address, nil, nil, filterer)
+	if err != nil {
+		return nil, err
+	}
+	return &DisperseFilterer{contract: contract}, nil
+}
+
+// bindDisperse binds a generic wrapper to an already deployed contract.
+func bindDisperse(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor, filterer bind.ContractFilterer) (*bind.BoundContract, error) {
+	parsed, err := abi.JSON(strings.NewReader(DisperseABI))
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, parsed, caller, transactor, filterer), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_Disperse *DisperseRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _Disperse.Contract.DisperseCaller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_Disperse *DisperseRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _Disperse.Contract.DisperseTransactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_Disperse *DisperseRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _Disperse.Contract.DisperseTransactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_Disperse *DisperseCallerRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _Disperse.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_Disperse *DisperseTransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _Disperse.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_Disperse *DisperseTransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _Disperse.Contract.contract.Transact(opts, method, params...)
+}
+
+// DisperseEther is a paid mutator transaction binding the contract method 0xe63d38ed.
+//
+// Solidity: function disperseEther(address[] recipients, uint256[] values) payable returns()
+func (_Disperse *DisperseTransactor) DisperseEther(opts *bind.TransactOpts, recipients []common.Address, values []*big.Int) (*types.Transaction, error) {
+	return _Disperse.contract.Transact(opts, "disperseEther", recipients, values)
+}
+
+// DisperseEther is a paid mutator transaction binding the contract method 0xe63d38ed.
+//
+// Solidity: function disperseEther(address[] recipients, uint256[] values) payable returns()
+func (_Disperse *DisperseSession) DisperseEther(recipients []common.Address, values []*big.Int) (*types.Transaction, error) {
+	return _Disperse.Contract.DisperseEther(&_Disperse.TransactOpts, recipients, values)
+}
+
+// DisperseEther is a paid mutator transaction binding the contract method 0xe63d38ed.
+//
+// Solidity: function disperseEther(address[] recipients, uint256[] values) payable returns()
+func (_Disperse *DisperseTransactorSession) DisperseEther(recipients []common.Address, values []*big.Int) (*types.Transaction, error) {
+	return _Disperse.Contract.DisperseEther(&_Disperse.TransactOpts, recipients, values)
+}
+
+// DisperseToken is a paid mutator transaction binding the contract method 0xc73a2d60.
+//
+// Solidity: function disperseToken(address token, address[] recipients, uint256[] values) returns()
+func (_Disperse *DisperseTransactor) DisperseToken(opts *bind.TransactOpts, token common.Address, recipients []common.Address, values []*big.Int) (*types.Transaction, error) {
+	return _Disperse.contract.Transact(opts, "disperseToken", token, recipients, values)
+}
+
+// DisperseToken is a paid mutator transaction binding the contract method 0xc73a2d60.
+//
+// Solidity: function disperseToken(address token, address[] recipients, uint256[] values) returns()
+func (_Disperse *DisperseSession) DisperseToken(token common.Address, recipients []common.Address, values []*big.Int) (*types.Transaction, error) {
+	return _Disperse.Contract.DisperseToken(&_Disperse.TransactOpts, token, recipients, values)
+}
+
+// DisperseToken is a paid mutator transaction binding the contract method 0xc73a2d60.
+//
+// Solidity: function disperseToken(address token, address[] recipients, uint256[] values) returns()
+func (_Disperse *DisperseTransactorSession) DisperseToken(token common.Address, recipients []common.Address, values []*big.Int) (*types.Transaction, error) {
+	return _Disperse.Contract.DisperseToken(&_Disperse.TransactOpts, token, recipients, values)
+}
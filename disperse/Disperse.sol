@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: MIT
+pragma solidity 0.8.19;
+
+/**
+ * @dev Sends ETH or an ERC20 token to many recipients in a single transaction, in the spirit of disperse.app.
+ *
+ * disperseEther forwards values[i] to recipients[i] and refunds the balance left to the caller. disperseToken pulls
+ * the total from the caller with transferFrom, so the contract must be approved first, and then transfers values[i]
+ * to recipients[i]. Any failed transfer reverts the whole batch.
+ */
+contract Disperse {
+    function disperseEther(address[] calldata recipients, uint256[] calldata values) external payable {
+        require(recipients.length == values.length, "Disperse: length mismatch");
+        for (uint256 i = 0; i < recipients.length; i++) {
+            (bool sent, ) = recipients[i].call{value: values[i]}("");
+            require(sent, "Disperse: call failed");
+        }
+
+        (bool refunded, ) = msg.sender.call{value: address(this).balance}("");
+        require(refunded, "Disperse: call failed");
+    }
+
+    function disperseToken(address token, address[] calldata recipients, uint256[] calldata values) external {
+        require(recipients.length == values.length, "Disperse: length mismatch");
+        uint256 total = 0;
+        for (uint256 i = 0; i < values.length; i++) {
+            total += values[i];
+        }
+
+        _tokenCall(token, abi.encodeWithSelector(0x23b872dd, msg.sender, address(this), total));
+        for (uint256 i = 0; i < recipients.length; i++) {
+            _tokenCall(token, abi.encodeWithSelector(0xa9059cbb, recipients[i], values[i]));
+        }
+    }
+
+    /**
+     * @dev Calls token with data and requires it not to return false, so that tokens returning nothing work too.
+     */
+    function _tokenCall(address token, bytes memory data) private {
+        (bool success, bytes memory result) = token.call(data);
+        require(success && (result.length == 0 || abi.decode(result, (bool))), "Disperse: call failed");
+    }
+}
@@ -0,0 +1,10 @@
+// Package disperse holds the Disperse contract and the Go binding generated from it.
+package disperse
+
+// Disperse.abi and Disperse.bin are compiled by the pinned ethereum/solc image, and the bindings by the abigen of the
+// go-ethereum version in go.mod, so that the bytecode and the bindings can be reproduced. The eth package only needs
+// the ABI. Disperse.easm is the hand-written assembly the checked-in Disperse.bin was built from, to be removed once
+// the bytecode is regenerated from Disperse.sol; both have the same ABI and behaviour.
+//go:generate docker run --rm -v $PWD:/sources -w /sources ethereum/solc:0.8.19 --optimize --optimize-runs 200 --evm-version london --abi --bin --overwrite -o . Disperse.sol
+//go:generate go run github.com/ethereum/go-ethereum/cmd/abigen --abi Disperse.abi --bin Disperse.bin --pkg disperse --type Disperse --out Disperse.go
+//go:generate go run github.com/ethereum/go-ethereum/cmd/abigen --abi Disperse.abi --pkg eth --type Disperse --out ../eth/disperse.go
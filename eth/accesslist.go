@@ -0,0 +1,69 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// CreateAccessList asks the node for the access list of the transaction request describes and estimates the
+// gas with and without it. Nodes list every slot the call touches, so entries that cost more than they save are
+// dropped before the estimate.
+func (svc *Service) CreateAccessList(ctx context.Context, request CreateTransactionRequest) (*AccessListResult, error) {
+	call, err := svc.transactionCall(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return svc.createAccessList(ctx, *call)
+}
+
+func (svc *Service) createAccessList(ctx context.Context, call ethereum.CallMsg) (*AccessListResult, error) {
+	backend, ok := svc.client.(AccessListBackend)
+	if !ok {
+		return nil, ErrAccessListNotSupported
+	}
+
+	accessList, _, vmErr, err := backend.CreateAccessList(ctx, call)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(vmErr) > 0 {
+		return nil, errors.New(vmErr)
+	}
+
+	result := AccessListResult{AccessList: pruneAccessList(*accessList, call.From, *call.To)}
+	call.AccessList = nil
+	result.GasWithoutAccessList, err = svc.client.EstimateGas(ctx, call)
+	if err != nil {
+		return nil, err
+	}
+
+	call.AccessList = result.AccessList
+	result.GasWithAccessList, err = backend.EstimateGasWithAccessList(ctx, call)
+	if err != nil {
+		return nil, err
+	}
+
+	result.GasSaving = int64(result.GasWithoutAccessList) - int64(result.GasWithAccessList)
+	return &result, nil
+}
+
+// pruneAccessList drops the entries of the sender and recipient, which are warm anyway, unless they list enough
+// storage keys to pay for the address. A listed key saves the difference between a cold and a warm read.
+func pruneAccessList(accessList types.AccessList, from, to common.Address) types.AccessList {
+	keySaving := params.ColdSloadCostEIP2929 - params.WarmStorageReadCostEIP2929 - params.TxAccessListStorageKeyGas
+	result := types.AccessList{}
+	for _, tuple := range accessList {
+		warm := tuple.Address == from || tuple.Address == to
+		if warm && uint64(len(tuple.StorageKeys))*keySaving <= params.TxAccessListAddressGas {
+			continue
+		}
+		result = append(result, tuple)
+	}
+	return result
+}
@@ -0,0 +1,153 @@
+package eth
+
+import (
+	"context"
+	"demo/permit"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func Test_CreateAccessList(t *testing.T) {
+	svc, backend := getSimulatedService(t)
+	ctx := context.Background()
+	auth := getSimulatedAuth(t, backend, owner1PrivateKey)
+	implementation, _, _, err := permit.DeployPermitToken(auth, backend)
+	assert.NoError(t, err)
+	proxyAddress, _, _, err := permit.DeployPermitProxy(auth, backend, implementation)
+	assert.NoError(t, err)
+	backend.Commit()
+
+	request := CreateTransactionRequest{
+		TokenAddress: proxyAddress.Hex(),
+		From:         owner1Addr,
+		To:           owner2Addr,
+		Amount:       "1.5",
+	}
+	result, err := svc.CreateAccessList(ctx, request)
+	assert.NoError(t, err)
+	assert.Equal(t, types.AccessList{{Address: implementation, StorageKeys: []common.Hash{}}}, result.AccessList)
+	assert.Greater(t, result.GasSaving, int64(0))
+	assert.Equal(t, int64(result.GasWithoutAccessList-result.GasWithAccessList), result.GasSaving)
+
+	// a plain token only touches its own storage, which is not worth listing
+	request.TokenAddress = implementation.Hex()
+	result, err = svc.CreateAccessList(ctx, request)
+	assert.NoError(t, err)
+	assert.Empty(t, result.AccessList)
+	assert.Equal(t, int64(0), result.GasSaving)
+}
+
+// accessListNode is the eth namespace of a node whose estimates are 2000 gas lower for calls with an access list.
+type accessListNode struct {
+	listed  common.Address
+	mu      sync.Mutex
+	queries []map[string]interface{}
+}
+
+func (n *accessListNode) CreateAccessList(args map[string]interface{}) (map[string]interface{}, error) {
+	accessList := types.AccessList{{Address: n.listed, StorageKeys: []common.Hash{}}}
+	return map[string]interface{}{"accessList": accessList, "gasUsed": hexutil.Uint64(48000)}, nil
+}
+
+func (n *accessListNode) EstimateGas(args map[string]interface{}) (hexutil.Uint64, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.queries = append(n.queries, args)
+	if _, ok := args["accessList"]; ok {
+		return 48000, nil
+	}
+	return 50000, nil
+}
+
+func Test_CreateAccessListRPC(t *testing.T) {
+	node := &accessListNode{listed: common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3")}
+	server := rpc.NewServer()
+	assert.NoError(t, server.RegisterName("eth", node))
+	defer server.Stop()
+	client := rpc.DialInProc(server)
+	defer client.Close()
+	svc := NewService(NewRPCBackend(client), 0, 1)
+
+	to := common.HexToAddress(owner2Addr)
+	call := ethereum.CallMsg{From: common.HexToAddress(owner1Addr), To: &to, Data: []byte{0xa9, 0x05, 0x9c, 0xbb}}
+	result, err := svc.createAccessList(context.Background(), call)
+	assert.NoError(t, err)
+	assert.Equal(t, types.AccessList{{Address: node.listed, StorageKeys: []common.Hash{}}}, result.AccessList)
+	assert.Equal(t, int64(2000), result.GasSaving, "the access list reaches eth_estimateGas")
+	assert.Len(t, node.queries, 2)
+	assert.NotContains(t, node.queries[0], "accessList")
+	assert.Contains(t, node.queries[1], "accessList")
+}
+
+func Test_CreateTransactionAccessList(t *testing.T) {
+	svc, backend := getSimulatedService(t)
+	ctx := context.Background()
+	auth := getSimulatedAuth(t, backend, owner1PrivateKey)
+	implementation, _, _, err := permit.DeployPermitToken(auth, backend)
+	assert.NoError(t, err)
+	proxyAddress, _, _, err := permit.DeployPermitProxy(auth, backend, implementation)
+	assert.NoError(t, err)
+	backend.Commit()
+
+	gasPrice, _ := svc.SuggestGasPrice(ctx)
+	nonce, _ := svc.Nonce(ctx, owner1Addr)
+	tx, err := svc.CreateTransaction(ctx, CreateTransactionRequest{
+		TokenAddress:       proxyAddress.Hex(),
+		From:               owner1Addr,
+		To:                 owner2Addr,
+		Amount:             "1.5",
+		GasMaxFee:          gasPrice.Add(*gasPrice).String(),
+		GasTip:             1,
+		Nonce:              nonce,
+		TxType:             types.DynamicFeeTxType,
+		GenerateAccessList: true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(types.DynamicFeeTxType), tx.Type())
+	assert.Equal(t, types.AccessList{{Address: implementation, StorageKeys: []common.Hash{}}}, tx.AccessList())
+
+	accessList := types.AccessList{{Address: implementation, StorageKeys: []common.Hash{}}}
+	tx2, err := svc.CreateTransaction(ctx, CreateTransactionRequest{
+		TokenAddress: proxyAddress.Hex(),
+		From:         owner1Addr,
+		To:           owner2Addr,
+		Amount:       "0.5",
+		GasMaxFee:    gasPrice.String(),
+		Nonce:        nonce + 1,
+		TxType:       types.AccessListTxType,
+		AccessList:   accessList,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(types.AccessListTxType), tx2.Type())
+	assert.Equal(t, accessList, tx2.AccessList())
+
+	for _, tx := range []*types.Transaction{tx, tx2} {
+		signedTx, err := svc.SignTransaction(ctx, tx, owner1PrivateKey)
+		assert.NoError(t, err)
+		assert.NoError(t, svc.Broadcast(ctx, signedTx))
+		backend.Commit()
+
+		receipt, err := backend.TransactionReceipt(ctx, signedTx.Hash())
+		assert.NoError(t, err)
+		assert.Equal(t, types.ReceiptStatusSuccessful, receipt.Status)
+	}
+
+	balance, err := svc.BalanceERC20(ctx, proxyAddress.Hex(), owner2Addr)
+	assert.NoError(t, err)
+	assert.Equal(t, "2", balance.String())
+
+	_, err = svc.CreateTransaction(ctx, CreateTransactionRequest{
+		From:       owner1Addr,
+		To:         owner2Addr,
+		Amount:     "1",
+		GasMaxFee:  gasPrice.String(),
+		AccessList: accessList,
+	})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+}
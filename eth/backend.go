@@ -0,0 +1,228 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/tracers/logger"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"math/big"
+)
+
+// Backend is the node interface used by Service. *ethclient.Client satisfies it,
+// and SimulatedBackend adapts an in-memory chain to it.
+type Backend interface {
+	bind.ContractBackend
+	bind.DeployBackend
+	ethereum.PendingContractCaller
+	ethereum.ChainReader
+	ethereum.ChainStateReader
+	ethereum.TransactionReader
+	BlockNumber(ctx context.Context) (uint64, error)
+	NetworkID(ctx context.Context) (*big.Int, error)
+}
+
+// AccessListBackend is implemented by backends that support eth_createAccessList.
+// CreateAccessList returns the access list, the gas used with it and the EVM error, if any.
+// EstimateGasWithAccessList estimates msg with its access list, which EstimateGas of
+// ethclient leaves out of the eth_estimateGas call.
+type AccessListBackend interface {
+	CreateAccessList(ctx context.Context, msg ethereum.CallMsg) (*types.AccessList, uint64, string, error)
+	EstimateGasWithAccessList(ctx context.Context, msg ethereum.CallMsg) (uint64, error)
+}
+
+// RPCBackend is an ethclient.Client that also exposes the geth specific calls
+// Service can make use of.
+type RPCBackend struct {
+	*ethclient.Client
+	geth *gethclient.Client
+	rpc  *rpc.Client
+}
+
+func NewRPCBackend(client *rpc.Client) *RPCBackend {
+	return &RPCBackend{Client: ethclient.NewClient(client), geth: gethclient.New(client), rpc: client}
+}
+
+func DialBackend(rawurl string) (*RPCBackend, error) {
+	client, err := rpc.Dial(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return NewRPCBackend(client), nil
+}
+
+func (b *RPCBackend) CreateAccessList(ctx context.Context, msg ethereum.CallMsg) (*types.AccessList, uint64, string, error) {
+	return b.geth.CreateAccessList(ctx, msg)
+}
+
+func (b *RPCBackend) EstimateGasWithAccessList(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	var gas hexutil.Uint64
+	if err := b.rpc.CallContext(ctx, &gas, "eth_estimateGas", callArg(msg)); err != nil {
+		return 0, err
+	}
+	return uint64(gas), nil
+}
+
+// callArg is the call object of msg for the JSON-RPC API, with the fee caps and the access list that ethclient
+// does not send.
+func callArg(msg ethereum.CallMsg) map[string]interface{} {
+	arg := map[string]interface{}{
+		"from": msg.From,
+		"to":   msg.To,
+	}
+	if len(msg.Data) > 0 {
+		arg["data"] = hexutil.Bytes(msg.Data)
+	}
+	if msg.Value != nil {
+		arg["value"] = (*hexutil.Big)(msg.Value)
+	}
+	if msg.Gas != 0 {
+		arg["gas"] = hexutil.Uint64(msg.Gas)
+	}
+	if msg.GasPrice != nil {
+		arg["gasPrice"] = (*hexutil.Big)(msg.GasPrice)
+	}
+	if msg.GasFeeCap != nil {
+		arg["maxFeePerGas"] = (*hexutil.Big)(msg.GasFeeCap)
+	}
+	if msg.GasTipCap != nil {
+		arg["maxPriorityFeePerGas"] = (*hexutil.Big)(msg.GasTipCap)
+	}
+	if msg.AccessList != nil {
+		arg["accessList"] = msg.AccessList
+	}
+	return arg
+}
+
+// SimulatedBackend wraps backends.SimulatedBackend with the few calls it is missing.
+type SimulatedBackend struct {
+	*backends.SimulatedBackend
+}
+
+func NewSimulatedBackend(backend *backends.SimulatedBackend) *SimulatedBackend {
+	return &SimulatedBackend{SimulatedBackend: backend}
+}
+
+func (b *SimulatedBackend) BlockNumber(ctx context.Context) (uint64, error) {
+	return b.Blockchain().CurrentBlock().NumberU64(), nil
+}
+
+func (b *SimulatedBackend) NetworkID(ctx context.Context) (*big.Int, error) {
+	return b.Blockchain().Config().ChainID, nil
+}
+
+// CreateAccessList runs msg against the latest block the same way geth does for
+// eth_createAccessList: it is traced repeatedly until the access list it touches
+// stops changing.
+func (b *SimulatedBackend) CreateAccessList(ctx context.Context, msg ethereum.CallMsg) (*types.AccessList, uint64, string, error) {
+	if msg.To == nil {
+		return nil, 0, "", errors.New("contract creation is not supported")
+	}
+
+	chain := b.Blockchain()
+	header := chain.CurrentHeader()
+	precompiles := vm.ActivePrecompiles(chain.Config().Rules(header.Number, false))
+	prevTracer := logger.NewAccessListTracer(msg.AccessList, msg.From, *msg.To, precompiles)
+	for {
+		accessList := prevTracer.AccessList()
+		statedb, err := chain.State()
+		if err != nil {
+			return nil, 0, "", err
+		}
+
+		tracer := logger.NewAccessListTracer(accessList, msg.From, *msg.To, precompiles)
+		result, err := b.applyMessage(header, statedb, msg, accessList, vm.Config{Tracer: tracer, Debug: true, NoBaseFee: true})
+		if err != nil {
+			return nil, 0, "", err
+		}
+
+		if tracer.Equal(prevTracer) {
+			vmErr := ""
+			if result.Err != nil {
+				vmErr = result.Err.Error()
+			}
+			return &accessList, result.UsedGas, vmErr, nil
+		}
+		prevTracer = tracer
+	}
+}
+
+// EstimateGasWithAccessList is EstimateGas, the simulated backend already estimates with the access list of msg.
+func (b *SimulatedBackend) EstimateGasWithAccessList(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	return b.EstimateGas(ctx, msg)
+}
+
+// CallContract also runs msg on the state of past blocks, which the wrapped backend refuses.
+func (b *SimulatedBackend) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	chain := b.Blockchain()
+	if blockNumber == nil || blockNumber.Cmp(chain.CurrentBlock().Number()) == 0 {
+		return b.SimulatedBackend.CallContract(ctx, msg, blockNumber)
+	}
+
+	header := chain.GetHeaderByNumber(blockNumber.Uint64())
+	if header == nil {
+		return nil, ethereum.NotFound
+	}
+
+	statedb, err := chain.StateAt(header.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := b.applyMessage(header, statedb, msg, msg.AccessList, vm.Config{NoBaseFee: true})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Revert()) > 0 {
+		return nil, &revertError{error: result.Err, data: hexutil.Encode(result.Revert())}
+	}
+	return result.Return(), result.Err
+}
+
+func (b *SimulatedBackend) applyMessage(header *types.Header, statedb *state.StateDB, msg ethereum.CallMsg,
+	accessList types.AccessList, config vm.Config) (*core.ExecutionResult, error) {
+	chain := b.Blockchain()
+	gas := msg.Gas
+	if gas == 0 {
+		gas = header.GasLimit
+	}
+
+	value := msg.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+
+	evmMsg := types.NewMessage(msg.From, msg.To, statedb.GetNonce(msg.From), value, gas,
+		new(big.Int), new(big.Int), new(big.Int), msg.Data, accessList, true)
+	evm := vm.NewEVM(core.NewEVMBlockContext(header, chain, &common.Address{}), core.NewEVMTxContext(evmMsg),
+		statedb, chain.Config(), config)
+	return core.ApplyMessage(evm, evmMsg, new(core.GasPool).AddGas(gas))
+}
+
+// revertErrorCode is the JSON-RPC error code of a reverted call.
+const revertErrorCode = 3
+
+// revertError carries the revert data of a call the way RPC errors do, see rpc.DataError.
+type revertError struct {
+	error
+	data string
+}
+
+func (e *revertError) ErrorCode() int {
+	return revertErrorCode
+}
+
+func (e *revertError) ErrorData() interface{} {
+	return e.data
+}
@@ -0,0 +1,88 @@
+package eth
+
+import (
+	"context"
+	"demo/amount"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+)
+
+// tokenCallMethods are the ERC20 methods DecodeTransaction understands.
+var tokenCallMethods = map[string]bool{"transfer": true, "transferFrom": true, "approve": true}
+
+// DecodeTransaction parses a hex encoded raw signed transaction, legacy or typed, and recovers its sender. It does
+// not use the node, so a transaction can be inspected before it is broadcast.
+func (svc *Service) DecodeTransaction(ctx context.Context, rawTx string) (*DecodedTransaction, error) {
+	data, err := hexutil.Decode(rawTx)
+	if err != nil {
+		return nil, ErrInvalidInput
+	}
+
+	tx := new(types.Transaction)
+	if err = tx.UnmarshalBinary(data); err != nil {
+		return nil, ErrInvalidInput
+	}
+
+	var chainID *big.Int
+	if tx.Protected() {
+		chainID = tx.ChainId()
+	}
+
+	from, err := types.Sender(types.LatestSignerForChainID(chainID), tx)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	decoded := DecodedTransaction{
+		Hash:       tx.Hash().Hex(),
+		Type:       tx.Type(),
+		ChainID:    chainID,
+		From:       from.Hex(),
+		Nonce:      tx.Nonce(),
+		GasLimit:   tx.Gas(),
+		GasTipCap:  amount.FromBase(tx.GasTipCap(), amount.Gwei),
+		GasFeeCap:  amount.FromBase(tx.GasFeeCap(), amount.Gwei),
+		MaxFee:     transactionMaxFee(tx),
+		Value:      amount.FromBase(tx.Value(), amount.Ether),
+		Data:       hexutil.Encode(tx.Data()),
+		AccessList: tx.AccessList(),
+	}
+	if tx.Type() != types.DynamicFeeTxType {
+		decoded.GasPrice = amount.FromBase(tx.GasPrice(), amount.Gwei)
+	}
+
+	if tx.To() != nil {
+		decoded.To = tx.To().Hex()
+		decoded.TokenCall = svc.decodeTokenCall(tx.Data())
+	}
+	return &decoded, nil
+}
+
+// decodeTokenCall returns nil when data is not a well formed ERC20 call.
+func (svc *Service) decodeTokenCall(data []byte) *TokenCall {
+	if len(data) < 4 {
+		return nil
+	}
+
+	method, err := svc.eabi.MethodById(data[:4])
+	if err != nil || !tokenCallMethods[method.RawName] {
+		return nil
+	}
+
+	args, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil
+	}
+
+	call := TokenCall{Method: method.RawName}
+	if method.RawName == "transferFrom" {
+		call.From = args[0].(common.Address).Hex()
+		args = args[1:]
+	}
+
+	call.To = args[0].(common.Address).Hex()
+	call.Value = args[1].(*big.Int)
+	return &call
+}
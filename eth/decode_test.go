@@ -0,0 +1,89 @@
+package eth
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+)
+
+func Test_DecodeTransaction(t *testing.T) {
+	// no node: DecodeTransaction must not touch the client
+	svc := NewService(nil, 0, 1)
+	ctx := context.Background()
+	privateKey, _ := ParsePrivateKey(owner1PrivateKey)
+	to := common.HexToAddress(owner2Addr)
+	tokenAddress := common.HexToAddress(tokenAddr)
+	chainID := big.NewInt(1337)
+
+	input, err := svc.eabi.Pack("transferFrom", common.HexToAddress(owner1Addr), to, big.NewInt(25))
+	assert.NoError(t, err)
+	tx, err := types.SignNewTx(privateKey, types.NewLondonSigner(chainID), &types.DynamicFeeTx{
+		ChainID:    chainID,
+		Nonce:      7,
+		GasTipCap:  big.NewInt(2 * params.GWei),
+		GasFeeCap:  big.NewInt(30 * params.GWei),
+		Gas:        60000,
+		To:         &tokenAddress,
+		Data:       input,
+		AccessList: types.AccessList{{Address: tokenAddress, StorageKeys: []common.Hash{{1}}}},
+	})
+	assert.NoError(t, err)
+
+	decoded, err := svc.DecodeTransaction(ctx, encodeTx(t, tx))
+	assert.NoError(t, err)
+	assert.Equal(t, tx.Hash().Hex(), decoded.Hash)
+	assert.Equal(t, uint8(types.DynamicFeeTxType), decoded.Type)
+	assert.Equal(t, "1337", decoded.ChainID.String())
+	assert.Equal(t, owner1Addr, decoded.From)
+	assert.Equal(t, tokenAddress.Hex(), decoded.To)
+	assert.Equal(t, uint64(7), decoded.Nonce)
+	assert.Equal(t, "2", decoded.GasTipCap.String())
+	assert.Equal(t, "30", decoded.GasFeeCap.String())
+	assert.True(t, decoded.GasPrice.IsZero())
+	assert.Equal(t, "0.0018", decoded.MaxFee.String())
+	assert.Equal(t, tx.AccessList(), decoded.AccessList)
+	assert.Equal(t, &TokenCall{Method: "transferFrom", From: owner1Addr, To: owner2Addr, Value: big.NewInt(25)}, decoded.TokenCall)
+
+	input, _ = svc.eabi.Pack("approve", to, big.NewInt(9))
+	tx, _ = types.SignNewTx(privateKey, types.NewLondonSigner(chainID), &types.AccessListTx{
+		ChainID: chainID, Nonce: 8, GasPrice: big.NewInt(params.GWei), Gas: 50000, To: &tokenAddress, Data: input,
+	})
+	decoded, err = svc.DecodeTransaction(ctx, encodeTx(t, tx))
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(types.AccessListTxType), decoded.Type)
+	assert.Equal(t, "1", decoded.GasPrice.String())
+	assert.Equal(t, &TokenCall{Method: "approve", To: owner2Addr, Value: big.NewInt(9)}, decoded.TokenCall)
+
+	// a plain transfer without replay protection
+	tx, _ = types.SignNewTx(privateKey, types.HomesteadSigner{}, &types.LegacyTx{
+		Nonce: 9, GasPrice: big.NewInt(params.GWei), Gas: 21000, To: &to, Value: big.NewInt(params.Ether / 2),
+	})
+	decoded, err = svc.DecodeTransaction(ctx, encodeTx(t, tx))
+	assert.NoError(t, err)
+	assert.Nil(t, decoded.ChainID)
+	assert.Equal(t, owner1Addr, decoded.From)
+	assert.Equal(t, "0.5", decoded.Value.String())
+	assert.Equal(t, "0x", decoded.Data)
+	assert.Nil(t, decoded.TokenCall)
+
+	unsigned, _ := types.NewTransaction(0, to, big.NewInt(1), 21000, big.NewInt(1), nil).MarshalBinary()
+	_, err = svc.DecodeTransaction(ctx, hexutil.Encode(unsigned))
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	_, err = svc.DecodeTransaction(ctx, "0x02ff")
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	_, err = svc.DecodeTransaction(ctx, "not hex")
+	assert.ErrorIs(t, err, ErrInvalidInput)
+}
+
+func encodeTx(t *testing.T, tx *types.Transaction) string {
+	data, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hexutil.Encode(data)
+}
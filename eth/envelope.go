@@ -0,0 +1,270 @@
+package eth
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"demo/amount"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/shopspring/decimal"
+	"math/big"
+	"strings"
+)
+
+const envelopeVersion = 1
+
+// ExportTransaction creates the transaction of request and wraps it in an envelope that can be signed offline with
+// SignEnvelope.
+func (svc *Service) ExportTransaction(ctx context.Context, request CreateTransactionRequest) (*SigningEnvelope, error) {
+	if !common.IsHexAddress(request.From) || !common.IsHexAddress(request.To) {
+		return nil, ErrInvalidInput
+	}
+
+	tx, err := svc.CreateTransaction(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	chainID, err := svc.client.NetworkID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	unsignedTx, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	envelope := SigningEnvelope{
+		Version:    envelopeVersion,
+		ChainID:    chainID.String(),
+		From:       common.HexToAddress(request.From).Hex(),
+		UnsignedTx: hexutil.Encode(unsignedTx),
+	}
+
+	symbol := "ETH"
+	if len(request.TokenAddress) > 0 {
+		tokenInfo, err := svc.ERC20Info(ctx, request.TokenAddress)
+		if err != nil {
+			return nil, err
+		}
+
+		symbol = tokenInfo.Symbol
+		envelope.TokenAddress = common.HexToAddress(request.TokenAddress).Hex()
+		envelope.TokenSymbol = tokenInfo.Symbol
+		envelope.TokenDecimals = tokenInfo.Decimals
+	}
+
+	value, err := decimal.NewFromString(request.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	envelope.Summary = fmt.Sprintf("send %s %s from %s to %s, nonce %d, gas limit %d, max fee %s ETH, chain %s",
+		value, symbol, envelope.From, common.HexToAddress(request.To).Hex(), tx.Nonce(), tx.Gas(), transactionMaxFee(tx), chainID)
+	envelope.Checksum, err = envelopeChecksum(&envelope)
+	if err != nil {
+		return nil, err
+	}
+	return &envelope, nil
+}
+
+// SignEnvelope signs the transaction of envelope with privateKey and stores it in SignedTx. It needs no node, so it
+// can run on an air-gapped host; the key must belong to the envelope's From address.
+func SignEnvelope(envelope *SigningEnvelope, privateKey string) error {
+	tx, chainID, err := unsignedEnvelopeTransaction(envelope)
+	if err != nil {
+		return err
+	}
+
+	privateKeyECDSA, err := ParsePrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+
+	if crypto.PubkeyToAddress(privateKeyECDSA.PublicKey) != common.HexToAddress(envelope.From) {
+		return ErrInvalidSignature
+	}
+
+	signedTx, err := types.SignTx(tx, types.NewLondonSigner(chainID), privateKeyECDSA)
+	if err != nil {
+		return err
+	}
+
+	data, err := signedTx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	envelope.SignedTx = hexutil.Encode(data)
+	return nil
+}
+
+// VerifyEnvelope checks a signed envelope against the request it was exported for and returns the transaction to
+// broadcast. It fails with ErrEnvelopeTampered when the envelope, the transaction or its signature was changed
+// after the export.
+func (svc *Service) VerifyEnvelope(ctx context.Context, envelope *SigningEnvelope, request CreateTransactionRequest) (*types.Transaction, error) {
+	tx, chainID, err := unsignedEnvelopeTransaction(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeChainID, err := svc.client.NetworkID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if nodeChainID.Cmp(chainID) != 0 || common.HexToAddress(request.From) != common.HexToAddress(envelope.From) ||
+		common.HexToAddress(request.TokenAddress) != common.HexToAddress(envelope.TokenAddress) {
+		return nil, ErrEnvelopeTampered
+	}
+
+	if err = svc.matchRequest(ctx, tx, chainID, request); err != nil {
+		return nil, err
+	}
+
+	signedData, err := hexutil.Decode(envelope.SignedTx)
+	if err != nil {
+		return nil, ErrEnvelopeTampered
+	}
+
+	signedTx := new(types.Transaction)
+	if err = signedTx.UnmarshalBinary(signedData); err != nil {
+		return nil, ErrEnvelopeTampered
+	}
+
+	signer := types.NewLondonSigner(chainID)
+	if signer.Hash(signedTx) != signer.Hash(tx) {
+		return nil, ErrEnvelopeTampered
+	}
+
+	sender, err := types.Sender(signer, signedTx)
+	if err != nil || sender != common.HexToAddress(envelope.From) {
+		return nil, ErrInvalidSignature
+	}
+	return signedTx, nil
+}
+
+// matchRequest rebuilds the transaction of request and compares it with tx. The gas limit of an estimated token
+// transfer may change between the export and the import, so it only has to stay within the estimate of the import
+// times the multiplier of the service.
+func (svc *Service) matchRequest(ctx context.Context, tx *types.Transaction, chainID *big.Int, request CreateTransactionRequest) error {
+	call, err := svc.transactionCall(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	maxFee, err := parseBaseUnits(request.GasMaxFee, amount.Ether)
+	if err != nil {
+		return err
+	}
+
+	call.AccessList, err = svc.accessList(ctx, request, call)
+	if err != nil {
+		return err
+	}
+
+	expected := newTransaction(chainID, request, call, maxFee)
+	switch {
+	case tx.Type() != expected.Type(), tx.Nonce() != expected.Nonce(), tx.GasFeeCap().Cmp(expected.GasFeeCap()) != 0,
+		tx.GasTipCap().Cmp(expected.GasTipCap()) != 0, tx.To() == nil || *tx.To() != *expected.To(),
+		tx.Value().Cmp(expected.Value()) != 0, !bytes.Equal(tx.Data(), expected.Data()),
+		!sameAccessList(tx.AccessList(), expected.AccessList()),
+		tx.Type() != types.LegacyTxType && tx.ChainId().Cmp(chainID) != 0:
+		return ErrEnvelopeTampered
+	}
+
+	if len(request.TokenAddress) < 1 || request.DisableEstimateGas {
+		if tx.Gas() != request.GasLimit {
+			return ErrEnvelopeTampered
+		}
+		return nil
+	}
+
+	estimate, err := svc.estimateGas(ctx, *call)
+	if err != nil {
+		return err
+	}
+
+	if tx.Gas() > uint64(float64(estimate)*svc.gasMultiplier()) {
+		return ErrEnvelopeTampered
+	}
+	return nil
+}
+
+// sameAccessList compares a and b entry by entry, with nil and empty lists equal.
+func sameAccessList(a, b types.AccessList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i].Address != b[i].Address || len(a[i].StorageKeys) != len(b[i].StorageKeys) {
+			return false
+		}
+
+		for j := range a[i].StorageKeys {
+			if a[i].StorageKeys[j] != b[i].StorageKeys[j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// unsignedEnvelopeTransaction checks the checksum of envelope and decodes its unsigned transaction.
+func unsignedEnvelopeTransaction(envelope *SigningEnvelope) (*types.Transaction, *big.Int, error) {
+	if envelope.Version != envelopeVersion {
+		return nil, nil, ErrInvalidInput
+	}
+
+	checksum, err := envelopeChecksum(envelope)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !strings.EqualFold(checksum, envelope.Checksum) {
+		return nil, nil, ErrEnvelopeTampered
+	}
+
+	chainID, ok := new(big.Int).SetString(envelope.ChainID, 10)
+	if !ok {
+		return nil, nil, ErrEnvelopeTampered
+	}
+
+	data, err := hexutil.Decode(envelope.UnsignedTx)
+	if err != nil {
+		return nil, nil, ErrEnvelopeTampered
+	}
+
+	tx := new(types.Transaction)
+	if err = tx.UnmarshalBinary(data); err != nil {
+		return nil, nil, ErrEnvelopeTampered
+	}
+
+	if tx.Type() != types.LegacyTxType && tx.ChainId().Cmp(chainID) != 0 {
+		return nil, nil, ErrEnvelopeTampered
+	}
+	return tx, chainID, nil
+}
+
+// envelopeChecksum is the SHA-256 of envelope without its signature. It catches corrupted or hand edited
+// envelopes; VerifyEnvelope compares the transaction with the original request as well, because anyone can
+// recompute it.
+func envelopeChecksum(envelope *SigningEnvelope) (string, error) {
+	unsigned := *envelope
+	unsigned.SignedTx, unsigned.Checksum = "", ""
+	data, err := json.Marshal(unsigned)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,156 @@
+package eth
+
+import (
+	"context"
+	"demo/token"
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+)
+
+func Test_SigningEnvelope(t *testing.T) {
+	svc, backend := getSimulatedService(t)
+	ctx := context.Background()
+	gasPrice, _ := svc.SuggestGasPrice(ctx)
+	nonce, _ := svc.Nonce(ctx, owner1Addr)
+	request := CreateTransactionRequest{
+		From:      owner1Addr,
+		To:        owner2Addr,
+		Amount:    "1.5",
+		GasLimit:  21000,
+		GasMaxFee: gasPrice.String(),
+		Nonce:     nonce,
+	}
+	envelope, err := svc.ExportTransaction(ctx, request)
+	assert.NoError(t, err)
+	assert.Equal(t, "1337", envelope.ChainID)
+	assert.Contains(t, envelope.Summary, "send 1.5 ETH from "+owner1Addr+" to "+owner2Addr)
+
+	// the envelope travels as JSON to the offline host and back
+	data, err := json.Marshal(envelope)
+	assert.NoError(t, err)
+	offline := SigningEnvelope{}
+	assert.NoError(t, json.Unmarshal(data, &offline))
+	assert.ErrorIs(t, SignEnvelope(&offline, owner2PrivateKey), ErrInvalidSignature)
+	assert.NoError(t, SignEnvelope(&offline, owner1PrivateKey))
+	data, err = json.Marshal(offline)
+	assert.NoError(t, err)
+	signed := SigningEnvelope{}
+	assert.NoError(t, json.Unmarshal(data, &signed))
+
+	tx, err := svc.VerifyEnvelope(ctx, &signed, request)
+	assert.NoError(t, err)
+	assert.NoError(t, svc.Broadcast(ctx, tx))
+	backend.Commit()
+	balance, _ := svc.BalanceETH(ctx, owner2Addr)
+	assert.Equal(t, "101.5", balance.String())
+
+	// the request on the online host is the reference
+	changed := request
+	changed.Amount = "2"
+	_, err = svc.VerifyEnvelope(ctx, &signed, changed)
+	assert.ErrorIs(t, err, ErrEnvelopeTampered)
+
+	edited := signed
+	edited.Summary = "send 0.1 ETH"
+	_, err = svc.VerifyEnvelope(ctx, &edited, request)
+	assert.ErrorIs(t, err, ErrEnvelopeTampered)
+	assert.ErrorIs(t, SignEnvelope(&edited, owner1PrivateKey), ErrEnvelopeTampered)
+
+	// a consistent envelope for another recipient still does not match the request
+	other := request
+	other.To = "0x35bb6eF95c72bf4804334BB9d6A3c77Bef18d81B"
+	forged, err := svc.ExportTransaction(ctx, other)
+	assert.NoError(t, err)
+	assert.NoError(t, SignEnvelope(forged, owner1PrivateKey))
+	_, err = svc.VerifyEnvelope(ctx, forged, request)
+	assert.ErrorIs(t, err, ErrEnvelopeTampered)
+
+	// so does a signed transaction swapped under an untouched envelope
+	swapped := signed
+	swapped.SignedTx = forged.SignedTx
+	_, err = svc.VerifyEnvelope(ctx, &swapped, request)
+	assert.ErrorIs(t, err, ErrEnvelopeTampered)
+}
+
+func Test_SigningEnvelopeToken(t *testing.T) {
+	svc, backend := getSimulatedService(t)
+	ctx := context.Background()
+	auth := getSimulatedAuth(t, backend, owner1PrivateKey)
+	tokenAddress, _, _, err := token.DeployToken(auth, backend, "gavin", "GV")
+	assert.NoError(t, err)
+	backend.Commit()
+
+	gasPrice, _ := svc.SuggestGasPrice(ctx)
+	nonce, _ := svc.Nonce(ctx, owner1Addr)
+	request := CreateTransactionRequest{
+		TokenAddress: tokenAddress.Hex(),
+		From:         owner1Addr,
+		To:           owner2Addr,
+		Amount:       "3",
+		GasMaxFee:    gasPrice.Add(*gasPrice).String(),
+		GasTip:       1,
+		Nonce:        nonce,
+		TxType:       types.DynamicFeeTxType,
+	}
+	envelope, err := svc.ExportTransaction(ctx, request)
+	assert.NoError(t, err)
+	assert.Equal(t, "GV", envelope.TokenSymbol)
+	assert.Equal(t, uint8(18), envelope.TokenDecimals)
+	assert.Contains(t, envelope.Summary, "send 3 GV")
+	assert.NoError(t, SignEnvelope(envelope, owner1PrivateKey))
+
+	tx, err := svc.VerifyEnvelope(ctx, envelope, request)
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(types.DynamicFeeTxType), tx.Type())
+
+	// every field of the transaction is checked, the gas of the estimate only up to the multiplier
+	for name, edit := range map[string]func(*types.DynamicFeeTx){
+		"tip":         func(inner *types.DynamicFeeTx) { inner.GasTipCap = inner.GasFeeCap },
+		"access list": func(inner *types.DynamicFeeTx) { inner.AccessList = types.AccessList{{Address: tokenAddress}} },
+		"gas":         func(inner *types.DynamicFeeTx) { inner.Gas *= 2 },
+	} {
+		inner := dynamicFeeTx(tx)
+		edit(inner)
+		forged := *envelope
+		data, _ := types.NewTx(inner).MarshalBinary()
+		forged.UnsignedTx = hexutil.Encode(data)
+		forged.Checksum, _ = envelopeChecksum(&forged)
+		assert.NoError(t, SignEnvelope(&forged, owner1PrivateKey), name)
+		_, err = svc.VerifyEnvelope(ctx, &forged, request)
+		assert.ErrorIs(t, err, ErrEnvelopeTampered, name)
+	}
+
+	assert.NoError(t, svc.Broadcast(ctx, tx))
+	backend.Commit()
+	balance, _ := svc.BalanceERC20(ctx, tokenAddress.Hex(), owner2Addr)
+	assert.Equal(t, "3", balance.String())
+
+	// the transaction must be for the chain the envelope names
+	data, _ := hexutil.Decode(envelope.UnsignedTx)
+	unsigned := new(types.Transaction)
+	assert.NoError(t, unsigned.UnmarshalBinary(data))
+	inner := dynamicFeeTx(unsigned)
+	inner.ChainID = big.NewInt(1)
+	data, _ = types.NewTx(inner).MarshalBinary()
+	envelope.UnsignedTx = hexutil.Encode(data)
+	envelope.Checksum, _ = envelopeChecksum(envelope)
+	assert.ErrorIs(t, SignEnvelope(envelope, owner1PrivateKey), ErrEnvelopeTampered)
+}
+
+func dynamicFeeTx(tx *types.Transaction) *types.DynamicFeeTx {
+	return &types.DynamicFeeTx{
+		ChainID:    tx.ChainId(),
+		Nonce:      tx.Nonce(),
+		GasTipCap:  tx.GasTipCap(),
+		GasFeeCap:  tx.GasFeeCap(),
+		Gas:        tx.Gas(),
+		To:         tx.To(),
+		Value:      tx.Value(),
+		Data:       tx.Data(),
+		AccessList: tx.AccessList(),
+	}
+}
@@ -0,0 +1,92 @@
+package eth
+
+import (
+	"context"
+	"demo/amount"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/shopspring/decimal"
+	"math/big"
+	"strings"
+)
+
+// L1DataFeeFunc returns the fee in wei that an L2 chain charged tx for posting its data to L1. The fee is paid
+// on top of gasUsed * effectiveGasPrice and does not show up in the receipt fields go-ethereum decodes.
+type L1DataFeeFunc func(ctx context.Context, tx *types.Transaction, receipt *types.Receipt) (*big.Int, error)
+
+var (
+	optimismGasPriceOracle    = common.HexToAddress("0x420000000000000000000000000000000000000F")
+	optimismGasPriceOracleABI = `[{"inputs":[{"internalType":"bytes","name":"_data","type":"bytes"}],"name":"getL1Fee","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"}]`
+)
+
+// SetL1DataFee makes the fees reported by Transaction and Block include the L1 data fee of an L2 chain.
+func (svc *Service) SetL1DataFee(l1DataFee L1DataFeeFunc) {
+	svc.l1DataFee = l1DataFee
+}
+
+// OptimismL1DataFee asks the GasPriceOracle predeploy of OP Stack chains for the L1 fee of each transaction,
+// at the block the transaction was mined in.
+func OptimismL1DataFee(client ethereum.ContractCaller) L1DataFeeFunc {
+	oracleABI, _ := abi.JSON(strings.NewReader(optimismGasPriceOracleABI))
+	return func(ctx context.Context, tx *types.Transaction, receipt *types.Receipt) (*big.Int, error) {
+		rawTx, err := tx.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := oracleABI.Pack("getL1Fee", rawTx)
+		if err != nil {
+			return nil, err
+		}
+
+		output, err := client.CallContract(ctx, ethereum.CallMsg{To: &optimismGasPriceOracle, Data: data}, receipt.BlockNumber)
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := oracleABI.Unpack("getL1Fee", output)
+		if err != nil {
+			return nil, err
+		}
+		if len(result) != 1 {
+			return nil, fmt.Errorf("getL1Fee returned %d values", len(result))
+		}
+
+		fee, ok := result[0].(*big.Int)
+		if !ok {
+			return nil, fmt.Errorf("getL1Fee returned %T, not a uint256", result[0])
+		}
+		return fee, nil
+	}
+}
+
+// transactionFee returns what tx cost its sender in ETH, and the L1 data fee part of it.
+func (svc *Service) transactionFee(ctx context.Context, tx *types.Transaction, receipt *types.Receipt, block *types.Block) (decimal.Decimal, decimal.Decimal, error) {
+	gasPrice := effectiveGasPrice(tx, block.BaseFee())
+	fee := new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(receipt.GasUsed))
+
+	l1Fee := new(big.Int)
+	if svc.l1DataFee != nil {
+		var err error
+		l1Fee, err = svc.l1DataFee(ctx, tx, receipt)
+		if err != nil {
+			return decimal0, decimal0, err
+		}
+		fee.Add(fee, l1Fee)
+	}
+
+	return amount.FromBase(fee, amount.Ether), amount.FromBase(l1Fee, amount.Ether), nil
+}
+
+// effectiveGasPrice is the price per gas tx paid in a block with baseFee, the same value nodes report as the
+// receipt's effectiveGasPrice: min(feeCap, baseFee+tipCap). For legacy and access list transactions both caps
+// are the gas price, so this is the gas price itself.
+func effectiveGasPrice(tx *types.Transaction, baseFee *big.Int) *big.Int {
+	if baseFee == nil {
+		return tx.GasPrice()
+	}
+	return new(big.Int).Add(baseFee, tx.EffectiveGasTipValue(baseFee))
+}
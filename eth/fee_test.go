@@ -0,0 +1,77 @@
+package eth
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+)
+
+func Test_TransactionFee(t *testing.T) {
+	svc, backend := getSimulatedService(t)
+	ctx := context.Background()
+	chainID, _ := backend.NetworkID(ctx)
+	to := common.HexToAddress("0x35bb6eF95c72bf4804334BB9d6A3c77Bef18d81B")
+	baseFee := backend.Blockchain().CurrentBlock().BaseFee()
+	gasPrice := new(big.Int).Add(baseFee, big.NewInt(2*params.GWei))
+
+	nonce, _ := svc.Nonce(ctx, owner1Addr)
+	txs := []*types.Transaction{
+		types.NewTransaction(nonce, to, big.NewInt(1), 21000, gasPrice, nil),
+		types.NewTx(&types.AccessListTx{
+			ChainID:  chainID,
+			Nonce:    nonce + 1,
+			GasPrice: gasPrice,
+			Gas:      30000,
+			To:       &to,
+			Value:    big.NewInt(1),
+			AccessList: types.AccessList{
+				{Address: to, StorageKeys: []common.Hash{{}}},
+			},
+		}),
+		// the fee cap binds, so only part of the tip is paid
+		types.NewTx(&types.DynamicFeeTx{
+			ChainID:   chainID,
+			Nonce:     nonce + 2,
+			GasTipCap: new(big.Int).Add(baseFee, big.NewInt(params.GWei)),
+			GasFeeCap: new(big.Int).Add(baseFee, big.NewInt(params.GWei)),
+			Gas:       21000,
+			To:        &to,
+			Value:     big.NewInt(1),
+		}),
+	}
+
+	for i, tx := range txs {
+		signedTx, err := svc.SignTransaction(ctx, tx, owner1PrivateKey)
+		assert.NoError(t, err)
+		assert.NoError(t, svc.Broadcast(ctx, signedTx))
+		txs[i] = signedTx
+	}
+	backend.Commit()
+
+	block := backend.Blockchain().CurrentBlock()
+	assert.Equal(t, 1, new(big.Int).Add(block.BaseFee(), txs[2].GasTipCap()).Cmp(txs[2].GasFeeCap()))
+	expected := []*big.Int{gasPrice, gasPrice, txs[2].GasFeeCap()}
+	for i, tx := range txs {
+		receipt, _ := backend.TransactionReceipt(ctx, tx.Hash())
+		txInfo, err := svc.Transaction(ctx, tx.Hash().Hex())
+		assert.NoError(t, err)
+
+		fee := new(big.Int).Mul(expected[i], new(big.Int).SetUint64(receipt.GasUsed))
+		assert.Equal(t, fee.String(), txInfo.Fee.Shift(18).String(), "tx type %d", tx.Type())
+		assert.True(t, txInfo.L1Fee.IsZero())
+	}
+
+	svc.SetL1DataFee(func(ctx context.Context, tx *types.Transaction, receipt *types.Receipt) (*big.Int, error) {
+		return big.NewInt(params.GWei), nil
+	})
+	receipt, _ := backend.TransactionReceipt(ctx, txs[0].Hash())
+	txInfo, err := svc.Transaction(ctx, txs[0].Hash().Hex())
+	assert.NoError(t, err)
+	fee := new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(receipt.GasUsed))
+	assert.Equal(t, new(big.Int).Add(fee, big.NewInt(params.GWei)).String(), txInfo.Fee.Shift(18).String())
+	assert.Equal(t, "0.000000001", txInfo.L1Fee.String())
+}
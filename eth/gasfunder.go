@@ -0,0 +1,248 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GasFunder tops up deposit addresses that hold tokens but not enough ETH to pay for their sweep. It keeps
+// track of everything it funded so that ETH left behind after the sweep can be reported.
+type GasFunder struct {
+	svc               *Service
+	fundingAddress    string
+	fundingPrivateKey string
+	pollInterval      time.Duration
+	notFoundGrace     time.Duration
+
+	mu     sync.Mutex
+	funded map[string]*GasFunding
+}
+
+func NewGasFunder(svc *Service, fundingAddress, fundingPrivateKey string) *GasFunder {
+	return &GasFunder{
+		svc:               svc,
+		fundingAddress:    fundingAddress,
+		fundingPrivateKey: fundingPrivateKey,
+		pollInterval:      3 * time.Second,
+		notFoundGrace:     time.Minute,
+		funded:            map[string]*GasFunding{},
+	}
+}
+
+// Fund sends each address skipped by plan for lack of gas the ETH its token transfers are missing. The
+// token transfers were estimated by PlanSweep with EstimateGas and the gas multiplier, so the same
+// GasMaxFee must be used when the address is swept.
+func (f *GasFunder) Fund(ctx context.Context, plan *SweepPlan) ([]*GasFunding, error) {
+	fundings := []*GasFunding{}
+	required := map[string]*GasFunding{}
+	for _, item := range plan.Skipped {
+		if item.Reason != SweepReasonInsufficientGas {
+			continue
+		}
+
+		if _, ok := required[item.Address]; !ok {
+			funding := &GasFunding{Index: item.Index, Address: item.Address}
+			required[item.Address] = funding
+			fundings = append(fundings, funding)
+		}
+	}
+
+	// the address has to pay for all of its token transfers, including the ones its balance already covered
+	items := make([]*SweepItem, 0, len(plan.Items)+len(plan.Skipped))
+	items = append(append(items, plan.Items...), plan.Skipped...)
+	for _, item := range items {
+		funding, ok := required[item.Address]
+		if !ok || len(item.TokenAddress) < 1 {
+			continue
+		}
+		funding.Amount = funding.Amount.Add(item.Fee)
+	}
+
+	if len(fundings) == 0 {
+		return fundings, nil
+	}
+
+	nonce, err := f.svc.Nonce(ctx, f.fundingAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, funding := range fundings {
+		balance, err := f.svc.BalanceETH(ctx, funding.Address)
+		if err != nil {
+			return nil, err
+		}
+
+		funding.Amount = funding.Amount.Sub(*balance)
+		if !funding.Amount.IsPositive() {
+			funding.State = GasFundingConfirmed
+			continue
+		}
+
+		tx, err := f.svc.CreateTransaction(ctx, CreateTransactionRequest{
+			From:      f.fundingAddress,
+			To:        funding.Address,
+			Amount:    funding.Amount.String(),
+			GasLimit:  ethTransferGasLimit,
+			GasMaxFee: plan.GasMaxFee,
+			Nonce:     nonce,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		tx, err = f.svc.SignTransaction(ctx, tx, f.fundingPrivateKey)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = f.svc.Broadcast(ctx, tx); err != nil {
+			funding.State = GasFundingFail
+			funding.Error = err.Error()
+			continue
+		}
+
+		nonce++
+		funding.TxID = tx.Hash().Hex()
+		funding.Fee = transactionMaxFee(tx)
+		funding.State = GasFundingPending
+		f.record(funding)
+	}
+	return fundings, nil
+}
+
+// Wait blocks until every pending funding is confirmed or failed, or ctx is done. It returns the
+// addresses that are released for sweeping. A funding transaction the node does not know is taken as not
+// propagated yet for a grace period, and as dropped after it; any other error of the node is returned.
+func (f *GasFunder) Wait(ctx context.Context, fundings []*GasFunding) ([]*GasFunding, error) {
+	missing := map[string]time.Time{}
+	for {
+		blockHeight, err := f.svc.CurrentBlockHeight(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		pending := 0
+		for _, funding := range fundings {
+			if funding.State != GasFundingPending {
+				continue
+			}
+
+			state, err := f.fundingState(ctx, funding, blockHeight)
+			if errors.Is(err, ethereum.NotFound) {
+				if _, ok := missing[funding.TxID]; !ok {
+					missing[funding.TxID] = time.Now()
+				}
+
+				if time.Since(missing[funding.TxID]) < f.notFoundGrace {
+					pending++
+					continue
+				}
+
+				funding.State = GasFundingFail
+				funding.Error = "funding transaction was dropped"
+				continue
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			delete(missing, funding.TxID)
+			switch state {
+			case TransactionSatePending:
+				pending++
+			case TransactionSateSuccess:
+				funding.State = GasFundingConfirmed
+			default:
+				funding.State = GasFundingFail
+				funding.Error = "funding transaction failed"
+			}
+		}
+
+		if pending == 0 {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(f.pollInterval):
+		}
+	}
+
+	released := []*GasFunding{}
+	for _, funding := range fundings {
+		if funding.State == GasFundingConfirmed {
+			released = append(released, funding)
+		}
+	}
+	return released, nil
+}
+
+// fundingState returns the state of the funding transaction, or ethereum.NotFound when the node does not know it.
+func (f *GasFunder) fundingState(ctx context.Context, funding *GasFunding, blockHeight uint64) (TransactionSate, error) {
+	txHash := common.HexToHash(funding.TxID)
+	_, isPending, err := f.svc.client.TransactionByHash(ctx, txHash)
+	if err != nil {
+		return TransactionSateDefault, err
+	}
+
+	if isPending {
+		return TransactionSatePending, nil
+	}
+
+	receipt, err := f.svc.client.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return TransactionSateDefault, err
+	}
+	return f.svc.receiptState(receipt, blockHeight), nil
+}
+
+// Dust reports the ETH still held by every address this funder has topped up.
+func (f *GasFunder) Dust(ctx context.Context) ([]*GasDust, error) {
+	f.mu.Lock()
+	funded := make([]GasFunding, 0, len(f.funded))
+	for _, funding := range f.funded {
+		funded = append(funded, *funding)
+	}
+	f.mu.Unlock()
+
+	result := []*GasDust{}
+	for _, funding := range funded {
+		balance, err := f.svc.BalanceETH(ctx, funding.Address)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, &GasDust{
+			Index:   funding.Index,
+			Address: funding.Address,
+			Funded:  funding.Amount,
+			Balance: *balance,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Index < result[j].Index
+	})
+	return result, nil
+}
+
+func (f *GasFunder) record(funding *GasFunding) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := strings.ToUpper(funding.Address)
+	total, ok := f.funded[key]
+	if !ok {
+		total = &GasFunding{Index: funding.Index, Address: funding.Address, Amount: decimal0}
+		f.funded[key] = total
+	}
+	total.Amount = total.Amount.Add(funding.Amount)
+}
@@ -0,0 +1,106 @@
+package eth
+
+import (
+	"context"
+	"demo/token"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func Test_GasFunder(t *testing.T) {
+	svc, backend := getSimulatedService(t)
+	ctx := context.Background()
+	auth := getSimulatedAuth(t, backend, owner1PrivateKey)
+	tokenAddress, _, instance, err := token.DeployToken(auth, backend, "gavin", "GV")
+	assert.NoError(t, err)
+	backend.Commit()
+
+	deposit, _ := svc.CreateAddress(ctx, sweepMnemonic, 5)
+	_, err = instance.Transfer(auth, common.HexToAddress(deposit), big.NewInt(4e18))
+	assert.NoError(t, err)
+	backend.Commit()
+
+	request := SweepRequest{
+		Mnemonic:       sweepMnemonic,
+		FromIndex:      5,
+		ToIndex:        5,
+		Destination:    owner2Addr,
+		TokenAddresses: []string{tokenAddress.Hex()},
+	}
+	plan, err := svc.PlanSweep(ctx, request)
+	assert.NoError(t, err)
+	assert.Len(t, plan.Items, 0)
+	assert.Len(t, plan.Skipped, 1)
+
+	funder := NewGasFunder(svc, owner1Addr, owner1PrivateKey)
+	fundings, err := funder.Fund(ctx, plan)
+	assert.NoError(t, err)
+	assert.Len(t, fundings, 1)
+	assert.Equal(t, GasFundingPending, fundings[0].State)
+	assert.Equal(t, plan.Skipped[0].Fee.String(), fundings[0].Amount.String())
+	backend.Commit()
+	backend.Commit()
+
+	released, err := funder.Wait(ctx, fundings)
+	assert.NoError(t, err)
+	assert.Len(t, released, 1)
+	assert.Equal(t, deposit, released[0].Address)
+
+	request.GasMaxFee = plan.GasMaxFee
+	plan, err = svc.PlanSweep(ctx, request)
+	assert.NoError(t, err)
+	assert.Len(t, plan.Items, 1)
+	assert.Len(t, plan.Skipped, 0)
+	assert.NoError(t, svc.ExecuteSweep(ctx, plan))
+	backend.Commit()
+
+	receipt, err := backend.TransactionReceipt(ctx, common.HexToHash(plan.Items[0].TxID))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), receipt.Status)
+
+	dust, err := funder.Dust(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, dust, 1)
+	assert.Equal(t, fundings[0].Amount.String(), dust[0].Funded.String())
+	assert.True(t, dust[0].Balance.IsPositive())
+	assert.True(t, dust[0].Balance.LessThan(dust[0].Funded))
+}
+
+// lookupErrorBackend fails every transaction lookup with err.
+type lookupErrorBackend struct {
+	*SimulatedBackend
+	err error
+}
+
+func (b *lookupErrorBackend) TransactionByHash(ctx context.Context, txHash common.Hash) (*types.Transaction, bool, error) {
+	return nil, false, b.err
+}
+
+func Test_GasFunderWait(t *testing.T) {
+	svc, backend := getSimulatedService(t)
+	ctx := context.Background()
+	funder := NewGasFunder(svc, owner1Addr, owner1PrivateKey)
+	funder.pollInterval = time.Millisecond
+	unknown := common.HexToHash("0x01").Hex()
+
+	// an unknown transaction stays pending for the grace period, and is dropped after it
+	funder.notFoundGrace = 20 * time.Millisecond
+	fundings := []*GasFunding{{Address: owner2Addr, TxID: unknown, State: GasFundingPending}}
+	released, err := funder.Wait(ctx, fundings)
+	assert.NoError(t, err)
+	assert.Empty(t, released)
+	assert.Equal(t, GasFundingFail, fundings[0].State)
+	assert.Equal(t, "funding transaction was dropped", fundings[0].Error)
+
+	failing := NewGasFunder(NewService(&lookupErrorBackend{SimulatedBackend: backend,
+		err: errors.New("connection refused")}, 0, 1.2), owner1Addr, owner1PrivateKey)
+	fundings = []*GasFunding{{Address: owner2Addr, TxID: unknown, State: GasFundingPending}}
+	_, err = failing.Wait(ctx, fundings)
+	assert.EqualError(t, err, "connection refused")
+	assert.Equal(t, GasFundingPending, fundings[0].State)
+}
@@ -0,0 +1,324 @@
+package eth
+
+import (
+	"context"
+	"demo/amount"
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logLimitMessages are what nodes and providers answer when an eth_getLogs range holds too many results.
+var logLimitMessages = []string{
+	"query returned more than",
+	"block range is too wide",
+	"block range too large",
+	"exceed maximum block range",
+	"limit exceeded",
+	"response size exceeded",
+	"log response size exceeded",
+	"too many results",
+}
+
+// CheckpointStore keeps the last block a TransferIndexer has fully handled.
+type CheckpointStore interface {
+	Load(ctx context.Context) (uint64, bool, error)
+	Save(ctx context.Context, blockNumber uint64) error
+}
+
+// MemoryCheckpointStore is a CheckpointStore that lives as long as the process.
+type MemoryCheckpointStore struct {
+	mu          sync.Mutex
+	blockNumber uint64
+	ok          bool
+}
+
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{}
+}
+
+func (s *MemoryCheckpointStore) Load(ctx context.Context) (uint64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.blockNumber, s.ok, nil
+}
+
+func (s *MemoryCheckpointStore) Save(ctx context.Context, blockNumber uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blockNumber, s.ok = blockNumber, true
+	return nil
+}
+
+// TransferIndexer back-fills the ERC20 Transfer events of a token set that were sent from or to an address set.
+// Blocks are read in chunks with eth_getLogs; a chunk shrinks when the node refuses it for holding too many
+// results and grows back after it succeeds.
+type TransferIndexer struct {
+	svc          *Service
+	tokens       []common.Address
+	addresses    []common.Address
+	checkpoint   CheckpointStore
+	chunkSize    uint64
+	minChunkSize uint64
+	maxChunkSize uint64
+
+	tokenInfo map[common.Address]*ERC20Info
+}
+
+// NewTransferIndexer indexes the transfers of tokenAddresses. All transfers are indexed when addresses is empty.
+func NewTransferIndexer(svc *Service, tokenAddresses, addresses []string, checkpoint CheckpointStore) *TransferIndexer {
+	indexer := TransferIndexer{
+		svc:          svc,
+		checkpoint:   checkpoint,
+		chunkSize:    2000,
+		minChunkSize: 1,
+		maxChunkSize: 10000,
+		tokenInfo:    map[common.Address]*ERC20Info{},
+	}
+	for _, tokenAddress := range tokenAddresses {
+		indexer.tokens = append(indexer.tokens, common.HexToAddress(tokenAddress))
+	}
+	for _, address := range addresses {
+		indexer.addresses = append(indexer.addresses, common.HexToAddress(address))
+	}
+	return &indexer
+}
+
+// Backfill indexes [fromBlock, toBlock], starting after the checkpoint when there is one. toBlock is capped to the
+// last confirmed block, so every record is final and has TransactionSateSuccess; Fee is not filled in. handle gets
+// the records of each chunk in chain order, and the checkpoint moves past the chunk once handle returns nil.
+func (x *TransferIndexer) Backfill(ctx context.Context, fromBlock, toBlock uint64, handle func([]*TransactionInfo) error) error {
+	return x.backfill(ctx, x.checkpoint, fromBlock, toBlock, handle)
+}
+
+func (x *TransferIndexer) backfill(ctx context.Context, checkpointStore CheckpointStore, fromBlock, toBlock uint64, handle func([]*TransactionInfo) error) error {
+	if fromBlock > toBlock {
+		return ErrInvalidInput
+	}
+
+	checkpoint, ok, err := checkpointStore.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	if ok && checkpoint >= fromBlock {
+		fromBlock = checkpoint + 1
+	}
+
+	blockHeight, err := x.svc.CurrentBlockHeight(ctx)
+	if err != nil {
+		return err
+	}
+
+	confirmations := x.svc.confirmations()
+	if blockHeight < confirmations {
+		return nil
+	}
+
+	if confirmed := blockHeight - confirmations; toBlock > confirmed {
+		toBlock = confirmed
+	}
+
+	for start := fromBlock; start <= toBlock; {
+		end := start + x.chunkSize - 1
+		if end > toBlock || end < start {
+			end = toBlock
+		}
+
+		logs, err := x.filterTransfers(ctx, start, end)
+		if isLogLimitError(err) && x.chunkSize > x.minChunkSize {
+			x.chunkSize /= 2
+			if x.chunkSize < x.minChunkSize {
+				x.chunkSize = x.minChunkSize
+			}
+			x.svc.logger.Debug("Log query refused, shrinking the chunk", "from", start, "to", end, "chunk", x.chunkSize)
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		records, err := x.transactionInfos(ctx, logs)
+		if err != nil {
+			return err
+		}
+
+		if len(records) > 0 {
+			if err = handle(records); err != nil {
+				return err
+			}
+		}
+
+		if err = checkpointStore.Save(ctx, end); err != nil {
+			return err
+		}
+		x.svc.hook.Scanned(end, blockHeight)
+		x.svc.logger.Debug("Indexed transfers", "from", start, "to", end, "transfers", len(records))
+
+		if x.chunkSize < x.maxChunkSize {
+			x.chunkSize *= 2
+			if x.chunkSize > x.maxChunkSize {
+				x.chunkSize = x.maxChunkSize
+			}
+		}
+		start = end + 1
+	}
+	return nil
+}
+
+// filterTransfers returns the Transfer logs of [start, end] in chain order. Matching the address set on either side
+// takes one query for the sender and one for the recipient.
+func (x *TransferIndexer) filterTransfers(ctx context.Context, start, end uint64) ([]*TokenTransfer, error) {
+	type logKey struct {
+		txHash common.Hash
+		index  uint
+	}
+
+	seen := map[logKey]bool{}
+	transfers := []*TokenTransfer{}
+	for _, tokenAddress := range x.tokens {
+		instance, err := NewTokenFilterer(tokenAddress, x.svc.client)
+		if err != nil {
+			return nil, err
+		}
+
+		filters := [][2][]common.Address{{nil, nil}}
+		if len(x.addresses) > 0 {
+			filters = [][2][]common.Address{{x.addresses, nil}, {nil, x.addresses}}
+		}
+
+		for _, filter := range filters {
+			iterator, err := instance.FilterTransfer(&bind.FilterOpts{Start: start, End: &end, Context: ctx}, filter[0], filter[1])
+			if err != nil {
+				return nil, err
+			}
+
+			for iterator.Next() {
+				key := logKey{txHash: iterator.Event.Raw.TxHash, index: iterator.Event.Raw.Index}
+				if iterator.Event.Raw.Removed || seen[key] {
+					continue
+				}
+
+				seen[key] = true
+				transfers = append(transfers, iterator.Event)
+			}
+
+			err = iterator.Error()
+			iterator.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sort.Slice(transfers, func(i, j int) bool {
+		if transfers[i].Raw.BlockNumber != transfers[j].Raw.BlockNumber {
+			return transfers[i].Raw.BlockNumber < transfers[j].Raw.BlockNumber
+		}
+		return transfers[i].Raw.Index < transfers[j].Raw.Index
+	})
+	return transfers, nil
+}
+
+// transferQuery is the filter of the Transfer logs of the tokens, from and to any address.
+func (x *TransferIndexer) transferQuery() ethereum.FilterQuery {
+	return ethereum.FilterQuery{Addresses: x.tokens, Topics: [][]common.Hash{{x.svc.eabi.Events["Transfer"].ID}}}
+}
+
+// parseTransfers returns the Transfer logs of logs that were sent from or to the address set, in the given order.
+func (x *TransferIndexer) parseTransfers(logs []types.Log) ([]*TokenTransfer, error) {
+	addresses := map[common.Address]bool{}
+	for _, address := range x.addresses {
+		addresses[address] = true
+	}
+
+	transfers := []*TokenTransfer{}
+	for _, log := range logs {
+		instance, err := NewTokenFilterer(log.Address, x.svc.client)
+		if err != nil {
+			return nil, err
+		}
+
+		transfer, err := instance.ParseTransfer(log)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(addresses) == 0 || addresses[transfer.From] || addresses[transfer.To] {
+			transfers = append(transfers, transfer)
+		}
+	}
+	return transfers, nil
+}
+
+func (x *TransferIndexer) transactionInfos(ctx context.Context, transfers []*TokenTransfer) ([]*TransactionInfo, error) {
+	headers := map[uint64]*types.Header{}
+	records := make([]*TransactionInfo, 0, len(transfers))
+	for _, transfer := range transfers {
+		tokenInfo, err := x.token(ctx, transfer.Raw.Address)
+		if err != nil {
+			return nil, err
+		}
+
+		header, ok := headers[transfer.Raw.BlockNumber]
+		if !ok {
+			header, err = x.svc.client.HeaderByHash(ctx, transfer.Raw.BlockHash)
+			if err != nil {
+				return nil, err
+			}
+			headers[transfer.Raw.BlockNumber] = header
+		}
+
+		records = append(records, &TransactionInfo{
+			ID:           transfer.Raw.TxHash.String(),
+			BlockNumber:  transfer.Raw.BlockNumber,
+			Time:         time.Unix(int64(header.Time), 0),
+			From:         strings.ToUpper(transfer.From.Hex()),
+			To:           strings.ToUpper(transfer.To.Hex()),
+			TokenAddress: strings.ToUpper(transfer.Raw.Address.Hex()),
+			Amount:       amount.FromBase(transfer.Value, amount.Token(tokenInfo.Decimals)),
+			State:        TransactionSateSuccess,
+		})
+	}
+	return records, nil
+}
+
+func (x *TransferIndexer) token(ctx context.Context, tokenAddress common.Address) (*ERC20Info, error) {
+	if tokenInfo, ok := x.tokenInfo[tokenAddress]; ok {
+		return tokenInfo, nil
+	}
+
+	tokenInfo, err := x.svc.ERC20Info(ctx, tokenAddress.Hex())
+	if err != nil {
+		return nil, err
+	}
+	x.tokenInfo[tokenAddress] = tokenInfo
+	return tokenInfo, nil
+}
+
+func isLogLimitError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == -32005 {
+		return true
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, limitMessage := range logLimitMessages {
+		if strings.Contains(message, limitMessage) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package eth
+
+import (
+	"context"
+	"demo/metrics"
+	"demo/token"
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// rangeLimitedBackend refuses eth_getLogs ranges wider than maxRange blocks, like hosted nodes do.
+type rangeLimitedBackend struct {
+	*SimulatedBackend
+	maxRange uint64
+	refused  int
+}
+
+func (b *rangeLimitedBackend) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	if query.ToBlock.Uint64()-query.FromBlock.Uint64()+1 > b.maxRange {
+		b.refused++
+		return nil, errors.New("query returned more than 10000 results")
+	}
+	return b.SimulatedBackend.FilterLogs(ctx, query)
+}
+
+// scanHook records the last progress of a scanner.
+type scanHook struct {
+	metrics.Nop
+	height, head uint64
+}
+
+func (h *scanHook) Scanned(height, head uint64) {
+	h.height, h.head = height, head
+}
+
+func Test_TransferIndexer(t *testing.T) {
+	_, simulated := getSimulatedService(t)
+	backend := &rangeLimitedBackend{SimulatedBackend: simulated, maxRange: 3}
+	svc := NewService(backend, 0, 1.2)
+	ctx := context.Background()
+	auth := getSimulatedAuth(t, simulated, owner1PrivateKey)
+	tokenAddress, _, instance, err := token.DeployToken(auth, simulated, "gavin", "GV")
+	assert.NoError(t, err)
+	simulated.Commit()
+
+	deposit := common.HexToAddress("0x35bb6eF95c72bf4804334BB9d6A3c77Bef18d81B")
+	other := common.HexToAddress("0x4Ce2109f8Db1190cd44BC6554E35642214FbE144")
+	for i := 1; i <= 10; i++ {
+		to := other
+		if i%3 == 0 {
+			to = deposit
+		}
+		_, err = instance.Transfer(auth, to, big.NewInt(int64(i)*1e17))
+		assert.NoError(t, err)
+		simulated.Commit()
+	}
+
+	checkpoint := NewMemoryCheckpointStore()
+	indexer := NewTransferIndexer(svc, []string{tokenAddress.Hex()}, []string{deposit.Hex()}, checkpoint)
+	indexer.chunkSize = 8
+
+	records := []*TransactionInfo{}
+	handle := func(infos []*TransactionInfo) error {
+		records = append(records, infos...)
+		return nil
+	}
+	head, _ := svc.CurrentBlockHeight(ctx)
+	assert.NoError(t, indexer.Backfill(ctx, 0, head+100, handle))
+	assert.Greater(t, backend.refused, 0)
+	assert.Len(t, records, 3)
+	for i, record := range records {
+		assert.Equal(t, []string{"0.3", "0.6", "0.9"}[i], record.Amount.String())
+		assert.Equal(t, strings.ToUpper(deposit.Hex()), record.To)
+		assert.Equal(t, strings.ToUpper(owner1Addr), record.From)
+		assert.Equal(t, strings.ToUpper(tokenAddress.Hex()), record.TokenAddress)
+		assert.Equal(t, TransactionSateSuccess, record.State)
+		assert.False(t, record.Time.IsZero())
+	}
+
+	saved, ok, _ := checkpoint.Load(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, head, saved)
+
+	// a second run resumes after the checkpoint
+	_, err = instance.Transfer(auth, deposit, big.NewInt(5e17))
+	assert.NoError(t, err)
+	simulated.Commit()
+	records = records[:0]
+	hook := &scanHook{}
+	svc.SetInstrumentation(hook)
+	assert.NoError(t, indexer.Backfill(ctx, 0, head+100, handle))
+	assert.Len(t, records, 1)
+	assert.Equal(t, "0.5", records[0].Amount.String())
+	assert.Equal(t, scanHook{height: head + 1, head: head + 1}, *hook, "the scanner reports its progress")
+}
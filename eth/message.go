@@ -0,0 +1,43 @@
+package eth
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// MessageHash returns the EIP-191 personal message hash of message, as used by personal_sign.
+func (svc *Service) MessageHash(ctx context.Context, message []byte) []byte {
+	return accounts.TextHash(message)
+}
+
+// SignMessage signs message as an EIP-191 personal message. The returned signature has v set to 27/28.
+func (svc *Service) SignMessage(ctx context.Context, message []byte, privateKey string) ([]byte, error) {
+	privateKeyECDSA, err := ParsePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := crypto.Sign(svc.MessageHash(ctx, message), privateKeyECDSA)
+	if err != nil {
+		return nil, err
+	}
+
+	signature[crypto.RecoveryIDOffset] += 27
+	return signature, nil
+}
+
+// RecoverMessage returns the address that signed message. Both v=0/1 and v=27/28 are accepted.
+func (svc *Service) RecoverMessage(ctx context.Context, message []byte, signature []byte) (string, error) {
+	return recoverAddress(svc.MessageHash(ctx, message), signature)
+}
+
+func (svc *Service) VerifyMessage(ctx context.Context, message []byte, signature []byte, address string) (bool, error) {
+	signer, err := svc.RecoverMessage(ctx, message, signature)
+	if err != nil {
+		return false, err
+	}
+
+	return common.HexToAddress(signer) == common.HexToAddress(address), nil
+}
@@ -0,0 +1,41 @@
+package eth
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_SignMessage(t *testing.T) {
+	svc, _ := getSimulatedService(t)
+	ctx := context.Background()
+	message := []byte("prove ownership: 7f3a9c")
+
+	signature, err := svc.SignMessage(ctx, message, owner1PrivateKey)
+	assert.NoError(t, err)
+	assert.Contains(t, []byte{27, 28}, signature[64])
+
+	signer, err := svc.RecoverMessage(ctx, message, signature)
+	assert.NoError(t, err)
+	assert.Equal(t, owner1Addr, signer)
+
+	signature[64] -= 27
+	ok, err := svc.VerifyMessage(ctx, message, signature, owner1Addr)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = svc.VerifyMessage(ctx, []byte("prove ownership: 7f3a9d"), signature, owner1Addr)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	signature[64] = 29
+	_, err = svc.RecoverMessage(ctx, message, signature)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func Test_MessageHash(t *testing.T) {
+	svc, _ := getSimulatedService(t)
+	hash := svc.MessageHash(context.Background(), []byte("hello"))
+	assert.Equal(t, "0x50b2c43fd39106bafbba0da34fc430e1f91e3c96ea2acee2bc34119f92b37750", hexutil.Encode(hash))
+}
@@ -2,10 +2,13 @@ package eth
 
 import (
 	"context"
+	"demo/apperr"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 	"github.com/shopspring/decimal"
 	"google.golang.org/grpc/codes"
+	"math/big"
 	"time"
 )
 
@@ -23,10 +26,159 @@ type CreateTransactionRequest struct {
 	To                 string
 	Amount             string
 	GasLimit           uint64
-	GasMaxFee          string //wei
-	GasTip             int32
+	GasMaxFee          string //eth
+	GasTip             int32  //gwei, DynamicFeeTxType only
 	DisableEstimateGas bool
 	Nonce              uint64
+	TxType             uint8            //types.LegacyTxType, types.AccessListTxType or types.DynamicFeeTxType
+	AccessList         types.AccessList //the GasLimit of ETH transfers must cover it
+	GenerateAccessList bool             //use eth_createAccessList when it saves gas
+}
+
+type AccessListResult struct {
+	AccessList           types.AccessList
+	GasWithAccessList    uint64
+	GasWithoutAccessList uint64
+	GasSaving            int64 //negative when the list costs more than it saves
+}
+
+type PermitRequest struct {
+	TokenAddress string
+	Owner        string
+	Spender      string
+	Amount       string
+	Deadline     time.Time
+	Version      string //EIP-712 domain version, "1" when empty
+}
+
+type Permit struct {
+	TokenAddress string
+	Owner        string
+	Spender      string
+	Value        *big.Int
+	Nonce        *big.Int
+	Deadline     *big.Int
+	Version      string
+	V            uint8
+	R            [32]byte
+	S            [32]byte
+}
+
+// Signature returns the permit signature as r || s || v.
+func (p *Permit) Signature() []byte {
+	signature := make([]byte, 0, 65)
+	signature = append(signature, p.R[:]...)
+	signature = append(signature, p.S[:]...)
+	return append(signature, p.V)
+}
+
+type SweepRequest struct {
+	Mnemonic       string
+	FromIndex      uint32
+	ToIndex        uint32
+	Destination    string
+	TokenAddresses []string
+	GasMaxFee      string //eth, SuggestGasPrice when empty
+}
+
+type SweepItem struct {
+	Index        uint32
+	Address      string
+	TokenAddress string
+	Amount       decimal.Decimal
+	Fee          decimal.Decimal
+	Transaction  *types.Transaction
+	Reason       string
+	TxID         string
+	Error        string
+}
+
+type SweepPlan struct {
+	Destination string
+	GasMaxFee   string
+	Items       []*SweepItem
+	Skipped     []*SweepItem
+	Fee         decimal.Decimal
+}
+
+type GasFunding struct {
+	Index   uint32
+	Address string
+	Amount  decimal.Decimal
+	Fee     decimal.Decimal
+	TxID    string
+	State   GasFundingState
+	Error   string
+}
+
+type GasFundingState int32
+
+const (
+	GasFundingDefault   GasFundingState = 0
+	GasFundingPending   GasFundingState = 1
+	GasFundingConfirmed GasFundingState = 2
+	GasFundingFail      GasFundingState = 3
+)
+
+type GasDust struct {
+	Index   uint32
+	Address string
+	Funded  decimal.Decimal
+	Balance decimal.Decimal
+}
+
+type PayoutRecipient struct {
+	To     string
+	Amount string
+}
+
+type PayoutRequest struct {
+	TokenAddress    string
+	From            string
+	Recipients      []PayoutRecipient
+	DisperseAddress string //sequential transfers when empty
+	GasMaxFee       string //eth, SuggestGasPrice when empty
+}
+
+type PayoutMode int32
+
+const (
+	PayoutModeDisperse   PayoutMode = 1
+	PayoutModeSequential PayoutMode = 2
+	PayoutModeApprove    PayoutMode = 3 //only the approve of the disperse contract, the recipients are not paid yet
+)
+
+type PayoutResult struct {
+	To          string
+	Amount      decimal.Decimal
+	Transaction *types.Transaction
+	TxID        string
+	State       TransactionSate
+	Error       string
+}
+
+type Payout struct {
+	From         string
+	TokenAddress string
+	Mode         PayoutMode
+	Transactions []*types.Transaction //in nonce order
+	Results      []*PayoutResult
+	Fee          decimal.Decimal
+}
+
+type EventType int32
+
+const (
+	EventTypeNewHead  EventType = 1
+	EventTypeTransfer EventType = 2
+)
+
+type Event struct {
+	Type        EventType
+	BlockNumber uint64
+	BlockHash   string
+	Time        time.Time
+	Transfer    *TransactionInfo //EventTypeTransfer only
 }
 
 type BlockInfo struct {
@@ -45,7 +197,59 @@ type TransactionInfo struct {
 	TokenAddress string
 	Amount       decimal.Decimal
 	State        TransactionSate
-	Fee          decimal.Decimal
+	Fee          decimal.Decimal //eth, includes L1Fee
+	L1Fee        decimal.Decimal //eth, L1 data fee charged by L2 chains
+	RevertReason string          //why a failed transaction reverted, when it can be recovered
+}
+
+// SigningEnvelope carries an unsigned transaction to an offline host and its signature back. UnsignedTx and
+// SignedTx are hex encoded RLP; Checksum covers every field but SignedTx and Checksum.
+type SigningEnvelope struct {
+	Version       int    `json:"version"`
+	ChainID       string `json:"chainId"`
+	From          string `json:"from"`
+	TokenAddress  string `json:"tokenAddress,omitempty"`
+	TokenSymbol   string `json:"tokenSymbol,omitempty"`
+	TokenDecimals uint8  `json:"tokenDecimals,omitempty"`
+	Summary       string `json:"summary"`
+	UnsignedTx    string `json:"unsignedTx"`
+	SignedTx      string `json:"signedTx,omitempty"`
+	Checksum      string `json:"checksum"`
+}
+
+// DecodedTransaction is what a raw signed transaction does, read without a node.
+type DecodedTransaction struct {
+	Hash       string
+	Type       uint8
+	ChainID    *big.Int //nil for transactions without replay protection
+	From       string
+	To         string //empty for contract creation
+	Nonce      uint64
+	GasLimit   uint64
+	GasPrice   decimal.Decimal //gwei, legacy and access list transactions
+	GasTipCap  decimal.Decimal //gwei
+	GasFeeCap  decimal.Decimal //gwei
+	MaxFee     decimal.Decimal //eth, the most the transaction can pay for gas
+	Value      decimal.Decimal //eth
+	Data       string
+	AccessList types.AccessList
+	TokenCall  *TokenCall //set when Data is an ERC20 transfer, transferFrom or approve
+}
+
+// TokenCall is a decoded ERC20 call. Value is in base units, the token decimals are not known offline.
+type TokenCall struct {
+	Method string
+	From   string //transferFrom only
+	To     string //recipient, or spender of approve
+	Value  *big.Int
+}
+
+// SimulationResult is the outcome of running a transaction without broadcasting it.
+type SimulationResult struct {
+	Success      bool
+	ReturnData   []byte
+	RevertData   []byte
+	RevertReason string
 }
 
 type TransactionSate int32
@@ -59,11 +263,14 @@ const (
 
 type Server interface {
 	Client() *ethclient.Client
+	Backend() Backend
 	CreateAddress(ctx context.Context, mnemonic string, index uint32) (string, error)
+	CreateAddressByPubKey(ctx context.Context, publicKey string) (string, error)
 	BalanceETH(ctx context.Context, address string) (*decimal.Decimal, error)
 	BalanceERC20(ctx context.Context, tokenAddress, ownerAddress string) (*decimal.Decimal, error)
 	ERC20Info(ctx context.Context, contractAddress string) (*ERC20Info, error)
 	CreateTransaction(ctx context.Context, request CreateTransactionRequest) (*types.Transaction, error)
+	CreateAccessList(ctx context.Context, request CreateTransactionRequest) (*AccessListResult, error)
 	SignTransaction(ctx context.Context, tx *types.Transaction, privateKey string) (*types.Transaction, error)
 	Broadcast(ctx context.Context, tx *types.Transaction) error
 	Block(ctx context.Context, number uint64) (*BlockInfo, error)
@@ -73,30 +280,53 @@ type Server interface {
 	MaxFee(ctx context.Context, tip int32) (*decimal.Decimal, error)
 	Nonce(ctx context.Context, fromAddress string) (uint64, error)
 	SignerHash(ctx context.Context, tx *types.Transaction) ([]byte, error)
+	SignerHashFrom(ctx context.Context, tx *types.Transaction, from string) ([]byte, error)
 	WithSignature(ctx context.Context, tx *types.Transaction, signature []byte) (*types.Transaction, error)
+	TypedDataHash(ctx context.Context, typedData apitypes.TypedData) ([]byte, error)
+	SignTypedData(ctx context.Context, typedData apitypes.TypedData, privateKey string) ([]byte, error)
+	RecoverTypedData(ctx context.Context, typedData apitypes.TypedData, signature []byte) (string, error)
+	VerifyTypedData(ctx context.Context, typedData apitypes.TypedData, signature []byte, address string) (bool, error)
+	PermitTypedData(ctx context.Context, request PermitRequest) (*apitypes.TypedData, error)
+	SignPermit(ctx context.Context, request PermitRequest, privateKey string) (*Permit, error)
+	RecoverPermit(ctx context.Context, permit *Permit) (string, error)
+	VerifyPermit(ctx context.Context, permit *Permit) (bool, error)
+	MessageHash(ctx context.Context, message []byte) []byte
+	SignMessage(ctx context.Context, message []byte, privateKey string) ([]byte, error)
+	RecoverMessage(ctx context.Context, message []byte, signature []byte) (string, error)
+	VerifyMessage(ctx context.Context, message []byte, signature []byte, address string) (bool, error)
+	PlanSweep(ctx context.Context, request SweepRequest) (*SweepPlan, error)
+	ExecuteSweep(ctx context.Context, plan *SweepPlan) error
+	CreatePayout(ctx context.Context, request PayoutRequest) (*Payout, error)
+	SignPayout(ctx context.Context, payout *Payout, privateKey string) error
+	ExecutePayout(ctx context.Context, payout *Payout) error
+	PayoutStatus(ctx context.Context, payout *Payout) error
+	SimulateTransaction(ctx context.Context, from string, tx *types.Transaction) (*SimulationResult, error)
+	ExportTransaction(ctx context.Context, request CreateTransactionRequest) (*SigningEnvelope, error)
+	VerifyEnvelope(ctx context.Context, envelope *SigningEnvelope, request CreateTransactionRequest) (*types.Transaction, error)
+	DecodeTransaction(ctx context.Context, rawTx string) (*DecodedTransaction, error)
 }
 
 /*------------------------------------------*/
 
 /*-----------------------------------------------*/
-type AppErr struct {
-	Code    string                 `json:"code"`
-	Message string                 `json:"message"`
-	Status  codes.Code             `json:"status"`
-	Details map[string]interface{} `json:"details"`
-}
 
-func (e AppErr) Error() string {
-	return e.Message
-}
+// AppErr is the error of the wallet the services return, see apperr.AppErr.
+type AppErr = apperr.AppErr
 
 func NewAppErr(code, message string) AppErr {
-	return AppErr{Code: code, Message: message}
+	return apperr.New(code, message)
 }
 
 var (
-	ErrNotFound               = &AppErr{Code: "NOT_FOUND", Message: "resource was not found", Status: codes.NotFound}
-	ErrInvalidInput           = &AppErr{Code: "INVALID_INPUT", Message: "input is valid", Status: codes.InvalidArgument}
-	ErrNotSupportTX           = &AppErr{Code: "NOT_SUPPORT_TX", Message: "the transaction is not support", Status: codes.InvalidArgument}
-	ErrNotSupportContractType = &AppErr{Code: "NOT_SUPPORT_CONTRACT_TYPE", Message: "not support contract type", Status: codes.InvalidArgument}
+	ErrNotFound                = &AppErr{Code: "NOT_FOUND", Message: "resource was not found", Status: codes.NotFound}
+	ErrInvalidInput            = &AppErr{Code: "INVALID_INPUT", Message: "input is valid", Status: codes.InvalidArgument}
+	ErrNotSupportTX            = &AppErr{Code: "NOT_SUPPORT_TX", Message: "the transaction is not support", Status: codes.InvalidArgument}
+	ErrNotSupportContractType  = &AppErr{Code: "NOT_SUPPORT_CONTRACT_TYPE", Message: "not support contract type", Status: codes.InvalidArgument}
+	ErrInvalidSignature        = &AppErr{Code: "INVALID_SIGNATURE", Message: "the signature is invalid", Status: codes.InvalidArgument}
+	ErrDomainSeparatorMismatch = &AppErr{Code: "DOMAIN_SEPARATOR_MISMATCH", Message: "the domain does not match the token DOMAIN_SEPARATOR", Status: codes.FailedPrecondition}
+	ErrAccessListNotSupported  = &AppErr{Code: "ACCESS_LIST_NOT_SUPPORTED", Message: "the node can not create access lists", Status: codes.Unimplemented}
+	ErrEnvelopeTampered        = &AppErr{Code: "ENVELOPE_TAMPERED", Message: "the signing envelope does not match the request", Status: codes.InvalidArgument}
+	ErrTooManyDecimals         = &AppErr{Code: "TOO_MANY_DECIMALS", Message: "the amount has more decimals than the asset supports", Status: codes.InvalidArgument}
+	ErrSenderRequired          = &AppErr{Code: "SENDER_REQUIRED", Message: "the withdrawal policy needs the sender of the transaction", Status: codes.FailedPrecondition}
+	ErrCallNotAllowed          = &AppErr{Code: "CALL_NOT_ALLOWED", Message: "the withdrawal policy can not tell what the contract call withdraws", Status: codes.PermissionDenied}
 )
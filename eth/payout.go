@@ -0,0 +1,317 @@
+package eth
+
+import (
+	"context"
+	"demo/amount"
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/shopspring/decimal"
+	"math/big"
+)
+
+// CreatePayout builds the unsigned transactions that pay every recipient of request. With a DisperseAddress
+// the batch is a single disperse call. When the token allowance of the disperse contract is too low the payout is
+// only the approve, in PayoutModeApprove, since the disperse call can't be estimated before it is mined; call
+// CreatePayout again once it is to pay the recipients. Without a DisperseAddress,
+// or when the disperse call would fail, every recipient gets its own transfer; a recipient whose transfer would
+// fail gets none and is marked failed. Other errors of the node fail the payout. Nonces start at the pending
+// nonce of From.
+func (svc *Service) CreatePayout(ctx context.Context, request PayoutRequest) (*Payout, error) {
+	if len(request.Recipients) < 1 || !common.IsHexAddress(request.From) {
+		return nil, ErrInvalidInput
+	}
+
+	if len(request.DisperseAddress) > 0 && !common.IsHexAddress(request.DisperseAddress) {
+		return nil, ErrInvalidInput
+	}
+
+	payout := Payout{From: request.From, TokenAddress: request.TokenAddress, Results: []*PayoutResult{}}
+	for _, recipient := range request.Recipients {
+		if !common.IsHexAddress(recipient.To) {
+			return nil, ErrInvalidInput
+		}
+
+		value, err := decimal.NewFromString(recipient.Amount)
+		if err != nil {
+			return nil, err
+		}
+
+		if !value.IsPositive() {
+			return nil, ErrInvalidInput
+		}
+		payout.Results = append(payout.Results, &PayoutResult{To: recipient.To, Amount: value})
+	}
+
+	if len(request.GasMaxFee) < 1 {
+		gasPrice, err := svc.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, err
+		}
+		request.GasMaxFee = gasPrice.String()
+	}
+
+	nonce, err := svc.Nonce(ctx, request.From)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(request.DisperseAddress) > 0 {
+		tx, mode, err := svc.createDisperseTransaction(ctx, request, payout.Results, nonce)
+		if err != nil {
+			return nil, err
+		}
+
+		if tx != nil {
+			payout.Mode = mode
+			payout.Transactions = []*types.Transaction{tx}
+		}
+
+		if payout.Mode == PayoutModeDisperse {
+			for _, result := range payout.Results {
+				result.Transaction = tx
+			}
+		}
+	}
+
+	if payout.Mode != PayoutModeDisperse && payout.Mode != PayoutModeApprove {
+		payout.Mode = PayoutModeSequential
+		for _, result := range payout.Results {
+			tx, err := svc.createPayoutTransfer(ctx, request, result, nonce)
+			if callFailed(err) {
+				result.State = TransactionSateFail
+				result.Error = err.Error()
+				continue
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			nonce++
+			result.Transaction = tx
+			payout.Transactions = append(payout.Transactions, tx)
+		}
+	}
+
+	for _, tx := range payout.Transactions {
+		payout.Fee = payout.Fee.Add(transactionMaxFee(tx))
+	}
+	return &payout, nil
+}
+
+// createPayoutTransfer returns the transfer that pays result on its own, with the gas limit the node estimates, as a
+// recipient contract may need more than a plain transfer.
+func (svc *Service) createPayoutTransfer(ctx context.Context, request PayoutRequest, result *PayoutResult, nonce uint64) (*types.Transaction, error) {
+	transfer := CreateTransactionRequest{
+		TokenAddress: request.TokenAddress,
+		From:         request.From,
+		To:           result.To,
+		Amount:       result.Amount.String(),
+		GasMaxFee:    request.GasMaxFee,
+		Nonce:        nonce,
+	}
+
+	// CreateTransaction estimates the gas of token transfers itself
+	if len(request.TokenAddress) < 1 {
+		value, err := toBaseUnits(result.Amount, amount.Ether)
+		if err != nil {
+			return nil, err
+		}
+
+		to := common.HexToAddress(result.To)
+		transfer.GasLimit, err = svc.client.EstimateGas(ctx, ethereum.CallMsg{
+			From:  common.HexToAddress(request.From),
+			To:    &to,
+			Value: value,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// a transfer to an account uses its intrinsic gas exactly, only contract calls need a margin
+		if transfer.GasLimit > ethTransferGasLimit {
+			transfer.GasLimit = uint64(float64(transfer.GasLimit) * svc.gasMultiplier())
+		}
+	}
+	return svc.CreateTransaction(ctx, transfer)
+}
+
+// SignPayout signs every transaction of payout with the privateKey of its From address.
+func (svc *Service) SignPayout(ctx context.Context, payout *Payout, privateKey string) error {
+	signed := make(map[*types.Transaction]*types.Transaction, len(payout.Transactions))
+	for i, tx := range payout.Transactions {
+		signedTx, err := svc.SignTransaction(ctx, tx, privateKey)
+		if err != nil {
+			return err
+		}
+
+		signed[tx] = signedTx
+		payout.Transactions[i] = signedTx
+	}
+
+	for _, result := range payout.Results {
+		result.Transaction = signed[result.Transaction]
+	}
+	return nil
+}
+
+// ExecutePayout broadcasts the transactions of payout in nonce order. Once a broadcast fails the later
+// transactions are not sent, since their nonces can no longer be mined, and their recipients are marked failed.
+func (svc *Service) ExecutePayout(ctx context.Context, payout *Payout) error {
+	var reason string
+	for _, tx := range payout.Transactions {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if len(reason) > 0 {
+			reason = "previous payout transaction failed"
+		} else if err := svc.Broadcast(ctx, tx); err != nil {
+			reason = err.Error()
+		}
+
+		for _, result := range payout.Results {
+			if result.Transaction != tx {
+				continue
+			}
+
+			if len(reason) > 0 {
+				result.State = TransactionSateFail
+				result.Error = reason
+				continue
+			}
+
+			result.TxID = tx.Hash().Hex()
+			result.State = TransactionSatePending
+		}
+	}
+	return nil
+}
+
+// PayoutStatus updates every pending result of payout from the receipt of its transaction. Recipients of a
+// disperse call share its outcome.
+func (svc *Service) PayoutStatus(ctx context.Context, payout *Payout) error {
+	blockHeight, err := svc.CurrentBlockHeight(ctx)
+	if err != nil {
+		return err
+	}
+
+	states := map[string]TransactionSate{}
+	for _, result := range payout.Results {
+		if result.State != TransactionSatePending {
+			continue
+		}
+
+		state, ok := states[result.TxID]
+		if !ok {
+			receipt, err := svc.client.TransactionReceipt(ctx, common.HexToHash(result.TxID))
+			if err != nil && !errors.Is(err, ethereum.NotFound) {
+				return err
+			}
+
+			state = TransactionSatePending
+			if receipt != nil {
+				state = svc.receiptState(receipt, blockHeight)
+			}
+			states[result.TxID] = state
+		}
+
+		result.State = state
+		if state == TransactionSateFail {
+			result.Error = "payout transaction reverted"
+		}
+	}
+	return nil
+}
+
+// createDisperseTransaction returns the disperse call that pays results, or the approve it needs first in
+// PayoutModeApprove. It returns a nil transaction when either would fail, e.g. because a recipient contract refuses
+// ETH, so that the caller can fall back to sequential transfers. Other errors are returned.
+func (svc *Service) createDisperseTransaction(ctx context.Context, request PayoutRequest, results []*PayoutResult, nonce uint64) (*types.Transaction, PayoutMode, error) {
+	fromAddress := common.HexToAddress(request.From)
+	disperseAddress := common.HexToAddress(request.DisperseAddress)
+
+	maxFee, err := parseBaseUnits(request.GasMaxFee, amount.Ether)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	unit := amount.Ether
+	if len(request.TokenAddress) > 0 {
+		tokenInfo, err := svc.ERC20Info(ctx, request.TokenAddress)
+		if err != nil {
+			return nil, 0, err
+		}
+		unit = amount.Token(tokenInfo.Decimals)
+	}
+
+	recipients := make([]common.Address, 0, len(results))
+	values := make([]*big.Int, 0, len(results))
+	total := new(big.Int)
+	for _, result := range results {
+		value, err := toBaseUnits(result.Amount, unit)
+		if err != nil {
+			return nil, 0, err
+		}
+		recipients = append(recipients, common.HexToAddress(result.To))
+		values = append(values, value)
+		total.Add(total, value)
+	}
+
+	disperseABI, err := DisperseMetaData.GetAbi()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	to, value, data := disperseAddress, total, []byte(nil)
+	mode := PayoutModeDisperse
+	if len(request.TokenAddress) < 1 {
+		data, err = disperseABI.Pack("disperseEther", recipients, values)
+	} else {
+		data, err = disperseABI.Pack("disperseToken", common.HexToAddress(request.TokenAddress), recipients, values)
+		value = big.NewInt(0)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(request.TokenAddress) > 0 {
+		instance, err := NewToken(common.HexToAddress(request.TokenAddress), svc.client)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		allowance, err := instance.Allowance(&bind.CallOpts{Context: ctx}, fromAddress, disperseAddress)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if allowance.Cmp(total) < 0 {
+			data, err = svc.eabi.Pack("approve", disperseAddress, total)
+			if err != nil {
+				return nil, 0, err
+			}
+			to, mode = common.HexToAddress(request.TokenAddress), PayoutModeApprove
+		}
+	}
+
+	gasLimit, err := svc.estimateGas(ctx, ethereum.CallMsg{
+		From:  fromAddress,
+		To:    &to,
+		Value: value,
+		Data:  data,
+	})
+	if callFailed(err) {
+		return nil, 0, nil
+	}
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	gasLimit = uint64(float64(gasLimit) * svc.gasMultiplier())
+	return types.NewTransaction(nonce, to, value, gasLimit, maxFee, data), mode, nil
+}
@@ -0,0 +1,221 @@
+package eth
+
+import (
+	"context"
+	"demo/disperse"
+	"demo/token"
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var payoutRecipients = []PayoutRecipient{
+	{To: "0x35bb6eF95c72bf4804334BB9d6A3c77Bef18d81B", Amount: "1.5"},
+	{To: "0x4Ce2109f8Db1190cd44BC6554E35642214FbE144", Amount: "0.25"},
+	{To: "0x1a2DB5F7D1F3e25a2cD4FC0a1a7a3E4C4a29bF6D", Amount: "2"},
+}
+
+// estimateErrorBackend fails every gas estimate with err.
+type estimateErrorBackend struct {
+	*SimulatedBackend
+	err error
+}
+
+func (b *estimateErrorBackend) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	return 0, b.err
+}
+
+func Test_PayoutDisperseETH(t *testing.T) {
+	svc, backend := getSimulatedService(t)
+	ctx := context.Background()
+	disperseAddress, _, _, err := disperse.DeployDisperse(getSimulatedAuth(t, backend, owner1PrivateKey), backend)
+	assert.NoError(t, err)
+	backend.Commit()
+
+	payout, err := svc.CreatePayout(ctx, PayoutRequest{
+		From:            owner1Addr,
+		Recipients:      payoutRecipients,
+		DisperseAddress: disperseAddress.Hex(),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, PayoutModeDisperse, payout.Mode)
+	assert.Len(t, payout.Transactions, 1)
+	assert.Equal(t, "3750000000000000000", payout.Transactions[0].Value().String())
+
+	assert.NoError(t, svc.SignPayout(ctx, payout, owner1PrivateKey))
+	assert.NoError(t, svc.ExecutePayout(ctx, payout))
+	backend.Commit()
+	backend.Commit()
+	assert.NoError(t, svc.PayoutStatus(ctx, payout))
+
+	for i, result := range payout.Results {
+		assert.Equal(t, payout.Transactions[0].Hash().Hex(), result.TxID)
+		assert.Equal(t, TransactionSateSuccess, result.State)
+		balance, _ := svc.BalanceETH(ctx, payoutRecipients[i].To)
+		assert.Equal(t, payoutRecipients[i].Amount, balance.String())
+	}
+
+	balance, _ := svc.BalanceETH(ctx, disperseAddress.Hex())
+	assert.True(t, balance.IsZero())
+}
+
+func Test_PayoutDisperseToken(t *testing.T) {
+	svc, backend := getSimulatedService(t)
+	ctx := context.Background()
+	auth := getSimulatedAuth(t, backend, owner1PrivateKey)
+	disperseAddress, _, _, err := disperse.DeployDisperse(auth, backend)
+	assert.NoError(t, err)
+	tokenAddress, _, instance, err := token.DeployToken(auth, backend, "gavin", "GV")
+	assert.NoError(t, err)
+	backend.Commit()
+
+	request := PayoutRequest{
+		TokenAddress:    tokenAddress.Hex(),
+		From:            owner1Addr,
+		Recipients:      payoutRecipients,
+		DisperseAddress: disperseAddress.Hex(),
+	}
+	payout, err := svc.CreatePayout(ctx, request)
+	assert.NoError(t, err)
+	assert.Equal(t, PayoutModeApprove, payout.Mode)
+	assert.Len(t, payout.Transactions, 1)
+	assert.Equal(t, tokenAddress, *payout.Transactions[0].To())
+	for _, result := range payout.Results {
+		assert.Nil(t, result.Transaction)
+	}
+
+	assert.NoError(t, svc.SignPayout(ctx, payout, owner1PrivateKey))
+	assert.NoError(t, svc.ExecutePayout(ctx, payout))
+	backend.Commit()
+	assert.Equal(t, TransactionSateDefault, payout.Results[0].State)
+
+	// once the approve is mined the disperse call is estimated like any other call
+	payout, err = svc.CreatePayout(ctx, request)
+	assert.NoError(t, err)
+	assert.Equal(t, PayoutModeDisperse, payout.Mode)
+	assert.Len(t, payout.Transactions, 1)
+	assert.Equal(t, disperseAddress, *payout.Transactions[0].To())
+	estimate, err := backend.EstimateGas(ctx, ethereum.CallMsg{From: common.HexToAddress(owner1Addr),
+		To: &disperseAddress, Data: payout.Transactions[0].Data()})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(float64(estimate)*1.2), payout.Transactions[0].Gas())
+
+	assert.NoError(t, svc.SignPayout(ctx, payout, owner1PrivateKey))
+	assert.NoError(t, svc.ExecutePayout(ctx, payout))
+	backend.Commit()
+	backend.Commit()
+	assert.NoError(t, svc.PayoutStatus(ctx, payout))
+
+	for i, result := range payout.Results {
+		assert.Equal(t, payout.Transactions[0].Hash().Hex(), result.TxID)
+		assert.Equal(t, TransactionSateSuccess, result.State)
+		balance, _ := svc.BalanceERC20(ctx, tokenAddress.Hex(), payoutRecipients[i].To)
+		assert.Equal(t, payoutRecipients[i].Amount, balance.String())
+	}
+
+	tokenBalance, _ := instance.BalanceOf(&bind.CallOpts{}, disperseAddress)
+	assert.Equal(t, "0", tokenBalance.String())
+
+	// the allowance is spent, so the next batch approves again
+	request.Recipients = payoutRecipients[:1]
+	payout, err = svc.CreatePayout(ctx, request)
+	assert.NoError(t, err)
+	assert.Equal(t, PayoutModeApprove, payout.Mode)
+}
+
+func Test_PayoutSequential(t *testing.T) {
+	svc, backend := getSimulatedService(t)
+	ctx := context.Background()
+	auth := getSimulatedAuth(t, backend, owner1PrivateKey)
+	tokenAddress, _, _, err := token.DeployToken(auth, backend, "gavin", "GV")
+	assert.NoError(t, err)
+	backend.Commit()
+
+	payout, err := svc.CreatePayout(ctx, PayoutRequest{
+		TokenAddress: tokenAddress.Hex(),
+		From:         owner1Addr,
+		Recipients:   payoutRecipients,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, PayoutModeSequential, payout.Mode)
+	assert.Len(t, payout.Transactions, 3)
+
+	nonce, _ := svc.Nonce(ctx, owner1Addr)
+	for i, tx := range payout.Transactions {
+		assert.Equal(t, nonce+uint64(i), tx.Nonce())
+		assert.Same(t, tx, payout.Results[i].Transaction)
+	}
+
+	assert.NoError(t, svc.SignPayout(ctx, payout, owner1PrivateKey))
+	assert.NoError(t, svc.ExecutePayout(ctx, payout))
+	backend.Commit()
+	assert.NoError(t, svc.PayoutStatus(ctx, payout))
+	assert.Equal(t, TransactionSatePending, payout.Results[0].State)
+
+	backend.Commit()
+	assert.NoError(t, svc.PayoutStatus(ctx, payout))
+	for i, result := range payout.Results {
+		assert.Equal(t, payout.Transactions[i].Hash().Hex(), result.TxID)
+		assert.Equal(t, TransactionSateSuccess, result.State)
+		balance, _ := svc.BalanceERC20(ctx, tokenAddress.Hex(), payoutRecipients[i].To)
+		assert.Equal(t, payoutRecipients[i].Amount, balance.String())
+	}
+}
+
+func Test_PayoutFallback(t *testing.T) {
+	svc, backend := getSimulatedService(t)
+	ctx := context.Background()
+	auth := getSimulatedAuth(t, backend, owner1PrivateKey)
+	disperseAddress, _, _, err := disperse.DeployDisperse(auth, backend)
+	assert.NoError(t, err)
+	tokenAddress, _, _, err := token.DeployToken(auth, backend, "gavin", "GV")
+	assert.NoError(t, err)
+	backend.Commit()
+
+	// the token contract refuses ETH, which makes the whole disperse call revert
+	recipients := append([]PayoutRecipient{{To: tokenAddress.Hex(), Amount: "1"}}, payoutRecipients[:2]...)
+	payout, err := svc.CreatePayout(ctx, PayoutRequest{
+		From:            owner1Addr,
+		Recipients:      recipients,
+		DisperseAddress: disperseAddress.Hex(),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, PayoutModeSequential, payout.Mode)
+
+	// the refused transfer is not sent at all
+	assert.Len(t, payout.Transactions, 2)
+	assert.Nil(t, payout.Results[0].Transaction)
+	assert.Equal(t, TransactionSateFail, payout.Results[0].State)
+	assert.NotEmpty(t, payout.Results[0].Error)
+	for _, tx := range payout.Transactions {
+		assert.Equal(t, ethTransferGasLimit, tx.Gas())
+	}
+
+	assert.NoError(t, svc.SignPayout(ctx, payout, owner1PrivateKey))
+	assert.NoError(t, svc.ExecutePayout(ctx, payout))
+	backend.Commit()
+	backend.Commit()
+	assert.NoError(t, svc.PayoutStatus(ctx, payout))
+
+	assert.Equal(t, TransactionSateFail, payout.Results[0].State)
+	for i, result := range payout.Results[1:] {
+		assert.Equal(t, TransactionSateSuccess, result.State)
+		balance, _ := svc.BalanceETH(ctx, payoutRecipients[i].To)
+		assert.Equal(t, payoutRecipients[i].Amount, balance.String())
+	}
+
+	// a node that can not estimate fails the payout rather than the disperse call
+	unreachable := NewService(&estimateErrorBackend{SimulatedBackend: backend, err: errors.New("connection refused")}, 0, 1.2)
+	_, err = unreachable.CreatePayout(ctx, PayoutRequest{
+		From:            owner1Addr,
+		Recipients:      payoutRecipients,
+		DisperseAddress: disperseAddress.Hex(),
+	})
+	assert.EqualError(t, err, "connection refused")
+
+	_, err = svc.CreatePayout(ctx, PayoutRequest{From: owner1Addr, Recipients: []PayoutRecipient{{To: common.Address{}.Hex(), Amount: "0"}}})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+}
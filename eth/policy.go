@@ -0,0 +1,146 @@
+package eth
+
+import (
+	"context"
+	"demo/amount"
+	"demo/policy"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+)
+
+// SetPolicy makes the service evaluate every transaction against policy before SignTransaction signs it and before
+// SignerHashFrom hands out the hash for an external signer, and again before WithSignature attaches the signature. A
+// nil policy allows everything, the default. Call it before the service is in use.
+func (svc *Service) SetPolicy(policy *policy.Policy) {
+	svc.policy = policy
+}
+
+// checkPolicy evaluates tx, signed by from, against the policy of the service.
+func (svc *Service) checkPolicy(ctx context.Context, tx *types.Transaction, signer types.Signer, from common.Address) error {
+	if svc.policy == nil {
+		return nil
+	}
+
+	hash := signer.Hash(tx).Hex()
+	withdrawals, err := svc.withdrawals(ctx, tx, from)
+	if err != nil {
+		svc.logger.Warn("Withdrawal refused", "from", from, "to", tx.To(), "hash", hash, "err", err)
+		return err
+	}
+
+	approvals := policy.ApprovalsFromContext(ctx)
+	for i := range withdrawals {
+		withdrawals[i].Hash = hash
+		withdrawals[i].Approvals = approvals
+	}
+
+	if err = svc.policy.Evaluate(withdrawals...); err != nil {
+		svc.logger.Warn("Withdrawal refused", "from", from, "to", tx.To(), "hash", hash, "err", err)
+		return err
+	}
+
+	for _, withdrawal := range withdrawals {
+		svc.logger.Info("Withdrawal allowed", "from", withdrawal.From, "to", withdrawal.To,
+			"asset", withdrawal.AssetName(), "amount", withdrawal.Amount, "approvals", withdrawal.Approvals)
+	}
+	return nil
+}
+
+// withdrawals returns what tx withdraws from from: the tokens of an ERC20 call to its recipient or spender, one
+// withdrawal per recipient of a disperse call, the ETH value of a plain transfer or contract creation. The policy can
+// not tell what other calls withdraw, they are refused with ErrCallNotAllowed.
+func (svc *Service) withdrawals(ctx context.Context, tx *types.Transaction, from common.Address) ([]policy.Withdrawal, error) {
+	withdrawal := policy.Withdrawal{Chain: "eth", From: from.Hex(), Amount: amount.FromBase(tx.Value(), amount.Ether)}
+	if tx.To() == nil {
+		return []policy.Withdrawal{withdrawal}, nil
+	}
+	withdrawal.To = tx.To().Hex()
+	if len(tx.Data()) == 0 {
+		return []policy.Withdrawal{withdrawal}, nil
+	}
+
+	if call := svc.decodeTokenCall(tx.Data()); call != nil {
+		unit, err := svc.tokenUnit(ctx, tx.To().Hex())
+		if err != nil {
+			return nil, err
+		}
+
+		withdrawal.To = call.To
+		withdrawal.Asset = tx.To().Hex()
+		withdrawal.Amount = amount.FromBase(call.Value, unit)
+		return []policy.Withdrawal{withdrawal}, nil
+	}
+
+	withdrawals, err := svc.disperseWithdrawals(ctx, tx, withdrawal)
+	if err != nil || withdrawals != nil {
+		return withdrawals, err
+	}
+
+	selector := tx.Data()
+	if len(selector) > 4 {
+		selector = selector[:4]
+	}
+	return nil, ErrCallNotAllowed.WithDetails(map[string]interface{}{"contract": tx.To().Hex(),
+		"selector": hexutil.Encode(selector)})
+}
+
+// disperseWithdrawals returns a withdrawal to every recipient of a disperseEther or disperseToken call, and one of
+// the ETH left over to the contract. It returns nil when tx is not a disperse call.
+func (svc *Service) disperseWithdrawals(ctx context.Context, tx *types.Transaction, value policy.Withdrawal) ([]policy.Withdrawal, error) {
+	disperseABI, err := DisperseMetaData.GetAbi()
+	if err != nil || len(tx.Data()) < 4 {
+		return nil, err
+	}
+
+	method, err := disperseABI.MethodById(tx.Data()[:4])
+	if err != nil {
+		return nil, nil
+	}
+
+	args, err := method.Inputs.Unpack(tx.Data()[4:])
+	if err != nil {
+		return nil, nil
+	}
+
+	unit, asset := amount.Ether, ""
+	if method.RawName == "disperseToken" {
+		asset = args[0].(common.Address).Hex()
+		if unit, err = svc.tokenUnit(ctx, asset); err != nil {
+			return nil, err
+		}
+		args = args[1:]
+	}
+
+	recipients, values := args[0].([]common.Address), args[1].([]*big.Int)
+	if len(recipients) != len(values) {
+		return nil, ErrCallNotAllowed.WithDetails(map[string]interface{}{"contract": tx.To().Hex(),
+			"method": method.RawName})
+	}
+
+	withdrawals := make([]policy.Withdrawal, 0, len(recipients)+1)
+	left := new(big.Int).Set(tx.Value())
+	for i, recipient := range recipients {
+		withdrawals = append(withdrawals, policy.Withdrawal{Chain: "eth", From: value.From, To: recipient.Hex(),
+			Asset: asset, Amount: amount.FromBase(values[i], unit)})
+		if len(asset) == 0 {
+			left.Sub(left, values[i])
+		}
+	}
+
+	if left.Sign() > 0 {
+		value.Amount = amount.FromBase(left, amount.Ether)
+		withdrawals = append(withdrawals, value)
+	}
+	return withdrawals, nil
+}
+
+func (svc *Service) tokenUnit(ctx context.Context, token string) (amount.Unit, error) {
+	info, err := svc.ERC20Info(ctx, token)
+	if err != nil {
+		return 0, fmt.Errorf("token of the withdrawal: %w", err)
+	}
+	return amount.Token(info.Decimals), nil
+}
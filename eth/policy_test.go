@@ -0,0 +1,125 @@
+package eth
+
+import (
+	"context"
+	"demo/disperse"
+	"demo/policy"
+	"demo/token"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func Test_SetPolicy(t *testing.T) {
+	svc, backend := getSimulatedService(t)
+	ctx := context.Background()
+	auth := getSimulatedAuth(t, backend, owner1PrivateKey)
+	tokenAddress, _, _, err := token.DeployToken(auth, backend, "gavin", "GV")
+	assert.NoError(t, err)
+	backend.Commit()
+
+	decisions := []policy.Decision{}
+	p := policy.New(policy.Rules{
+		Limits:    []policy.Limit{{Asset: tokenAddress.Hex(), PerTransaction: decimal.NewFromInt(10)}},
+		Approvals: []policy.Approval{{Threshold: decimal.NewFromInt(1), Approvals: 1}},
+		Approvers: []string{"alice"},
+	})
+	p.SetAudit(func(decision policy.Decision) {
+		decisions = append(decisions, decision)
+	})
+	svc.SetPolicy(p)
+
+	request := CreateTransactionRequest{TokenAddress: tokenAddress.Hex(), From: owner1Addr, To: owner2Addr,
+		Amount: "11", GasMaxFee: "0.000000002"}
+	tx, err := svc.CreateTransaction(ctx, request)
+	assert.NoError(t, err)
+	_, err = svc.SignTransaction(ctx, tx, owner1PrivateKey)
+	assert.ErrorIs(t, err, policy.ErrLimitExceeded)
+	withdrawal := decisions[0].Withdrawal
+	assert.Equal(t, []string{owner1Addr, owner2Addr, tokenAddress.Hex(), "11"},
+		[]string{withdrawal.From, withdrawal.To, withdrawal.Asset, withdrawal.Amount.String()})
+
+	request = CreateTransactionRequest{From: owner1Addr, To: owner2Addr, Amount: "2", GasLimit: 21000, Nonce: 1,
+		GasMaxFee: "0.000000002"}
+	tx, err = svc.CreateTransaction(ctx, request)
+	assert.NoError(t, err)
+	_, err = svc.SignTransaction(ctx, tx, owner1PrivateKey)
+	assert.ErrorIs(t, err, policy.ErrApprovalRequired)
+
+	signedTx, err := svc.SignTransaction(policy.WithApprovals(ctx, "alice"), tx, owner1PrivateKey)
+	assert.NoError(t, err)
+	assert.True(t, decisions[2].Allowed)
+	assert.Equal(t, []string{"alice"}, decisions[2].Withdrawal.Approvals)
+	assert.NoError(t, svc.Broadcast(ctx, signedTx))
+
+	// an external signer gets the hash only once the policy allows it, and the signature is checked again
+	_, err = svc.SignerHash(ctx, tx)
+	assert.ErrorIs(t, err, ErrSenderRequired)
+	_, err = svc.SignerHashFrom(ctx, tx, "owner1")
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	_, err = svc.SignerHashFrom(ctx, tx, owner1Addr)
+	assert.ErrorIs(t, err, policy.ErrApprovalRequired)
+	hash, err := svc.SignerHashFrom(policy.WithApprovals(ctx, "alice"), tx, owner1Addr)
+	assert.NoError(t, err)
+	assert.Equal(t, hexutil.Encode(hash), decisions[4].Withdrawal.Hash)
+	key, _ := crypto.HexToECDSA(owner1PrivateKey[2:])
+	signature, err := crypto.Sign(hash, key)
+	assert.NoError(t, err)
+	_, err = svc.WithSignature(ctx, tx, signature)
+	assert.ErrorIs(t, err, policy.ErrApprovalRequired)
+	_, err = svc.WithSignature(policy.WithApprovals(ctx, "alice"), tx, signature)
+	assert.NoError(t, err)
+
+	svc.SetPolicy(nil)
+	_, err = svc.WithSignature(ctx, tx, signature)
+	assert.NoError(t, err)
+	assert.Len(t, decisions, 7)
+}
+
+func Test_SetPolicyCalls(t *testing.T) {
+	svc, backend := getSimulatedService(t)
+	ctx := context.Background()
+	disperseAddress, _, _, err := disperse.DeployDisperse(getSimulatedAuth(t, backend, owner1PrivateKey), backend)
+	assert.NoError(t, err)
+	backend.Commit()
+
+	decisions := []policy.Decision{}
+	p := policy.New(policy.Rules{Blacklist: []string{payoutRecipients[2].To}})
+	p.SetAudit(func(decision policy.Decision) {
+		decisions = append(decisions, decision)
+	})
+	svc.SetPolicy(p)
+
+	// every recipient of a disperse call is a withdrawal of its own
+	request := PayoutRequest{From: owner1Addr, Recipients: payoutRecipients, DisperseAddress: disperseAddress.Hex()}
+	payout, err := svc.CreatePayout(ctx, request)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, svc.SignPayout(ctx, payout, owner1PrivateKey), policy.ErrDestinationNotAllowed)
+	assert.True(t, strings.EqualFold(payoutRecipients[2].To, decisions[0].Withdrawal.To))
+
+	request.Recipients = payoutRecipients[:2]
+	payout, err = svc.CreatePayout(ctx, request)
+	assert.NoError(t, err)
+	assert.NoError(t, svc.SignPayout(ctx, payout, owner1PrivateKey))
+	assert.Len(t, decisions, 3)
+	for i, decision := range decisions[1:] {
+		assert.True(t, strings.EqualFold(payoutRecipients[i].To, decision.Withdrawal.To))
+		assert.Equal(t, payoutRecipients[i].Amount, decision.Withdrawal.Amount.String())
+	}
+
+	// the policy can not tell what other calls withdraw
+	tx := types.NewTransaction(1, disperseAddress, big.NewInt(0), 50000, big.NewInt(2000000000),
+		[]byte{0xde, 0xad, 0xbe, 0xef})
+	_, err = svc.SignTransaction(ctx, tx, owner1PrivateKey)
+	assert.ErrorIs(t, err, ErrCallNotAllowed)
+	assert.Len(t, decisions, 3)
+
+	svc.SetPolicy(nil)
+	_, err = svc.SignTransaction(ctx, tx, owner1PrivateKey)
+	assert.NoError(t, err)
+}
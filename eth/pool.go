@@ -0,0 +1,317 @@
+package eth
+
+import (
+	"context"
+	"demo/failover"
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"math/big"
+	"net"
+	"net/http"
+)
+
+// Pool is a Backend over several nodes. Calls go to the fastest healthy node in sync with the others and fail over
+// to the next one when the node can not be reached; errors the node answers with, such as a revert, are returned
+// as they are. Timeouts, 429s and blocks a node has not imported yet are retried under the policy of SetPolicy.
+// Check or Watch keeps the heights up to date and quarantines the nodes that lag behind.
+type Pool struct {
+	*failover.Pool
+}
+
+// NewPool returns an empty pool that quarantines nodes more than maxLag blocks behind.
+func NewPool(maxLag uint64) *Pool {
+	return &Pool{Pool: failover.New(probeBlockNumber, classify, maxLag)}
+}
+
+// DialPool dials every url into a pool. Dialing http endpoints does not connect, so a node that is down only shows
+// when it is called.
+func DialPool(urls []string, maxLag uint64) (*Pool, error) {
+	pool := NewPool(maxLag)
+	for _, url := range urls {
+		backend, err := DialBackend(url)
+		if err != nil {
+			return nil, err
+		}
+		pool.Add(url, backend)
+	}
+	return pool, nil
+}
+
+// Add adds a node called name, usually its url.
+func (p *Pool) Add(name string, backend Backend) {
+	p.Pool.Add(name, backend)
+}
+
+func probeBlockNumber(ctx context.Context, client interface{}) (uint64, error) {
+	return client.(Backend).BlockNumber(ctx)
+}
+
+// limitExceeded is the JSON-RPC error code of a node that rate limits the caller, see EIP-1474.
+const limitExceeded = -32005
+
+// classify tells the answers of a node from transient errors and from errors reaching it.
+func classify(err error) failover.Class {
+	var httpErr rpc.HTTPError
+	var rpcErr rpc.Error
+	var appErr *AppErr
+	var netErr net.Error
+	switch {
+	case errors.As(err, &httpErr):
+		if httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode == http.StatusServiceUnavailable {
+			return failover.Transient
+		}
+		return failover.Unreachable
+	case errors.As(err, &rpcErr):
+		//a node behind the one that served the previous call does not know its newest block yet
+		message := rpcErr.Error()
+		if rpcErr.ErrorCode() == limitExceeded || message == "header not found" || message == "unknown block" {
+			return failover.Transient
+		}
+		return failover.Answer
+	case errors.As(err, &appErr), errors.Is(err, ethereum.NotFound):
+		return failover.Answer
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return failover.Transient
+	}
+	return failover.Unreachable
+}
+
+// do calls fn under Call, as the JSON-RPC method it makes. The node calls of fn must use the ctx it is given, which
+// ends at the timeout of the policy.
+func (p *Pool) do(ctx context.Context, method string, fn func(ctx context.Context, b Backend) error) error {
+	return p.Call(ctx, method, func(ctx context.Context, client interface{}) error {
+		return fn(ctx, client.(Backend))
+	})
+}
+
+func (p *Pool) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) (code []byte, err error) {
+	err = p.do(ctx, "eth_getCode", func(ctx context.Context, b Backend) (err error) {
+		code, err = b.CodeAt(ctx, contract, blockNumber)
+		return
+	})
+	return
+}
+
+func (p *Pool) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) (result []byte, err error) {
+	err = p.do(ctx, "eth_call", func(ctx context.Context, b Backend) (err error) {
+		result, err = b.CallContract(ctx, call, blockNumber)
+		return
+	})
+	return
+}
+
+func (p *Pool) PendingCallContract(ctx context.Context, call ethereum.CallMsg) (result []byte, err error) {
+	err = p.do(ctx, "eth_call", func(ctx context.Context, b Backend) (err error) {
+		result, err = b.PendingCallContract(ctx, call)
+		return
+	})
+	return
+}
+
+func (p *Pool) HeaderByHash(ctx context.Context, hash common.Hash) (header *types.Header, err error) {
+	err = p.do(ctx, "eth_getBlockByHash", func(ctx context.Context, b Backend) (err error) {
+		header, err = b.HeaderByHash(ctx, hash)
+		return
+	})
+	return
+}
+
+func (p *Pool) HeaderByNumber(ctx context.Context, number *big.Int) (header *types.Header, err error) {
+	err = p.do(ctx, "eth_getBlockByNumber", func(ctx context.Context, b Backend) (err error) {
+		header, err = b.HeaderByNumber(ctx, number)
+		return
+	})
+	return
+}
+
+func (p *Pool) BlockByHash(ctx context.Context, hash common.Hash) (block *types.Block, err error) {
+	err = p.do(ctx, "eth_getBlockByHash", func(ctx context.Context, b Backend) (err error) {
+		block, err = b.BlockByHash(ctx, hash)
+		return
+	})
+	return
+}
+
+func (p *Pool) BlockByNumber(ctx context.Context, number *big.Int) (block *types.Block, err error) {
+	err = p.do(ctx, "eth_getBlockByNumber", func(ctx context.Context, b Backend) (err error) {
+		block, err = b.BlockByNumber(ctx, number)
+		return
+	})
+	return
+}
+
+func (p *Pool) BlockNumber(ctx context.Context) (number uint64, err error) {
+	err = p.do(ctx, "eth_blockNumber", func(ctx context.Context, b Backend) (err error) {
+		number, err = b.BlockNumber(ctx)
+		return
+	})
+	return
+}
+
+func (p *Pool) NetworkID(ctx context.Context) (id *big.Int, err error) {
+	err = p.do(ctx, "net_version", func(ctx context.Context, b Backend) (err error) {
+		id, err = b.NetworkID(ctx)
+		return
+	})
+	return
+}
+
+func (p *Pool) TransactionCount(ctx context.Context, blockHash common.Hash) (count uint, err error) {
+	err = p.do(ctx, "eth_getBlockTransactionCountByHash", func(ctx context.Context, b Backend) (err error) {
+		count, err = b.TransactionCount(ctx, blockHash)
+		return
+	})
+	return
+}
+
+func (p *Pool) TransactionInBlock(ctx context.Context, blockHash common.Hash, index uint) (tx *types.Transaction, err error) {
+	err = p.do(ctx, "eth_getTransactionByBlockHashAndIndex", func(ctx context.Context, b Backend) (err error) {
+		tx, err = b.TransactionInBlock(ctx, blockHash, index)
+		return
+	})
+	return
+}
+
+func (p *Pool) TransactionByHash(ctx context.Context, hash common.Hash) (tx *types.Transaction, isPending bool, err error) {
+	err = p.do(ctx, "eth_getTransactionByHash", func(ctx context.Context, b Backend) (err error) {
+		tx, isPending, err = b.TransactionByHash(ctx, hash)
+		return
+	})
+	return
+}
+
+func (p *Pool) TransactionReceipt(ctx context.Context, hash common.Hash) (receipt *types.Receipt, err error) {
+	err = p.do(ctx, "eth_getTransactionReceipt", func(ctx context.Context, b Backend) (err error) {
+		receipt, err = b.TransactionReceipt(ctx, hash)
+		return
+	})
+	return
+}
+
+func (p *Pool) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (balance *big.Int, err error) {
+	err = p.do(ctx, "eth_getBalance", func(ctx context.Context, b Backend) (err error) {
+		balance, err = b.BalanceAt(ctx, account, blockNumber)
+		return
+	})
+	return
+}
+
+func (p *Pool) StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) (value []byte, err error) {
+	err = p.do(ctx, "eth_getStorageAt", func(ctx context.Context, b Backend) (err error) {
+		value, err = b.StorageAt(ctx, account, key, blockNumber)
+		return
+	})
+	return
+}
+
+func (p *Pool) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (nonce uint64, err error) {
+	err = p.do(ctx, "eth_getTransactionCount", func(ctx context.Context, b Backend) (err error) {
+		nonce, err = b.NonceAt(ctx, account, blockNumber)
+		return
+	})
+	return
+}
+
+func (p *Pool) PendingCodeAt(ctx context.Context, account common.Address) (code []byte, err error) {
+	err = p.do(ctx, "eth_getCode", func(ctx context.Context, b Backend) (err error) {
+		code, err = b.PendingCodeAt(ctx, account)
+		return
+	})
+	return
+}
+
+func (p *Pool) PendingNonceAt(ctx context.Context, account common.Address) (nonce uint64, err error) {
+	err = p.do(ctx, "eth_getTransactionCount", func(ctx context.Context, b Backend) (err error) {
+		nonce, err = b.PendingNonceAt(ctx, account)
+		return
+	})
+	return
+}
+
+func (p *Pool) SuggestGasPrice(ctx context.Context) (price *big.Int, err error) {
+	err = p.do(ctx, "eth_gasPrice", func(ctx context.Context, b Backend) (err error) {
+		price, err = b.SuggestGasPrice(ctx)
+		return
+	})
+	return
+}
+
+func (p *Pool) SuggestGasTipCap(ctx context.Context) (tip *big.Int, err error) {
+	err = p.do(ctx, "eth_maxPriorityFeePerGas", func(ctx context.Context, b Backend) (err error) {
+		tip, err = b.SuggestGasTipCap(ctx)
+		return
+	})
+	return
+}
+
+func (p *Pool) EstimateGas(ctx context.Context, call ethereum.CallMsg) (gas uint64, err error) {
+	err = p.do(ctx, "eth_estimateGas", func(ctx context.Context, b Backend) (err error) {
+		gas, err = b.EstimateGas(ctx, call)
+		return
+	})
+	return
+}
+
+// SendTransaction sends tx to the next node when a node can not be reached. A node that already has tx, because an
+// earlier attempt reached it or another node relayed it, counts as a success.
+func (p *Pool) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	return p.do(ctx, "eth_sendRawTransaction", func(ctx context.Context, b Backend) error {
+		err := b.SendTransaction(ctx, tx)
+		if err != nil && err.Error() == core.ErrAlreadyKnown.Error() {
+			return nil
+		}
+		return err
+	})
+}
+
+func (p *Pool) FilterLogs(ctx context.Context, query ethereum.FilterQuery) (logs []types.Log, err error) {
+	err = p.do(ctx, "eth_getLogs", func(ctx context.Context, b Backend) (err error) {
+		logs, err = b.FilterLogs(ctx, query)
+		return
+	})
+	return
+}
+
+func (p *Pool) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (sub ethereum.Subscription, err error) {
+	err = p.do(ctx, "eth_subscribe", func(ctx context.Context, b Backend) (err error) {
+		sub, err = b.SubscribeFilterLogs(ctx, query, ch)
+		return
+	})
+	return
+}
+
+func (p *Pool) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (sub ethereum.Subscription, err error) {
+	err = p.do(ctx, "eth_subscribe", func(ctx context.Context, b Backend) (err error) {
+		sub, err = b.SubscribeNewHead(ctx, ch)
+		return
+	})
+	return
+}
+
+func (p *Pool) CreateAccessList(ctx context.Context, msg ethereum.CallMsg) (accessList *types.AccessList, gas uint64, vmErr string, err error) {
+	err = p.do(ctx, "eth_createAccessList", func(ctx context.Context, b Backend) (err error) {
+		backend, ok := b.(AccessListBackend)
+		if !ok {
+			return ErrAccessListNotSupported
+		}
+		accessList, gas, vmErr, err = backend.CreateAccessList(ctx, msg)
+		return
+	})
+	return
+}
+
+func (p *Pool) EstimateGasWithAccessList(ctx context.Context, msg ethereum.CallMsg) (gas uint64, err error) {
+	err = p.do(ctx, "eth_estimateGas", func(ctx context.Context, b Backend) (err error) {
+		backend, ok := b.(AccessListBackend)
+		if !ok {
+			return ErrAccessListNotSupported
+		}
+		gas, err = backend.EstimateGasWithAccessList(ctx, msg)
+		return
+	})
+	return
+}
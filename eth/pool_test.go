@@ -0,0 +1,197 @@
+package eth
+
+import (
+	"context"
+	"demo/failover"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNode is a JSON-RPC server answering the few calls the pool tests make.
+type fakeNode struct {
+	*httptest.Server
+	mu      sync.Mutex
+	height  uint64
+	balance int64
+	sendErr string //error message of eth_sendRawTransaction
+	behind  int    //eth_getBalance calls answered with "header not found"
+	calls   map[string]int
+}
+
+func newFakeNode(t *testing.T, height uint64, balance int64) *fakeNode {
+	node := &fakeNode{height: height, balance: balance, calls: map[string]int{}}
+	node.Server = httptest.NewServer(http.HandlerFunc(node.serve))
+	t.Cleanup(node.Close)
+	return node
+}
+
+func (n *fakeNode) serve(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.calls[request.Method]++
+	response := map[string]interface{}{"jsonrpc": "2.0", "id": request.ID}
+	switch request.Method {
+	case "eth_blockNumber":
+		response["result"] = hexutil.Uint64(n.height)
+	case "eth_getBalance":
+		if n.behind > 0 {
+			n.behind--
+			response["error"] = map[string]interface{}{"code": -32000, "message": "header not found"}
+			break
+		}
+		response["result"] = (*hexutil.Big)(big.NewInt(n.balance))
+	case "eth_sendRawTransaction":
+		if len(n.sendErr) > 0 {
+			response["error"] = map[string]interface{}{"code": -32000, "message": n.sendErr}
+			break
+		}
+		response["result"] = common.Hash{}
+	default:
+		response["error"] = map[string]interface{}{"code": -32601, "message": "the method does not exist"}
+	}
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func (n *fakeNode) setSendErr(message string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sendErr = message
+}
+
+func (n *fakeNode) callCount(method string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.calls[method]
+}
+
+func Test_PoolReads(t *testing.T) {
+	synced, lagging, down := newFakeNode(t, 100, 1), newFakeNode(t, 90, 2), newFakeNode(t, 100, 3)
+	down.Close()
+	pool, err := DialPool([]string{down.URL, lagging.URL, synced.URL}, 5)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	balance, err := pool.BalanceAt(ctx, common.Address{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), balance.Int64(), "before the first check every node is healthy, in order")
+	assert.False(t, pool.Status()[0].Healthy)
+
+	pool.Check(ctx)
+	statuses := pool.Status()
+	assert.False(t, statuses[0].Healthy)
+	assert.True(t, statuses[1].Lagging)
+	assert.Equal(t, uint64(100), statuses[2].Height)
+
+	balance, err = pool.BalanceAt(ctx, common.Address{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), balance.Int64(), "reads go to the node in sync")
+	assert.Equal(t, 1, lagging.callCount("eth_getBalance"), "the lagging node is quarantined")
+
+	_, err = pool.CallContract(ctx, ethereum.CallMsg{}, nil)
+	assert.EqualError(t, err, "the method does not exist")
+	assert.True(t, pool.Status()[2].Healthy, "an error answer does not fail the node over")
+
+	synced.Close()
+	_, err = pool.BalanceAt(ctx, common.Address{}, nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "all 2 nodes failed")
+}
+
+func Test_PoolSendTransaction(t *testing.T) {
+	first, second := newFakeNode(t, 100, 0), newFakeNode(t, 100, 0)
+	pool := NewPool(5)
+	for _, node := range []*fakeNode{first, second} {
+		backend, err := DialBackend(node.URL)
+		assert.NoError(t, err)
+		pool.Add(node.URL, backend)
+	}
+
+	key, _ := crypto.GenerateKey()
+	tx, _ := types.SignTx(types.NewTransaction(0, common.Address{}, big.NewInt(1), 21000, big.NewInt(1), nil),
+		types.HomesteadSigner{}, key)
+	ctx := context.Background()
+
+	first.setSendErr("nonce too low")
+	second.setSendErr("nonce too low")
+	assert.EqualError(t, pool.SendTransaction(ctx, tx), "nonce too low")
+	assert.Equal(t, 1, first.callCount("eth_sendRawTransaction")+second.callCount("eth_sendRawTransaction"),
+		"an error answer is not sent to the next node")
+
+	first.Close()
+	second.setSendErr("already known")
+	assert.NoError(t, pool.SendTransaction(ctx, tx), "a node that has the transaction already is fine")
+	assert.Equal(t, 1, second.callCount("eth_sendRawTransaction"), "a write fails over to the next node")
+	assert.False(t, pool.Status()[0].Healthy)
+}
+
+func Test_PoolRetry(t *testing.T) {
+	node := newFakeNode(t, 100, 7)
+	node.behind = 2
+	pool, _ := DialPool([]string{node.URL}, 0)
+	ctx := context.Background()
+
+	_, err := pool.BalanceAt(ctx, common.Address{}, nil)
+	assert.EqualError(t, err, "all 1 nodes failed, the last with: header not found")
+
+	pool.SetPolicy(failover.Policy{Retries: 2, Backoff: time.Millisecond})
+	balance, err := pool.BalanceAt(ctx, common.Address{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), balance.Int64())
+	assert.Equal(t, 3, node.callCount("eth_getBalance"))
+}
+
+type codeError struct {
+	code    int
+	message string
+}
+
+func (e codeError) Error() string {
+	return e.message
+}
+
+func (e codeError) ErrorCode() int {
+	return e.code
+}
+
+func Test_Classify(t *testing.T) {
+	for _, c := range []struct {
+		err   error
+		class failover.Class
+	}{
+		{codeError{-32000, "execution reverted"}, failover.Answer},
+		{codeError{-32000, "header not found"}, failover.Transient},
+		{codeError{limitExceeded, "daily request count exceeded"}, failover.Transient},
+		{rpc.HTTPError{StatusCode: http.StatusTooManyRequests}, failover.Transient},
+		{rpc.HTTPError{StatusCode: http.StatusBadGateway}, failover.Unreachable},
+		{fmt.Errorf("call: %w", context.DeadlineExceeded), failover.Transient},
+		{ethereum.NotFound, failover.Answer},
+		{ErrAccessListNotSupported, failover.Answer},
+		{errors.New("dial tcp 127.0.0.1:8545: connect: connection refused"), failover.Unreachable},
+	} {
+		assert.Equal(t, c.class, classify(c.err), c.err.Error())
+	}
+}
@@ -2,6 +2,11 @@ package eth
 
 import (
 	"context"
+	"demo/amount"
+	"demo/failover"
+	"demo/logging"
+	"demo/metrics"
+	"demo/policy"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -13,65 +18,130 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
 	hdwallet "github.com/miguelmota/go-ethereum-hdwallet"
 	"github.com/nite-coder/blackbear/pkg/cast"
 	"github.com/shopspring/decimal"
+	"math"
 	"math/big"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
 var (
 	decimal0         = decimal.NewFromInt(0)
-	decimal18        = decimal.New(1, 18)
-	decimal9         = decimal.New(1, 9)
 	transferMethodId = "a9059cbb"
 )
 
 type Service struct {
-	client                *ethclient.Client
+	client                Backend
 	blockConfirmationNum  uint64
 	eabi                  abi.ABI
-	estimateGasMultiplier float64
+	estimateGasMultiplier uint64 //math.Float64bits of the multiplier, see SetGasMultiplier
+	l1DataFee             L1DataFeeFunc
+	errorABIs             []abi.ABI
+	hook                  metrics.Hook
+	instrumented          bool //SetInstrumentation was given a hook, the nonce gaps are worth the extra call
+	logger                log.Logger
+	policy                *policy.Policy
 }
 
-func NewService(client *ethclient.Client, blockConfirmationNum uint64, estimateGasMultiplier float64) *Service {
-	decimal.DivisionPrecision = 18
+func NewService(client Backend, blockConfirmationNum uint64, estimateGasMultiplier float64) *Service {
 	eabi, _ := abi.JSON(strings.NewReader(TokenMetaData.ABI))
 	return &Service{
 		client:                client,
 		blockConfirmationNum:  blockConfirmationNum,
-		estimateGasMultiplier: estimateGasMultiplier,
+		estimateGasMultiplier: math.Float64bits(estimateGasMultiplier),
 		eabi:                  eabi,
+		hook:                  metrics.Nop{},
+		logger:                logging.Discard(),
 	}
 }
 
+// SetLogger makes the service log to logger, with chain=eth on every entry. The service logs nothing by default.
+// Call it before the service is in use.
+func (svc *Service) SetLogger(logger log.Logger) {
+	svc.logger = logging.With(logger, "chain", "eth")
+}
+
+// SetInstrumentation makes the service report its broadcasts, fee levels, nonce gaps and scanner progress to hook,
+// and the node calls of its client too when the client is a Pool. A nil hook turns the instrumentation off. Call it
+// before the service is in use.
+func (svc *Service) SetInstrumentation(hook metrics.Hook) {
+	svc.instrumented = hook != nil
+	if hook == nil {
+		hook = metrics.Nop{}
+	}
+	svc.hook = hook
+	if pool, ok := svc.client.(*Pool); ok {
+		pool.SetObserver(hook)
+	}
+}
+
+// Client returns the *ethclient.Client behind the service: the client NewService was given, or the one an
+// RPCBackend wraps. It is nil for other backends, such as a Pool or a SimulatedBackend, see Backend.
 func (svc *Service) Client() *ethclient.Client {
+	switch client := svc.client.(type) {
+	case *ethclient.Client:
+		return client
+	case *RPCBackend:
+		return client.Client
+	}
+	return nil
+}
+
+// Backend returns the backend the service was built with.
+func (svc *Service) Backend() Backend {
 	return svc.client
 }
 
+// SetConfirmations changes the blockConfirmationNum of NewService. It is safe to call while the service is in use.
+func (svc *Service) SetConfirmations(blockConfirmationNum uint64) {
+	atomic.StoreUint64(&svc.blockConfirmationNum, blockConfirmationNum)
+}
+
+func (svc *Service) confirmations() uint64 {
+	return atomic.LoadUint64(&svc.blockConfirmationNum)
+}
+
+// SetGasMultiplier changes the estimateGasMultiplier of NewService. It is safe to call while the service is in use.
+func (svc *Service) SetGasMultiplier(estimateGasMultiplier float64) {
+	atomic.StoreUint64(&svc.estimateGasMultiplier, math.Float64bits(estimateGasMultiplier))
+}
+
+func (svc *Service) gasMultiplier() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&svc.estimateGasMultiplier))
+}
+
 func (svc *Service) CurrentBlockHeight(ctx context.Context) (uint64, error) {
 	return svc.client.BlockNumber(ctx)
 }
 
+// Nonce returns the pending nonce of fromAddress. An instrumented service also reads the mined nonce, to report the
+// nonce gap; failing to read it is logged and does not fail the call.
 func (svc *Service) Nonce(ctx context.Context, fromAddress string) (uint64, error) {
-	return svc.client.PendingNonceAt(ctx, common.HexToAddress(fromAddress))
-}
-
-func (svc *Service) CreateAddress(ctx context.Context, mnemonic string, index uint32) (string, error) {
-	wallet, err := hdwallet.NewFromMnemonic(mnemonic)
+	address := common.HexToAddress(fromAddress)
+	nonce, err := svc.client.PendingNonceAt(ctx, address)
 	if err != nil {
-		return "", err
+		return 0, err
 	}
 
-	path := fmt.Sprintf("m/44'/60'/0'/0/%d", index)
-	account, err := wallet.Derive(hdwallet.MustParseDerivationPath(path), false)
-	if err != nil {
-		return "", err
+	if svc.instrumented {
+		mined, err := svc.client.NonceAt(ctx, address, nil)
+		if err != nil {
+			svc.logger.Warn("Nonce gap not reported", "address", address.Hex(), "err", err)
+		} else if nonce >= mined {
+			svc.hook.NonceGap(address.Hex(), nonce-mined)
+		}
 	}
+	return nonce, nil
+}
 
-	return wallet.AddressHex(account)
+func (svc *Service) CreateAddress(ctx context.Context, mnemonic string, index uint32) (string, error) {
+	address, _, err := deriveAccount(mnemonic, index)
+	return address, err
 }
 
 func (svc *Service) CreateAddressByPubKey(ctx context.Context, publicKey string) (string, error) {
@@ -95,23 +165,16 @@ func (svc *Service) BalanceETH(ctx context.Context, address string) (*decimal.De
 		return nil, err
 	}
 
-	result, err := decimal.NewFromString(balance.String())
-	if err != nil {
-		return nil, err
-	}
-
-	result = result.Div(decimal18)
+	result := amount.FromBase(balance, amount.Ether)
 	return &result, nil
 }
 
 func (svc *Service) BalanceERC20(ctx context.Context, tokenAddr, ownerAddr string) (*decimal.Decimal, error) {
-	result := decimal.Decimal{}
 	erc20Token, err := svc.ERC20Info(ctx, tokenAddr)
 	if err != nil {
 		return nil, err
 	}
 
-	decimalPlaces := decimal.New(1, int32(erc20Token.Decimals))
 	tokenAddress := common.HexToAddress(tokenAddr)
 	ownerAddress := common.HexToAddress(ownerAddr)
 
@@ -120,17 +183,12 @@ func (svc *Service) BalanceERC20(ctx context.Context, tokenAddr, ownerAddr strin
 		return nil, err
 	}
 
-	amount, err := instance.BalanceOf(&bind.CallOpts{}, ownerAddress)
+	balance, err := instance.BalanceOf(&bind.CallOpts{Context: ctx}, ownerAddress)
 	if err != nil {
 		return nil, err
 	}
 
-	result, err = decimal.NewFromString(amount.String())
-	if err != nil {
-		return nil, err
-	}
-
-	result = result.Div(decimalPlaces)
+	result := amount.FromBase(balance, amount.Token(erc20Token.Decimals))
 	return &result, nil
 }
 
@@ -143,22 +201,22 @@ func (svc *Service) ERC20Info(ctx context.Context, contractAddress string) (*ERC
 		return nil, err
 	}
 
-	info.Name, err = instance.Name(&bind.CallOpts{})
+	info.Name, err = instance.Name(&bind.CallOpts{Context: ctx})
 	if err != nil {
 		return nil, err
 	}
 
-	info.Decimals, err = instance.Decimals(&bind.CallOpts{})
+	info.Decimals, err = instance.Decimals(&bind.CallOpts{Context: ctx})
 	if err != nil {
 		return nil, err
 	}
 
-	info.Symbol, err = instance.Symbol(&bind.CallOpts{})
+	info.Symbol, err = instance.Symbol(&bind.CallOpts{Context: ctx})
 	if err != nil {
 		return nil, err
 	}
 
-	totalSupply, err := instance.TotalSupply(&bind.CallOpts{})
+	totalSupply, err := instance.TotalSupply(&bind.CallOpts{Context: ctx})
 	if err != nil {
 		return nil, err
 	}
@@ -172,12 +230,8 @@ func (svc *Service) SuggestGasPrice(ctx context.Context) (*decimal.Decimal, erro
 	if err != nil {
 		return nil, err
 	}
-	result, err := decimal.NewFromString(gasPrice.String())
-	if err != nil {
-		return nil, err
-	}
-
-	result = result.Div(decimal18)
+	svc.hook.Fee("gas_price", weiFloat(gasPrice))
+	result := amount.FromBase(gasPrice, amount.Ether)
 	return &result, nil
 }
 
@@ -187,130 +241,215 @@ func (svc *Service) MaxFee(ctx context.Context, tip int32) (*decimal.Decimal, er
 		return nil, err
 	}
 
-	tipString := new(big.Int).Mul(big.NewInt(int64(tip)), big.NewInt(params.GWei)).String()
-	tipDecimal, err := decimal.NewFromString(tipString)
+	tipDecimal, err := amount.Convert(decimal.NewFromInt32(tip), amount.Gwei, amount.Ether, amount.RoundExact)
 	if err != nil {
 		return nil, err
 	}
 
-	tipDecimal = tipDecimal.Div(decimal18)
 	result := gasPrice.Add(tipDecimal)
 	return &result, nil
 }
 
 func (svc *Service) CreateTransaction(ctx context.Context, request CreateTransactionRequest) (*types.Transaction, error) {
-	reqAmount, err := decimal.NewFromString(request.Amount)
-	if err != nil {
-		return nil, err
+	if request.TxType > types.DynamicFeeTxType {
+		return nil, ErrInvalidInput
 	}
 
-	if reqAmount.LessThan(decimal0) {
+	if request.TxType == types.LegacyTxType && (len(request.AccessList) > 0 || request.GenerateAccessList) {
 		return nil, ErrInvalidInput
 	}
 
-	fromAddress := common.HexToAddress(request.From)
-	toAddress := common.HexToAddress(request.To)
+	call, err := svc.transactionCall(ctx, request)
+	if err != nil {
+		return nil, err
+	}
 
-	maxFee, err := decimal.NewFromString(request.GasMaxFee)
+	maxFee, err := parseBaseUnits(request.GasMaxFee, amount.Ether)
 	if err != nil {
 		return nil, err
 	}
-	maxFee = maxFee.Mul(decimal18)
+	svc.hook.Fee("max_fee", weiFloat(maxFee))
 
 	chainID, err := svc.client.NetworkID(ctx)
 	if err != nil {
 		return nil, err
 	}
-	fmt.Print(chainID)
-	//  DynamicFeeTx
-	if len(request.TokenAddress) < 1 {
-		var data []byte
-		tx := types.NewTransaction(request.Nonce, toAddress, reqAmount.Mul(decimal18).BigInt(), request.GasLimit, maxFee.BigInt(), data)
-/*
-			tx := types.NewTx(&types.DynamicFeeTx{
-				ChainID:   chainID,
-				Nonce:     request.Nonce,
-				GasFeeCap: maxFee.BigInt(),
-				GasTipCap: new(big.Int).Mul(big.NewInt(int64(request.GasTip)), big.NewInt(params.Wei)),
-				Gas:       request.GasLimit,
-				To:        &toAddress,
-				Value:     reqAmount.Mul(decimal18).BigInt(),
-				Data:      data,
-				AccessList: nil,
-			})
-*/
-		return tx, nil
+
+	call.AccessList, err = svc.accessList(ctx, request, call)
+	if err != nil {
+		return nil, err
 	}
+	request.AccessList = call.AccessList
 
-	tokenInfo, err := svc.ERC20Info(ctx, request.TokenAddress)
+	if len(request.TokenAddress) > 0 && !request.DisableEstimateGas {
+		request.GasLimit, err = svc.estimateGas(ctx, *call)
+		if err != nil {
+			return nil, err
+		}
+
+		request.GasLimit = uint64(float64(request.GasLimit) * svc.gasMultiplier())
+	}
+
+	tx := newTransaction(chainID, request, call, maxFee)
+	svc.logger.Debug("Created transaction", "from", request.From, "to", request.To, "token", request.TokenAddress,
+		"amount", request.Amount, "nonce", request.Nonce, "chain_id", chainID)
+	return tx, nil
+}
+
+// estimateGas estimates call with its access list when it has one and the backend can send it.
+func (svc *Service) estimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	if backend, ok := svc.client.(AccessListBackend); ok && len(call.AccessList) > 0 {
+		return backend.EstimateGasWithAccessList(ctx, call)
+	}
+	return svc.client.EstimateGas(ctx, call)
+}
+
+// accessList returns the access list of request, the generated one when GenerateAccessList is set and it saves gas.
+func (svc *Service) accessList(ctx context.Context, request CreateTransactionRequest, call *ethereum.CallMsg) (types.AccessList, error) {
+	if !request.GenerateAccessList {
+		return request.AccessList, nil
+	}
+
+	result, err := svc.createAccessList(ctx, *call)
 	if err != nil {
 		return nil, err
 	}
 
-	tokenAddress := common.HexToAddress(request.TokenAddress)
-	decimalPlace := decimal.New(1, int32(tokenInfo.Decimals))
-	amount := reqAmount.Mul(decimalPlace)
+	if result.GasSaving > 0 {
+		return result.AccessList, nil
+	}
+	return request.AccessList, nil
+}
 
-	data, err := svc.eabi.Pack("transfer", toAddress, amount.BigInt())
+// transactionCall returns the call request makes: a plain ETH transfer, or a transfer on the token contract.
+func (svc *Service) transactionCall(ctx context.Context, request CreateTransactionRequest) (*ethereum.CallMsg, error) {
+	reqAmount, err := decimal.NewFromString(request.Amount)
 	if err != nil {
 		return nil, err
 	}
 
-	if !request.DisableEstimateGas {
-		request.GasLimit, err = svc.client.EstimateGas(ctx, ethereum.CallMsg{
-			From:  fromAddress,
-			To:    &tokenAddress,
-			Value: big.NewInt(0),
-			Data:  data,
-		})
+	if reqAmount.LessThan(decimal0) {
+		return nil, ErrInvalidInput
+	}
+
+	toAddress := common.HexToAddress(request.To)
+	call := ethereum.CallMsg{From: common.HexToAddress(request.From)}
+	if len(request.TokenAddress) < 1 {
+		call.To = &toAddress
+		call.Value, err = toBaseUnits(reqAmount, amount.Ether)
 		if err != nil {
 			return nil, err
 		}
+		return &call, nil
+	}
 
-		request.GasLimit = uint64(float64(request.GasLimit) * svc.estimateGasMultiplier)
-	}
-	tx := types.NewTransaction(request.Nonce, toAddress, reqAmount.Mul(decimal18).BigInt(), request.GasLimit, maxFee.BigInt(), data)
-	/*
-		tx := types.NewTx(&types.DynamicFeeTx{
-			ChainID:   chainID,
-			Nonce:     request.Nonce,
-			GasFeeCap: maxFee.BigInt(),
-			GasTipCap: new(big.Int).Mul(big.NewInt(int64(request.GasTip)), big.NewInt(params.Wei)),
-			Gas:       request.GasLimit,
-			To:        &tokenAddress,
-			Value:     big.NewInt(0),
-			Data:      data,
-		})
+	tokenInfo, err := svc.ERC20Info(ctx, request.TokenAddress)
+	if err != nil {
+		return nil, err
+	}
 
-	*/
-	return tx, nil
+	tokenAddress := common.HexToAddress(request.TokenAddress)
+	value, err := toBaseUnits(reqAmount, amount.Token(tokenInfo.Decimals))
+	if err != nil {
+		return nil, err
+	}
+
+	call.Data, err = svc.eabi.Pack("transfer", toAddress, value)
+	if err != nil {
+		return nil, err
+	}
+
+	call.To = &tokenAddress
+	call.Value = big.NewInt(0)
+	return &call, nil
+}
+
+func newTransaction(chainID *big.Int, request CreateTransactionRequest, call *ethereum.CallMsg, maxFee *big.Int) *types.Transaction {
+	switch request.TxType {
+	case types.AccessListTxType:
+		return types.NewTx(&types.AccessListTx{
+			ChainID:    chainID,
+			Nonce:      request.Nonce,
+			GasPrice:   maxFee,
+			Gas:        request.GasLimit,
+			To:         call.To,
+			Value:      call.Value,
+			Data:       call.Data,
+			AccessList: request.AccessList,
+		})
+	case types.DynamicFeeTxType:
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:    chainID,
+			Nonce:      request.Nonce,
+			GasTipCap:  new(big.Int).Mul(big.NewInt(int64(request.GasTip)), big.NewInt(params.GWei)),
+			GasFeeCap:  maxFee,
+			Gas:        request.GasLimit,
+			To:         call.To,
+			Value:      call.Value,
+			Data:       call.Data,
+			AccessList: request.AccessList,
+		})
+	}
+	return types.NewTransaction(request.Nonce, *call.To, call.Value, request.GasLimit, maxFee, call.Data)
 }
 
 func (svc *Service) SignTransaction(ctx context.Context, tx *types.Transaction, privateKey string) (*types.Transaction, error) {
-	if strings.HasPrefix(privateKey, "0x") {
-		privateKey = privateKey[2:]
+	privateKeyECDSA, err := ParsePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
 	}
-	privateKeyECDSA, err := crypto.HexToECDSA(privateKey)
+
+	chainID, err := svc.client.NetworkID(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	chainID, err := svc.Client().NetworkID(ctx)
+	signer := types.NewLondonSigner(chainID)
+	address := crypto.PubkeyToAddress(privateKeyECDSA.PublicKey)
+	if err = svc.checkPolicy(ctx, tx, signer, address); err != nil {
+		return nil, err
+	}
+
+	signedTx, err := types.SignTx(tx, signer, privateKeyECDSA)
 	if err != nil {
 		return nil, err
 	}
 
-	return types.SignTx(tx, types.NewLondonSigner(chainID), privateKeyECDSA)
+	svc.logger.Debug("Signed transaction", "tx", signedTx.Hash(), "address", address)
+	return signedTx, nil
 }
 
+// SignerHash returns the hash an external signer signs for tx. A policy needs the sender of tx, so a service with
+// one refuses with ErrSenderRequired, use SignerHashFrom instead.
 func (svc *Service) SignerHash(ctx context.Context, tx *types.Transaction) ([]byte, error) {
+	if svc.policy != nil {
+		return nil, ErrSenderRequired
+	}
+
 	chainId, err := svc.client.NetworkID(ctx)
 	if err != nil {
 		return nil, err
 	}
+	return types.NewLondonSigner(chainId).Hash(tx).Bytes(), nil
+}
+
+// SignerHashFrom returns the hash an external signer signs for tx to be sent from the address from. The policy of
+// the service is evaluated here, before the hash goes out, and again by WithSignature.
+func (svc *Service) SignerHashFrom(ctx context.Context, tx *types.Transaction, from string) ([]byte, error) {
+	if !common.IsHexAddress(from) {
+		return nil, ErrInvalidInput
+	}
 
-	signer := types.NewLondonSigner(chainId).Hash(tx)
-	return signer.Bytes(), nil
+	chainId, err := svc.client.NetworkID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	signer := types.NewLondonSigner(chainId)
+	if err = svc.checkPolicy(ctx, tx, signer, common.HexToAddress(from)); err != nil {
+		return nil, err
+	}
+	return signer.Hash(tx).Bytes(), nil
 }
 
 func (svc *Service) WithSignature(ctx context.Context, tx *types.Transaction, signature []byte) (*types.Transaction, error) {
@@ -319,11 +458,41 @@ func (svc *Service) WithSignature(ctx context.Context, tx *types.Transaction, si
 		return nil, err
 	}
 
-	return tx.WithSignature(types.NewLondonSigner(chainId), signature)
+	signer := types.NewLondonSigner(chainId)
+	signedTx, err := tx.WithSignature(signer, signature)
+	if err != nil {
+		return nil, err
+	}
+
+	if svc.policy == nil {
+		return signedTx, nil
+	}
+
+	from, err := types.Sender(signer, signedTx)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	if err = svc.checkPolicy(ctx, tx, signer, from); err != nil {
+		return nil, err
+	}
+	return signedTx, nil
 }
 
 func (svc *Service) Broadcast(ctx context.Context, tx *types.Transaction) error {
-	return svc.client.SendTransaction(ctx, tx)
+	err := svc.client.SendTransaction(ctx, tx)
+	switch {
+	case err == nil:
+		svc.hook.Broadcast(metrics.Accepted)
+		svc.logger.Info("Broadcast transaction", "tx", tx.Hash(), "nonce", tx.Nonce())
+	case classify(err) == failover.Answer:
+		svc.hook.Broadcast(metrics.Rejected)
+		svc.logger.Warn("Transaction rejected", "tx", tx.Hash(), "nonce", tx.Nonce(), "err", err)
+	default:
+		svc.hook.Broadcast(metrics.Failed)
+		svc.logger.Error("Broadcast failed", "tx", tx.Hash(), "nonce", tx.Nonce(), "err", err)
+	}
+	return err
 }
 
 func (svc *Service) createTransactionInfo(ctx context.Context, tx *types.Transaction, receipt *types.Receipt, signer types.Signer,
@@ -339,35 +508,16 @@ func (svc *Service) createTransactionInfo(ctx context.Context, tx *types.Transac
 	}
 
 	//fee
-	gasUsed := decimal.NewFromInt(int64(receipt.GasUsed))
-	switch tx.Type() {
-	case 0: //legacy
-		gasPrice, err := decimal.NewFromString(tx.GasPrice().String())
-		if err != nil {
-			return nil, err
-		}
-		txInfo.Fee = gasPrice.Mul(gasUsed).Div(decimal18)
-	case 2: //eip-1559
-		tip, err := decimal.NewFromString(tx.GasTipCap().String())
-		if err != nil {
-			return nil, err
-		}
-
-		baseFee, err := decimal.NewFromString(block.BaseFee().String())
-		if err != nil {
-			return nil, err
-		}
-		txInfo.Fee = baseFee.Add(tip).Mul(gasUsed).Div(decimal18)
+	fee, l1Fee, err := svc.transactionFee(ctx, tx, receipt, block)
+	if err != nil {
+		return nil, err
 	}
+	txInfo.Fee = fee
+	txInfo.L1Fee = l1Fee
 
-	if currentBlockHeight > txInfo.BlockNumber+svc.blockConfirmationNum {
-		if receipt.Status == 1 {
-			txInfo.State = TransactionSateSuccess
-		} else {
-			txInfo.State = TransactionSateFail
-		}
-	} else {
-		txInfo.State = TransactionSatePending
+	txInfo.State = svc.receiptState(receipt, currentBlockHeight)
+	if receipt.Status == types.ReceiptStatusFailed {
+		txInfo.RevertReason = svc.failedTransactionReason(ctx, tx, receipt, signer)
 	}
 
 	isTokenAddress := true
@@ -388,7 +538,7 @@ func (svc *Service) createTransactionInfo(ctx context.Context, tx *types.Transac
 		}
 
 		methodID := inputData[0:8]
-		decimalPlaces := decimal.New(1, int32(tokenInfo.Decimals))
+		tokenUnit := amount.Token(tokenInfo.Decimals)
 		//交易失败logs不会有资料 需要自己解析
 		if len(receipt.Logs) == 0 {
 			decodeSig, err := hex.DecodeString(methodID)
@@ -419,15 +569,13 @@ func (svc *Service) createTransactionInfo(ctx context.Context, tx *types.Transac
 			if err != nil {
 				return nil, err
 			}
-			amount, err := decimal.NewFromString(strAmount)
+			value, err := decimal.NewFromString(strAmount)
 			if err != nil {
 				return nil, err
 			}
 
-			amount = amount.Div(decimalPlaces)
-
 			txInfo.To = strings.ToUpper(common.HexToAddress(toAddr).String())
-			txInfo.Amount = amount
+			txInfo.Amount = amount.FromBase(value.BigInt(), tokenUnit)
 			return &txInfo, nil
 		}
 		instance, err := NewToken(*tx.To(), svc.client)
@@ -440,15 +588,10 @@ func (svc *Service) createTransactionInfo(ctx context.Context, tx *types.Transac
 			return nil, err
 		}
 
-		amount, err := decimal.NewFromString(ethTransfer.Value.String())
-		if err != nil {
-			return nil, ErrNotSupportTX
-		}
-
 		txInfo.TokenAddress = strings.ToUpper(log.Address.Hex())
 		txInfo.From = strings.ToUpper(ethTransfer.From.Hex())
 		txInfo.To = strings.ToUpper(ethTransfer.To.Hex())
-		txInfo.Amount = amount.Div(decimalPlaces)
+		txInfo.Amount = amount.FromBase(ethTransfer.Value, tokenUnit)
 		return &txInfo, nil
 	}
 	from, err := types.Sender(signer, tx)
@@ -457,11 +600,7 @@ func (svc *Service) createTransactionInfo(ctx context.Context, tx *types.Transac
 	}
 
 	txInfo.From = strings.ToUpper(from.Hex())
-	amount, err := decimal.NewFromString(tx.Value().String())
-	if err != nil {
-		return nil, err
-	}
-	txInfo.Amount = amount.Div(decimal18)
+	txInfo.Amount = amount.FromBase(tx.Value(), amount.Ether)
 
 	return &txInfo, nil
 }
@@ -564,6 +703,17 @@ func (svc *Service) Block(ctx context.Context, number uint64) (*BlockInfo, error
 	return &blockInfo, nil
 }
 
+func (svc *Service) receiptState(receipt *types.Receipt, currentBlockHeight uint64) TransactionSate {
+	if currentBlockHeight <= receipt.BlockNumber.Uint64()+svc.confirmations() {
+		return TransactionSatePending
+	}
+
+	if receipt.Status == types.ReceiptStatusSuccessful {
+		return TransactionSateSuccess
+	}
+	return TransactionSateFail
+}
+
 func isMethodSupport(tx *types.Transaction) bool {
 	inputData := hex.EncodeToString(tx.Data())
 	if len(inputData) < 8 {
@@ -572,4 +722,47 @@ func isMethodSupport(tx *types.Transaction) bool {
 
 	methodID := inputData[0:8]
 	return methodID == transferMethodId
-}
\ No newline at end of file
+}
+
+// weiFloat returns wei as a float64 for the metrics, which do not need its precision.
+func weiFloat(wei *big.Int) float64 {
+	value, _ := new(big.Float).SetInt(wei).Float64()
+	return value
+}
+
+func deriveAccount(mnemonic string, index uint32) (string, string, error) {
+	wallet, err := hdwallet.NewFromMnemonic(mnemonic)
+	if err != nil {
+		return "", "", err
+	}
+
+	path := fmt.Sprintf("m/44'/60'/0'/0/%d", index)
+	account, err := wallet.Derive(hdwallet.MustParseDerivationPath(path), false)
+	if err != nil {
+		return "", "", err
+	}
+
+	privateKey, err := wallet.PrivateKeyHex(account)
+	if err != nil {
+		return "", "", err
+	}
+
+	return account.Address.Hex(), privateKey, nil
+}
+
+// toBaseUnits converts a display amount to base units, rejecting amounts more precise than the unit.
+func toBaseUnits(value decimal.Decimal, unit amount.Unit) (*big.Int, error) {
+	result, err := amount.ToBase(value, unit, amount.RoundExact)
+	if errors.Is(err, amount.ErrTooManyDecimals) {
+		return nil, ErrTooManyDecimals
+	}
+	return result, err
+}
+
+func parseBaseUnits(value string, unit amount.Unit) (*big.Int, error) {
+	result, err := decimal.NewFromString(value)
+	if err != nil {
+		return nil, err
+	}
+	return toBaseUnits(result, unit)
+}
@@ -1,20 +1,27 @@
 package eth
 
 import (
+	"bytes"
 	"context"
 	"crypto/ecdsa"
+	"demo/logging"
+	"demo/metrics"
 	"demo/token"
 	"encoding/hex"
-	"fmt"
+	"errors"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/params"
-	"log"
+	"github.com/stretchr/testify/assert"
 	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 )
@@ -23,11 +30,12 @@ const (
 	owner1Addr       = "0xE280029a7867BA5C9154434886c241775ea87e53"
 	owner1PrivateKey = "0xf1b3f8e0d52caec13491368449ab8d90f3d222a3e485aa7f02591bbceb5efba5"
 	owner2Addr       = "0x68dB32D26d9529B2a142927c6f1af248fc6Ba7e9"
+	owner2PrivateKey = "0x91821f9af458d612362136648fc8552a47d8289c0f25a8a1bf0860510332cef9"
 	tokenAddr        = "0xf3585FCD969502624c6A8ACf73721d1fce214E83"
 )
 
 func Test_ERc20Info(t *testing.T) {
-	svc := getService()
+	svc := getService(t)
 	ctx := context.Background()
 	tokenInfo, err := svc.ERC20Info(ctx, tokenAddr)
 	if err != nil {
@@ -42,7 +50,7 @@ func Test_ERc20Info(t *testing.T) {
 }
 
 func Test_SuggestionPrice(t *testing.T) {
-	svc := getService()
+	svc := getService(t)
 	ctx := context.Background()
 	gasPrice, err := svc.SuggestGasPrice(ctx)
 	if err != nil {
@@ -53,7 +61,7 @@ func Test_SuggestionPrice(t *testing.T) {
 }
 
 func Test_CreateAddress(t *testing.T) {
-	svc := getService()
+	svc := getService(t)
 	ctx := context.Background()
 
 	mnemonic := "tag volcano eight thank tide danger coast health above argue embrace heavy"
@@ -66,7 +74,7 @@ func Test_CreateAddress(t *testing.T) {
 	///
 	privateKey, err := crypto.HexToECDSA("f1b3f8e0d52caec13491368449ab8d90f3d222a3e485aa7f02591bbceb5efba5")
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
 	publicKey := privateKey.Public()
@@ -81,7 +89,7 @@ func Test_CreateAddress(t *testing.T) {
 }
 
 func Test_Balance(t *testing.T) {
-	svc := getService()
+	svc := getService(t)
 	ctx := context.Background()
 	balance, err := svc.BalanceETH(ctx, owner1Addr)
 	if err != nil {
@@ -97,16 +105,16 @@ func Test_Balance(t *testing.T) {
 }
 
 func Test_GetTransaction(t *testing.T) {
-	svc := getService()
+	svc := getService(t)
 	ctx := context.Background()
-	txAddress := ethTransaction()
+	txAddress := ethTransaction(t)
 	txInfo, err := svc.Transaction(ctx, txAddress)
 	if err != nil {
 		t.Log(err)
 	}
 	t.Log(txInfo)
 
-	txAddress = ercTransaction()
+	txAddress = ercTransaction(t)
 	txInfo, err = svc.Transaction(ctx, txAddress)
 	if err != nil {
 		t.Log(err)
@@ -115,7 +123,7 @@ func Test_GetTransaction(t *testing.T) {
 }
 
 func Test_CreateTransactEth(t *testing.T) {
-	svc := getService()
+	svc := getService(t)
 	ctx := context.Background()
 	var gasTip int32 = 2
 	gasMaxFee, _ := svc.MaxFee(ctx, gasTip)
@@ -156,7 +164,7 @@ func Test_CreateTransactEth(t *testing.T) {
 }
 
 func Test_CreateTransactERC(t *testing.T) {
-	svc := getService()
+	svc := getService(t)
 	ctx := context.Background()
 	var gasTip int32 = 2
 	gasMaxFee, _ := svc.MaxFee(ctx, gasTip)
@@ -188,7 +196,7 @@ func Test_CreateTransactERC(t *testing.T) {
 }
 
 func Test_Block(t *testing.T) {
-	svc := getService()
+	svc := getService(t)
 	ctx := context.Background()
 	height, err := svc.CurrentBlockHeight(ctx)
 	if err != nil {
@@ -216,7 +224,7 @@ func TestEip1559(t *testing.T) {
 	if err != nil {
 		t.Log(err)
 	}
-	client := getClient()
+	client := getClient(t)
 	err = client.SendTransaction(context.Background(), tx)
 	if err != nil {
 		t.Log(err)
@@ -240,37 +248,243 @@ ganache-cli -m "much repair shock carbon improve miss forget sock include bullet
 */
 
 func Test_Deploy(t *testing.T) {
-	s := getService()
+	s := getService(t)
 
-	auth := getAuth(s.client)
+	auth := getAuth(t, s.client)
 	input := "1.0"
 	//address, tx, instance, err := store.DeployStore(auth, client, input)
 	address, tx, _, err := token.DeployToken(auth, s.client, "gavin", input)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
-	fmt.Println(address.Hex())   // 0x147B8eb97fD247D06C4006D269c90C1908Fb5D54
-	fmt.Println(tx.Hash().Hex()) // 0xdae8ba5444eefdc99f4d45cd0c4f24056cba6a02cefbf78066ef9f4188ff7dc0
+	t.Log(address.Hex())   // 0x147B8eb97fD247D06C4006D269c90C1908Fb5D54
+	t.Log(tx.Hash().Hex()) // 0xdae8ba5444eefdc99f4d45cd0c4f24056cba6a02cefbf78066ef9f4188ff7dc0
 
 }
 
-func getAuth(client *ethclient.Client) *bind.TransactOpts {
+func Test_CreateTransactionPrecision(t *testing.T) {
+	svc, backend := getSimulatedService(t)
+	ctx := context.Background()
+	auth := getSimulatedAuth(t, backend, owner1PrivateKey)
+	tokenAddress, _, _, err := token.DeployToken(auth, backend, "gavin", "GV")
+	assert.NoError(t, err)
+	backend.Commit()
+
+	request := CreateTransactionRequest{From: owner1Addr, To: owner2Addr, Amount: "0.000000000000000001", GasLimit: 21000, GasMaxFee: "0.000000002"}
+	tx, err := svc.CreateTransaction(ctx, request)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", tx.Value().String())
+	assert.Equal(t, "2000000000", tx.GasPrice().String())
+
+	request.Amount = "0.0000000000000000001"
+	_, err = svc.CreateTransaction(ctx, request)
+	assert.ErrorIs(t, err, ErrTooManyDecimals)
+
+	request.Amount = "1"
+	request.GasMaxFee = "0.0000000000000000001"
+	_, err = svc.CreateTransaction(ctx, request)
+	assert.ErrorIs(t, err, ErrTooManyDecimals)
+
+	request.TokenAddress = tokenAddress.Hex()
+	request.GasMaxFee = "0.000000002"
+	request.Amount = "1.0000000000000000001"
+	_, err = svc.CreateTransaction(ctx, request)
+	assert.ErrorIs(t, err, ErrTooManyDecimals)
+
+	balance, err := svc.BalanceERC20(ctx, tokenAddress.Hex(), owner1Addr)
+	assert.NoError(t, err)
+	assert.Equal(t, "100", balance.String())
+}
+
+// an ERC20 transfer calls the token contract without value; it used to be sent to the recipient with the amount as
+// ETH value.
+func Test_CreateTransactionERC20(t *testing.T) {
+	svc, backend := getSimulatedService(t)
+	ctx := context.Background()
+	auth := getSimulatedAuth(t, backend, owner1PrivateKey)
+	tokenAddress, _, _, err := token.DeployToken(auth, backend, "gavin", "GV")
+	assert.NoError(t, err)
+	backend.Commit()
+
+	tx, err := svc.CreateTransaction(ctx, CreateTransactionRequest{TokenAddress: tokenAddress.Hex(), From: owner1Addr,
+		To: owner2Addr, Amount: "2.5", GasMaxFee: "0.000000002", Nonce: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, tokenAddress, *tx.To())
+	assert.Equal(t, "0", tx.Value().String())
+	call := svc.decodeTokenCall(tx.Data())
+	assert.Equal(t, "transfer", call.Method)
+	assert.Equal(t, owner2Addr, call.To)
+	assert.Equal(t, "2500000000000000000", call.Value.String())
+
+	signedTx, err := svc.SignTransaction(ctx, tx, owner1PrivateKey)
+	assert.NoError(t, err)
+	assert.NoError(t, svc.Broadcast(ctx, signedTx))
+	backend.Commit()
+	balance, err := svc.BalanceERC20(ctx, tokenAddress.Hex(), owner2Addr)
+	assert.NoError(t, err)
+	assert.Equal(t, "2.5", balance.String())
+	balance, err = svc.BalanceETH(ctx, owner2Addr)
+	assert.NoError(t, err)
+	assert.Equal(t, "100", balance.String())
+}
+
+func Test_SetGasMultiplier(t *testing.T) {
+	svc, backend := getSimulatedService(t)
+	ctx := context.Background()
+	auth := getSimulatedAuth(t, backend, owner1PrivateKey)
+	tokenAddress, _, _, err := token.DeployToken(auth, backend, "gavin", "GV")
+	assert.NoError(t, err)
+	backend.Commit()
+
+	request := CreateTransactionRequest{TokenAddress: tokenAddress.Hex(), From: owner1Addr, To: owner2Addr,
+		Amount: "1", GasMaxFee: "0.000000002"}
+	tx, err := svc.CreateTransaction(ctx, request)
+	assert.NoError(t, err)
+	svc.SetGasMultiplier(2.4)
+	doubled, err := svc.CreateTransaction(ctx, request)
+	assert.NoError(t, err)
+	assert.InDelta(t, tx.Gas()*2, doubled.Gas(), 1)
+}
+
+func Test_Client(t *testing.T) {
+	client := ethclient.NewClient(nil)
+	assert.Same(t, client, NewService(client, 0, 1).Client())
+	backend := NewRPCBackend(nil)
+	assert.Same(t, backend.Client, NewService(backend, 0, 1).Client())
+	svc, _ := getSimulatedService(t)
+	assert.Nil(t, svc.Client())
+	assert.NotNil(t, svc.Backend())
+}
+
+func Test_SignerHash(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := getSimulatedService(t)
+	tx, err := svc.CreateTransaction(ctx, CreateTransactionRequest{From: owner1Addr, To: owner2Addr, Amount: "1",
+		GasLimit: 21000, GasMaxFee: "0.000000002"})
+	assert.NoError(t, err)
+	hash, err := svc.SignerHash(ctx, tx)
+	assert.NoError(t, err)
+	from, err := svc.SignerHashFrom(ctx, tx, owner1Addr)
+	assert.NoError(t, err)
+	assert.Equal(t, hash, from)
+
+	key, _ := crypto.HexToECDSA(owner1PrivateKey[2:])
+	signature, err := crypto.Sign(hash, key)
+	assert.NoError(t, err)
+	signedTx, err := svc.WithSignature(ctx, tx, signature)
+	assert.NoError(t, err)
+	sender, err := types.Sender(types.NewLondonSigner(signedTx.ChainId()), signedTx)
+	assert.NoError(t, err)
+	assert.Equal(t, owner1Addr, sender.Hex())
+}
+
+func Test_SetConfirmations(t *testing.T) {
+	ctx := context.Background()
+	svc, backend := getSimulatedService(t)
+	request := CreateTransactionRequest{From: owner1Addr, To: owner2Addr, Amount: "1", GasLimit: 21000, GasMaxFee: "0.000000002"}
+	tx, err := svc.CreateTransaction(ctx, request)
+	assert.NoError(t, err)
+	signedTx, err := svc.SignTransaction(ctx, tx, owner1PrivateKey)
+	assert.NoError(t, err)
+	assert.NoError(t, svc.Broadcast(ctx, signedTx))
+	backend.Commit()
+	backend.Commit()
+
+	txInfo, err := svc.Transaction(ctx, signedTx.Hash().Hex())
+	assert.NoError(t, err)
+	assert.Equal(t, TransactionSateSuccess, txInfo.State)
+
+	svc.SetConfirmations(5)
+	txInfo, err = svc.Transaction(ctx, signedTx.Hash().Hex())
+	assert.NoError(t, err)
+	assert.Equal(t, TransactionSatePending, txInfo.State)
+}
+
+func Test_SetInstrumentation(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := getSimulatedService(t)
+	measurements := metrics.New()
+	svc.SetInstrumentation(measurements.Chain("eth"))
+
+	_, err := svc.SuggestGasPrice(ctx)
+	assert.NoError(t, err)
+	request := CreateTransactionRequest{From: owner1Addr, To: owner2Addr, Amount: "1", GasLimit: 21000, GasMaxFee: "0.000000002"}
+	tx, err := svc.CreateTransaction(ctx, request)
+	assert.NoError(t, err)
+	signedTx, err := svc.SignTransaction(ctx, tx, owner1PrivateKey)
+	assert.NoError(t, err)
+	assert.NoError(t, svc.Broadcast(ctx, signedTx))
+	nonce, err := svc.Nonce(ctx, owner1Addr)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), nonce)
+
+	recorder := httptest.NewRecorder()
+	measurements.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := recorder.Body.String()
+	assert.Contains(t, body, `wallet_broadcasts_total{chain="eth",outcome="accepted"} 1`)
+	assert.Contains(t, body, `wallet_nonce_gap{chain="eth"} 1`)
+	assert.Contains(t, body, `wallet_nonce_gap_senders{chain="eth"} 1`)
+	assert.Contains(t, body, `wallet_fee_level{chain="eth",kind="max_fee"} 2e+09`)
+	assert.Contains(t, body, `wallet_fee_level{chain="eth",kind="gas_price"}`)
+
+	_, backend := getSimulatedService(t)
+	failing := NewService(&nonceErrorBackend{backend}, 0, 1.2)
+	failing.SetInstrumentation(measurements.Chain("eth"))
+	nonce, err = failing.Nonce(ctx, owner1Addr)
+	assert.NoError(t, err, "the nonce gap is best effort")
+	assert.Equal(t, uint64(0), nonce)
+}
+
+// nonceErrorBackend fails every read of a mined nonce.
+type nonceErrorBackend struct {
+	*SimulatedBackend
+}
+
+func (b *nonceErrorBackend) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	return 0, errors.New("connection refused")
+}
+
+func Test_SetLogger(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := getSimulatedService(t)
+	var buf bytes.Buffer
+	logger, _ := logging.New(&buf, "logfmt", "debug")
+	svc.SetLogger(logger)
+
+	request := CreateTransactionRequest{From: owner1Addr, To: owner2Addr, Amount: "1", GasLimit: 21000, GasMaxFee: "0.000000002"}
+	tx, err := svc.CreateTransaction(ctx, request)
+	assert.NoError(t, err)
+	signedTx, err := svc.SignTransaction(ctx, tx, owner1PrivateKey)
+	assert.NoError(t, err)
+	assert.NoError(t, svc.Broadcast(ctx, signedTx))
+	assert.Error(t, svc.Broadcast(ctx, signedTx))
+
+	logs := buf.String()
+	assert.Contains(t, logs, `msg="Created transaction" chain=eth from=`+owner1Addr+` to=`+owner2Addr)
+	assert.Contains(t, logs, `msg="Signed transaction"`)
+	assert.Contains(t, logs, `chain=eth tx=`+signedTx.Hash().Hex()+` address=`+owner1Addr)
+	assert.Contains(t, logs, `lvl=info msg="Broadcast transaction" chain=eth tx=`+signedTx.Hash().Hex()+` nonce=0`)
+	assert.Contains(t, logs, `lvl=eror msg="Broadcast failed"`)
+	assert.NotContains(t, logs, owner1PrivateKey[2:])
+}
+
+func getAuth(t *testing.T, client Backend) *bind.TransactOpts {
 	privateKey, err := crypto.HexToECDSA("f1b3f8e0d52caec13491368449ab8d90f3d222a3e485aa7f02591bbceb5efba5")
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
 	publicKey := privateKey.Public()
 	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
 	if !ok {
-		log.Fatal("cannot assert type: publicKey is not of type *ecdsa.PublicKey")
+		t.Fatal("cannot assert type: publicKey is not of type *ecdsa.PublicKey")
 	}
 
 	fromAddress := crypto.PubkeyToAddress(*publicKeyECDSA)
 	nonce, err := client.PendingNonceAt(context.Background(), fromAddress)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
 	auth := bind.NewKeyedTransactor(privateKey)
@@ -282,42 +496,71 @@ func getAuth(client *ethclient.Client) *bind.TransactOpts {
 	return auth
 }
 
-func getService() *Service {
-	return NewService(getClient(), 0, 12)
+func getService(t *testing.T) *Service {
+	return NewService(getClient(t), 0, 12)
+}
+
+// getSimulatedService returns a service over an in-memory chain where owner1 and owner2 hold 100 ETH each.
+func getSimulatedService(t *testing.T) (*Service, *SimulatedBackend) {
+	balance, _ := new(big.Int).SetString("100000000000000000000", 10)
+	genesisAlloc := core.GenesisAlloc{
+		common.HexToAddress(owner1Addr): {Balance: balance},
+		common.HexToAddress(owner2Addr): {Balance: balance},
+	}
+
+	backend := NewSimulatedBackend(backends.NewSimulatedBackend(genesisAlloc, 8000000))
+	t.Cleanup(func() {
+		backend.Close()
+	})
+	return NewService(backend, 0, 1.2), backend
+}
+
+func getSimulatedAuth(t *testing.T, backend *SimulatedBackend, privateKey string) *bind.TransactOpts {
+	privateKeyECDSA, err := ParsePrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chainID, _ := backend.NetworkID(context.Background())
+	auth, err := bind.NewKeyedTransactorWithChainID(privateKeyECDSA, chainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auth
 }
 
-func getClient() *ethclient.Client {
+func getClient(t *testing.T) *ethclient.Client {
 	client, err := ethclient.Dial("http://localhost:8545")
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 	return client
 }
-func ethTransaction() string {
-	client := getClient()
+func ethTransaction(t *testing.T) string {
+	client := getClient(t)
 
 	privateKey, err := crypto.HexToECDSA(owner1PrivateKey[2:])
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
 	publicKey := privateKey.Public()
 	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
 	if !ok {
-		log.Fatal("cannot assert type: publicKey is not of type *ecdsa.PublicKey")
+		t.Fatal("cannot assert type: publicKey is not of type *ecdsa.PublicKey")
 	}
 
 	fromAddress := crypto.PubkeyToAddress(*publicKeyECDSA)
 	nonce, err := client.PendingNonceAt(context.Background(), fromAddress)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
 	value := big.NewInt(1000000000000000000) // in wei (1 eth)
 	gasLimit := uint64(21000)                // in units
 	gasPrice, err := client.SuggestGasPrice(context.Background())
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
 	toAddress := common.HexToAddress(owner2Addr)
@@ -326,41 +569,41 @@ func ethTransaction() string {
 
 	chainID, err := client.NetworkID(context.Background())
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
 	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), privateKey)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
 	err = client.SendTransaction(context.Background(), signedTx)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
-	fmt.Printf("tx sent: %s", signedTx.Hash().Hex())
+	t.Logf("tx sent: %s", signedTx.Hash().Hex())
 	return signedTx.Hash().Hex()
 }
 
-func ercTransaction() string {
-	client := getClient()
+func ercTransaction(t *testing.T) string {
+	client := getClient(t)
 	instance, err := token.NewToken(common.HexToAddress(tokenAddr), client)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 	toAddress := common.HexToAddress(owner2Addr)
-	auth := getAuth(client)
+	auth := getAuth(t, client)
 	tx, err := instance.Transfer(auth, toAddress, big.NewInt(1))
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
 
-	fmt.Printf("tx sent: %s", tx.Hash().Hex()) // tx sent: 0x8d490e535678e9a24360e955d75b27ad307bdfb97a1dca51d0f3035dcee3e870
+	t.Logf("tx sent: %s", tx.Hash().Hex()) // tx sent: 0x8d490e535678e9a24360e955d75b27ad307bdfb97a1dca51d0f3035dcee3e870
 	bal, err := instance.BalanceOf(&bind.CallOpts{}, toAddress)
 	if err != nil {
-		log.Fatal(err)
+		t.Fatal(err)
 	}
-	fmt.Printf("wei: %s\n", bal)
+	t.Logf("wei: %s", bal)
 	return tx.Hash().String()
 }
@@ -0,0 +1,240 @@
+package eth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"math/big"
+	"strings"
+)
+
+var (
+	errorSelector = methodSelector("Error(string)")
+	panicSelector = methodSelector("Panic(uint256)")
+
+	// panicReasons are the codes solidity panics with, see
+	// https://docs.soliditylang.org/en/latest/control-structures.html#panic-via-assert-and-error-via-require
+	panicReasons = map[uint64]string{
+		0x00: "generic compiler panic",
+		0x01: "assertion failed",
+		0x11: "arithmetic underflow or overflow",
+		0x12: "division or modulo by zero",
+		0x21: "invalid enum value",
+		0x22: "invalid storage byte array encoding",
+		0x31: "pop on empty array",
+		0x32: "array index out of bounds",
+		0x41: "out of memory",
+		0x51: "call to uninitialized function",
+	}
+
+	// callFailures are the errors a node returns when the transaction itself can not run, as opposed to the node
+	// being unreachable.
+	callFailures = []error{
+		core.ErrInsufficientFunds,
+		core.ErrInsufficientFundsForTransfer,
+		core.ErrIntrinsicGas,
+		core.ErrGasLimitReached,
+		core.ErrFeeCapTooLow,
+		core.ErrNonceTooHigh,
+		core.ErrNonceTooLow,
+		vm.ErrOutOfGas,
+		vm.ErrExecutionReverted,
+		vm.ErrInvalidJump,
+		vm.ErrWriteProtection,
+		vm.ErrDepth,
+	}
+)
+
+// AddErrorABI registers the custom errors of a contract ABI, so that reverts with them can be decoded.
+func (svc *Service) AddErrorABI(abiJSON string) error {
+	parsed, err := abi.JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		return err
+	}
+
+	svc.errorABIs = append(svc.errorABIs, parsed)
+	return nil
+}
+
+// SimulateTransaction runs tx with eth_call on the pending state without broadcasting it. from is the sender, it
+// may be empty when tx is signed. A transaction that would fail is not an error: the result has Success false and
+// the decoded revert reason.
+func (svc *Service) SimulateTransaction(ctx context.Context, from string, tx *types.Transaction) (*SimulationResult, error) {
+	sender := common.HexToAddress(from)
+	if len(from) < 1 {
+		chainID, err := svc.client.NetworkID(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		sender, err = types.Sender(types.LatestSignerForChainID(chainID), tx)
+		if err != nil {
+			return nil, ErrInvalidSignature
+		}
+	} else if !common.IsHexAddress(from) {
+		return nil, ErrInvalidInput
+	}
+
+	msg := callMessage(sender, tx)
+	data, err := svc.client.PendingCallContract(ctx, msg)
+	if err == nil {
+		return &SimulationResult{Success: true, ReturnData: data}, nil
+	}
+
+	revertData, ok := revertData(err)
+	if !ok {
+		return nil, err
+	}
+
+	return &SimulationResult{RevertData: revertData, RevertReason: svc.revertReason(err, revertData)}, nil
+}
+
+// failedTransactionReason replays a failed transaction on the state of its parent block to recover the revert
+// reason receipts do not carry. Transactions mined before it in the same block are not replayed, so the reason is
+// empty when the replay does not fail.
+func (svc *Service) failedTransactionReason(ctx context.Context, tx *types.Transaction, receipt *types.Receipt, signer types.Signer) string {
+	sender, err := types.Sender(signer, tx)
+	if err != nil || receipt.BlockNumber.Sign() < 1 {
+		return ""
+	}
+
+	parent := new(big.Int).Sub(receipt.BlockNumber, big.NewInt(1))
+	_, err = svc.client.CallContract(ctx, callMessage(sender, tx), parent)
+	if err == nil {
+		return ""
+	}
+
+	revertData, ok := revertData(err)
+	if !ok {
+		return ""
+	}
+	return svc.revertReason(err, revertData)
+}
+
+func callMessage(from common.Address, tx *types.Transaction) ethereum.CallMsg {
+	msg := ethereum.CallMsg{
+		From:       from,
+		To:         tx.To(),
+		Gas:        tx.Gas(),
+		Value:      tx.Value(),
+		Data:       tx.Data(),
+		AccessList: tx.AccessList(),
+	}
+
+	if tx.Type() == types.DynamicFeeTxType {
+		msg.GasFeeCap, msg.GasTipCap = tx.GasFeeCap(), tx.GasTipCap()
+	} else {
+		msg.GasPrice = tx.GasPrice()
+	}
+	return msg
+}
+
+// revertData returns the revert data of a failed call and whether err is a failure of the call rather than of
+// the connection or the node. Revert data is empty for failures such as running out of gas.
+func revertData(err error) ([]byte, bool) {
+	if !callFailed(err) {
+		return nil, false
+	}
+
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if data, ok := dataErr.ErrorData().(string); ok {
+			decoded, decodeErr := hexutil.Decode(data)
+			if decodeErr == nil {
+				return decoded, true
+			}
+		}
+	}
+	return nil, true
+}
+
+// callFailed reports whether err is a revert, with the code of one over JSON-RPC, or one of callFailures, which
+// nodes answer with as the message of a JSON-RPC error.
+func callFailed(err error) bool {
+	var rpcErr rpc.Error
+	isRPCErr := errors.As(err, &rpcErr)
+	if isRPCErr && rpcErr.ErrorCode() == revertErrorCode {
+		return true
+	}
+
+	for _, failure := range callFailures {
+		if errors.Is(err, failure) || isRPCErr && strings.Contains(rpcErr.Error(), failure.Error()) {
+			return true
+		}
+	}
+	return false
+}
+
+// revertReason decodes data as Error(string), Panic(uint256) or a registered custom error, falling back to the
+// error message of the call.
+func (svc *Service) revertReason(err error, data []byte) string {
+	if len(data) < 4 {
+		return err.Error()
+	}
+
+	if reason, ok := decodeRevert(data, svc.errorABIs); ok {
+		return reason
+	}
+	return fmt.Sprintf("%s: %s", vm.ErrExecutionReverted, hexutil.Encode(data))
+}
+
+func decodeRevert(data []byte, errorABIs []abi.ABI) (string, bool) {
+	switch {
+	case bytes.Equal(data[:4], errorSelector):
+		reason, err := abi.UnpackRevert(data)
+		return reason, err == nil
+	case bytes.Equal(data[:4], panicSelector):
+		if len(data) != 36 {
+			return "", false
+		}
+
+		code := new(big.Int).SetBytes(data[4:])
+		reason, ok := panicReasons[code.Uint64()]
+		if !ok || !code.IsUint64() {
+			reason = "unknown panic"
+		}
+		return fmt.Sprintf("panic: %s (0x%x)", reason, code), true
+	}
+
+	for _, errorABI := range errorABIs {
+		for _, abiError := range errorABI.Errors {
+			if !bytes.Equal(data[:4], abiError.ID[:4]) {
+				continue
+			}
+
+			values, err := abiError.Unpack(data)
+			if err != nil {
+				continue
+			}
+			return formatError(abiError, values.([]interface{})), true
+		}
+	}
+	return "", false
+}
+
+func formatError(abiError abi.Error, values []interface{}) string {
+	args := make([]string, len(values))
+	for i, value := range values {
+		if name := abiError.Inputs[i].Name; len(name) > 0 {
+			args[i] = fmt.Sprintf("%s=%v", name, value)
+		} else {
+			args[i] = fmt.Sprint(value)
+		}
+	}
+	return fmt.Sprintf("%s(%s)", abiError.Name, strings.Join(args, ", "))
+}
+
+// methodSelector is the first four bytes of the keccak256 hash of signature.
+func methodSelector(signature string) []byte {
+	return crypto.Keccak256([]byte(signature))[:4]
+}
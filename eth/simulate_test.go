@@ -0,0 +1,113 @@
+package eth
+
+import (
+	"context"
+	"demo/token"
+	"errors"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+const insufficientBalanceABI = `[{"type":"error","name":"InsufficientBalance","inputs":[{"name":"available","type":"uint256"},{"name":"required","type":"uint256"}]}]`
+
+func Test_SimulateTransaction(t *testing.T) {
+	svc, backend := getSimulatedService(t)
+	ctx := context.Background()
+	auth := getSimulatedAuth(t, backend, owner1PrivateKey)
+	tokenAddress, _, _, err := token.DeployToken(auth, backend, "gavin", "GV")
+	assert.NoError(t, err)
+	backend.Commit()
+
+	gasPrice, _ := svc.SuggestGasPrice(ctx)
+	request := CreateTransactionRequest{
+		TokenAddress: tokenAddress.Hex(),
+		From:         owner1Addr,
+		To:           owner2Addr,
+		Amount:       "1",
+		GasMaxFee:    gasPrice.String(),
+	}
+	tx, err := svc.CreateTransaction(ctx, request)
+	assert.NoError(t, err)
+	result, err := svc.SimulateTransaction(ctx, owner1Addr, tx)
+	assert.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, math.PaddedBigBytes(big.NewInt(1), 32), result.ReturnData)
+
+	// owner2 holds no tokens, the transfer would burn gas for nothing
+	request.From, request.To = owner2Addr, owner1Addr
+	request.GasLimit, request.DisableEstimateGas = 100000, true
+	tx, err = svc.CreateTransaction(ctx, request)
+	assert.NoError(t, err)
+	signedTx, err := svc.SignTransaction(ctx, tx, owner2PrivateKey)
+	assert.NoError(t, err)
+	result, err = svc.SimulateTransaction(ctx, "", signedTx)
+	assert.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Equal(t, "ERC20: transfer amount exceeds balance", result.RevertReason)
+	assert.Equal(t, errorSelector, result.RevertData[:4])
+
+	// mined anyway, the reason is recovered by replaying it
+	assert.NoError(t, svc.Broadcast(ctx, signedTx))
+	backend.Commit()
+	backend.Commit()
+	txInfo, err := svc.Transaction(ctx, signedTx.Hash().Hex())
+	assert.NoError(t, err)
+	assert.Equal(t, TransactionSateFail, txInfo.State)
+	assert.Equal(t, "ERC20: transfer amount exceeds balance", txInfo.RevertReason)
+
+	// failures without revert data keep the node's message
+	request.GasLimit = 22000
+	tx, err = svc.CreateTransaction(ctx, request)
+	assert.NoError(t, err)
+	result, err = svc.SimulateTransaction(ctx, owner2Addr, tx)
+	assert.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Empty(t, result.RevertData)
+	assert.Contains(t, result.RevertReason, "out of gas")
+
+	_, err = svc.SimulateTransaction(ctx, "", tx)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func Test_DecodeRevert(t *testing.T) {
+	svc, _ := getSimulatedService(t)
+	callErr := errors.New("execution reverted")
+
+	panicData := append(methodSelector("Panic(uint256)"), math.PaddedBigBytes(big.NewInt(0x11), 32)...)
+	assert.Equal(t, "panic: arithmetic underflow or overflow (0x11)", svc.revertReason(callErr, panicData))
+	panicData = append(methodSelector("Panic(uint256)"), math.PaddedBigBytes(big.NewInt(0x99), 32)...)
+	assert.Equal(t, "panic: unknown panic (0x99)", svc.revertReason(callErr, panicData))
+
+	parsed, _ := abi.JSON(strings.NewReader(insufficientBalanceABI))
+	customError := parsed.Errors["InsufficientBalance"]
+	customData, err := customError.Inputs.Pack(big.NewInt(1), big.NewInt(2))
+	assert.NoError(t, err)
+	customData = append(customError.ID[:4], customData...)
+	assert.Equal(t, "execution reverted: "+hexutil.Encode(customData), svc.revertReason(callErr, customData))
+
+	assert.NoError(t, svc.AddErrorABI(insufficientBalanceABI))
+	assert.Equal(t, "InsufficientBalance(available=1, required=2)", svc.revertReason(callErr, customData))
+	assert.Equal(t, "execution reverted", svc.revertReason(callErr, nil))
+
+	_, ok := revertData(errors.New("dial tcp: connection refused"))
+	assert.False(t, ok)
+	data, ok := revertData(&revertError{error: callErr, data: hexutil.Encode(customData)})
+	assert.True(t, ok)
+	assert.Equal(t, customData, data)
+
+	// other JSON-RPC errors are errors of the node, but for the failures it reports by message
+	_, ok = revertData(codeError{code: -32601, message: "the method eth_call does not exist/is not available"})
+	assert.False(t, ok)
+	_, ok = revertData(codeError{code: -32005, message: "rate limit exceeded"})
+	assert.False(t, ok)
+	_, ok = revertData(codeError{code: -32000, message: "gas required exceeds allowance (30000000): out of gas"})
+	assert.True(t, ok)
+	data, ok = revertData(codeError{code: -32000, message: "execution reverted: no access"})
+	assert.True(t, ok)
+	assert.Empty(t, data)
+}
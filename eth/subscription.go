@@ -0,0 +1,427 @@
+package eth
+
+import (
+	"context"
+	"demo/logging"
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// catchUpBlocks is how many blocks are read at once when back-filling after a reconnect.
+const catchUpBlocks = uint64(100)
+
+// logWait is how long a new block whose bloom may hold a Transfer waits for its subscribed logs before they are
+// queried with eth_getLogs instead.
+const logWait = time.Second
+
+// logQuiet is how long the logs of a block are read for after the last one arrived, when no log of a later block
+// has.
+const logQuiet = 100 * time.Millisecond
+
+var errSubscriptionClosed = errors.New("subscription closed")
+
+// Dialer connects to a node. Subscriptions need a WebSocket or IPC endpoint. Every call must return a new client,
+// the manager owns it and closes it, when it has a Close method, once the connection is given up.
+type Dialer func(ctx context.Context) (Backend, error)
+
+// SubscriptionManager follows the chain over a WebSocket connection and turns every new block into one ordered
+// stream: the block's head event, then its Transfer events in log order. The Transfer logs are subscribed to and
+// held back until their block is emitted. A new block whose bloom may hold a Transfer waits up to logWait for all
+// of its logs and otherwise reads them with eth_getLogs, so logs can neither overtake their block nor be dropped. The blocks
+// missed before the subscriptions start, and every block while polling, are read with eth_getLogs as well. When
+// the connection drops it reconnects with exponential backoff, polls the fallback node in the meantime and
+// back-fills every block it missed. Blocks are emitted once; a reorg is not rewound.
+type SubscriptionManager struct {
+	dial         Dialer
+	fallback     Dialer
+	tokens       []string
+	addresses    []string
+	checkpoint   CheckpointStore
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	pollInterval time.Duration
+	events       chan *Event
+	logger       log.Logger
+
+	next    uint64
+	started bool
+}
+
+// NewSubscriptionManager follows the Transfer events of tokenAddresses from or to addresses, all of them when
+// addresses is empty. checkpoint records the last emitted block.
+func NewSubscriptionManager(dial Dialer, tokenAddresses, addresses []string, checkpoint CheckpointStore) *SubscriptionManager {
+	return &SubscriptionManager{
+		dial:         dial,
+		tokens:       tokenAddresses,
+		addresses:    addresses,
+		checkpoint:   checkpoint,
+		minBackoff:   time.Second,
+		maxBackoff:   time.Minute,
+		pollInterval: 3 * time.Second,
+		events:       make(chan *Event, 256),
+		logger:       logging.Discard(),
+	}
+}
+
+// SetLogger makes the manager log its reconnections and the blocks it emits to logger, with chain=eth on every
+// entry.
+func (m *SubscriptionManager) SetLogger(logger log.Logger) {
+	m.logger = logging.With(logger, "chain", "eth")
+}
+
+// SetFallback sets the node that is polled while dial can not subscribe, usually an HTTP endpoint.
+func (m *SubscriptionManager) SetFallback(fallback Dialer) {
+	m.fallback = fallback
+}
+
+// Events is closed when Run returns.
+func (m *SubscriptionManager) Events() <-chan *Event {
+	return m.events
+}
+
+// Run follows the chain until ctx is done. It resumes after the checkpoint, or starts at the current head when
+// there is none.
+func (m *SubscriptionManager) Run(ctx context.Context) error {
+	defer close(m.events)
+
+	checkpoint, ok, err := m.checkpoint.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		m.next = checkpoint + 1
+		m.started = true
+	}
+
+	backoff := m.minBackoff
+	for {
+		subscribed, err := m.follow(ctx, m.dial, time.Time{})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if subscribed {
+			backoff = m.minBackoff
+		}
+
+		m.logger.Warn("Subscription lost, reconnecting", "block", m.next, "backoff", backoff, "err", err)
+		retryAt := time.Now().Add(backoff)
+		if m.fallback != nil {
+			if _, err = m.follow(ctx, m.fallback, retryAt); err != nil && ctx.Err() == nil {
+				m.logger.Warn("Polling the fallback node failed", "block", m.next, "err", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Until(retryAt)):
+		}
+
+		backoff *= 2
+		if backoff > m.maxBackoff {
+			backoff = m.maxBackoff
+		}
+	}
+}
+
+// follow connects with dial and emits blocks until the connection fails. With a zero pollUntil it subscribes to
+// new heads, falling back to polling only when the node has no subscriptions; otherwise it polls until pollUntil.
+func (m *SubscriptionManager) follow(ctx context.Context, dial Dialer, pollUntil time.Time) (bool, error) {
+	client, err := dial(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if closer, ok := client.(interface{ Close() }); ok {
+		defer closer.Close()
+	}
+
+	svc := NewService(client, 0, 1)
+	svc.logger = m.logger
+	indexer := NewTransferIndexer(svc, m.tokens, m.addresses, nil)
+	if !pollUntil.IsZero() {
+		return false, m.poll(ctx, client, indexer, pollUntil)
+	}
+
+	headers := make(chan *types.Header, 16)
+	sub, err := client.SubscribeNewHead(ctx, headers)
+	if errors.Is(err, rpc.ErrNotificationsUnsupported) {
+		return false, m.poll(ctx, client, indexer, time.Time{})
+	}
+
+	if err != nil {
+		return false, err
+	}
+	defer sub.Unsubscribe()
+
+	var live *liveTransfers
+	logErrs := make(<-chan error)
+	if len(m.tokens) > 0 {
+		live = &liveTransfers{query: indexer.transferQuery(), logs: make(chan types.Log, 256), blocks: map[uint64][]types.Log{}}
+		logSub, err := client.SubscribeFilterLogs(ctx, live.query, live.logs)
+		if err != nil {
+			return false, err
+		}
+		defer logSub.Unsubscribe()
+		logErrs = logSub.Err()
+	}
+
+	// blocks mined while disconnected have no notification of their own
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return true, err
+	}
+
+	if err = m.catchUp(ctx, client, indexer, nil, head); err != nil {
+		return true, err
+	}
+
+	if live != nil {
+		live.from = head + 1
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+		case err := <-sub.Err():
+			if err == nil {
+				err = errSubscriptionClosed
+			}
+			return true, err
+		case err := <-logErrs:
+			if err == nil {
+				err = errSubscriptionClosed
+			}
+			return true, err
+		case header := <-headers:
+			if err := m.catchUp(ctx, client, indexer, live, header.Number.Uint64()); err != nil {
+				return true, err
+			}
+		}
+	}
+}
+
+// poll emits blocks every pollInterval until pollUntil, or forever when it is zero.
+func (m *SubscriptionManager) poll(ctx context.Context, client Backend, indexer *TransferIndexer, pollUntil time.Time) error {
+	for {
+		head, err := client.BlockNumber(ctx)
+		if err != nil {
+			return err
+		}
+
+		if err = m.catchUp(ctx, client, indexer, nil, head); err != nil {
+			return err
+		}
+
+		wait := m.pollInterval
+		if !pollUntil.IsZero() {
+			if remaining := time.Until(pollUntil); remaining < wait {
+				wait = remaining
+			}
+
+			if wait <= 0 {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// catchUp emits every block up to head that has not been emitted yet. The transfers of the blocks live holds the
+// logs of are taken from it, the others are read with eth_getLogs.
+func (m *SubscriptionManager) catchUp(ctx context.Context, client Backend, indexer *TransferIndexer, live *liveTransfers, head uint64) error {
+	if !m.started {
+		m.next = head
+		m.started = true
+	}
+
+	for m.next <= head {
+		end := m.next + catchUpBlocks - 1
+		if end > head {
+			end = head
+		}
+
+		subscribed := live != nil && m.next >= live.from
+		transfers := map[uint64][]*TransactionInfo{}
+		if len(m.tokens) > 0 && !subscribed {
+			if err := m.filterTransfers(ctx, indexer, m.next, end, transfers); err != nil {
+				return err
+			}
+		}
+
+		for number := m.next; number <= end; number++ {
+			header, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+			if err != nil {
+				return err
+			}
+
+			if subscribed {
+				if transfers[number], err = m.liveTransfers(ctx, indexer, live, header); err != nil {
+					return err
+				}
+			}
+
+			blockHash := header.Hash().Hex()
+			blockTime := time.Unix(int64(header.Time), 0)
+			if err = m.emit(ctx, &Event{Type: EventTypeNewHead, BlockNumber: number, BlockHash: blockHash, Time: blockTime}); err != nil {
+				return err
+			}
+
+			for _, transfer := range transfers[number] {
+				event := Event{Type: EventTypeTransfer, BlockNumber: number, BlockHash: blockHash, Time: blockTime, Transfer: transfer}
+				if err = m.emit(ctx, &event); err != nil {
+					return err
+				}
+			}
+
+			if err = m.checkpoint.Save(ctx, number); err != nil {
+				return err
+			}
+			m.logger.Debug("Emitted block", "block", number, "hash", blockHash, "transfers", len(transfers[number]))
+			m.next = number + 1
+		}
+	}
+	return nil
+}
+
+// filterTransfers reads the transfers of [start, end] with eth_getLogs into transfers, by block.
+func (m *SubscriptionManager) filterTransfers(ctx context.Context, indexer *TransferIndexer, start, end uint64, transfers map[uint64][]*TransactionInfo) error {
+	return indexer.backfill(ctx, NewMemoryCheckpointStore(), start, end, func(records []*TransactionInfo) error {
+		for _, record := range records {
+			transfers[record.BlockNumber] = append(transfers[record.BlockNumber], record)
+		}
+		return nil
+	})
+}
+
+// liveTransfers returns the transfers of header from the subscribed logs, or from eth_getLogs when its bloom may
+// hold a Transfer and its logs were not complete within logWait.
+func (m *SubscriptionManager) liveTransfers(ctx context.Context, indexer *TransferIndexer, live *liveTransfers, header *types.Header) ([]*TransactionInfo, error) {
+	number := header.Number.Uint64()
+	logs, ok, err := live.take(ctx, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		m.logger.Debug("Transfer logs not received, querying them", "block", number)
+		transfers := map[uint64][]*TransactionInfo{}
+		if err = m.filterTransfers(ctx, indexer, number, number, transfers); err != nil {
+			return nil, err
+		}
+		return transfers[number], nil
+	}
+
+	parsed, err := indexer.parseTransfers(logs)
+	if err != nil {
+		return nil, err
+	}
+	return indexer.transactionInfos(ctx, parsed)
+}
+
+// liveTransfers holds the subscribed Transfer logs of the blocks from on until their block is emitted.
+type liveTransfers struct {
+	query  ethereum.FilterQuery
+	logs   chan types.Log
+	from   uint64
+	blocks map[uint64][]types.Log
+	latest uint64
+}
+
+// take returns the logs of header in log order. A block's logs arrive one by one, so when its bloom may hold a
+// Transfer take reads them until a log of a later block arrives or none has for logQuiet. ok is false when they
+// are not complete within logWait. The logs of earlier blocks are dropped, they were emitted already.
+func (l *liveTransfers) take(ctx context.Context, header *types.Header) (logs []types.Log, ok bool, err error) {
+	number := header.Number.Uint64()
+	l.drain()
+	if l.latest <= number && l.mayHold(header.Bloom) {
+		timer := time.NewTimer(logWait)
+		defer timer.Stop()
+		for complete := false; !complete && l.latest <= number; {
+			quiet := time.NewTimer(logQuiet)
+			select {
+			case <-ctx.Done():
+				quiet.Stop()
+				return nil, false, ctx.Err()
+			case <-timer.C:
+				quiet.Stop()
+				return nil, false, nil
+			case <-quiet.C:
+				complete = len(l.blocks[number]) > 0
+			case log := <-l.logs:
+				quiet.Stop()
+				l.add(log)
+			}
+		}
+	}
+
+	logs = l.blocks[number]
+	for block := range l.blocks {
+		if block <= number {
+			delete(l.blocks, block)
+		}
+	}
+
+	sort.Slice(logs, func(i, j int) bool {
+		return logs[i].Index < logs[j].Index
+	})
+	return logs, true, nil
+}
+
+func (l *liveTransfers) drain() {
+	for {
+		select {
+		case log := <-l.logs:
+			l.add(log)
+		default:
+			return
+		}
+	}
+}
+
+// add keeps log unless it was removed by a reorg, which is not rewound.
+func (l *liveTransfers) add(log types.Log) {
+	if !log.Removed {
+		l.blocks[log.BlockNumber] = append(l.blocks[log.BlockNumber], log)
+		if log.BlockNumber > l.latest {
+			l.latest = log.BlockNumber
+		}
+	}
+}
+
+// mayHold reports whether bloom may hold a log of the query.
+func (l *liveTransfers) mayHold(bloom types.Bloom) bool {
+	if !types.BloomLookup(bloom, l.query.Topics[0][0]) {
+		return false
+	}
+
+	for _, address := range l.query.Addresses {
+		if types.BloomLookup(bloom, address) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *SubscriptionManager) emit(ctx context.Context, event *Event) error {
+	select {
+	case m.events <- event:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
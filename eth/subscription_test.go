@@ -0,0 +1,259 @@
+package eth
+
+import (
+	"context"
+	"demo/token"
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// droppableBackend lets a test cut the head subscriptions and refuse new connections.
+type droppableBackend struct {
+	*SimulatedBackend
+	mu         sync.Mutex
+	subs       []ethereum.Subscription
+	down       bool
+	dials      int
+	opened     int
+	closed     int
+	filterLogs int
+}
+
+// dialedBackend is a connection to a droppableBackend, closed by the manager that dialed it.
+type dialedBackend struct {
+	*droppableBackend
+}
+
+func (c *dialedBackend) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed++
+}
+
+func (b *droppableBackend) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	b.mu.Lock()
+	b.filterLogs++
+	b.mu.Unlock()
+	return b.SimulatedBackend.FilterLogs(ctx, query)
+}
+
+func (b *droppableBackend) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	sub, err := b.SimulatedBackend.SubscribeNewHead(ctx, ch)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs = append(b.subs, sub)
+	return sub, nil
+}
+
+func (b *droppableBackend) dial(ctx context.Context) (Backend, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.dials++
+	if b.down {
+		return nil, errors.New("connection refused")
+	}
+	b.opened++
+	return &dialedBackend{droppableBackend: b}, nil
+}
+
+func (b *droppableBackend) setDown(down bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.down = down
+	if down {
+		for _, sub := range b.subs {
+			sub.Unsubscribe()
+		}
+		b.subs = nil
+	}
+}
+
+func Test_SubscriptionManager(t *testing.T) {
+	_, simulated := getSimulatedService(t)
+	backend := &droppableBackend{SimulatedBackend: simulated}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	auth := getSimulatedAuth(t, simulated, owner1PrivateKey)
+	tokenAddress, _, instance, err := token.DeployToken(auth, simulated, "gavin", "GV")
+	assert.NoError(t, err)
+	simulated.Commit()
+
+	head, _ := backend.BlockNumber(ctx)
+	checkpoint := NewMemoryCheckpointStore()
+	_ = checkpoint.Save(ctx, head)
+	deposit := common.HexToAddress("0x35bb6eF95c72bf4804334BB9d6A3c77Bef18d81B")
+	manager := NewSubscriptionManager(backend.dial, []string{tokenAddress.Hex()}, []string{deposit.Hex()}, checkpoint)
+	manager.minBackoff = 10 * time.Millisecond
+	manager.maxBackoff = 50 * time.Millisecond
+	done := make(chan error)
+	go func() {
+		done <- manager.Run(ctx)
+	}()
+
+	_, err = instance.Transfer(auth, deposit, big.NewInt(1e18))
+	assert.NoError(t, err)
+	simulated.Commit()
+	assertEvent(t, manager, EventTypeNewHead, head+1)
+	event := assertEvent(t, manager, EventTypeTransfer, head+1)
+	assert.Equal(t, strings.ToUpper(deposit.Hex()), event.Transfer.To)
+	assert.Equal(t, "1", event.Transfer.Amount.String())
+	backend.mu.Lock()
+	assert.Zero(t, backend.filterLogs, "the transfers of a new block come from the log subscription")
+	backend.mu.Unlock()
+
+	// blocks mined while disconnected are back-filled in order after the reconnect
+	backend.setDown(true)
+	_, err = instance.Transfer(auth, deposit, big.NewInt(2e18))
+	assert.NoError(t, err)
+	simulated.Commit()
+	simulated.Commit()
+	time.Sleep(30 * time.Millisecond)
+	backend.setDown(false)
+
+	assertEvent(t, manager, EventTypeNewHead, head+2)
+	event = assertEvent(t, manager, EventTypeTransfer, head+2)
+	assert.Equal(t, "2", event.Transfer.Amount.String())
+	assertEvent(t, manager, EventTypeNewHead, head+3)
+
+	backend.mu.Lock()
+	assert.Greater(t, backend.dials, 1)
+	backend.mu.Unlock()
+	saved, _, _ := checkpoint.Load(ctx)
+	assert.Equal(t, head+3, saved)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	assert.Greater(t, backend.filterLogs, 0, "the blocks missed while disconnected are queried")
+	assert.Equal(t, backend.opened, backend.closed, "every dialed client is closed")
+}
+
+func Test_SubscriptionManagerFallback(t *testing.T) {
+	_, simulated := getSimulatedService(t)
+	backend := &droppableBackend{SimulatedBackend: simulated, down: true}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	manager := NewSubscriptionManager(backend.dial, nil, nil, NewMemoryCheckpointStore())
+	manager.minBackoff = 50 * time.Millisecond
+	manager.pollInterval = 10 * time.Millisecond
+	fallback := &droppableBackend{SimulatedBackend: simulated}
+	manager.SetFallback(fallback.dial)
+	var mu sync.Mutex
+	entries := map[string][]interface{}{}
+	logger := log.New()
+	logger.SetHandler(log.FuncHandler(func(r *log.Record) error {
+		mu.Lock()
+		defer mu.Unlock()
+		entries[r.Msg] = r.Ctx
+		return nil
+	}))
+	manager.SetLogger(logger)
+	go func() {
+		_ = manager.Run(ctx)
+	}()
+
+	head, _ := simulated.BlockNumber(ctx)
+	assertEvent(t, manager, EventTypeNewHead, head)
+	simulated.Commit()
+	assertEvent(t, manager, EventTypeNewHead, head+1)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []interface{}{"chain", "eth", "block", uint64(0), "backoff", 50 * time.Millisecond,
+		"err", errors.New("connection refused")}, entries["Subscription lost, reconnecting"])
+	assert.Equal(t, []interface{}{"chain", "eth", "block"}, entries["Emitted block"][:3])
+}
+
+func Test_LiveTransfersTake(t *testing.T) {
+	ctx := context.Background()
+	tokenAddress := common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3")
+	svc, _ := getSimulatedService(t)
+	query := NewTransferIndexer(svc, []string{tokenAddress.Hex()}, nil, nil).transferQuery()
+	live := &liveTransfers{query: query, logs: make(chan types.Log, 8), blocks: map[uint64][]types.Log{}}
+	transfer := types.Log{Address: tokenAddress, Topics: query.Topics[0], BlockNumber: 7}
+
+	live.logs <- types.Log{Address: tokenAddress, BlockNumber: 5}
+	live.logs <- types.Log{Address: tokenAddress, BlockNumber: 7, Index: 3}
+	live.logs <- types.Log{Address: tokenAddress, BlockNumber: 7, Index: 1}
+	live.logs <- types.Log{Address: tokenAddress, BlockNumber: 7, Index: 2, Removed: true}
+	logs, ok, err := live.take(ctx, &types.Header{Number: big.NewInt(7)})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []uint{1, 3}, []uint{logs[0].Index, logs[1].Index}, "in log order, without removed logs")
+	assert.Empty(t, live.blocks, "earlier blocks are dropped")
+
+	// a block whose bloom holds a Transfer of the token waits for its logs
+	bloom := types.CreateBloom(types.Receipts{{Logs: []*types.Log{&transfer}}})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		live.logs <- types.Log{Address: tokenAddress, BlockNumber: 8}
+	}()
+	logs, ok, err = live.take(ctx, &types.Header{Number: big.NewInt(8), Bloom: bloom})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Len(t, logs, 1)
+
+	// the logs of a block that arrive after its first one are not dropped
+	go func() {
+		live.logs <- types.Log{Address: tokenAddress, BlockNumber: 9, Index: 0}
+		time.Sleep(logQuiet / 2)
+		live.logs <- types.Log{Address: tokenAddress, BlockNumber: 9, Index: 1}
+	}()
+	logs, ok, err = live.take(ctx, &types.Header{Number: big.NewInt(9), Bloom: bloom})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Len(t, logs, 2)
+
+	// a log of a later block means the block's logs are complete
+	live.logs <- types.Log{Address: tokenAddress, BlockNumber: 10, Index: 0}
+	live.logs <- types.Log{Address: tokenAddress, BlockNumber: 11, Index: 0}
+	start := time.Now()
+	logs, ok, err = live.take(ctx, &types.Header{Number: big.NewInt(10), Bloom: bloom})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Len(t, logs, 1)
+	assert.Less(t, time.Since(start), logQuiet)
+
+	// logs still arriving when logWait is up are queried instead
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(logQuiet / 2):
+				live.logs <- types.Log{Address: tokenAddress, BlockNumber: 12}
+			}
+		}
+	}()
+	_, ok, err = live.take(ctx, &types.Header{Number: big.NewInt(12), Bloom: bloom})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.False(t, live.mayHold(types.Bloom{}))
+}
+
+func assertEvent(t *testing.T, manager *SubscriptionManager, eventType EventType, blockNumber uint64) *Event {
+	select {
+	case event := <-manager.Events():
+		assert.Equal(t, eventType, event.Type)
+		assert.Equal(t, blockNumber, event.BlockNumber)
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no event for block %d", blockNumber)
+		return nil
+	}
+}
@@ -0,0 +1,185 @@
+package eth
+
+import (
+	"context"
+	"demo/amount"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/shopspring/decimal"
+	"math/big"
+)
+
+const (
+	ethTransferGasLimit = uint64(21000)
+
+	SweepReasonInsufficientGas = "insufficient ETH for gas"
+	SweepReasonDust            = "balance does not cover the fee"
+	SweepReasonTokensPending   = "ETH is kept for the gas of the tokens left"
+)
+
+// PlanSweep derives the deposit addresses in [FromIndex, ToIndex] and builds signed transactions that move
+// their token balances and then all remaining ETH to the destination. The ETH of an address stays when one of its
+// tokens is skipped for lack of gas, to pay for that token once more ETH arrives. Nothing is broadcast until
+// ExecuteSweep.
+func (svc *Service) PlanSweep(ctx context.Context, request SweepRequest) (*SweepPlan, error) {
+	if request.FromIndex > request.ToIndex || !common.IsHexAddress(request.Destination) {
+		return nil, ErrInvalidInput
+	}
+
+	if len(request.GasMaxFee) < 1 {
+		gasPrice, err := svc.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, err
+		}
+		request.GasMaxFee = gasPrice.String()
+	}
+
+	plan := SweepPlan{Destination: request.Destination, GasMaxFee: request.GasMaxFee, Items: []*SweepItem{}, Skipped: []*SweepItem{}}
+	for index := request.FromIndex; ; index++ {
+		if err := svc.planSweepAddress(ctx, request, index, &plan); err != nil {
+			return nil, err
+		}
+
+		if index == request.ToIndex {
+			break
+		}
+	}
+
+	for _, item := range plan.Items {
+		plan.Fee = plan.Fee.Add(item.Fee)
+	}
+	return &plan, nil
+}
+
+// ExecuteSweep broadcasts the transactions of plan in order. A failed item stops the remaining items of the
+// same address, since their nonces can no longer be used; the outcome is recorded on each item.
+func (svc *Service) ExecuteSweep(ctx context.Context, plan *SweepPlan) error {
+	failed := map[string]bool{}
+	for _, item := range plan.Items {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if failed[item.Address] {
+			item.Error = "previous transaction of the address failed"
+			continue
+		}
+
+		if err := svc.Broadcast(ctx, item.Transaction); err != nil {
+			item.Error = err.Error()
+			failed[item.Address] = true
+			continue
+		}
+		item.TxID = item.Transaction.Hash().Hex()
+	}
+	return nil
+}
+
+func (svc *Service) planSweepAddress(ctx context.Context, request SweepRequest, index uint32, plan *SweepPlan) error {
+	address, privateKey, err := deriveAccount(request.Mnemonic, index)
+	if err != nil {
+		return err
+	}
+
+	balance, err := svc.BalanceETH(ctx, address)
+	if err != nil {
+		return err
+	}
+
+	nonce, err := svc.Nonce(ctx, address)
+	if err != nil {
+		return err
+	}
+
+	remaining, tokensLeft := *balance, false
+	for _, tokenAddress := range request.TokenAddresses {
+		tokenBalance, err := svc.BalanceERC20(ctx, tokenAddress, address)
+		if err != nil {
+			return err
+		}
+
+		if !tokenBalance.IsPositive() {
+			continue
+		}
+
+		item := &SweepItem{Index: index, Address: address, TokenAddress: tokenAddress, Amount: *tokenBalance}
+		tx, err := svc.CreateTransaction(ctx, CreateTransactionRequest{
+			TokenAddress: tokenAddress,
+			From:         address,
+			To:           plan.Destination,
+			Amount:       tokenBalance.String(),
+			GasMaxFee:    request.GasMaxFee,
+			Nonce:        nonce,
+		})
+		if err != nil {
+			return err
+		}
+
+		item.Fee = transactionMaxFee(tx)
+		if remaining.LessThan(item.Fee) {
+			item.Reason = SweepReasonInsufficientGas
+			plan.Skipped = append(plan.Skipped, item)
+			tokensLeft = true
+			continue
+		}
+
+		item.Transaction, err = svc.SignTransaction(ctx, tx, privateKey)
+		if err != nil {
+			return err
+		}
+
+		remaining = remaining.Sub(item.Fee)
+		nonce++
+		plan.Items = append(plan.Items, item)
+	}
+
+	if !remaining.IsPositive() {
+		return nil
+	}
+
+	if tokensLeft {
+		plan.Skipped = append(plan.Skipped, &SweepItem{Index: index, Address: address, Amount: remaining,
+			Reason: SweepReasonTokensPending})
+		return nil
+	}
+
+	maxFee, err := decimal.NewFromString(request.GasMaxFee)
+	if err != nil {
+		return err
+	}
+
+	item := &SweepItem{Index: index, Address: address, Fee: maxFee.Mul(decimal.NewFromInt(int64(ethTransferGasLimit)))}
+	if !remaining.GreaterThan(item.Fee) {
+		item.Amount = remaining
+		item.Reason = SweepReasonDust
+		plan.Skipped = append(plan.Skipped, item)
+		return nil
+	}
+
+	item.Amount = remaining.Sub(item.Fee)
+	tx, err := svc.CreateTransaction(ctx, CreateTransactionRequest{
+		From:      address,
+		To:        plan.Destination,
+		Amount:    item.Amount.String(),
+		GasLimit:  ethTransferGasLimit,
+		GasMaxFee: request.GasMaxFee,
+		Nonce:     nonce,
+	})
+	if err != nil {
+		return err
+	}
+
+	item.Transaction, err = svc.SignTransaction(ctx, tx, privateKey)
+	if err != nil {
+		return err
+	}
+
+	plan.Items = append(plan.Items, item)
+	return nil
+}
+
+// transactionMaxFee returns the most tx can pay for gas, in ETH.
+func transactionMaxFee(tx *types.Transaction) decimal.Decimal {
+	fee := new(big.Int).Mul(tx.GasFeeCap(), new(big.Int).SetUint64(tx.Gas()))
+	return amount.FromBase(fee, amount.Ether)
+}
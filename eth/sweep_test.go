@@ -0,0 +1,116 @@
+package eth
+
+import (
+	"context"
+	"demo/token"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+)
+
+const sweepMnemonic = "tag volcano eight thank tide danger coast health above argue embrace heavy"
+
+func Test_Sweep(t *testing.T) {
+	svc, backend := getSimulatedService(t)
+	ctx := context.Background()
+	auth := getSimulatedAuth(t, backend, owner1PrivateKey)
+	tokenAddress, _, instance, err := token.DeployToken(auth, backend, "gavin", "GV")
+	assert.NoError(t, err)
+	backend.Commit()
+
+	deposit0, _ := svc.CreateAddress(ctx, sweepMnemonic, 0)
+	deposit1, _ := svc.CreateAddress(ctx, sweepMnemonic, 1)
+	deposit2, _ := svc.CreateAddress(ctx, sweepMnemonic, 2)
+	sendETH(t, svc, owner1Addr, owner1PrivateKey, deposit0, "0.5")
+	sendETH(t, svc, owner1Addr, owner1PrivateKey, deposit1, "0.01")
+	_, err = instance.Transfer(auth, common.HexToAddress(deposit1), big.NewInt(3e18))
+	assert.NoError(t, err)
+	_, err = instance.Transfer(auth, common.HexToAddress(deposit2), big.NewInt(2e18))
+	assert.NoError(t, err)
+	backend.Commit()
+
+	destination := "0x35bb6eF95c72bf4804334BB9d6A3c77Bef18d81B"
+	plan, err := svc.PlanSweep(ctx, SweepRequest{
+		Mnemonic:       sweepMnemonic,
+		FromIndex:      0,
+		ToIndex:        3,
+		Destination:    destination,
+		TokenAddresses: []string{tokenAddress.Hex()},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, plan.Items, 3)
+	assert.Len(t, plan.Skipped, 1)
+	assert.Equal(t, deposit2, plan.Skipped[0].Address)
+	assert.Equal(t, SweepReasonInsufficientGas, plan.Skipped[0].Reason)
+	assert.Equal(t, tokenAddress.Hex(), plan.Items[1].TokenAddress)
+	assert.Equal(t, "3", plan.Items[1].Amount.String())
+	assert.Equal(t, "0.5", plan.Items[0].Amount.Add(plan.Items[0].Fee).String())
+
+	err = svc.ExecuteSweep(ctx, plan)
+	assert.NoError(t, err)
+	backend.Commit()
+	for _, item := range plan.Items {
+		assert.Empty(t, item.Error)
+		receipt, err := backend.TransactionReceipt(ctx, common.HexToHash(item.TxID))
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(1), receipt.Status)
+	}
+
+	balance, _ := svc.BalanceETH(ctx, deposit0)
+	assert.True(t, balance.IsZero())
+	balance, _ = svc.BalanceETH(ctx, destination)
+	assert.Equal(t, plan.Items[0].Amount.Add(plan.Items[2].Amount).String(), balance.String())
+	tokenBalance, _ := instance.BalanceOf(&bind.CallOpts{}, common.HexToAddress(destination))
+	assert.Equal(t, "3000000000000000000", tokenBalance.String())
+	tokenBalance, _ = instance.BalanceOf(&bind.CallOpts{}, common.HexToAddress(deposit2))
+	assert.Equal(t, "2000000000000000000", tokenBalance.String())
+
+	// the ETH of an address is not swept while it still holds tokens it can not pay the gas for
+	sendETH(t, svc, owner1Addr, owner1PrivateKey, deposit2, "0.00003")
+	backend.Commit()
+	plan, err = svc.PlanSweep(ctx, SweepRequest{
+		Mnemonic:       sweepMnemonic,
+		FromIndex:      2,
+		ToIndex:        2,
+		Destination:    destination,
+		TokenAddresses: []string{tokenAddress.Hex()},
+		GasMaxFee:      "0.000000001",
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, plan.Items)
+	assert.Len(t, plan.Skipped, 2)
+	assert.Equal(t, SweepReasonInsufficientGas, plan.Skipped[0].Reason)
+	assert.Equal(t, SweepReasonTokensPending, plan.Skipped[1].Reason)
+	assert.Equal(t, "0.00003", plan.Skipped[1].Amount.String())
+
+	_, err = svc.PlanSweep(ctx, SweepRequest{Mnemonic: sweepMnemonic, FromIndex: 2, ToIndex: 1, Destination: destination})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+}
+
+func sendETH(t *testing.T, svc *Service, from, privateKey, to, amount string) {
+	ctx := context.Background()
+	gasPrice, _ := svc.SuggestGasPrice(ctx)
+	nonce, _ := svc.Nonce(ctx, from)
+	tx, err := svc.CreateTransaction(ctx, CreateTransactionRequest{
+		From:      from,
+		To:        to,
+		Amount:    amount,
+		GasLimit:  21000,
+		GasMaxFee: gasPrice.String(),
+		Nonce:     nonce,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err = svc.SignTransaction(ctx, tx, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = svc.Broadcast(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,274 @@
+package eth
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"demo/amount"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/shopspring/decimal"
+	"math/big"
+	"strings"
+)
+
+const permitDefaultVersion = "1"
+
+var permitTypes = apitypes.Types{
+	"EIP712Domain": {
+		{Name: "name", Type: "string"},
+		{Name: "version", Type: "string"},
+		{Name: "chainId", Type: "uint256"},
+		{Name: "verifyingContract", Type: "address"},
+	},
+	"Permit": {
+		{Name: "owner", Type: "address"},
+		{Name: "spender", Type: "address"},
+		{Name: "value", Type: "uint256"},
+		{Name: "nonce", Type: "uint256"},
+		{Name: "deadline", Type: "uint256"},
+	},
+}
+
+// TypedDataHash returns the EIP-712 digest of typedData, which is what gets signed.
+func (svc *Service) TypedDataHash(ctx context.Context, typedData apitypes.TypedData) ([]byte, error) {
+	domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+	if err != nil {
+		return nil, err
+	}
+
+	messageHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	rawData := []byte(fmt.Sprintf("\x19\x01%s%s", string(domainSeparator), string(messageHash)))
+	return crypto.Keccak256(rawData), nil
+}
+
+// SignTypedData signs typedData with privateKey. The returned signature has v set to 27/28.
+func (svc *Service) SignTypedData(ctx context.Context, typedData apitypes.TypedData, privateKey string) ([]byte, error) {
+	privateKeyECDSA, err := ParsePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := svc.TypedDataHash(ctx, typedData)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := crypto.Sign(hash, privateKeyECDSA)
+	if err != nil {
+		return nil, err
+	}
+
+	signature[crypto.RecoveryIDOffset] += 27
+	return signature, nil
+}
+
+// RecoverTypedData returns the address that signed typedData. Both v=0/1 and v=27/28 are accepted.
+func (svc *Service) RecoverTypedData(ctx context.Context, typedData apitypes.TypedData, signature []byte) (string, error) {
+	hash, err := svc.TypedDataHash(ctx, typedData)
+	if err != nil {
+		return "", err
+	}
+
+	return recoverAddress(hash, signature)
+}
+
+func (svc *Service) VerifyTypedData(ctx context.Context, typedData apitypes.TypedData, signature []byte, address string) (bool, error) {
+	signer, err := svc.RecoverTypedData(ctx, typedData, signature)
+	if err != nil {
+		return false, err
+	}
+
+	return common.HexToAddress(signer) == common.HexToAddress(address), nil
+}
+
+// PermitTypedData builds the EIP-2612 permit message for request. The nonce is read from the token and the
+// domain is checked against the token's DOMAIN_SEPARATOR.
+func (svc *Service) PermitTypedData(ctx context.Context, request PermitRequest) (*apitypes.TypedData, error) {
+	permit, tokenName, err := svc.createPermit(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return svc.permitTypedData(ctx, tokenName, permit)
+}
+
+// SignPermit builds the permit message for request and signs it with the owner's privateKey.
+func (svc *Service) SignPermit(ctx context.Context, request PermitRequest, privateKey string) (*Permit, error) {
+	permit, tokenName, err := svc.createPermit(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	typedData, err := svc.permitTypedData(ctx, tokenName, permit)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := svc.SignTypedData(ctx, *typedData, privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	copy(permit.R[:], signature[:32])
+	copy(permit.S[:], signature[32:64])
+	permit.V = signature[crypto.RecoveryIDOffset]
+	return permit, nil
+}
+
+// RecoverPermit returns the address that signed permit.
+func (svc *Service) RecoverPermit(ctx context.Context, permit *Permit) (string, error) {
+	tokenInfo, err := svc.ERC20Info(ctx, permit.TokenAddress)
+	if err != nil {
+		return "", err
+	}
+
+	typedData, err := svc.permitTypedData(ctx, tokenInfo.Name, permit)
+	if err != nil {
+		return "", err
+	}
+
+	return svc.RecoverTypedData(ctx, *typedData, permit.Signature())
+}
+
+// VerifyPermit reports whether permit was signed by its owner.
+func (svc *Service) VerifyPermit(ctx context.Context, permit *Permit) (bool, error) {
+	signer, err := svc.RecoverPermit(ctx, permit)
+	if err != nil {
+		return false, err
+	}
+
+	return common.HexToAddress(signer) == common.HexToAddress(permit.Owner), nil
+}
+
+func (svc *Service) createPermit(ctx context.Context, request PermitRequest) (*Permit, string, error) {
+	reqAmount, err := decimal.NewFromString(request.Amount)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if reqAmount.LessThan(decimal0) {
+		return nil, "", ErrInvalidInput
+	}
+
+	tokenInfo, err := svc.ERC20Info(ctx, request.TokenAddress)
+	if err != nil {
+		return nil, "", err
+	}
+
+	instance, err := NewERC20Permit(common.HexToAddress(request.TokenAddress), svc.client)
+	if err != nil {
+		return nil, "", err
+	}
+
+	nonce, err := instance.Nonces(&bind.CallOpts{Context: ctx}, common.HexToAddress(request.Owner))
+	if err != nil {
+		return nil, "", err
+	}
+
+	value, err := toBaseUnits(reqAmount, amount.Token(tokenInfo.Decimals))
+	if err != nil {
+		return nil, "", err
+	}
+
+	permit := Permit{
+		TokenAddress: request.TokenAddress,
+		Owner:        request.Owner,
+		Spender:      request.Spender,
+		Value:        value,
+		Nonce:        nonce,
+		Deadline:     big.NewInt(request.Deadline.Unix()),
+		Version:      request.Version,
+	}
+	return &permit, tokenInfo.Name, nil
+}
+
+func (svc *Service) permitTypedData(ctx context.Context, tokenName string, permit *Permit) (*apitypes.TypedData, error) {
+	chainID, err := svc.client.NetworkID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	version := permit.Version
+	if len(version) < 1 {
+		version = permitDefaultVersion
+	}
+
+	tokenAddress := common.HexToAddress(permit.TokenAddress)
+	typedData := apitypes.TypedData{
+		Types:       permitTypes,
+		PrimaryType: "Permit",
+		Domain: apitypes.TypedDataDomain{
+			Name:              tokenName,
+			Version:           version,
+			ChainId:           (*math.HexOrDecimal256)(chainID),
+			VerifyingContract: tokenAddress.Hex(),
+		},
+		Message: apitypes.TypedDataMessage{
+			"owner":    common.HexToAddress(permit.Owner).Hex(),
+			"spender":  common.HexToAddress(permit.Spender).Hex(),
+			"value":    permit.Value.String(),
+			"nonce":    permit.Nonce.String(),
+			"deadline": permit.Deadline.String(),
+		},
+	}
+
+	domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+	if err != nil {
+		return nil, err
+	}
+
+	instance, err := NewERC20Permit(tokenAddress, svc.client)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenDomainSeparator, err := instance.DOMAINSEPARATOR(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(domainSeparator, tokenDomainSeparator[:]) {
+		return nil, ErrDomainSeparatorMismatch
+	}
+	return &typedData, nil
+}
+
+// ParsePrivateKey parses a hex private key, with or without the 0x prefix, as the signing methods of Service do.
+func ParsePrivateKey(privateKey string) (*ecdsa.PrivateKey, error) {
+	if strings.HasPrefix(privateKey, "0x") {
+		privateKey = privateKey[2:]
+	}
+	return crypto.HexToECDSA(privateKey)
+}
+
+func recoverAddress(hash, signature []byte) (string, error) {
+	if len(signature) != crypto.SignatureLength {
+		return "", ErrInvalidSignature
+	}
+
+	sig := make([]byte, crypto.SignatureLength)
+	copy(sig, signature)
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+
+	if sig[crypto.RecoveryIDOffset] > 1 {
+		return "", ErrInvalidSignature
+	}
+
+	publicKey, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return "", ErrInvalidSignature
+	}
+
+	return crypto.PubkeyToAddress(*publicKey).Hex(), nil
+}
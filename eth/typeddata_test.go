@@ -0,0 +1,120 @@
+package eth
+
+import (
+	"context"
+	"demo/permit"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func Test_SignTypedData(t *testing.T) {
+	svc, _ := getSimulatedService(t)
+	ctx := context.Background()
+	typedData := apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+			},
+			"Order": {
+				{Name: "maker", Type: "address"},
+				{Name: "amount", Type: "uint256"},
+				{Name: "expiry", Type: "uint256"},
+			},
+		},
+		PrimaryType: "Order",
+		Domain: apitypes.TypedDataDomain{
+			Name:    "demo",
+			Version: "1",
+			ChainId: math.NewHexOrDecimal256(1337),
+		},
+		Message: apitypes.TypedDataMessage{
+			"maker":  owner1Addr,
+			"amount": "1000",
+			"expiry": "1700000000",
+		},
+	}
+
+	signature, err := svc.SignTypedData(ctx, typedData, owner1PrivateKey)
+	assert.NoError(t, err)
+	assert.Contains(t, []byte{27, 28}, signature[64])
+
+	signer, err := svc.RecoverTypedData(ctx, typedData, signature)
+	assert.NoError(t, err)
+	assert.Equal(t, owner1Addr, signer)
+
+	signature[64] -= 27
+	ok, err := svc.VerifyTypedData(ctx, typedData, signature, owner1Addr)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = svc.VerifyTypedData(ctx, typedData, signature, owner2Addr)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	typedData.Message["amount"] = "1001"
+	ok, err = svc.VerifyTypedData(ctx, typedData, signature, owner1Addr)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = svc.RecoverTypedData(ctx, typedData, signature[:64])
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func Test_SignPermit(t *testing.T) {
+	svc, backend := getSimulatedService(t)
+	ctx := context.Background()
+	tokenAddress, _, token, err := permit.DeployPermitToken(getSimulatedAuth(t, backend, owner1PrivateKey), backend)
+	assert.NoError(t, err)
+	backend.Commit()
+
+	req := PermitRequest{
+		TokenAddress: tokenAddress.Hex(),
+		Owner:        owner1Addr,
+		Spender:      owner2Addr,
+		Amount:       "1.5",
+		Deadline:     time.Now().Add(time.Hour),
+	}
+	signed, err := svc.SignPermit(ctx, req, owner1PrivateKey)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), signed.Nonce.Int64())
+	assert.Equal(t, "1500000000000000000", signed.Value.String())
+
+	ok, err := svc.VerifyPermit(ctx, signed)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// the spender relays the permit and pays the gas
+	owner, spender := common.HexToAddress(owner1Addr), common.HexToAddress(owner2Addr)
+	relayer := getSimulatedAuth(t, backend, owner2PrivateKey)
+	_, err = token.Permit(relayer, owner, spender, signed.Value, signed.Deadline, signed.V, signed.R, signed.S)
+	assert.NoError(t, err)
+	backend.Commit()
+
+	allowance, err := token.Allowance(&bind.CallOpts{}, owner, spender)
+	assert.NoError(t, err)
+	assert.Equal(t, signed.Value, allowance)
+
+	_, err = token.TransferFrom(relayer, owner, spender, big.NewInt(1))
+	assert.NoError(t, err)
+	backend.Commit()
+
+	// the nonce moved on, so the same signature no longer verifies
+	typedData, err := svc.PermitTypedData(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", typedData.Message["nonce"])
+
+	_, err = token.Permit(relayer, owner, spender, signed.Value, signed.Deadline, signed.V, signed.R, signed.S)
+	assert.Error(t, err)
+
+	req.Version = "2"
+	_, err = svc.PermitTypedData(ctx, req)
+	assert.ErrorIs(t, err, ErrDomainSeparatorMismatch)
+}
@@ -0,0 +1,390 @@
+// Package failover spreads the calls of a chain client over several nodes. It tracks the head height and latency of
+// each node, routes calls to the fastest healthy node that is in sync, moves on to the next node when one fails,
+// and quarantines nodes that lag behind the best head until they catch up.
+//
+// A Policy retries transient errors with a jittered backoff, and caps the rate and the concurrency of the calls to
+// each node. Clients are opaque to the package; eth.Pool and btc.Pool adapt it to their chain.
+package failover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/time/rate"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrNoNodes = errors.New("no node to route the call to")
+
+// Probe returns the head height of a node.
+type Probe func(ctx context.Context, client interface{}) (uint64, error)
+
+// Class is what a call error means for the pool.
+type Class int
+
+const (
+	// Answer is the final answer of a node, such as a JSON-RPC error, returned to the caller as it is.
+	Answer Class = iota
+	// Transient is an error worth trying again, such as a timeout, a 429 or a block the node has not imported yet.
+	// The call moves on to the next node, and all of them are tried again after a backoff.
+	Transient
+	// Unreachable fails the node over to the next one and marks it unhealthy.
+	Unreachable
+)
+
+// Classify returns the Class of a call error that is not nil.
+type Classify func(err error) Class
+
+// Policy is how hard the pool tries a call, and how much it asks of each node. The zero Policy tries each node
+// once, without limits.
+type Policy struct {
+	Timeout     time.Duration //of each call to a node and of each probe, 0 for the deadline of the caller only
+	Retries     int           //rounds over the nodes after the first one, while the errors are transient
+	Backoff     time.Duration //before the first retry, doubled for every next one
+	MaxBackoff  time.Duration //0 for no cap
+	RateLimit   float64       //calls per second to each node, 0 for no limit
+	Concurrency int           //calls in flight to each node, 0 for no limit
+}
+
+// backoff returns the wait before retry, half of it random so that callers failing together do not retry together.
+func (p Policy) backoff(retry int) time.Duration {
+	backoff := p.Backoff
+	for i := 0; i < retry && backoff < math.MaxInt64/2 && (p.MaxBackoff == 0 || backoff < p.MaxBackoff); i++ {
+		backoff *= 2
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+
+	if backoff <= 0 {
+		return 0
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+// Observer is told about every call attempt and every probe, for metrics. Its methods must be safe for concurrent
+// use and must not block.
+type Observer interface {
+	// RPC reports an attempt of method on node, err being nil on success.
+	RPC(node, method string, latency time.Duration, err error)
+	// Head reports the head height of a node that answered a probe, and how many blocks it is behind the best head.
+	Head(node string, height, lag uint64)
+}
+
+type Status struct {
+	Name    string
+	Height  uint64
+	Latency time.Duration
+	Healthy bool   //the last call or probe reached the node
+	Lagging bool   //quarantined behind the best head
+	Error   string //of the last failure
+}
+
+type node struct {
+	client  interface{}
+	status  Status
+	limiter *rate.Limiter //nil for no rate limit
+	slots   chan struct{} //nil for no concurrency limit
+}
+
+// acquire waits for the rate limit and a free slot of n, and returns the func releasing the slot. The limits are read
+// under the lock, SetPolicy replaces them, and the slot is released to the channel it was taken from.
+func (p *Pool) acquire(ctx context.Context, n *node) (func(), error) {
+	p.mu.RLock()
+	limiter, slots := n.limiter, n.slots
+	p.mu.RUnlock()
+
+	if limiter != nil {
+		if err := limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if slots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type Pool struct {
+	probe    Probe
+	classify Classify
+	maxLag   uint64
+
+	mu       sync.RWMutex
+	policy   Policy
+	observer Observer //nil for none
+	nodes    []*node
+}
+
+// New returns an empty pool that quarantines nodes more than maxLag blocks behind the best head.
+func New(probe Probe, classify Classify, maxLag uint64) *Pool {
+	return &Pool{probe: probe, classify: classify, maxLag: maxLag}
+}
+
+// SetPolicy sets the retries and limits of the calls, the zero Policy by default. Calls in flight keep the limits
+// they started with.
+func (p *Pool) SetPolicy(policy Policy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.policy = policy
+	for _, n := range p.nodes {
+		n.limiter, n.slots = policy.limits()
+	}
+}
+
+func (p Policy) limits() (*rate.Limiter, chan struct{}) {
+	var limiter *rate.Limiter
+	if p.RateLimit > 0 {
+		burst := int(p.RateLimit)
+		if burst < 1 {
+			burst = 1
+		}
+		limiter = rate.NewLimiter(rate.Limit(p.RateLimit), burst)
+	}
+
+	var slots chan struct{}
+	if p.Concurrency > 0 {
+		slots = make(chan struct{}, p.Concurrency)
+	}
+	return limiter, slots
+}
+
+// SetObserver sets the observer of the calls and probes, none by default.
+func (p *Pool) SetObserver(observer Observer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.observer = observer
+}
+
+// Add adds a node, healthy until a call or a probe fails.
+func (p *Pool) Add(name string, client interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	limiter, slots := p.policy.limits()
+	p.nodes = append(p.nodes, &node{client: client, status: Status{Name: name, Healthy: true}, limiter: limiter,
+		slots: slots})
+}
+
+// Do calls fn with the client of the fastest healthy node, and with the next one while fn fails to reach them or
+// fails with a transient error. fn must make its node calls with the ctx it is given, which ends at the timeout of
+// the policy; a node that times out counts as a transient error. Nodes that failed before are tried after the
+// healthy ones, so a call still goes through when every node failed once; lagging nodes are never tried. When every
+// node failed transiently, Do tries again after a backoff, as often as the policy allows.
+//
+// Do returns as soon as ctx is done, and does not start a retry it could not finish before the deadline of ctx.
+func (p *Pool) Do(ctx context.Context, fn func(ctx context.Context, client interface{}) error) error {
+	return p.Call(ctx, "", fn)
+}
+
+// Call is Do for a call the Observer knows as method.
+func (p *Pool) Call(ctx context.Context, method string, fn func(ctx context.Context, client interface{}) error) error {
+	p.mu.RLock()
+	policy, observer := p.policy, p.observer
+	p.mu.RUnlock()
+
+	for retry := 0; ; retry++ {
+		transient, err := p.round(ctx, policy.Timeout, observer, method, fn)
+		if !transient || retry >= policy.Retries {
+			return err
+		}
+
+		backoff := policy.backoff(retry)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < backoff {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// round tries the candidates once, and reports whether any of them failed transiently.
+func (p *Pool) round(ctx context.Context, timeout time.Duration, observer Observer, method string,
+	fn func(ctx context.Context, client interface{}) error) (transient bool, err error) {
+	candidates := p.candidates()
+	if len(candidates) == 0 {
+		return false, ErrNoNodes
+	}
+
+	for _, n := range candidates {
+		release, waitErr := p.acquire(ctx, n)
+		if waitErr != nil {
+			return false, waitErr
+		}
+
+		callCtx, cancel := withTimeout(ctx, timeout)
+		start := time.Now()
+		err = fn(callCtx, n.client)
+		latency := time.Since(start)
+		cancel()
+		release()
+		if observer != nil {
+			observer.RPC(n.status.Name, method, latency, err)
+		}
+		if err == nil {
+			p.succeeded(n, latency)
+			return false, nil
+		}
+
+		if ctx.Err() != nil {
+			return false, err
+		}
+
+		switch p.classify(err) {
+		case Answer:
+			p.succeeded(n, latency)
+			return false, err
+		case Transient:
+			transient = true
+		default:
+			p.failed(n, err)
+		}
+	}
+	return transient, fmt.Errorf("all %d nodes failed, the last with: %w", len(candidates), err)
+}
+
+// Check probes every node at once and quarantines the nodes more than maxLag blocks behind the best head.
+func (p *Pool) Check(ctx context.Context) {
+	p.mu.RLock()
+	nodes := append([]*node{}, p.nodes...)
+	timeout, observer := p.policy.Timeout, p.observer
+	p.mu.RUnlock()
+
+	type result struct {
+		height  uint64
+		latency time.Duration
+		err     error
+	}
+	results := make([]result, len(nodes))
+	var wg sync.WaitGroup
+	for i, n := range nodes {
+		wg.Add(1)
+		go func(i int, n *node) {
+			defer wg.Done()
+			probeCtx, cancel := withTimeout(ctx, timeout)
+			defer cancel()
+			start := time.Now()
+			height, err := p.probe(probeCtx, n.client)
+			results[i] = result{height: height, latency: time.Since(start), err: err}
+		}(i, n)
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var best uint64
+	for i, n := range nodes {
+		if results[i].err != nil {
+			n.status.Healthy, n.status.Error = false, results[i].err.Error()
+			continue
+		}
+
+		n.status.Healthy, n.status.Error, n.status.Height = true, "", results[i].height
+		n.status.Latency = average(n.status.Latency, results[i].latency)
+		if n.status.Height > best {
+			best = n.status.Height
+		}
+	}
+
+	for i, n := range nodes {
+		n.status.Lagging = n.status.Healthy && best-n.status.Height > p.maxLag
+		if observer != nil && results[i].err == nil {
+			observer.Head(n.status.Name, n.status.Height, best-n.status.Height)
+		}
+	}
+}
+
+// Watch checks the nodes every interval until ctx is done.
+func (p *Pool) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		p.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status returns the state of every node in the order they were added.
+func (p *Pool) Status() []Status {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	statuses := make([]Status, len(p.nodes))
+	for i, n := range p.nodes {
+		statuses[i] = n.status
+	}
+	return statuses
+}
+
+// candidates orders the nodes to try: the healthy ones by latency, those not measured yet last, then the ones that
+// failed.
+func (p *Pool) candidates() []*node {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var healthy, failed []*node
+	for _, n := range p.nodes {
+		switch {
+		case n.status.Lagging:
+		case n.status.Healthy:
+			healthy = append(healthy, n)
+		default:
+			failed = append(failed, n)
+		}
+	}
+
+	sort.SliceStable(healthy, func(i, j int) bool {
+		a, b := healthy[i].status.Latency, healthy[j].status.Latency
+		return a != 0 && (b == 0 || a < b)
+	})
+	return append(healthy, failed...)
+}
+
+func (p *Pool) succeeded(n *node, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n.status.Healthy, n.status.Error = true, ""
+	n.status.Latency = average(n.status.Latency, latency)
+}
+
+func (p *Pool) failed(n *node, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n.status.Healthy, n.status.Error = false, err.Error()
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// average is a moving average that weighs the latest latency by a fifth.
+func average(current, latest time.Duration) time.Duration {
+	if current == 0 {
+		return latest
+	}
+	return (current*4 + latest) / 5
+}
@@ -0,0 +1,287 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var (
+	errDown   = errors.New("connection refused")
+	errAnswer = errors.New("execution reverted")
+	errBusy   = errors.New("429 Too Many Requests")
+)
+
+// fakeNode is a client that is at height and fails with err.
+type fakeNode struct {
+	height uint64
+	err    error
+	calls  int
+}
+
+func newPool(maxLag uint64, nodes map[string]*fakeNode, order ...string) *Pool {
+	pool := New(func(ctx context.Context, client interface{}) (uint64, error) {
+		node := client.(*fakeNode)
+		return node.height, node.err
+	}, func(err error) Class {
+		switch err {
+		case errAnswer:
+			return Answer
+		case errBusy, context.DeadlineExceeded:
+			return Transient
+		}
+		return Unreachable
+	}, maxLag)
+	for _, name := range order {
+		pool.Add(name, nodes[name])
+	}
+	return pool
+}
+
+// call returns the name of the node Do routed to.
+func call(pool *Pool, nodes map[string]*fakeNode) (string, error) {
+	var name string
+	err := pool.Do(context.Background(), func(ctx context.Context, client interface{}) error {
+		node := client.(*fakeNode)
+		node.calls++
+		for n, fake := range nodes {
+			if fake == node {
+				name = n
+			}
+		}
+		return node.err
+	})
+	return name, err
+}
+
+func Test_Failover(t *testing.T) {
+	nodes := map[string]*fakeNode{"a": {height: 10, err: errDown}, "b": {height: 10}}
+	pool := newPool(1, nodes, "a", "b")
+
+	name, err := call(pool, nodes)
+	assert.NoError(t, err)
+	assert.Equal(t, "b", name)
+	assert.Equal(t, 1, nodes["a"].calls)
+	assert.False(t, pool.Status()[0].Healthy)
+	assert.Equal(t, "connection refused", pool.Status()[0].Error)
+
+	name, _ = call(pool, nodes)
+	assert.Equal(t, "b", name)
+	assert.Equal(t, 1, nodes["a"].calls, "a failed node is tried after the healthy ones")
+
+	nodes["b"].err = errAnswer
+	_, err = call(pool, nodes)
+	assert.Equal(t, errAnswer, err, "an answer does not fail over")
+	assert.Equal(t, 1, nodes["a"].calls)
+
+	nodes["b"].err = errDown
+	_, err = call(pool, nodes)
+	assert.ErrorIs(t, err, errDown)
+	assert.Contains(t, err.Error(), "all 2 nodes failed")
+
+	nodes["a"].err = nil
+	name, err = call(pool, nodes)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", name, "failed nodes are still tried when no node is healthy")
+	assert.True(t, pool.Status()[0].Healthy)
+}
+
+func Test_Check(t *testing.T) {
+	nodes := map[string]*fakeNode{"a": {height: 100}, "b": {height: 97}, "c": {height: 99}, "d": {err: errDown}}
+	pool := newPool(2, nodes, "a", "b", "c", "d")
+	pool.Check(context.Background())
+
+	statuses := pool.Status()
+	assert.Equal(t, []string{"a", "b", "c", "d"}, []string{statuses[0].Name, statuses[1].Name, statuses[2].Name, statuses[3].Name})
+	assert.Equal(t, uint64(97), statuses[1].Height)
+	assert.True(t, statuses[1].Lagging)
+	assert.False(t, statuses[2].Lagging)
+	assert.False(t, statuses[3].Healthy)
+
+	for _, candidate := range pool.candidates() {
+		assert.NotEqual(t, nodes["b"], candidate.client, "a lagging node is quarantined")
+	}
+	assert.Equal(t, nodes["d"], pool.candidates()[2].client)
+
+	nodes["b"].height = 100
+	pool.Check(context.Background())
+	assert.False(t, pool.Status()[1].Lagging, "a node that caught up leaves the quarantine")
+}
+
+func Test_Latency(t *testing.T) {
+	nodes := map[string]*fakeNode{"slow": {height: 1}, "fast": {height: 1}}
+	pool := newPool(0, nodes, "slow", "fast")
+	pool.nodes[0].status.Latency = 50 * time.Millisecond
+	pool.nodes[1].status.Latency = 5 * time.Millisecond
+
+	name, _ := call(pool, nodes)
+	assert.Equal(t, "fast", name)
+
+	pool.Add("new", &fakeNode{height: 1})
+	assert.Equal(t, "new", pool.candidates()[2].status.Name, "a node not measured yet comes last")
+	assert.Equal(t, 42*time.Millisecond, average(50*time.Millisecond, 10*time.Millisecond))
+	assert.Equal(t, 10*time.Millisecond, average(0, 10*time.Millisecond))
+}
+
+func Test_DoCanceled(t *testing.T) {
+	nodes := map[string]*fakeNode{"a": {err: errDown}, "b": {}}
+	pool := newPool(0, nodes, "a", "b")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := pool.Do(ctx, func(ctx context.Context, client interface{}) error {
+		client.(*fakeNode).calls++
+		return context.Canceled
+	})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, nodes["b"].calls)
+	assert.True(t, pool.Status()[0].Healthy, "a canceled call does not fail the node")
+
+	assert.Equal(t, ErrNoNodes, New(nil, nil, 0).Do(context.Background(), nil))
+}
+
+func Test_Retry(t *testing.T) {
+	nodes := map[string]*fakeNode{"a": {err: errBusy}, "b": {err: errDown}}
+	pool := newPool(0, nodes, "a", "b")
+	pool.SetPolicy(Policy{Retries: 2, Backoff: time.Millisecond})
+
+	_, err := call(pool, nodes)
+	assert.EqualError(t, err, "all 2 nodes failed, the last with: connection refused")
+	assert.Equal(t, 3, nodes["a"].calls, "a transient error is retried")
+	assert.True(t, pool.Status()[0].Healthy, "a transient error does not fail the node")
+	assert.False(t, pool.Status()[1].Healthy)
+
+	calls := 0
+	err = pool.Do(context.Background(), func(ctx context.Context, client interface{}) error {
+		if calls++; calls < 3 {
+			return errBusy
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls, "a node busy at first round is tried again, after the others")
+
+	nodes["a"].calls = 0
+	nodes["a"].err = errAnswer
+	_, err = call(pool, nodes)
+	assert.Equal(t, errAnswer, err)
+	assert.Equal(t, 1, nodes["a"].calls, "an answer is not retried")
+
+	nodes["a"].calls = 0
+	nodes["a"].err = errBusy
+	pool.SetPolicy(Policy{Retries: 5, Backoff: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	err = pool.Do(ctx, func(ctx context.Context, client interface{}) error {
+		client.(*fakeNode).calls++
+		return client.(*fakeNode).err
+	})
+	assert.Error(t, err)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second), "no retry starts that would outlast the deadline")
+	assert.Equal(t, 1, nodes["a"].calls)
+}
+
+func Test_Backoff(t *testing.T) {
+	policy := Policy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for retry, max := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		backoff := policy.backoff(retry)
+		assert.GreaterOrEqual(t, int64(backoff), int64(max*time.Millisecond/2))
+		assert.LessOrEqual(t, int64(backoff), int64(max*time.Millisecond))
+	}
+	assert.Equal(t, time.Duration(0), Policy{MaxBackoff: time.Second}.backoff(3))
+	assert.Greater(t, int64(Policy{Backoff: time.Hour}.backoff(100)), int64(0), "doubling does not overflow")
+}
+
+func Test_Limits(t *testing.T) {
+	nodes := map[string]*fakeNode{"a": {}}
+	pool := newPool(0, nodes, "a")
+	pool.SetPolicy(Policy{RateLimit: 20})
+
+	start := time.Now()
+	for i := 0; i < 30; i++ {
+		_, err := call(pool, nodes)
+		assert.NoError(t, err)
+	}
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(400*time.Millisecond), "20 calls a second after a burst of 20")
+
+	pool.SetPolicy(Policy{Concurrency: 1})
+	release, err := pool.acquire(context.Background(), pool.nodes[0])
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = pool.Do(ctx, func(ctx context.Context, client interface{}) error { return nil })
+	assert.Equal(t, context.DeadlineExceeded, err, "a call waits for a free slot until its deadline")
+	release()
+	assert.NoError(t, pool.Do(context.Background(), func(ctx context.Context, client interface{}) error { return nil }))
+
+	release, err = pool.acquire(context.Background(), pool.nodes[0])
+	assert.NoError(t, err)
+	pool.SetPolicy(Policy{Concurrency: 1})
+	held, err := pool.acquire(context.Background(), pool.nodes[0])
+	assert.NoError(t, err)
+	release()
+	assert.Len(t, pool.nodes[0].slots, 1, "a slot taken before SetPolicy is released to the old limits")
+	held()
+}
+
+func Test_Timeout(t *testing.T) {
+	nodes := map[string]*fakeNode{"hung": {height: 1}, "b": {height: 1}}
+	pool := newPool(0, nodes, "hung", "b")
+	pool.SetPolicy(Policy{Timeout: 10 * time.Millisecond})
+
+	var reached []interface{}
+	err := pool.Do(context.Background(), func(ctx context.Context, client interface{}) error {
+		reached = append(reached, client)
+		if client == nodes["hung"] {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{nodes["hung"], nodes["b"]}, reached, "a node that times out moves the call on")
+
+	hung := New(func(ctx context.Context, client interface{}) (uint64, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}, nil, 0)
+	hung.Add("hung", nil)
+	hung.SetPolicy(Policy{Timeout: 10 * time.Millisecond})
+	hung.Check(context.Background())
+	assert.False(t, hung.Status()[0].Healthy, "a probe times out")
+	assert.Equal(t, context.DeadlineExceeded.Error(), hung.Status()[0].Error)
+}
+
+// recorder is an Observer that records what it is told.
+type recorder struct {
+	calls []string
+	heads map[string][2]uint64
+}
+
+func (r *recorder) RPC(node, method string, latency time.Duration, err error) {
+	r.calls = append(r.calls, fmt.Sprintf("%s %s %v", node, method, err))
+}
+
+func (r *recorder) Head(node string, height, lag uint64) {
+	r.heads[node] = [2]uint64{height, lag}
+}
+
+func Test_Observer(t *testing.T) {
+	nodes := map[string]*fakeNode{"a": {height: 10, err: errDown}, "b": {height: 12}, "c": {height: 9}}
+	pool := newPool(5, nodes, "a", "b", "c")
+	observer := &recorder{heads: map[string][2]uint64{}}
+	pool.SetObserver(observer)
+
+	err := pool.Call(context.Background(), "eth_blockNumber", func(ctx context.Context, client interface{}) error {
+		return client.(*fakeNode).err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a eth_blockNumber connection refused", "b eth_blockNumber <nil>"}, observer.calls)
+
+	pool.Check(context.Background())
+	assert.Equal(t, map[string][2]uint64{"b": {12, 0}, "c": {9, 3}}, observer.heads, "a node that fails the probe has no head")
+}
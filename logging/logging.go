@@ -0,0 +1,94 @@
+// Package logging builds the leveled, structured loggers of the wallet on go-ethereum's log package. Entries carry
+// their context as key/value pairs, such as chain, tx, address and block, and key material never reaches a handler:
+// Redact replaces the values of secret keys and of private key types.
+//
+// Library packages take a log.Logger and log nothing until they are given one.
+package logging
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/ethereum/go-ethereum/log"
+	"io"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// secretKeys are the context keys whose values are never written, compared case-insensitively.
+var secretKeys = map[string]bool{
+	"key":         true,
+	"private_key": true,
+	"privatekey":  true,
+	"mnemonic":    true,
+	"seed":        true,
+	"password":    true,
+	"passphrase":  true,
+	"secret":      true,
+	"wif":         true,
+	"xprv":        true,
+}
+
+// Discard returns a logger that writes nothing, the logger of a service until it is given one.
+func Discard() log.Logger {
+	logger := log.New()
+	logger.SetHandler(log.DiscardHandler())
+	return logger
+}
+
+// New returns a logger writing to w the entries at level or above. format is terminal, logfmt or json; level is
+// one of trace, debug, info, warn, error and crit.
+func New(w io.Writer, format, level string) (log.Logger, error) {
+	lvl, err := log.LvlFromString(level)
+	if err != nil {
+		return nil, fmt.Errorf("unknown log level %q", level)
+	}
+
+	var formatter log.Format
+	switch format {
+	case "terminal":
+		formatter = log.TerminalFormat(false)
+	case "logfmt":
+		formatter = log.LogfmtFormat()
+	case "json":
+		formatter = log.JSONFormat()
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+
+	logger := log.New()
+	logger.SetHandler(Redact(log.LvlFilterHandler(lvl, log.StreamHandler(w, formatter))))
+	return logger, nil
+}
+
+// With returns a child of logger that adds ctx to every entry and redacts key material, whatever the handler of
+// logger does.
+func With(logger log.Logger, ctx ...interface{}) log.Logger {
+	child := logger.New(ctx...)
+	child.SetHandler(Redact(child.GetHandler()))
+	return child
+}
+
+// Redact returns a handler passing the entries to h with the values of secret keys and private keys replaced.
+func Redact(h log.Handler) log.Handler {
+	return log.FuncHandler(func(r *log.Record) error {
+		ctx := make([]interface{}, len(r.Ctx))
+		copy(ctx, r.Ctx)
+		for i := 0; i+1 < len(ctx); i += 2 {
+			if key, ok := ctx[i].(string); ok && secretKeys[strings.ToLower(key)] {
+				ctx[i+1] = redacted
+				continue
+			}
+
+			switch ctx[i+1].(type) {
+			case *ecdsa.PrivateKey, ecdsa.PrivateKey, *btcec.PrivateKey, btcec.PrivateKey:
+				ctx[i+1] = redacted
+			}
+		}
+
+		record := *r
+		record.Ctx = ctx
+		return h.Log(&record)
+	})
+}
@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"bytes"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func Test_New(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "logfmt", "info")
+	assert.NoError(t, err)
+	logger.Debug("Hidden")
+	logger.Info("Broadcast transaction", "chain", "eth", "tx", "0xabc", "block", 12)
+	assert.NotContains(t, buf.String(), "Hidden")
+	assert.Contains(t, buf.String(), `msg="Broadcast transaction" chain=eth tx=0xabc block=12`)
+
+	buf.Reset()
+	logger, _ = New(&buf, "json", "debug")
+	logger.Debug("Read block", "block", 7)
+	assert.Contains(t, buf.String(), `"block":7`)
+
+	_, err = New(&buf, "logfmt", "loud")
+	assert.EqualError(t, err, `unknown log level "loud"`)
+	_, err = New(&buf, "xml", "info")
+	assert.EqualError(t, err, `unknown log format "xml"`)
+}
+
+func Test_Redact(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	btcKey, _ := btcec.NewPrivateKey(btcec.S256())
+	var records []*log.Record
+	logger := log.New()
+	logger.SetHandler(Redact(log.FuncHandler(func(r *log.Record) error {
+		records = append(records, r)
+		return nil
+	})))
+
+	ctx := []interface{}{"address", "0xE280029a7867BA5C9154434886c241775ea87e53", "Private_Key", "f1b3f8e0",
+		"mnemonic", "tag volcano eight", "signer", key, "btc", btcKey}
+	logger.Warn("Signing", ctx...)
+	assert.Equal(t, []interface{}{"address", "0xE280029a7867BA5C9154434886c241775ea87e53", "Private_Key", redacted,
+		"mnemonic", redacted, "signer", redacted, "btc", redacted}, records[0].Ctx)
+	assert.Equal(t, "f1b3f8e0", ctx[3], "the context of the caller is left as it is")
+}
+
+func Test_With(t *testing.T) {
+	var buf bytes.Buffer
+	parent := log.New()
+	parent.SetHandler(log.StreamHandler(&buf, log.LogfmtFormat()))
+
+	logger := With(parent, "chain", "btc")
+	logger.Info("Signed transaction", "tx", "0xabc", "wif", "cVt4o7BGAig1UXywgGSmARhxMdzP5qvQsxKkSsc1XEkw3tDTQFpy")
+	assert.Contains(t, buf.String(), `chain=btc tx=0xabc wif=[REDACTED]`)
+	assert.False(t, strings.Contains(buf.String(), "cVt4o7"))
+
+	buf.Reset()
+	parent.Info("Unchanged", "seed", "00")
+	assert.Contains(t, buf.String(), "seed=00", "the parent keeps its handler")
+
+	Discard().Error("Nothing")
+}
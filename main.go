@@ -1,230 +1,266 @@
+// Command wallet drives the eth and btc services from the command line: deriving addresses, reading balances,
+// blocks and transactions, building, signing and broadcasting transfers, deploying the bundled contracts and running
+// a local simulated chain.
+//
+// Settings come from the configuration file of --config and WALLET_* environment variables as described in package
+// config, and a few flags override them. Private keys and mnemonics are only read from a file or the environment,
+// never from a flag value or the configuration.
 package main
 
 import (
 	"context"
-	"crypto/ecdsa"
-	"demo/token"
+	"demo/btc"
+	"demo/chain"
+	"demo/config"
+	"demo/eth"
+	"demo/logging"
 	"fmt"
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
-	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
-	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/core"
-	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
-	"log"
-	"math/big"
+	"github.com/ethereum/go-ethereum/log"
+	"gopkg.in/urfave/cli.v1"
+	"os"
+	"os/signal"
 )
 
-/*
- ==================
-(0) 0xE280029a7867BA5C9154434886c241775ea87e53 (100 ETH)
-(1) 0x68dB32D26d9529B2a142927c6f1af248fc6Ba7e9 (100 ETH)
-(2) 0x35bb6eF95c72bf4804334BB9d6A3c77Bef18d81B (100 ETH)
-(3) 0x26d8094A90AD1A4440600fa18073730Ef17F5eCE (100 ETH)
-(4) 0xb53cC19aD713e00cB71542d064215784c908D387 (100 ETH)
-(5) 0xcb98ce2619f90f54052524Fb79b03E0261b01BEE (100 ETH)
-(6) 0x4C4Cb54BdBad6c96805b92b8063F3c75B24F65eB (100 ETH)
-(7) 0x67B27Df78bb0f199EDBd568e655BD9b2B202866d (100 ETH)
-(8) 0xE93E2b43fC45CCEcc056A6Ea400972298A304b4B (100 ETH)
-(9) 0x288Ab710f8DEc0b13753Fec71161E50Ee0cDA7e6 (100 ETH)
-
-Private Keys
-==================
-(0) 0xf1b3f8e0d52caec13491368449ab8d90f3d222a3e485aa7f02591bbceb5efba5
-(1) 0x91821f9af458d612362136648fc8552a47d8289c0f25a8a1bf0860510332cef9
-(2) 0xbb32062807c162a5243dc9bcf21d8114cb636c376596e1cf2895ec9e5e3e0a68
-(3) 0x95ce6122165d94aa51b0fcf51021895b39b0ff291aa640c803d5401bd87894d5
-(4) 0x3af93668029f95d526fc1d2bdefccc120bfe1d26a0462d268e8f6b2f71402ba3
-(5) 0x3b24a4fdf2e6e1375008c387c5456ce00cb0772435ae1938c2fe833103393b9a
-(6) 0xcba858feeb49e1ca8053a5213987a22c3ee83d9f9f396e138940a018dd837ebb
-(7) 0xdf48bfda4cb4b4e094803e923836a8538fbf607da79f6e46d68cdd43fb2f3f88
-(8) 0x487efb6249a8a4d45a19c8e5d1e5c7d3f6610a7e69f8f81ddcf368f9a0c0d6d5
-(9) 0xbb4cce73db59f456ea427e5862fdb0d5bc038a7d0b930cbb45e1c4f6d122289e
-
-ganache-cli -m "much repair shock carbon improve miss forget sock include bullet interest solution"
-
-Gas Price
-==================
-20000000000
-
-Gas Limit
-==================
-6721975
-
-Call Gas Limit
-==================
-9007199254740991
-*/
-func main() {
-	//client, err := ethclient.Dial("https://cloudflare-eth.com")
-	//client, err := ethclient.Dial("http://localhost:8545")
-	//if err != nil {
-	//log.Fatal(err)
-	//	}
-	privateKey, err := crypto.GenerateKey()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	auth := bind.NewKeyedTransactor(privateKey)
-
-	balance := new(big.Int)
-	balance.SetString("10000000000000000000", 10) // 10 eth in wei
+const (
+	configKey     = "config"
+	contextKey    = "context"
+	loggerKey     = "logger"
+	ethServiceKey = "eth"
+	btcServiceKey = "btc"
+)
 
-	address := auth.From
-	genesisAlloc := map[common.Address]core.GenesisAccount{
-		address: {
-			Balance: balance,
-		},
+var (
+	configFlag = cli.StringFlag{
+		Name:   "config",
+		Usage:  "YAML or TOML configuration file, see package config",
+		EnvVar: "WALLET_CONFIG",
 	}
-
-	blockGasLimit := uint64(4712388)
-	client := backends.NewSimulatedBackend(genesisAlloc, blockGasLimit)
-
-	fromAddress := auth.From
-	nonce, err := client.PendingNonceAt(context.Background(), fromAddress)
-	if err != nil {
-		log.Fatal(err)
+	ethRPCFlag = cli.StringFlag{
+		Name:  "eth-rpc",
+		Usage: "Ethereum JSON-RPC endpoint, replaces eth.endpoints",
+	}
+	btcHostFlag = cli.StringFlag{
+		Name:  "btc-host",
+		Usage: "bitcoind RPC host:port, replaces the host of the first btc endpoint",
+	}
+	timeoutFlag = cli.DurationFlag{
+		Name:   "timeout",
+		Usage:  "deadline of all the node calls of the command, 0 for none; each call also ends at rpc.timeout",
+		EnvVar: "WALLET_TIMEOUT",
+	}
+	logLevelFlag = cli.StringFlag{
+		Name:   "log-level",
+		Usage:  "trace, debug, info, warn, error or crit",
+		Value:  "warn",
+		EnvVar: "WALLET_LOG_LEVEL",
+	}
+	logFormatFlag = cli.StringFlag{
+		Name:   "log-format",
+		Usage:  "terminal, logfmt or json; logs go to stderr",
+		Value:  "terminal",
+		EnvVar: "WALLET_LOG_FORMAT",
+	}
+	keyFileFlag = cli.StringFlag{
+		Name:   "key-file",
+		Usage:  "file holding the signing key, hex for eth and WIF for btc; defaults to $" + privateKeyEnv,
+		EnvVar: "WALLET_KEY_FILE",
 	}
+	chainFlag = cli.StringFlag{
+		Name:  "chain",
+		Usage: "eth or btc",
+		Value: "eth",
+	}
+)
 
-	value := big.NewInt(1000000000000000000) // in wei (1 eth)
-	gasLimit := uint64(21000)                // in units
-	gasPrice, err := client.SuggestGasPrice(context.Background())
-	if err != nil {
-		log.Fatal(err)
+func main() {
+	if err := newApp().Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
+}
 
-	toAddress := common.HexToAddress("0x4592d8f8d7b001e72cb26a73e4fa1806a51ac79d")
-	var data []byte
-	tx := types.NewTransaction(nonce, toAddress, value, gasLimit, gasPrice, data)
+func newApp() *cli.App {
+	app := cli.NewApp()
+	app.Name = "wallet"
+	app.Usage = "eth and btc wallet"
+	app.Version = "0.1.0"
+	app.Flags = []cli.Flag{configFlag, ethRPCFlag, btcHostFlag, timeoutFlag, logLevelFlag, logFormatFlag, keyFileFlag}
+	app.Before = func(ctx *cli.Context) error {
+		startContext(ctx)
+		if err := startLogger(ctx); err != nil {
+			return err
+		}
+		return loadConfig(ctx)
+	}
+	app.After = stopContext
+	app.Commands = []cli.Command{
+		addressCommand,
+		balanceCommand,
+		heightCommand,
+		blockCommand,
+		transactionCommand,
+		transferCommand,
+		tokensCommand,
+		nodesCommand,
+		deployCommand,
+		simulateCommand,
+	}
+	app.ErrWriter = os.Stderr
+	app.Metadata = map[string]interface{}{}
+	return app
+}
 
-	chainID := big.NewInt(1337)
-	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), privateKey)
-	if err != nil {
-		log.Fatal(err)
+// startContext makes the context of the node calls of a command, see nodeContext.
+func startContext(ctx *cli.Context) {
+	c, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	cancel := stop
+	if timeout := ctx.Duration(timeoutFlag.Name); timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		c, cancelTimeout = context.WithTimeout(c, timeout)
+		cancel = func() {
+			cancelTimeout()
+			stop()
+		}
 	}
+	ctx.App.Metadata[contextKey] = contextAndCancel{c, cancel}
+}
 
-	err = client.SendTransaction(context.Background(), signedTx)
-	if err != nil {
-		log.Fatal(err)
+func stopContext(ctx *cli.Context) error {
+	if c, ok := ctx.App.Metadata[contextKey].(contextAndCancel); ok {
+		c.cancel()
 	}
+	return nil
+}
 
-	fmt.Printf("tx sent: %s\n", signedTx.Hash().Hex()) // tx sent: 0xec3ceb05642c61d33fa6c951b54080d1953ac8227be81e7b5e4e2cfed69eeb51
+type contextAndCancel struct {
+	context.Context
+	cancel context.CancelFunc
+}
 
-	client.Commit()
+// nodeContext is the context of the node calls of a command. An interrupt cancels it, so that a hung call does not
+// keep the command from exiting, and --timeout ends it.
+func nodeContext(ctx *cli.Context) context.Context {
+	return ctx.App.Metadata[contextKey].(contextAndCancel).Context
+}
 
-	receipt, err := client.TransactionReceipt(context.Background(), signedTx.Hash())
+// startLogger makes the logger of the services from --log-level and --log-format.
+func startLogger(ctx *cli.Context) error {
+	logger, err := logging.New(ctx.App.ErrWriter, ctx.String(logFormatFlag.Name), ctx.String(logLevelFlag.Name))
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	if receipt == nil {
-		log.Fatal("receipt is nil. Forgot to commit?")
-	}
-
-	fmt.Printf("status: %v\n", receipt.Status) // status: 1
-	ret, _ := client.BalanceAt(context.Background(), toAddress, nil)
-	fmt.Println(ret.String())
+	ctx.App.Metadata[loggerKey] = logger
+	return nil
 }
 
-func deployContract(client *ethclient.Client) string {
-	/*
-		0x4A31ECe693fB614935eFB337034F9C79efEC03B5
-		0x6578c861a339fa8c3d30fabb6ef98f2f266b7cab697c54360dd2dc792ae632be
-	*/
+func appLogger(ctx *cli.Context) log.Logger {
+	return ctx.App.Metadata[loggerKey].(log.Logger)
+}
 
-	/*
-		gasPrice, err := client.SuggestGasPrice(context.Background())
-		if err != nil {
-			log.Fatal(err)
-		}
-	*/
+// ethService returns the eth.Service of the app, dialing the eth endpoints on first use. Tests put a simulated
+// service in the app's Metadata instead.
+func ethService(ctx *cli.Context) (*eth.Service, error) {
+	if svc, ok := ctx.App.Metadata[ethServiceKey].(*eth.Service); ok {
+		return svc, nil
+	}
 
-	auth := getAuth(client)
-	input := "1.0"
-	//address, tx, instance, err := store.DeployStore(auth, client, input)
-	address, tx, instance, err := token.DeployToken(auth, client, "gavin", input)
+	settings := configStore(ctx).Config().ETH
+	pool, err := ethPool(nodeContext(ctx), settings)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	fmt.Println(address.Hex())   // 0x147B8eb97fD247D06C4006D269c90C1908Fb5D54
-	fmt.Println(tx.Hash().Hex()) // 0xdae8ba5444eefdc99f4d45cd0c4f24056cba6a02cefbf78066ef9f4188ff7dc0
-
-	_ = instance
-	return address.Hex()
+	svc := eth.NewService(pool, settings.Confirmations, settings.Fee.GasMultiplier)
+	svc.SetLogger(appLogger(ctx))
+	ctx.App.Metadata[ethServiceKey] = svc
+	return svc, nil
 }
 
-func getAuth(client *ethclient.Client) *bind.TransactOpts {
-	privateKey, err := crypto.HexToECDSA("f1b3f8e0d52caec13491368449ab8d90f3d222a3e485aa7f02591bbceb5efba5")
-	if err != nil {
-		log.Fatal(err)
+// btcService returns the btc.Service of the app. Nothing is dialed until the first node call.
+func btcService(ctx *cli.Context) (*btc.Service, error) {
+	if svc, ok := ctx.App.Metadata[btcServiceKey].(*btc.Service); ok {
+		return svc, nil
 	}
 
-	publicKey := privateKey.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		log.Fatal("cannot assert type: publicKey is not of type *ecdsa.PublicKey")
+	settings := configStore(ctx).Config().BTC
+	pool, err := btcPool(nodeContext(ctx), settings)
+	if err != nil {
+		return nil, err
 	}
 
-	fromAddress := crypto.PubkeyToAddress(*publicKeyECDSA)
-	nonce, err := client.PendingNonceAt(context.Background(), fromAddress)
+	params, err := btc.NetworkParams(settings.Network)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	auth := bind.NewKeyedTransactor(privateKey)
-	auth.Nonce = big.NewInt(int64(nonce))
-	auth.Value = big.NewInt(0)      // in wei
-	auth.GasLimit = uint64(6721975) // in units
-	auth.GasPrice = big.NewInt(20000000000)
-
-	return auth
+	svc := btc.NewClientService(pool)
+	svc.SetNetwork(params)
+	svc.SetConfirmations(settings.Confirmations)
+	svc.SetLogger(appLogger(ctx))
+	ctx.App.Metadata[btcServiceKey] = svc
+	return svc, nil
 }
 
-func writeAndRead(client *ethclient.Client, tokenAddress, toAddress common.Address) {
-	instance, err := token.NewToken(tokenAddress, client)
+// ethPool dials the eth endpoints into a pool under the rpc policy. With several endpoints it checks which nodes
+// are in sync before the first call.
+func ethPool(ctx context.Context, settings config.ETH) (*eth.Pool, error) {
+	pool, err := eth.DialPool(settings.Endpoints, settings.MaxLag)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	auth := getAuth(client)
-	tx, err := instance.Transfer(auth, toAddress, big.NewInt(1))
-	if err != nil {
-		log.Fatal(err)
+	pool.SetPolicy(settings.RPC.Policy())
+	if len(settings.Endpoints) > 1 {
+		pool.Check(ctx)
 	}
+	return pool, nil
+}
 
-	fmt.Printf("tx sent: %s", tx.Hash().Hex()) // tx sent: 0x8d490e535678e9a24360e955d75b27ad307bdfb97a1dca51d0f3035dcee3e870
-	bal, err := instance.BalanceOf(&bind.CallOpts{}, toAddress)
+// btcPool dials the btc endpoints into a pool under the rpc policy. With several endpoints it checks which nodes
+// are in sync before the first call.
+func btcPool(ctx context.Context, settings config.BTC) (*btc.Pool, error) {
+	pool, err := btc.DialPool(btcEndpoints(settings), settings.MaxLag)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	fmt.Printf("wei: %s\n", bal) // "wei: 74605500647408739782407023"
-	/*
-		auth.Nonce= big.NewInt(int64(tx.Nonce()))
-		result, err := instance.Approve(auth,toAddress,big.NewInt(1))
-		if err != nil {
-			log.Fatal(err)
-		}
-		fmt.Printf("tx sent2: %s", result.Hash().Hex()) // tx sent: 0x8d490e535678e9a24360e955d75b27ad307bdfb97a1dca51d0f3035dcee3e870
-		bal, err = instance.BalanceOf(&bind.CallOpts{}, toAddress)
-		if err != nil {
-			log.Fatal(err)
-		}
-		fmt.Printf("wei: %s\n", bal) // "wei: 74605500647408739782407023"
-	*/
+
+	pool.SetPolicy(settings.RPC.Policy())
+	if len(settings.Endpoints) > 1 {
+		pool.Check(ctx)
+	}
+	return pool, nil
 }
 
-type LogTransfer struct {
-	From  common.Address
-	To    common.Address
-	Value *big.Int
+func btcEndpoints(settings config.BTC) []btc.Endpoint {
+	endpoints := make([]btc.Endpoint, len(settings.Endpoints))
+	for i, endpoint := range settings.Endpoints {
+		endpoints[i] = btc.Endpoint{Host: endpoint.Host, User: endpoint.User, Password: endpoint.Password}
+	}
+	return endpoints
 }
 
-// LogApproval ..
-type LogApproval struct {
-	TokenOwner common.Address
-	Spender    common.Address
+// wallet returns the Chain called name, "eth" or "btc", under the fee policy of the configuration.
+func wallet(ctx *cli.Context, name string) (chain.Chain, error) {
+	settings := configStore(ctx).Config()
+	switch name {
+	case "eth":
+		svc, err := ethService(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		c := chain.NewETH(svc)
+		c.SetFeePolicy(chain.FeePolicy{Default: settings.ETH.Fee.GasPrice.Decimal, Max: settings.ETH.Fee.MaxGasPrice.Decimal})
+		return c, nil
+	case "btc":
+		svc, err := btcService(ctx)
+		if err != nil {
+			return nil, err
+		}
 
-	Tokens *big.Int
+		c := chain.NewBTC(svc)
+		c.SetFeePolicy(chain.FeePolicy{Default: settings.BTC.Fee.Default.Decimal, Max: settings.BTC.Fee.Max.Decimal})
+		return c, nil
+	}
+	return nil, fmt.Errorf("%w: %s", chain.ErrUnknownChain, name)
 }
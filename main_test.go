@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"demo/chain"
+	"demo/eth"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	owner1Addr       = "0xE280029a7867BA5C9154434886c241775ea87e53"
+	owner1PrivateKey = "0xf1b3f8e0d52caec13491368449ab8d90f3d222a3e485aa7f02591bbceb5efba5"
+	owner2Addr       = "0x68dB32D26d9529B2a142927c6f1af248fc6Ba7e9"
+)
+
+func Test_Wallet(t *testing.T) {
+	svc, _ := getSimulatedService(t)
+	out, err := runApp(t, svc, "height", "--chain", "eth")
+	assert.NoError(t, err)
+	assert.Equal(t, "0\n", out)
+
+	_, err = runApp(t, svc, "height", "--chain", "sol")
+	assert.ErrorIs(t, err, chain.ErrUnknownChain)
+}
+
+func Test_LogFlags(t *testing.T) {
+	_, err := runApp(t, nil, "--log-level", "loud", "tokens")
+	assert.EqualError(t, err, `unknown log level "loud"`)
+	_, err = runApp(t, nil, "--log-format", "xml", "tokens")
+	assert.EqualError(t, err, `unknown log format "xml"`)
+
+	out, err := runApp(t, nil, "--log-level", "debug", "--log-format", "json", "tokens")
+	assert.NoError(t, err)
+	assert.NotContains(t, out, `"lvl"`, "commands that call no service log nothing")
+}
+
+func Test_Help(t *testing.T) {
+	out, err := runApp(t, nil, "--help")
+	assert.NoError(t, err)
+	for _, command := range []string{"address", "balance", "transfer", "block", "tx", "deploy", "simulate"} {
+		assert.Contains(t, out, command)
+	}
+	assert.NotContains(t, out, "private-key")
+}
+
+func getSimulatedService(t *testing.T) (*eth.Service, *eth.SimulatedBackend) {
+	balance, _ := new(big.Int).SetString("100000000000000000000", 10)
+	backend := eth.NewSimulatedBackend(backends.NewSimulatedBackend(core.GenesisAlloc{
+		common.HexToAddress(owner1Addr): {Balance: balance},
+		common.HexToAddress(owner2Addr): {Balance: balance},
+	}, simulatedGasLimit))
+	t.Cleanup(func() {
+		backend.Close()
+	})
+	return eth.NewService(backend, 0, 1.2), backend
+}
+
+// runApp runs the wallet with args on svc and returns what it printed.
+func runApp(t *testing.T, svc *eth.Service, args ...string) (string, error) {
+	app := newApp()
+	if svc != nil {
+		app.Metadata[ethServiceKey] = svc
+	}
+
+	var out bytes.Buffer
+	app.Writer, app.ErrWriter = &out, &out
+	err := app.Run(append([]string{"wallet"}, args...))
+	return out.String(), err
+}
+
+// writeFile writes content to name in a directory removed after the test, and returns its path.
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,188 @@
+// Package metrics instruments the eth and btc services and exposes their measurements in the Prometheus text
+// format: the latency and errors of the RPC calls per method and endpoint, the head height and lag of each endpoint,
+// the progress of the scanners, the outcomes of the broadcasts, the nonce gaps of the senders and the fee levels.
+//
+// The services take a Hook, Metrics.Chain returns the Hook of a chain. Serve Metrics at /metrics for Prometheus to
+// scrape.
+package metrics
+
+import (
+	"demo/failover"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Broadcast outcomes.
+const (
+	Accepted = "accepted" //the node took the transaction
+	Rejected = "rejected" //the node answered with an error, such as a nonce too low
+	Failed   = "failed"   //no node could be reached
+)
+
+// Hook receives the measurements of the service of a chain. Its methods must be safe for concurrent use and must
+// not block. The RPC and Head methods of failover.Observer measure the node calls of a pool.
+type Hook interface {
+	failover.Observer
+	// Scanned reports that a scanner indexed every block up to height, head being the head of the chain.
+	Scanned(height, head uint64)
+	// Broadcast reports the outcome of sending a transaction: Accepted, Rejected or Failed.
+	Broadcast(outcome string)
+	// NonceGap reports how many transactions of address are pending.
+	NonceGap(address string, gap uint64)
+	// Fee reports the latest fee level of kind, see Metrics for the kinds and their units.
+	Fee(kind string, value float64)
+}
+
+// Nop is the Hook of a service that is not instrumented.
+type Nop struct{}
+
+func (Nop) RPC(node, method string, latency time.Duration, err error) {}
+func (Nop) Head(node string, height, lag uint64)                      {}
+func (Nop) Scanned(height, head uint64)                               {}
+func (Nop) Broadcast(outcome string)                                  {}
+func (Nop) NonceGap(address string, gap uint64)                       {}
+func (Nop) Fee(kind string, value float64)                            {}
+
+// rpcBuckets are the latency buckets of the RPC calls, in seconds.
+var rpcBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Metrics holds the measurements of every chain. Fee kinds are gas_price and max_fee in wei per gas for eth, and
+// fee_rate in satoshi per kvB for btc.
+type Metrics struct {
+	mu       sync.Mutex
+	families []*family
+
+	rpcDuration *family
+	rpcErrors   *family
+	headHeight  *family
+	headLag     *family
+	scanHeight  *family
+	scanLag     *family
+	broadcasts  *family
+	nonceGap    *family
+	nonceGapped *family
+	fee         *family
+
+	nonceGaps map[string]map[string]uint64 //the gaps of the senders with pending transactions, by chain
+}
+
+func New() *Metrics {
+	m := &Metrics{nonceGaps: map[string]map[string]uint64{}}
+	m.rpcDuration = m.register(histogram, "wallet_rpc_duration_seconds", "Latency of the RPC calls to the nodes.",
+		"chain", "endpoint", "method")
+	m.rpcErrors = m.register(counter, "wallet_rpc_errors_total",
+		"RPC calls to the nodes that failed or were answered with an error.", "chain", "endpoint", "method")
+	m.headHeight = m.register(gauge, "wallet_chain_head_height", "Head block height of each node.", "chain", "endpoint")
+	m.headLag = m.register(gauge, "wallet_chain_head_lag_blocks", "Blocks a node is behind the best head of its pool.",
+		"chain", "endpoint")
+	m.scanHeight = m.register(gauge, "wallet_scanner_height", "Last block indexed by the scanner.", "chain")
+	m.scanLag = m.register(gauge, "wallet_scanner_lag_blocks", "Blocks the scanner is behind the head of the chain.",
+		"chain")
+	m.broadcasts = m.register(counter, "wallet_broadcasts_total", "Transactions sent to the nodes, by outcome.",
+		"chain", "outcome")
+	m.nonceGap = m.register(gauge, "wallet_nonce_gap",
+		"Largest nonce gap of the senders, their pending nonce less their mined one.", "chain")
+	m.nonceGapped = m.register(gauge, "wallet_nonce_gap_senders", "Senders with pending transactions.", "chain")
+	m.fee = m.register(gauge, "wallet_fee_level",
+		"Latest fee level: gas_price and max_fee in wei per gas for eth, fee_rate in satoshi per kvB for btc.",
+		"chain", "kind")
+	return m
+}
+
+func (m *Metrics) register(kind, name, help string, labels ...string) *family {
+	f := &family{kind: kind, name: name, help: help, labels: labels, series: map[string]*series{}}
+	if kind == histogram {
+		f.buckets = rpcBuckets
+	}
+	m.families = append(m.families, f)
+	return f
+}
+
+// Chain returns the Hook of the service of chain, eth or btc.
+func (m *Metrics) Chain(chain string) Hook {
+	return chainHook{m: m, chain: chain}
+}
+
+// ServeHTTP writes every metric in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, f := range m.families {
+		if err := f.write(w); err != nil {
+			return
+		}
+	}
+}
+
+type chainHook struct {
+	m     *Metrics
+	chain string
+}
+
+func (h chainHook) RPC(node, method string, latency time.Duration, err error) {
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+	h.m.rpcDuration.observe(latency.Seconds(), h.chain, node, method)
+	if err != nil {
+		h.m.rpcErrors.add(1, h.chain, node, method)
+	}
+}
+
+func (h chainHook) Head(node string, height, lag uint64) {
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+	h.m.headHeight.set(float64(height), h.chain, node)
+	h.m.headLag.set(float64(lag), h.chain, node)
+}
+
+func (h chainHook) Scanned(height, head uint64) {
+	lag := uint64(0)
+	if head > height {
+		lag = head - height
+	}
+
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+	h.m.scanHeight.set(float64(height), h.chain)
+	h.m.scanLag.set(float64(lag), h.chain)
+}
+
+func (h chainHook) Broadcast(outcome string) {
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+	h.m.broadcasts.add(1, h.chain, outcome)
+}
+
+// NonceGap keeps the gap of address only while it is not zero and exports the largest gap and the number of senders
+// with a gap per chain, so the series do not grow with the addresses.
+func (h chainHook) NonceGap(address string, gap uint64) {
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+	gaps := h.m.nonceGaps[h.chain]
+	if gaps == nil {
+		gaps = map[string]uint64{}
+		h.m.nonceGaps[h.chain] = gaps
+	}
+	if gap == 0 {
+		delete(gaps, address)
+	} else {
+		gaps[address] = gap
+	}
+
+	largest := uint64(0)
+	for _, gap := range gaps {
+		if gap > largest {
+			largest = gap
+		}
+	}
+	h.m.nonceGap.set(float64(largest), h.chain)
+	h.m.nonceGapped.set(float64(len(gaps)), h.chain)
+}
+
+func (h chainHook) Fee(kind string, value float64) {
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+	h.m.fee.set(value, h.chain, kind)
+}
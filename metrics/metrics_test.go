@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func scrape(m *Metrics) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return recorder
+}
+
+func Test_Metrics(t *testing.T) {
+	m := New()
+	assert.Empty(t, scrape(m).Body.String(), "metrics without series are left out")
+
+	eth, btc := m.Chain("eth"), m.Chain("btc")
+	eth.RPC("http://node1:8545", "eth_call", 20*time.Millisecond, nil)
+	eth.RPC("http://node1:8545", "eth_call", 2*time.Second, errors.New("connection refused"))
+	eth.Head("http://node1:8545", 100, 2)
+	eth.Scanned(90, 100)
+	eth.Broadcast(Accepted)
+	eth.Broadcast(Accepted)
+	eth.NonceGap("0xE280029a7867BA5C9154434886c241775ea87e53", 3)
+	eth.NonceGap("0x0F4D2C5E3dD1b8B5A0F1E0aC3e2a1C6e6E6B47f1", 5)
+	eth.NonceGap("0x0F4D2C5E3dD1b8B5A0F1E0aC3e2a1C6e6E6B47f1", 0)
+	eth.NonceGap("0xd2E1D6D3f4A4bB8fE5C6A2B1e9F0a3C4d5E6F7A8", 1)
+	btc.Broadcast(Rejected)
+	btc.Fee("fee_rate", 12000)
+
+	response := scrape(m)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", response.Header().Get("Content-Type"))
+	assert.Equal(t, `# HELP wallet_rpc_duration_seconds Latency of the RPC calls to the nodes.
+# TYPE wallet_rpc_duration_seconds histogram
+wallet_rpc_duration_seconds_bucket{chain="eth",endpoint="http://node1:8545",method="eth_call",le="0.005"} 0
+wallet_rpc_duration_seconds_bucket{chain="eth",endpoint="http://node1:8545",method="eth_call",le="0.01"} 0
+wallet_rpc_duration_seconds_bucket{chain="eth",endpoint="http://node1:8545",method="eth_call",le="0.025"} 1
+wallet_rpc_duration_seconds_bucket{chain="eth",endpoint="http://node1:8545",method="eth_call",le="0.05"} 1
+wallet_rpc_duration_seconds_bucket{chain="eth",endpoint="http://node1:8545",method="eth_call",le="0.1"} 1
+wallet_rpc_duration_seconds_bucket{chain="eth",endpoint="http://node1:8545",method="eth_call",le="0.25"} 1
+wallet_rpc_duration_seconds_bucket{chain="eth",endpoint="http://node1:8545",method="eth_call",le="0.5"} 1
+wallet_rpc_duration_seconds_bucket{chain="eth",endpoint="http://node1:8545",method="eth_call",le="1"} 1
+wallet_rpc_duration_seconds_bucket{chain="eth",endpoint="http://node1:8545",method="eth_call",le="2.5"} 2
+wallet_rpc_duration_seconds_bucket{chain="eth",endpoint="http://node1:8545",method="eth_call",le="5"} 2
+wallet_rpc_duration_seconds_bucket{chain="eth",endpoint="http://node1:8545",method="eth_call",le="10"} 2
+wallet_rpc_duration_seconds_bucket{chain="eth",endpoint="http://node1:8545",method="eth_call",le="30"} 2
+wallet_rpc_duration_seconds_bucket{chain="eth",endpoint="http://node1:8545",method="eth_call",le="+Inf"} 2
+wallet_rpc_duration_seconds_sum{chain="eth",endpoint="http://node1:8545",method="eth_call"} 2.02
+wallet_rpc_duration_seconds_count{chain="eth",endpoint="http://node1:8545",method="eth_call"} 2
+# HELP wallet_rpc_errors_total RPC calls to the nodes that failed or were answered with an error.
+# TYPE wallet_rpc_errors_total counter
+wallet_rpc_errors_total{chain="eth",endpoint="http://node1:8545",method="eth_call"} 1
+# HELP wallet_chain_head_height Head block height of each node.
+# TYPE wallet_chain_head_height gauge
+wallet_chain_head_height{chain="eth",endpoint="http://node1:8545"} 100
+# HELP wallet_chain_head_lag_blocks Blocks a node is behind the best head of its pool.
+# TYPE wallet_chain_head_lag_blocks gauge
+wallet_chain_head_lag_blocks{chain="eth",endpoint="http://node1:8545"} 2
+# HELP wallet_scanner_height Last block indexed by the scanner.
+# TYPE wallet_scanner_height gauge
+wallet_scanner_height{chain="eth"} 90
+# HELP wallet_scanner_lag_blocks Blocks the scanner is behind the head of the chain.
+# TYPE wallet_scanner_lag_blocks gauge
+wallet_scanner_lag_blocks{chain="eth"} 10
+# HELP wallet_broadcasts_total Transactions sent to the nodes, by outcome.
+# TYPE wallet_broadcasts_total counter
+wallet_broadcasts_total{chain="btc",outcome="rejected"} 1
+wallet_broadcasts_total{chain="eth",outcome="accepted"} 2
+# HELP wallet_nonce_gap Largest nonce gap of the senders, their pending nonce less their mined one.
+# TYPE wallet_nonce_gap gauge
+wallet_nonce_gap{chain="eth"} 3
+# HELP wallet_nonce_gap_senders Senders with pending transactions.
+# TYPE wallet_nonce_gap_senders gauge
+wallet_nonce_gap_senders{chain="eth"} 2
+# HELP wallet_fee_level Latest fee level: gas_price and max_fee in wei per gas for eth, fee_rate in satoshi per kvB for btc.
+# TYPE wallet_fee_level gauge
+wallet_fee_level{chain="btc",kind="fee_rate"} 12000
+`, response.Body.String())
+}
+
+func Test_Nop(t *testing.T) {
+	var hook Hook = Nop{}
+	hook.RPC("node", "method", time.Second, nil)
+	hook.Scanned(1, 2)
+}
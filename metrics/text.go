@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	counter   = "counter"
+	gauge     = "gauge"
+	histogram = "histogram"
+)
+
+// family is a metric and its series, one for each combination of label values. It is not safe for concurrent use;
+// Metrics serializes the access.
+type family struct {
+	kind    string
+	name    string
+	help    string
+	labels  []string
+	buckets []float64 //upper bounds of a histogram, ascending
+
+	series map[string]*series //by the formatted labels
+}
+
+type series struct {
+	labels string  //formatted, such as chain="eth",method="eth_call"
+	value  float64 //of a counter or a gauge, the sum of a histogram
+	counts []uint64
+	count  uint64
+}
+
+func (f *family) get(values []string) *series {
+	pairs := make([]string, len(f.labels))
+	for i, label := range f.labels {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, label, escapeLabel(values[i]))
+	}
+	labels := strings.Join(pairs, ",")
+
+	s, ok := f.series[labels]
+	if !ok {
+		s = &series{labels: labels, counts: make([]uint64, len(f.buckets))}
+		f.series[labels] = s
+	}
+	return s
+}
+
+func (f *family) add(delta float64, values ...string) {
+	f.get(values).value += delta
+}
+
+func (f *family) set(value float64, values ...string) {
+	f.get(values).value = value
+}
+
+func (f *family) observe(value float64, values ...string) {
+	s := f.get(values)
+	s.value += value
+	s.count++
+	for i, bound := range f.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+}
+
+// write writes f in the text exposition format, its series sorted by labels. A family without series is left out.
+func (f *family) write(w io.Writer) error {
+	if len(f.series) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.kind)
+	for _, key := range keys {
+		s := f.series[key]
+		if f.kind != histogram {
+			fmt.Fprintf(&b, "%s{%s} %s\n", f.name, s.labels, formatFloat(s.value))
+			continue
+		}
+
+		for i, bound := range f.buckets {
+			fmt.Fprintf(&b, "%s_bucket{%s,le=\"%s\"} %d\n", f.name, s.labels, formatFloat(bound), s.counts[i])
+		}
+		fmt.Fprintf(&b, "%s_bucket{%s,le=\"+Inf\"} %d\n", f.name, s.labels, s.count)
+		fmt.Fprintf(&b, "%s_sum{%s} %s\n", f.name, s.labels, formatFloat(s.value))
+		fmt.Fprintf(&b, "%s_count{%s} %d\n", f.name, s.labels, s.count)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
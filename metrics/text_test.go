@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"strings"
+	"testing"
+)
+
+func Test_FamilyWrite(t *testing.T) {
+	f := &family{kind: gauge, name: "test_gauge", help: "A help\nwith \\ a newline.", labels: []string{"name"},
+		series: map[string]*series{}}
+	f.set(1.5, `quoted "name"`)
+	f.set(2, "back\\slash\nnewline")
+	f.add(1, "a")
+
+	var b strings.Builder
+	assert.NoError(t, f.write(&b))
+	assert.Equal(t, `# HELP test_gauge A help\nwith \\ a newline.
+# TYPE test_gauge gauge
+test_gauge{name="a"} 1
+test_gauge{name="back\\slash\nnewline"} 2
+test_gauge{name="quoted \"name\""} 1.5
+`, b.String())
+}
+
+func Test_FormatFloat(t *testing.T) {
+	assert.Equal(t, "+Inf", formatFloat(math.Inf(1)))
+	assert.Equal(t, "-Inf", formatFloat(math.Inf(-1)))
+	assert.Equal(t, "NaN", formatFloat(math.NaN()))
+	assert.Equal(t, "0.25", formatFloat(0.25))
+	assert.Equal(t, "1e+18", formatFloat(1e18))
+}
@@ -0,0 +1,41 @@
+// SPDX-License-Identifier: MIT
+pragma solidity 0.8.19;
+
+/**
+ * @dev Forwards every call to the implementation stored in the EIP-1967 slot with delegatecall, the way upgradeable
+ * tokens do. Together with PermitToken it gives the eth package a token whose transfers touch a second contract. The
+ * deployer gets 100 tokens, minted in the proxy's own storage. PermitProxy.abi is the ABI of PermitToken with the
+ * constructor of the proxy, for a binding that calls the token through it.
+ */
+contract PermitProxy {
+    bytes32 private constant IMPLEMENTATION_SLOT = 0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc;
+
+    // the storage layout of PermitToken
+    uint256 private _totalSupply;
+    mapping(address => uint256) private _balances;
+    mapping(address => mapping(address => uint256)) private _allowances;
+    mapping(address => uint256) private _nonces;
+
+    event Transfer(address indexed from, address indexed to, uint256 value);
+
+    constructor(address implementation) {
+        _totalSupply = 100 ether;
+        _balances[msg.sender] = 100 ether;
+        emit Transfer(address(0), msg.sender, 100 ether);
+        assembly {
+            sstore(IMPLEMENTATION_SLOT, implementation)
+        }
+    }
+
+    fallback() external payable {
+        assembly {
+            calldatacopy(0, 0, calldatasize())
+            let success := delegatecall(gas(), sload(IMPLEMENTATION_SLOT), 0, calldatasize(), 0, 0)
+            returndatacopy(0, 0, returndatasize())
+            if iszero(success) {
+                revert(0, returndatasize())
+            }
+            return(0, returndatasize())
+        }
+    }
+}
@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: MIT
+pragma solidity 0.8.19;
+
+/**
+ * @dev A minimal ERC20 token implementing EIP-2612 permit, used to exercise the typed data signing in the eth package
+ * on a simulated backend. The deployer gets 100 tokens.
+ *
+ * storage, which PermitProxy shares:
+ *   slot 0                               totalSupply
+ *   keccak(owner . 1)                    balances[owner]
+ *   keccak(spender . keccak(owner . 2))  allowances[owner][spender]
+ *   keccak(owner . 3)                    nonces[owner]
+ */
+contract PermitToken {
+    uint256 private _totalSupply;
+    mapping(address => uint256) private _balances;
+    mapping(address => mapping(address => uint256)) private _allowances;
+    mapping(address => uint256) private _nonces;
+
+    bytes32 private constant DOMAIN_TYPEHASH =
+        keccak256("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)");
+    bytes32 private constant PERMIT_TYPEHASH =
+        keccak256("Permit(address owner,address spender,uint256 value,uint256 nonce,uint256 deadline)");
+
+    event Transfer(address indexed from, address indexed to, uint256 value);
+    event Approval(address indexed owner, address indexed spender, uint256 value);
+
+    constructor() {
+        _totalSupply = 100 ether;
+        _balances[msg.sender] = 100 ether;
+        emit Transfer(address(0), msg.sender, 100 ether);
+    }
+
+    function name() external pure returns (string memory) {
+        return "Permit Token";
+    }
+
+    function symbol() external pure returns (string memory) {
+        return "PMT";
+    }
+
+    function decimals() external pure returns (uint8) {
+        return 18;
+    }
+
+    function totalSupply() external view returns (uint256) {
+        return _totalSupply;
+    }
+
+    function balanceOf(address account) external view returns (uint256) {
+        return _balances[account];
+    }
+
+    function allowance(address owner, address spender) external view returns (uint256) {
+        return _allowances[owner][spender];
+    }
+
+    function nonces(address owner) external view returns (uint256) {
+        return _nonces[owner];
+    }
+
+    /**
+     * @dev Computed on every call from the chain and the address of the contract, which is the proxy's when called
+     * through PermitProxy.
+     */
+    function DOMAIN_SEPARATOR() public view returns (bytes32) {
+        return keccak256(abi.encode(DOMAIN_TYPEHASH, keccak256("Permit Token"), keccak256("1"), block.chainid,
+            address(this)));
+    }
+
+    function transfer(address to, uint256 amount) external returns (bool) {
+        _move(msg.sender, to, amount);
+        return true;
+    }
+
+    function approve(address spender, uint256 amount) external returns (bool) {
+        _allowances[msg.sender][spender] = amount;
+        emit Approval(msg.sender, spender, amount);
+        return true;
+    }
+
+    function transferFrom(address from, address to, uint256 amount) external returns (bool) {
+        uint256 allowed = _allowances[from][msg.sender];
+        require(allowed >= amount, "ERC20: insufficient allowance");
+        _allowances[from][msg.sender] = allowed - amount;
+        _move(from, to, amount);
+        return true;
+    }
+
+    function permit(address owner, address spender, uint256 value, uint256 deadline, uint8 v, bytes32 r, bytes32 s)
+        external
+    {
+        require(block.timestamp <= deadline, "ERC20Permit: expired deadline");
+        bytes32 structHash = keccak256(abi.encode(PERMIT_TYPEHASH, owner, spender, value, _nonces[owner]++, deadline));
+        bytes32 digest = keccak256(abi.encodePacked("\x19\x01", DOMAIN_SEPARATOR(), structHash));
+        address signer = ecrecover(digest, v, r, s);
+        require(signer != address(0) && signer == owner, "ERC20Permit: invalid signature");
+
+        _allowances[owner][spender] = value;
+        emit Approval(owner, spender, value);
+    }
+
+    function _move(address from, address to, uint256 amount) private {
+        uint256 balance = _balances[from];
+        require(balance >= amount, "ERC20: insufficient balance");
+        _balances[from] = balance - amount;
+        _balances[to] += amount;
+        emit Transfer(from, to, amount);
+    }
+}
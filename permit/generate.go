@@ -0,0 +1,11 @@
+// Package permit holds the EIP-2612 test tokens of the eth package and the Go bindings generated from them.
+package permit
+
+// The bytecode is compiled by the pinned ethereum/solc image, and the bindings by the abigen of the go-ethereum
+// version in go.mod, so that both can be reproduced. PermitProxy.abi is kept by hand, see PermitProxy.sol. The .easm
+// files are the hand-written assembly the checked-in .bin files were built from, to be removed once the bytecode is
+// regenerated from the Solidity sources; both have the same ABI and behaviour.
+//go:generate docker run --rm -v $PWD:/sources -w /sources ethereum/solc:0.8.19 --optimize --optimize-runs 200 --evm-version london --abi --bin --overwrite -o . PermitToken.sol
+//go:generate docker run --rm -v $PWD:/sources -w /sources ethereum/solc:0.8.19 --optimize --optimize-runs 200 --evm-version london --bin --overwrite -o . PermitProxy.sol
+//go:generate go run github.com/ethereum/go-ethereum/cmd/abigen --abi PermitToken.abi --bin PermitToken.bin --pkg permit --type PermitToken --out PermitToken.go
+//go:generate go run github.com/ethereum/go-ethereum/cmd/abigen --abi PermitProxy.abi --bin PermitProxy.bin --pkg permit --type PermitProxy --out PermitProxy.go
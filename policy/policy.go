@@ -0,0 +1,379 @@
+package policy
+
+import (
+	"context"
+	"demo/apperr"
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc/codes"
+	"strings"
+	"sync"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+var (
+	ErrDestinationNotAllowed = &apperr.AppErr{Code: "DESTINATION_NOT_ALLOWED", Message: "the withdrawal policy does not allow the destination", Status: codes.PermissionDenied}
+	ErrLimitExceeded         = &apperr.AppErr{Code: "LIMIT_EXCEEDED", Message: "the withdrawal is above a limit of the withdrawal policy", Status: codes.FailedPrecondition}
+	ErrVelocityExceeded      = &apperr.AppErr{Code: "VELOCITY_EXCEEDED", Message: "too many withdrawals for the withdrawal policy", Status: codes.ResourceExhausted}
+	ErrApprovalRequired      = &apperr.AppErr{Code: "APPROVAL_REQUIRED", Message: "the withdrawal needs more approvals", Status: codes.FailedPrecondition}
+)
+
+// Withdrawal is a transfer as the withdrawal Policy sees it. Asset is the token contract, empty for the native coin
+// of Chain, and Amount is in the display unit of the asset.
+type Withdrawal struct {
+	Hash      string //signer hash of the transaction, a withdrawal evaluated again is counted once
+	Chain     string //eth or btc, for the name of the native asset
+	From      string
+	To        string //empty for a contract creation
+	Asset     string
+	Amount    decimal.Decimal
+	Approvals []string //approvers who signed off the withdrawal, see WithApprovals
+}
+
+// AssetName returns the asset of w, the native coin of its chain when it is empty.
+func (w Withdrawal) AssetName() string {
+	switch {
+	case len(w.Asset) > 0:
+		return w.Asset
+	case len(w.Chain) > 0:
+		return strings.ToUpper(w.Chain)
+	}
+	return "ETH"
+}
+
+// Rules are the rules of a withdrawal Policy. Addresses and assets are compared case-insensitively.
+type Rules struct {
+	Limits    []Limit
+	Whitelist []string //the only destinations allowed, any destination when empty
+	Blacklist []string //destinations never allowed, whitelisted or not
+	Velocity  []Velocity
+	Approvals []Approval
+	Approvers []string //the approvals the approval rules count, the others are ignored
+}
+
+// Limit bounds the withdrawals of Asset by Address, or by all senders together when Address is empty. Zero amounts
+// do not apply.
+type Limit struct {
+	Asset          string
+	Address        string
+	PerTransaction decimal.Decimal
+	Daily          decimal.Decimal //over the last 24 hours
+}
+
+// Velocity allows Address, or all senders together when it is empty, at most Count withdrawals of any asset in
+// Window.
+type Velocity struct {
+	Address string
+	Count   int
+	Window  time.Duration
+}
+
+// Approval requires Approvals distinct approvers of Rules for the withdrawals of Asset above Threshold.
+type Approval struct {
+	Asset     string
+	Threshold decimal.Decimal
+	Approvals int
+}
+
+// Decision is the audit record of one evaluation of a Withdrawal. Rule names the rule that refused it, one of the
+// Rule constants, and Err is the AppErr returned.
+type Decision struct {
+	Time       time.Time
+	Withdrawal Withdrawal
+	Allowed    bool
+	Rule       string
+	Err        error
+}
+
+// Rules of a withdrawal Policy, as named in Decision and in the "rule" detail of its errors.
+const (
+	RuleBlacklist      = "blacklist"
+	RuleWhitelist      = "whitelist"
+	RulePerTransaction = "per_transaction_limit"
+	RuleDaily          = "daily_limit"
+	RuleVelocity       = "velocity"
+	RuleApproval       = "approval"
+)
+
+type approvalsKey struct{}
+
+// WithApprovals returns a copy of ctx carrying the approvers who signed off the withdrawal signed with it, for the
+// approval rules of the Policy of a service. It is meant for an approval workflow such as chain.Approvals, which
+// collects the approvals itself; never build it from what a client claims. Only the approvers named in Rules are
+// counted.
+func WithApprovals(ctx context.Context, approvers ...string) context.Context {
+	return context.WithValue(ctx, approvalsKey{}, approvers)
+}
+
+// ApprovalsFromContext returns the approvers ctx carries, see WithApprovals.
+func ApprovalsFromContext(ctx context.Context) []string {
+	approvers, _ := ctx.Value(approvalsKey{}).([]string)
+	return approvers
+}
+
+// Policy decides which withdrawals may be signed. It keeps the withdrawals it allowed in the last 24 hours, or the
+// longest velocity window, for the daily limits and velocity checks; they are lost when the process stops. The eth and
+// the btc Service both take a Policy; give each its own, since an empty asset is the native coin of the chain.
+type Policy struct {
+	rules     Rules
+	whitelist map[string]bool
+	blacklist map[string]bool
+	approvers map[string]bool
+	now       func() time.Time
+
+	mu      sync.Mutex
+	allowed []record
+	audit   func(Decision)
+}
+
+type record struct {
+	time       time.Time
+	withdrawal Withdrawal
+}
+
+func New(rules Rules) *Policy {
+	return &Policy{
+		rules:     rules,
+		whitelist: addressSet(rules.Whitelist),
+		blacklist: addressSet(rules.Blacklist),
+		approvers: nameSet(rules.Approvers),
+		now:       time.Now,
+		audit:     func(Decision) {},
+	}
+}
+
+func nameSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+func addressSet(addresses []string) map[string]bool {
+	set := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		set[strings.ToLower(address)] = true
+	}
+	return set
+}
+
+// SetAudit makes the policy pass every decision to audit, in the order they are made. audit must not block. Call
+// it before the policy is in use.
+func (p *Policy) SetAudit(audit func(Decision)) {
+	p.audit = audit
+}
+
+// Evaluate decides on the withdrawals of one transaction, which share its Hash, and counts them toward the daily
+// limits and velocity checks when they are all allowed; a transaction counts as one withdrawal for the velocity
+// checks. Withdrawals of the same asset to the same destination are merged first, and the per transaction limits,
+// daily limits and approvals apply to all the transaction withdraws of an asset. The rules are checked in the order
+// blacklist, whitelist, per transaction limits, daily limits, velocity and approvals, and the first one to refuse
+// returns one of ErrDestinationNotAllowed, ErrLimitExceeded, ErrVelocityExceeded and ErrApprovalRequired with the
+// details of the refusal.
+func (p *Policy) Evaluate(withdrawals ...Withdrawal) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	p.prune(now)
+	withdrawals = merge(withdrawals)
+	for i, withdrawal := range withdrawals {
+		if rule, err := p.check(now, withdrawal, withdrawals[:i]); err != nil {
+			p.audit(Decision{Time: now, Withdrawal: withdrawal, Rule: rule, Err: err})
+			return err
+		}
+	}
+
+	for _, withdrawal := range withdrawals {
+		p.audit(Decision{Time: now, Withdrawal: withdrawal, Allowed: true})
+	}
+	if len(withdrawals) > 0 && !p.counted(withdrawals[0].Hash) {
+		for _, withdrawal := range withdrawals {
+			p.allowed = append(p.allowed, record{time: now, withdrawal: withdrawal})
+		}
+	}
+	return nil
+}
+
+// merge adds up the withdrawals of the same asset to the same destination, in the order they first appear.
+func merge(withdrawals []Withdrawal) []Withdrawal {
+	merged := make([]Withdrawal, 0, len(withdrawals))
+	for _, withdrawal := range withdrawals {
+		found := false
+		for i := range merged {
+			if sameAddress(merged[i].To, withdrawal.To) && sameAddress(merged[i].Asset, withdrawal.Asset) {
+				merged[i].Amount = merged[i].Amount.Add(withdrawal.Amount)
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, withdrawal)
+		}
+	}
+	return merged
+}
+
+// check decides on withdrawal, pending being the withdrawals of the same transaction already allowed.
+func (p *Policy) check(now time.Time, withdrawal Withdrawal, pending []Withdrawal) (string, error) {
+	to := strings.ToLower(withdrawal.To)
+	if p.blacklist[to] {
+		return RuleBlacklist, ErrDestinationNotAllowed.WithDetails(map[string]interface{}{
+			"rule": RuleBlacklist, "destination": withdrawal.To})
+	}
+
+	if len(p.whitelist) > 0 && !p.whitelist[to] {
+		return RuleWhitelist, ErrDestinationNotAllowed.WithDetails(map[string]interface{}{
+			"rule": RuleWhitelist, "destination": withdrawal.To})
+	}
+
+	amount := withdrawal.Amount
+	for _, other := range pending {
+		if sameAddress(other.Asset, withdrawal.Asset) {
+			amount = amount.Add(other.Amount)
+		}
+	}
+
+	for _, limit := range p.rules.Limits {
+		if !sameAddress(limit.Asset, withdrawal.Asset) || !appliesTo(limit.Address, withdrawal.From) {
+			continue
+		}
+
+		if limit.PerTransaction.IsPositive() && amount.GreaterThan(limit.PerTransaction) {
+			return RulePerTransaction, ErrLimitExceeded.WithDetails(limitDetails(RulePerTransaction,
+				limit, limit.PerTransaction, withdrawal, amount))
+		}
+	}
+
+	for _, limit := range p.rules.Limits {
+		if !limit.Daily.IsPositive() || !sameAddress(limit.Asset, withdrawal.Asset) ||
+			!appliesTo(limit.Address, withdrawal.From) {
+			continue
+		}
+
+		used := decimal.Zero
+		for _, record := range p.since(now.Add(-day), withdrawal.Hash) {
+			if sameAddress(record.Asset, limit.Asset) && appliesTo(limit.Address, record.From) {
+				used = used.Add(record.Amount)
+			}
+		}
+
+		if used.Add(amount).GreaterThan(limit.Daily) {
+			details := limitDetails(RuleDaily, limit, limit.Daily, withdrawal, amount)
+			details["used"] = used.String()
+			return RuleDaily, ErrLimitExceeded.WithDetails(details)
+		}
+	}
+
+	for _, velocity := range p.rules.Velocity {
+		if velocity.Count < 1 || !appliesTo(velocity.Address, withdrawal.From) {
+			continue
+		}
+
+		count, seen := 0, map[string]bool{}
+		for _, record := range p.since(now.Add(-velocity.Window), withdrawal.Hash) {
+			if !appliesTo(velocity.Address, record.From) || (len(record.Hash) > 0 && seen[record.Hash]) {
+				continue
+			}
+			seen[record.Hash] = true
+			count++
+		}
+
+		if count >= velocity.Count {
+			return RuleVelocity, ErrVelocityExceeded.WithDetails(map[string]interface{}{
+				"rule": RuleVelocity, "address": withdrawal.From, "count": velocity.Count,
+				"window": velocity.Window.String()})
+		}
+	}
+
+	approvers := p.countApprovals(withdrawal.Approvals)
+	for _, approval := range p.rules.Approvals {
+		if !sameAddress(approval.Asset, withdrawal.Asset) || !amount.GreaterThan(approval.Threshold) {
+			continue
+		}
+
+		if approvers < approval.Approvals {
+			return RuleApproval, ErrApprovalRequired.WithDetails(map[string]interface{}{
+				"rule": RuleApproval, "asset": withdrawal.AssetName(),
+				"threshold": approval.Threshold.String(), "amount": amount.String(),
+				"required": approval.Approvals, "approvals": approvers})
+		}
+	}
+	return "", nil
+}
+
+// since returns the allowed withdrawals from start on, but for the withdrawal of hash.
+func (p *Policy) since(start time.Time, hash string) []Withdrawal {
+	withdrawals := []Withdrawal{}
+	for _, record := range p.allowed {
+		if record.time.After(start) && (len(hash) == 0 || record.withdrawal.Hash != hash) {
+			withdrawals = append(withdrawals, record.withdrawal)
+		}
+	}
+	return withdrawals
+}
+
+func (p *Policy) counted(hash string) bool {
+	if len(hash) == 0 {
+		return false
+	}
+
+	for _, record := range p.allowed {
+		if record.withdrawal.Hash == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// prune drops the withdrawals no rule looks at anymore.
+func (p *Policy) prune(now time.Time) {
+	keep := day
+	for _, velocity := range p.rules.Velocity {
+		if velocity.Window > keep {
+			keep = velocity.Window
+		}
+	}
+
+	i := 0
+	for i < len(p.allowed) && !p.allowed[i].time.After(now.Add(-keep)) {
+		i++
+	}
+	p.allowed = p.allowed[i:]
+}
+
+func limitDetails(rule string, limit Limit, value decimal.Decimal, withdrawal Withdrawal,
+	amount decimal.Decimal) map[string]interface{} {
+	details := map[string]interface{}{
+		"rule":   rule,
+		"asset":  withdrawal.AssetName(),
+		"limit":  value.String(),
+		"amount": amount.String(),
+	}
+	if len(limit.Address) > 0 {
+		details["address"] = withdrawal.From
+	}
+	return details
+}
+
+func sameAddress(a, b string) bool {
+	return strings.EqualFold(a, b)
+}
+
+// appliesTo reports whether a rule for address, every address when it is empty, covers from.
+func appliesTo(address, from string) bool {
+	return len(address) == 0 || strings.EqualFold(address, from)
+}
+
+// countApprovals returns how many distinct approvers of the rules are among approvals.
+func (p *Policy) countApprovals(approvals []string) int {
+	set := make(map[string]bool, len(approvals))
+	for _, approver := range approvals {
+		if p.approvers[approver] {
+			set[approver] = true
+		}
+	}
+	return len(set)
+}
@@ -0,0 +1,188 @@
+package policy
+
+import (
+	"demo/apperr"
+	"errors"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+const (
+	testToken  = "0x5FbDB2315678afecb367f032d93F642f64180aa3"
+	owner1Addr = "0xE280029a7867BA5C9154434886c241775ea87e53"
+	owner2Addr = "0x68dB32D26d9529B2a142927c6f1af248fc6Ba7e9"
+)
+
+func newTestPolicy(rules Rules, clock *time.Time) (*Policy, *[]Decision) {
+	decisions := []Decision{}
+	policy := New(rules)
+	policy.now = func() time.Time { return *clock }
+	policy.SetAudit(func(decision Decision) {
+		decisions = append(decisions, decision)
+	})
+	return policy, &decisions
+}
+
+func appErrDetails(t *testing.T, err error) map[string]interface{} {
+	var appErr *apperr.AppErr
+	if !errors.As(err, &appErr) {
+		t.Fatalf("%v is not an AppErr", err)
+	}
+	return appErr.Details
+}
+
+func Test_PolicyDestinations(t *testing.T) {
+	clock := time.Unix(1700000000, 0)
+	policy, decisions := newTestPolicy(Rules{
+		Whitelist: []string{owner2Addr, "0x0000000000000000000000000000000000000Bad"},
+		Blacklist: []string{"0x0000000000000000000000000000000000000bad"},
+	}, &clock)
+
+	assert.NoError(t, policy.Evaluate(Withdrawal{From: owner1Addr, To: owner2Addr, Amount: decimal.NewFromInt(1)}))
+
+	err := policy.Evaluate(Withdrawal{From: owner1Addr, To: "0x0000000000000000000000000000000000000BAD"})
+	assert.ErrorIs(t, err, ErrDestinationNotAllowed)
+	assert.Equal(t, map[string]interface{}{"rule": RuleBlacklist,
+		"destination": "0x0000000000000000000000000000000000000BAD"}, appErrDetails(t, err))
+
+	err = policy.Evaluate(Withdrawal{From: owner1Addr, To: owner1Addr})
+	assert.ErrorIs(t, err, ErrDestinationNotAllowed)
+	assert.Equal(t, RuleWhitelist, appErrDetails(t, err)["rule"])
+
+	assert.Len(t, *decisions, 3)
+	assert.True(t, (*decisions)[0].Allowed)
+	assert.Equal(t, clock, (*decisions)[0].Time)
+	assert.Equal(t, RuleBlacklist, (*decisions)[1].Rule)
+	assert.Equal(t, err, (*decisions)[2].Err)
+}
+
+func Test_Limits(t *testing.T) {
+	clock := time.Unix(1700000000, 0)
+	policy, _ := newTestPolicy(Rules{Limits: []Limit{
+		{Asset: testToken, PerTransaction: decimal.NewFromInt(100), Daily: decimal.NewFromInt(250)},
+		{Address: owner1Addr, Daily: decimal.NewFromInt(2)},
+	}}, &clock)
+
+	err := policy.Evaluate(Withdrawal{From: owner1Addr, To: owner2Addr, Asset: testToken,
+		Amount: decimal.NewFromInt(101)})
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+	assert.Equal(t, map[string]interface{}{"rule": RulePerTransaction, "asset": testToken, "limit": "100",
+		"amount": "101"}, appErrDetails(t, err))
+
+	// the token limit is shared by every sender, and a withdrawal signed again is counted once
+	lowerToken := "0x5fbdb2315678afecb367f032d93f642f64180aa3"
+	assert.NoError(t, policy.Evaluate(Withdrawal{Hash: "0x01", From: owner1Addr, Asset: lowerToken,
+		Amount: decimal.NewFromInt(100)}))
+	assert.NoError(t, policy.Evaluate(Withdrawal{Hash: "0x01", From: owner1Addr, Asset: lowerToken,
+		Amount: decimal.NewFromInt(100)}))
+	assert.NoError(t, policy.Evaluate(Withdrawal{Hash: "0x02", From: owner2Addr, Asset: testToken,
+		Amount: decimal.NewFromInt(100)}))
+	err = policy.Evaluate(Withdrawal{Hash: "0x03", From: owner2Addr, Asset: testToken,
+		Amount: decimal.NewFromInt(51)})
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+	assert.Equal(t, map[string]interface{}{"rule": RuleDaily, "asset": testToken, "limit": "250",
+		"amount": "51", "used": "200"}, appErrDetails(t, err))
+
+	// the ETH limit of owner1 does not cover owner2
+	assert.NoError(t, policy.Evaluate(Withdrawal{From: owner1Addr, Amount: decimal.NewFromFloat(1.5)}))
+	assert.NoError(t, policy.Evaluate(Withdrawal{From: owner2Addr, Amount: decimal.NewFromInt(5)}))
+	err = policy.Evaluate(Withdrawal{From: owner1Addr, Amount: decimal.NewFromInt(1)})
+	assert.Equal(t, map[string]interface{}{"rule": RuleDaily, "asset": "ETH", "address": owner1Addr,
+		"limit": "2", "amount": "1", "used": "1.5"}, appErrDetails(t, err))
+
+	clock = clock.Add(day)
+	assert.NoError(t, policy.Evaluate(Withdrawal{From: owner1Addr, Amount: decimal.NewFromInt(1)}))
+	assert.NoError(t, policy.Evaluate(Withdrawal{From: owner2Addr, Asset: testToken,
+		Amount: decimal.NewFromInt(100)}))
+	assert.Len(t, policy.allowed, 2, "the withdrawals of the day before are dropped")
+}
+
+func Test_Velocity(t *testing.T) {
+	clock := time.Unix(1700000000, 0)
+	policy, _ := newTestPolicy(Rules{Velocity: []Velocity{
+		{Address: owner1Addr, Count: 2, Window: time.Minute},
+		{Count: 3, Window: time.Hour},
+	}}, &clock)
+
+	assert.NoError(t, policy.Evaluate(Withdrawal{From: owner1Addr}))
+	assert.NoError(t, policy.Evaluate(Withdrawal{From: owner1Addr}))
+	err := policy.Evaluate(Withdrawal{From: owner1Addr})
+	assert.ErrorIs(t, err, ErrVelocityExceeded)
+	assert.Equal(t, map[string]interface{}{"rule": RuleVelocity, "address": owner1Addr, "count": 2,
+		"window": "1m0s"}, appErrDetails(t, err))
+
+	clock = clock.Add(time.Minute)
+	assert.NoError(t, policy.Evaluate(Withdrawal{From: owner2Addr}))
+	assert.ErrorIs(t, policy.Evaluate(Withdrawal{From: owner1Addr}), ErrVelocityExceeded, "3 withdrawals an hour")
+}
+
+func Test_Approvals(t *testing.T) {
+	clock := time.Unix(1700000000, 0)
+	policy, decisions := newTestPolicy(Rules{Approvals: []Approval{
+		{Threshold: decimal.NewFromInt(10), Approvals: 1},
+		{Threshold: decimal.NewFromInt(100), Approvals: 2},
+	}, Approvers: []string{"alice", "bob"}}, &clock)
+
+	assert.NoError(t, policy.Evaluate(Withdrawal{From: owner1Addr, Amount: decimal.NewFromInt(10)}))
+	assert.NoError(t, policy.Evaluate(Withdrawal{From: owner1Addr, Asset: testToken,
+		Amount: decimal.NewFromInt(1000)}), "the rules are for ETH")
+
+	err := policy.Evaluate(Withdrawal{From: owner1Addr, Amount: decimal.NewFromInt(101), Approvals: []string{"alice",
+		"alice"}})
+	assert.ErrorIs(t, err, ErrApprovalRequired)
+	assert.Equal(t, map[string]interface{}{"rule": RuleApproval, "asset": "ETH", "threshold": "100",
+		"amount": "101", "required": 2, "approvals": 1}, appErrDetails(t, err))
+	assert.Equal(t, []string{"alice", "alice"}, (*decisions)[2].Withdrawal.Approvals)
+
+	err = policy.Evaluate(Withdrawal{From: owner1Addr, Amount: decimal.NewFromInt(101),
+		Approvals: []string{"alice", "mallory"}})
+	assert.Equal(t, 1, appErrDetails(t, err)["approvals"], "only the approvers of the rules count")
+	assert.NoError(t, policy.Evaluate(Withdrawal{From: owner1Addr, Amount: decimal.NewFromInt(101),
+		Approvals: []string{"alice", "bob"}}))
+}
+
+func Test_PolicyTransaction(t *testing.T) {
+	clock := time.Unix(1700000000, 0)
+	policy, decisions := newTestPolicy(Rules{
+		Limits:    []Limit{{PerTransaction: decimal.NewFromInt(10), Daily: decimal.NewFromInt(15)}},
+		Blacklist: []string{"0x0000000000000000000000000000000000000bad"},
+		Velocity:  []Velocity{{Count: 2, Window: time.Hour}},
+	}, &clock)
+
+	// a payment split over several outputs is one withdrawal
+	err := policy.Evaluate(Withdrawal{Hash: "0x01", From: owner1Addr, To: owner2Addr, Amount: decimal.NewFromInt(6)},
+		Withdrawal{Hash: "0x01", From: owner1Addr, To: owner2Addr, Amount: decimal.NewFromInt(6)})
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+	assert.Equal(t, "12", appErrDetails(t, err)["amount"])
+	assert.Equal(t, "12", (*decisions)[0].Withdrawal.Amount.String())
+
+	// and so are the withdrawals to several destinations
+	err = policy.Evaluate(Withdrawal{Hash: "0x02", From: owner1Addr, To: owner2Addr, Amount: decimal.NewFromInt(6)},
+		Withdrawal{Hash: "0x02", From: owner1Addr, To: owner1Addr, Amount: decimal.NewFromInt(6)})
+	assert.Equal(t, RulePerTransaction, appErrDetails(t, err)["rule"])
+
+	// nothing is counted when one of them is refused
+	err = policy.Evaluate(Withdrawal{Hash: "0x03", From: owner1Addr, To: owner2Addr, Amount: decimal.NewFromInt(5)},
+		Withdrawal{Hash: "0x03", From: owner1Addr, To: "0x0000000000000000000000000000000000000BAD",
+			Amount: decimal.NewFromInt(1)})
+	assert.ErrorIs(t, err, ErrDestinationNotAllowed)
+	assert.Empty(t, policy.allowed)
+
+	assert.NoError(t, policy.Evaluate(
+		Withdrawal{Hash: "0x04", From: owner1Addr, To: owner2Addr, Amount: decimal.NewFromInt(5)},
+		Withdrawal{Hash: "0x04", From: owner1Addr, To: owner1Addr, Amount: decimal.NewFromInt(5)}))
+	assert.Len(t, policy.allowed, 2)
+	assert.Len(t, *decisions, 5)
+	assert.True(t, (*decisions)[3].Allowed && (*decisions)[4].Allowed)
+
+	// the transaction counts once for the velocity, and its total for the daily limit
+	err = policy.Evaluate(Withdrawal{Hash: "0x05", From: owner1Addr, To: owner2Addr, Amount: decimal.NewFromInt(6)})
+	assert.Equal(t, map[string]interface{}{"rule": RuleDaily, "asset": "ETH", "limit": "15", "amount": "6",
+		"used": "10"}, appErrDetails(t, err))
+	assert.NoError(t, policy.Evaluate(Withdrawal{Hash: "0x05", From: owner1Addr, To: owner2Addr,
+		Amount: decimal.NewFromInt(5)}))
+	err = policy.Evaluate(Withdrawal{Hash: "0x06", From: owner1Addr, To: owner2Addr})
+	assert.ErrorIs(t, err, ErrVelocityExceeded)
+}
@@ -0,0 +1,249 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"demo/amount"
+	"demo/api"
+	"demo/btc"
+	"encoding/hex"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/shopspring/decimal"
+	"math/big"
+)
+
+type btcServer struct {
+	api.UnimplementedBtcServiceServer
+	svc btc.Server
+}
+
+func (s *btcServer) ValidateAddress(ctx context.Context, req *api.ValidateAddressRequest) (*api.ValidateAddressResponse, error) {
+	return &api.ValidateAddressResponse{Valid: s.svc.ValidateAddress(ctx, req.Address)}, nil
+}
+
+func (s *btcServer) AddressFromPublicKey(ctx context.Context, req *api.PublicKeyRequest) (*api.AddressResponse, error) {
+	if !validPublicKey(req.PublicKey) {
+		return nil, invalidInput("public_key")
+	}
+
+	address, err := s.svc.CreateAddressByPubKey(ctx, req.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &api.AddressResponse{Address: address}, nil
+}
+
+func (s *btcServer) BlockHeight(ctx context.Context, req *api.BlockHeightRequest) (*api.BlockHeightResponse, error) {
+	height, err := s.svc.CurrentBlockHeight(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &api.BlockHeightResponse{Height: uint64(height)}, nil
+}
+
+// Block groups the outputs btc.Service returns for a block by transaction.
+func (s *btcServer) Block(ctx context.Context, req *api.BlockRequest) (*api.BtcBlock, error) {
+	block, err := s.svc.Block(ctx, int64(req.Height))
+	if err != nil {
+		return nil, err
+	}
+	return btcBlock(req.Height, block), nil
+}
+
+// ListBlocks returns the blocks of the page without their transactions, use ListTransactions for them.
+func (s *btcServer) ListBlocks(ctx context.Context, req *api.ListBlocksRequest) (*api.BtcBlockList, error) {
+	height, err := s.svc.CurrentBlockHeight(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := blockPage(req.PageToken, req.PageSize, uint64(height))
+	if err != nil {
+		return nil, err
+	}
+
+	list := api.BtcBlockList{NextPageToken: p.nextBlock()}
+	for i := uint64(0); i < p.size; i++ {
+		block, err := s.Block(ctx, &api.BlockRequest{Height: p.start - i})
+		if err != nil {
+			return nil, err
+		}
+		block.Transactions = nil
+		list.Blocks = append(list.Blocks, block)
+	}
+	return &list, nil
+}
+
+func (s *btcServer) ListTransactions(ctx context.Context, req *api.ListTransactionsRequest) (*api.BtcTransactionList, error) {
+	block, err := s.Block(ctx, &api.BlockRequest{Height: req.Height})
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := itemPage(req.PageToken, req.PageSize, len(block.Transactions))
+	if err != nil {
+		return nil, err
+	}
+	return &api.BtcTransactionList{
+		Transactions:  block.Transactions[p.start : p.start+p.size],
+		NextPageToken: p.nextItem(len(block.Transactions)),
+	}, nil
+}
+
+func (s *btcServer) Transaction(ctx context.Context, req *api.TransactionRequest) (*api.BtcTransaction, error) {
+	if _, err := hex.DecodeString(req.Id); err != nil || len(req.Id) != 64 {
+		return nil, invalidInput("id")
+	}
+
+	transaction, err := s.svc.Transaction(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return btcTransaction(transaction)
+}
+
+func (s *btcServer) CreateTransaction(ctx context.Context, req *api.BtcCreateTransactionRequest) (*api.UnsignedTransaction, error) {
+	if !s.svc.ValidateAddress(ctx, req.From) {
+		return nil, invalidInput("from")
+	}
+
+	if !s.svc.ValidateAddress(ctx, req.To) {
+		return nil, invalidInput("to")
+	}
+
+	value, err := decimal.NewFromString(req.Amount)
+	if err != nil || !value.IsPositive() {
+		return nil, invalidInput("amount")
+	}
+
+	fee, err := decimal.NewFromString(req.Fee)
+	if err != nil || fee.IsNegative() {
+		return nil, invalidInput("fee")
+	}
+
+	if req.Utxo == nil {
+		return nil, invalidInput("utxo")
+	}
+
+	utxoValue, err := decimal.NewFromString(req.Utxo.Value)
+	if err != nil {
+		return nil, invalidInput("utxo.value")
+	}
+
+	envelope, err := s.svc.ExportTx(ctx, req.From, req.To, value, fee, btc.TransactionOutPut{
+		TXId:  req.Utxo.TxId,
+		Value: utxoValue,
+		N:     req.Utxo.N,
+		Hex:   req.Utxo.Script,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &api.UnsignedTransaction{Psbt: envelope.PSBT, Summary: envelope.Summary}, nil
+}
+
+func (s *btcServer) SignTransaction(ctx context.Context, req *api.BtcSignTransactionRequest) (*api.SignedTransaction, error) {
+	if _, err := btcutil.DecodeWIF(req.Wif); err != nil {
+		return nil, invalidInput("wif")
+	}
+
+	signedTx, err := s.svc.SignPSBT(ctx, req.Psbt, req.Wif)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := decodeBTCTransaction(signedTx)
+	if err != nil {
+		return nil, err
+	}
+	return &api.SignedTransaction{RawTx: signedTx, Id: tx.TxHash().String()}, nil
+}
+
+func (s *btcServer) Broadcast(ctx context.Context, req *api.BroadcastRequest) (*api.BroadcastResponse, error) {
+	tx, err := decodeBTCTransaction(req.RawTx)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := s.svc.BroadcastTx(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	return &api.BroadcastResponse{Id: id}, nil
+}
+
+func decodeBTCTransaction(rawTx string) (*wire.MsgTx, error) {
+	data, err := hex.DecodeString(rawTx)
+	if err != nil {
+		return nil, invalidInput("raw_tx")
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	if err = tx.Deserialize(bytes.NewReader(data)); err != nil {
+		return nil, invalidInput("raw_tx")
+	}
+	return tx, nil
+}
+
+func btcBlock(height uint64, block *btc.Block) *api.BtcBlock {
+	result := api.BtcBlock{
+		Height: height,
+		Hash:   block.Block.BlockHash().String(),
+		Time:   block.Block.Header.Timestamp.Unix(),
+	}
+
+	transactions := map[string]*api.BtcTransaction{}
+	for _, output := range block.Transactions {
+		transaction, ok := transactions[output.TxId]
+		if !ok {
+			transaction = &api.BtcTransaction{
+				Id:            output.TxId,
+				BlockHeight:   height,
+				BlockHash:     result.Hash,
+				Time:          output.BlockTime,
+				State:         api.TransactionState(output.State),
+				Fee:           output.Fee.String(),
+				Confirmations: output.Confirmations,
+			}
+			transactions[output.TxId] = transaction
+			result.Transactions = append(result.Transactions, transaction)
+		}
+		transaction.Outputs = append(transaction.Outputs, &api.BtcOutput{
+			N:         output.N,
+			Value:     output.Value.String(),
+			Addresses: output.Address,
+			Type:      output.Type,
+			Script:    output.Hex,
+		})
+	}
+	return &result
+}
+
+func btcTransaction(transaction *btc.RawTransactionInfo) (*api.BtcTransaction, error) {
+	result := api.BtcTransaction{
+		Id:            transaction.Result.Txid,
+		BlockHeight:   uint64(transaction.BlockHeight),
+		BlockHash:     transaction.Result.BlockHash,
+		Time:          transaction.Result.Blocktime,
+		State:         api.TransactionState(transaction.State),
+		Fee:           transaction.Fee.String(),
+		Confirmations: transaction.Result.Confirmations,
+	}
+
+	for _, output := range transaction.Result.Vout {
+		value, err := btcutil.NewAmount(output.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Outputs = append(result.Outputs, &api.BtcOutput{
+			N:         output.N,
+			Value:     amount.FromBase(big.NewInt(int64(value)), amount.Bitcoin).String(),
+			Addresses: output.ScriptPubKey.Addresses,
+			Type:      output.ScriptPubKey.Type,
+			Script:    output.ScriptPubKey.Hex,
+		})
+	}
+	return &result, nil
+}
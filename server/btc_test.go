@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"demo/api"
+	"demo/btc"
+	"encoding/hex"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+func Test_BtcServiceTransfer(t *testing.T) {
+	ctx := context.Background()
+	btcSvc := getBTCService()
+	svc, _ := getSimulatedService(t)
+	client := api.NewBtcServiceClient(dialGRPC(t, svc, btcSvc))
+	wif, from, utxo := getBTCKey(t)
+	to := "mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn"
+
+	valid, err := client.ValidateAddress(ctx, &api.ValidateAddressRequest{Address: to})
+	assert.NoError(t, err)
+	assert.True(t, valid.Valid)
+
+	decodeWIF, _ := btcutil.DecodeWIF(wif)
+	address, err := client.AddressFromPublicKey(ctx, &api.PublicKeyRequest{PublicKey: "0x" + hex.EncodeToString(decodeWIF.SerializePubKey())})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, address.Address)
+
+	unsigned, err := client.CreateTransaction(ctx, &api.BtcCreateTransactionRequest{From: from, To: to, Amount: "0.004", Fee: "0.0001", Utxo: utxo})
+	assert.NoError(t, err)
+	assert.Contains(t, unsigned.Summary, "change 0.0059 BTC")
+
+	signed, err := client.SignTransaction(ctx, &api.BtcSignTransactionRequest{Psbt: unsigned.Psbt, Wif: wif})
+	assert.NoError(t, err)
+	decoded, err := btcSvc.DecodeRawTx(ctx, signed.RawTx)
+	assert.NoError(t, err)
+	assert.Equal(t, decoded.TxId, signed.Id)
+	assert.Equal(t, []string{to}, decoded.Outputs[0].Addresses)
+}
+
+func Test_BtcServiceValidation(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := getSimulatedService(t)
+	client := api.NewBtcServiceClient(dialGRPC(t, svc, getBTCService()))
+	wif, from, utxo := getBTCKey(t)
+	to := "mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn"
+
+	requests := map[string]*api.BtcCreateTransactionRequest{
+		"from":       {From: "1", To: to, Amount: "0.004", Fee: "0.0001", Utxo: utxo},
+		"to":         {From: from, To: "0x68dB32D26d9529B2a142927c6f1af248fc6Ba7e9", Amount: "0.004", Fee: "0.0001", Utxo: utxo},
+		"amount":     {From: from, To: to, Amount: "0", Fee: "0.0001", Utxo: utxo},
+		"fee":        {From: from, To: to, Amount: "0.004", Fee: "", Utxo: utxo},
+		"utxo":       {From: from, To: to, Amount: "0.004", Fee: "0.0001"},
+		"utxo.value": {From: from, To: to, Amount: "0.004", Fee: "0.0001", Utxo: &api.Utxo{TxId: utxo.TxId, Script: utxo.Script}},
+	}
+	for field, request := range requests {
+		_, err := client.CreateTransaction(ctx, request)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Equal(t, field+" is invalid", status.Convert(err).Message())
+	}
+
+	_, err := client.CreateTransaction(ctx, &api.BtcCreateTransactionRequest{From: from, To: to, Amount: "0.000000001", Fee: "0.0001", Utxo: utxo})
+	assert.Equal(t, "TOO_MANY_DECIMALS", errorReason(t, err))
+
+	_, err = client.SignTransaction(ctx, &api.BtcSignTransactionRequest{Psbt: "cHNidP8=", Wif: "key"})
+	assert.Equal(t, "wif is invalid", status.Convert(err).Message())
+	_, err = client.SignTransaction(ctx, &api.BtcSignTransactionRequest{Psbt: "cHNidP8=", Wif: wif})
+	assert.Equal(t, codes.Unknown, status.Code(err))
+	_, err = client.AddressFromPublicKey(ctx, &api.PublicKeyRequest{PublicKey: "0x02"})
+	assert.Equal(t, "public_key is invalid", status.Convert(err).Message())
+	_, err = client.Broadcast(ctx, &api.BroadcastRequest{RawTx: "zz"})
+	assert.Equal(t, "raw_tx is invalid", status.Convert(err).Message())
+	_, err = client.Transaction(ctx, &api.TransactionRequest{Id: "abc"})
+	assert.Equal(t, "id is invalid", status.Convert(err).Message())
+}
+
+func Test_BtcBlock(t *testing.T) {
+	msgBlock := wire.NewMsgBlock(&wire.BlockHeader{Timestamp: time.Unix(1600000000, 0)})
+	block := btcBlock(9, &btc.Block{
+		Block: msgBlock,
+		Transactions: []*btc.TransactionInfo{
+			{TxId: "a", N: 0, Value: decimal.RequireFromString("1"), Address: []string{"x"}, Confirmations: 3},
+			{TxId: "b", N: 0, Value: decimal.RequireFromString("2")},
+			{TxId: "a", N: 1, Value: decimal.RequireFromString("0.5"), Address: []string{"y"}, Confirmations: 3},
+		},
+	})
+	assert.Equal(t, int64(1600000000), block.Time)
+	assert.Len(t, block.Transactions, 2)
+	assert.Equal(t, uint64(3), block.Transactions[0].Confirmations)
+	assert.Len(t, block.Transactions[0].Outputs, 2)
+	assert.Equal(t, "0.5", block.Transactions[0].Outputs[1].Value)
+	assert.Equal(t, []string{"y"}, block.Transactions[0].Outputs[1].Addresses)
+
+	transaction, err := btcTransaction(&btc.RawTransactionInfo{
+		State: btc.TransactionSatePending,
+		Fee:   decimal.RequireFromString("0.0001"),
+		Result: &btcjson.TxRawResult{
+			Txid: "c",
+			Vout: []btcjson.Vout{{Value: 0.0059, N: 1, ScriptPubKey: btcjson.ScriptPubKeyResult{Type: "pubkeyhash", Addresses: []string{"z"}}}},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, api.TransactionState_TRANSACTION_STATE_PENDING, transaction.State)
+	assert.Equal(t, "0.0001", transaction.Fee)
+	assert.Equal(t, "0.0059", transaction.Outputs[0].Value)
+	assert.Equal(t, "pubkeyhash", transaction.Outputs[0].Type)
+}
+
+// getBTCKey returns a testnet WIF, its P2PKH address and an output of 0.01 BTC it can spend.
+func getBTCKey(t *testing.T) (string, string, *api.Utxo) {
+	wif, prevTx := btcTestKey()
+	address, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(wif.SerializePubKey()), &chaincfg.TestNet3Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return wif.String(), address.EncodeAddress(), &api.Utxo{
+		TxId:   prevTx.TxHash().String(),
+		N:      1,
+		Value:  "0.01",
+		Script: hex.EncodeToString(prevTx.TxOut[1].PkScript),
+	}
+}
+
+// btcTestKey returns the key of getBTCKey and the transaction that paid 0.01 BTC to it at output 1.
+func btcTestKey() (*btcutil.WIF, *wire.MsgTx) {
+	keyByte, _ := hex.DecodeString("f1b3f8e0d52caec13491368449ab8d90f3d222a3e485aa7f02591bbceb5efba5")
+	privateKey, _ := btcec.PrivKeyFromBytes(btcec.S256(), keyByte)
+	wif, _ := btcutil.NewWIF(privateKey, &chaincfg.TestNet3Params, true)
+	address, _ := btcutil.NewAddressPubKeyHash(btcutil.Hash160(wif.SerializePubKey()), &chaincfg.TestNet3Params)
+	pkScript, _ := txscript.PayToAddrScript(address)
+
+	prevTx := btc.NewTx()
+	prevTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil))
+	prevTx.AddTxOut(wire.NewTxOut(5000, pkScript))
+	prevTx.AddTxOut(wire.NewTxOut(1000000, pkScript))
+	return wif, prevTx
+}
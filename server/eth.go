@@ -0,0 +1,298 @@
+package server
+
+import (
+	"context"
+	"demo/api"
+	"demo/eth"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/shopspring/decimal"
+	"math/big"
+)
+
+const ethTransferGasLimit = 21000
+
+type ethServer struct {
+	api.UnimplementedEthServiceServer
+	svc eth.Server
+}
+
+func (s *ethServer) CreateAddress(ctx context.Context, req *api.CreateAddressRequest) (*api.AddressResponse, error) {
+	address, err := s.svc.CreateAddress(ctx, req.Mnemonic, req.Index)
+	if err != nil {
+		return nil, err
+	}
+	return &api.AddressResponse{Address: address}, nil
+}
+
+func (s *ethServer) AddressFromPublicKey(ctx context.Context, req *api.PublicKeyRequest) (*api.AddressResponse, error) {
+	if !validPublicKey(req.PublicKey) {
+		return nil, invalidInput("public_key")
+	}
+
+	address, err := s.svc.CreateAddressByPubKey(ctx, req.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &api.AddressResponse{Address: address}, nil
+}
+
+func (s *ethServer) Balance(ctx context.Context, req *api.EthBalanceRequest) (*api.BalanceResponse, error) {
+	if !common.IsHexAddress(req.Address) {
+		return nil, invalidInput("address")
+	}
+
+	var balance *decimal.Decimal
+	var err error
+	if len(req.TokenAddress) > 0 {
+		if !common.IsHexAddress(req.TokenAddress) {
+			return nil, invalidInput("token_address")
+		}
+		balance, err = s.svc.BalanceERC20(ctx, req.TokenAddress, req.Address)
+	} else {
+		balance, err = s.svc.BalanceETH(ctx, req.Address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &api.BalanceResponse{Amount: balance.String()}, nil
+}
+
+func (s *ethServer) TokenInfo(ctx context.Context, req *api.TokenInfoRequest) (*api.TokenInfoResponse, error) {
+	if !common.IsHexAddress(req.ContractAddress) {
+		return nil, invalidInput("contract_address")
+	}
+
+	info, err := s.svc.ERC20Info(ctx, req.ContractAddress)
+	if err != nil {
+		return nil, err
+	}
+	return &api.TokenInfoResponse{
+		ContractAddress: info.ContractAddress,
+		Name:            info.Name,
+		Symbol:          info.Symbol,
+		Decimals:        uint32(info.Decimals),
+		TotalSupply:     info.TotalSupply,
+	}, nil
+}
+
+func (s *ethServer) SuggestGasPrice(ctx context.Context, req *api.SuggestGasPriceRequest) (*api.GasPrice, error) {
+	gasPrice, err := s.svc.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &api.GasPrice{GasPrice: gasPrice.String()}, nil
+}
+
+func (s *ethServer) Nonce(ctx context.Context, req *api.NonceRequest) (*api.NonceResponse, error) {
+	if !common.IsHexAddress(req.Address) {
+		return nil, invalidInput("address")
+	}
+
+	nonce, err := s.svc.Nonce(ctx, req.Address)
+	if err != nil {
+		return nil, err
+	}
+	return &api.NonceResponse{Nonce: nonce}, nil
+}
+
+func (s *ethServer) BlockHeight(ctx context.Context, req *api.BlockHeightRequest) (*api.BlockHeightResponse, error) {
+	height, err := s.svc.CurrentBlockHeight(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &api.BlockHeightResponse{Height: height}, nil
+}
+
+func (s *ethServer) Block(ctx context.Context, req *api.BlockRequest) (*api.EthBlock, error) {
+	blockInfo, err := s.svc.Block(ctx, req.Height)
+	if err != nil {
+		return nil, err
+	}
+
+	block := api.EthBlock{Height: blockInfo.BlockNumber, Hash: blockInfo.Hash, Time: blockInfo.Time.Unix()}
+	for _, txInfo := range blockInfo.Transactions {
+		block.Transactions = append(block.Transactions, ethTransaction(txInfo))
+	}
+	return &block, nil
+}
+
+// ListBlocks reads only the headers of the page, use ListTransactions for the transactions.
+func (s *ethServer) ListBlocks(ctx context.Context, req *api.ListBlocksRequest) (*api.EthBlockList, error) {
+	height, err := s.svc.CurrentBlockHeight(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := blockPage(req.PageToken, req.PageSize, height)
+	if err != nil {
+		return nil, err
+	}
+
+	list := api.EthBlockList{NextPageToken: p.nextBlock()}
+	for i := uint64(0); i < p.size; i++ {
+		header, err := s.svc.Backend().HeaderByNumber(ctx, new(big.Int).SetUint64(p.start-i))
+		if err != nil {
+			return nil, err
+		}
+		list.Blocks = append(list.Blocks, &api.EthBlock{Height: header.Number.Uint64(), Hash: header.Hash().Hex(), Time: int64(header.Time)})
+	}
+	return &list, nil
+}
+
+func (s *ethServer) ListTransactions(ctx context.Context, req *api.ListTransactionsRequest) (*api.EthTransactionList, error) {
+	block, err := s.Block(ctx, &api.BlockRequest{Height: req.Height})
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := itemPage(req.PageToken, req.PageSize, len(block.Transactions))
+	if err != nil {
+		return nil, err
+	}
+	return &api.EthTransactionList{
+		Transactions:  block.Transactions[p.start : p.start+p.size],
+		NextPageToken: p.nextItem(len(block.Transactions)),
+	}, nil
+}
+
+func (s *ethServer) Transaction(ctx context.Context, req *api.TransactionRequest) (*api.EthTransaction, error) {
+	if _, err := hexutil.Decode(req.Id); err != nil || len(req.Id) != 66 {
+		return nil, invalidInput("id")
+	}
+
+	txInfo, err := s.svc.Transaction(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return ethTransaction(txInfo), nil
+}
+
+// CreateTransaction fills in what the request leaves out, the nonce, the gas price and the gas limit of ETH
+// transfers, and returns the unsigned transaction with its summary.
+func (s *ethServer) CreateTransaction(ctx context.Context, req *api.EthCreateTransactionRequest) (*api.UnsignedTransaction, error) {
+	if !common.IsHexAddress(req.From) {
+		return nil, invalidInput("from")
+	}
+
+	if !common.IsHexAddress(req.To) {
+		return nil, invalidInput("to")
+	}
+
+	if len(req.TokenAddress) > 0 && !common.IsHexAddress(req.TokenAddress) {
+		return nil, invalidInput("token_address")
+	}
+
+	if value, err := decimal.NewFromString(req.Amount); err != nil || value.IsNegative() {
+		return nil, invalidInput("amount")
+	}
+
+	if req.TxType > types.DynamicFeeTxType {
+		return nil, invalidInput("tx_type")
+	}
+
+	request := eth.CreateTransactionRequest{
+		TokenAddress: req.TokenAddress,
+		From:         req.From,
+		To:           req.To,
+		Amount:       req.Amount,
+		GasLimit:     req.GasLimit,
+		GasMaxFee:    req.GasMaxFee,
+		GasTip:       req.GasTip,
+		TxType:       uint8(req.TxType),
+	}
+
+	if len(request.GasMaxFee) == 0 {
+		gasPrice, err := s.svc.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, err
+		}
+		request.GasMaxFee = gasPrice.String()
+	} else if _, err := decimal.NewFromString(request.GasMaxFee); err != nil {
+		return nil, invalidInput("gas_max_fee")
+	}
+
+	if request.GasLimit == 0 && len(request.TokenAddress) == 0 {
+		request.GasLimit = ethTransferGasLimit
+	}
+
+	if req.Nonce != nil {
+		request.Nonce = *req.Nonce
+	} else {
+		nonce, err := s.svc.Nonce(ctx, req.From)
+		if err != nil {
+			return nil, err
+		}
+		request.Nonce = nonce
+	}
+
+	envelope, err := s.svc.ExportTransaction(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return &api.UnsignedTransaction{RawTx: envelope.UnsignedTx, Summary: envelope.Summary}, nil
+}
+
+func (s *ethServer) SignTransaction(ctx context.Context, req *api.EthSignTransactionRequest) (*api.SignedTransaction, error) {
+	tx, err := decodeETHTransaction(req.RawTx)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = eth.ParsePrivateKey(req.PrivateKey); err != nil {
+		return nil, invalidInput("private_key")
+	}
+
+	signedTx, err := s.svc.SignTransaction(ctx, tx, req.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := signedTx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &api.SignedTransaction{RawTx: hexutil.Encode(data), Id: signedTx.Hash().Hex()}, nil
+}
+
+func (s *ethServer) Broadcast(ctx context.Context, req *api.BroadcastRequest) (*api.BroadcastResponse, error) {
+	tx, err := decodeETHTransaction(req.RawTx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.svc.Broadcast(ctx, tx); err != nil {
+		return nil, err
+	}
+	return &api.BroadcastResponse{Id: tx.Hash().Hex()}, nil
+}
+
+func decodeETHTransaction(rawTx string) (*types.Transaction, error) {
+	data, err := hexutil.Decode(rawTx)
+	if err != nil {
+		return nil, invalidInput("raw_tx")
+	}
+
+	var tx types.Transaction
+	if err = tx.UnmarshalBinary(data); err != nil {
+		return nil, invalidInput("raw_tx")
+	}
+	return &tx, nil
+}
+
+func ethTransaction(txInfo *eth.TransactionInfo) *api.EthTransaction {
+	return &api.EthTransaction{
+		Id:           txInfo.ID,
+		BlockHeight:  txInfo.BlockNumber,
+		Time:         unixTime(txInfo.Time),
+		From:         txInfo.From,
+		To:           txInfo.To,
+		TokenAddress: txInfo.TokenAddress,
+		Amount:       txInfo.Amount.String(),
+		State:        api.TransactionState(txInfo.State),
+		Fee:          txInfo.Fee.String(),
+		L1Fee:        txInfo.L1Fee.String(),
+		RevertReason: txInfo.RevertReason,
+	}
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"demo/api"
+	"demo/policy"
+	"demo/token"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func Test_EthServiceTransfer(t *testing.T) {
+	ctx := context.Background()
+	svc, backend := getSimulatedService(t)
+	client := api.NewEthServiceClient(dialGRPC(t, svc, getBTCService()))
+
+	unsigned, err := client.CreateTransaction(ctx, &api.EthCreateTransactionRequest{From: owner1Addr, To: owner2Addr, Amount: "1.25"})
+	assert.NoError(t, err)
+	assert.Contains(t, unsigned.Summary, "send 1.25 ETH")
+
+	signed, err := client.SignTransaction(ctx, &api.EthSignTransactionRequest{RawTx: unsigned.RawTx, PrivateKey: owner1PrivateKey})
+	assert.NoError(t, err)
+	broadcast, err := client.Broadcast(ctx, &api.BroadcastRequest{RawTx: signed.RawTx})
+	assert.NoError(t, err)
+	assert.Equal(t, signed.Id, broadcast.Id)
+	backend.Commit()
+	backend.Commit()
+
+	balance, err := client.Balance(ctx, &api.EthBalanceRequest{Address: owner2Addr})
+	assert.NoError(t, err)
+	assert.Equal(t, "101.25", balance.Amount)
+
+	nonce, err := client.Nonce(ctx, &api.NonceRequest{Address: owner1Addr})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), nonce.Nonce)
+
+	height, err := client.BlockHeight(ctx, &api.BlockHeightRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), height.Height)
+
+	transaction, err := client.Transaction(ctx, &api.TransactionRequest{Id: broadcast.Id})
+	assert.NoError(t, err)
+	assert.Equal(t, api.TransactionState_TRANSACTION_STATE_SUCCESS, transaction.State)
+	assert.Equal(t, "1.25", transaction.Amount)
+	assert.True(t, strings.EqualFold(owner1Addr, transaction.From))
+	assert.Equal(t, uint64(1), transaction.BlockHeight)
+
+	block, err := client.Block(ctx, &api.BlockRequest{Height: 1})
+	assert.NoError(t, err)
+	assert.Len(t, block.Transactions, 1)
+	assert.Equal(t, broadcast.Id, block.Transactions[0].Id)
+	assert.NotZero(t, block.Transactions[0].Time)
+}
+
+func Test_EthServiceToken(t *testing.T) {
+	ctx := context.Background()
+	svc, backend := getSimulatedService(t)
+	client := api.NewEthServiceClient(dialGRPC(t, svc, getBTCService()))
+
+	privateKey, _ := crypto.HexToECDSA(owner1PrivateKey[2:])
+	auth, _ := bind.NewKeyedTransactorWithChainID(privateKey, big.NewInt(1337))
+	tokenAddress, _, _, err := token.DeployToken(auth, backend, "gavin", "GV")
+	assert.NoError(t, err)
+	backend.Commit()
+
+	info, err := client.TokenInfo(ctx, &api.TokenInfoRequest{ContractAddress: tokenAddress.Hex()})
+	assert.NoError(t, err)
+	assert.Equal(t, "GV", info.Symbol)
+	assert.Equal(t, uint32(18), info.Decimals)
+
+	balance, err := client.Balance(ctx, &api.EthBalanceRequest{Address: owner1Addr, TokenAddress: tokenAddress.Hex()})
+	assert.NoError(t, err)
+	assert.Equal(t, "100", balance.Amount)
+
+	_, err = client.CreateTransaction(ctx, &api.EthCreateTransactionRequest{
+		From: owner1Addr, To: owner2Addr, TokenAddress: tokenAddress.Hex(), Amount: "0.0000000000000000001",
+	})
+	assert.Equal(t, "TOO_MANY_DECIMALS", errorReason(t, err))
+}
+
+func Test_EthServiceValidation(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := getSimulatedService(t)
+	client := api.NewEthServiceClient(dialGRPC(t, svc, getBTCService()))
+
+	_, err := client.Balance(ctx, &api.EthBalanceRequest{Address: "0x1234"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "INVALID_INPUT", errorReason(t, err))
+
+	requests := map[string]*api.EthCreateTransactionRequest{
+		"from":        {From: "owner1", To: owner2Addr, Amount: "1"},
+		"to":          {From: owner1Addr, Amount: "1"},
+		"amount":      {From: owner1Addr, To: owner2Addr, Amount: "-1"},
+		"tx_type":     {From: owner1Addr, To: owner2Addr, Amount: "1", TxType: 3},
+		"gas_max_fee": {From: owner1Addr, To: owner2Addr, Amount: "1", GasMaxFee: "cheap"},
+	}
+	for field, request := range requests {
+		_, err = client.CreateTransaction(ctx, request)
+		assert.Equal(t, field+" is invalid", status.Convert(err).Message())
+	}
+
+	_, err = client.SignTransaction(ctx, &api.EthSignTransactionRequest{RawTx: "0x02", PrivateKey: owner1PrivateKey})
+	assert.Equal(t, "raw_tx is invalid", status.Convert(err).Message())
+	_, err = client.AddressFromPublicKey(ctx, &api.PublicKeyRequest{PublicKey: "0x02"})
+	assert.Equal(t, "public_key is invalid", status.Convert(err).Message())
+
+	unsigned, err := client.CreateTransaction(ctx, &api.EthCreateTransactionRequest{From: owner1Addr, To: owner2Addr, Amount: "2"})
+	assert.NoError(t, err)
+	_, err = client.SignTransaction(ctx, &api.EthSignTransactionRequest{RawTx: unsigned.RawTx, PrivateKey: "0x12"})
+	assert.Equal(t, "private_key is invalid", status.Convert(err).Message())
+
+	// refusals of the withdrawal policy keep their code and details
+	svc.SetPolicy(policy.New(policy.Rules{Limits: []policy.Limit{{PerTransaction: decimal.NewFromInt(1)}}}))
+	_, err = client.SignTransaction(ctx, &api.EthSignTransactionRequest{RawTx: unsigned.RawTx, PrivateKey: owner1PrivateKey})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	info := status.Convert(err).Details()[0].(*errdetails.ErrorInfo)
+	assert.Equal(t, "LIMIT_EXCEEDED", info.Reason)
+	assert.Equal(t, "1", info.Metadata["limit"])
+	_, err = client.Transaction(ctx, &api.TransactionRequest{Id: "0x12"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.Block(ctx, &api.BlockRequest{Height: 99})
+	assert.Equal(t, codes.Unknown, status.Code(err))
+}
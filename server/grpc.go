@@ -0,0 +1,94 @@
+// Package server exposes the eth and btc services over the network.
+package server
+
+import (
+	"context"
+	"demo/amount"
+	"demo/api"
+	"demo/btc"
+	"demo/eth"
+	"errors"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+// NewGRPCServer returns a grpc server with the EthService and BtcService of api registered. Errors of the handlers
+// are sent as statuses, see grpcError.
+func NewGRPCServer(ethSvc eth.Server, btcSvc btc.Server, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts, grpc.ChainUnaryInterceptor(errorInterceptor))
+	server := grpc.NewServer(opts...)
+	api.RegisterEthServiceServer(server, &ethServer{svc: ethSvc})
+	api.RegisterBtcServiceServer(server, &btcServer{svc: btcSvc})
+	return server
+}
+
+func errorInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	return resp, grpcError(err)
+}
+
+// grpcError converts err to a status error. An AppErr keeps its code and details, see AppErr.GRPCStatus; other
+// errors of the services become Unknown.
+func grpcError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var appErr *eth.AppErr
+	if errors.As(err, &appErr) {
+		return appErr.GRPCStatus().Err()
+	}
+
+	var appErrValue eth.AppErr
+	if errors.As(err, &appErrValue) {
+		return appErrValue.GRPCStatus().Err()
+	}
+
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	case errors.Is(err, amount.ErrTooManyDecimals):
+		return eth.ErrTooManyDecimals.GRPCStatus().Err()
+	case errors.Is(err, btc.ErrEnvelopeTampered), errors.Is(err, btc.ErrAmountOutOfRange):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.Unknown, err.Error())
+}
+
+// invalidInput is ErrInvalidInput naming the request field that failed validation.
+func invalidInput(field string) error {
+	return &eth.AppErr{
+		Code:    eth.ErrInvalidInput.Code,
+		Message: field + " is invalid",
+		Status:  codes.InvalidArgument,
+		Details: map[string]interface{}{"field": field},
+	}
+}
+
+// validPublicKey reports whether publicKey is a 0x prefixed compressed secp256k1 public key, the form both services
+// derive addresses from.
+func validPublicKey(publicKey string) bool {
+	data, err := hexutil.Decode(publicKey)
+	if err != nil {
+		return false
+	}
+
+	_, err = crypto.DecompressPubkey(data)
+	return err == nil
+}
+
+// unixTime is t in unix seconds, 0 for the zero time.
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
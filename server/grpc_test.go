@@ -0,0 +1,128 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"demo/amount"
+	"demo/btc"
+	"demo/eth"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"math/big"
+	"net"
+	"testing"
+)
+
+const (
+	owner1Addr       = "0xE280029a7867BA5C9154434886c241775ea87e53"
+	owner1PrivateKey = "0xf1b3f8e0d52caec13491368449ab8d90f3d222a3e485aa7f02591bbceb5efba5"
+	owner2Addr       = "0x68dB32D26d9529B2a142927c6f1af248fc6Ba7e9"
+)
+
+func Test_GRPCError(t *testing.T) {
+	assert.Nil(t, grpcError(nil))
+
+	err := grpcError(fmt.Errorf("transfer: %w", eth.ErrInvalidSignature))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "INVALID_SIGNATURE", errorReason(t, err))
+
+	err = grpcError(invalidInput("amount"))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "amount is invalid", status.Convert(err).Message())
+	info := status.Convert(err).Details()[0].(*errdetails.ErrorInfo)
+	assert.Equal(t, map[string]string{"field": "amount"}, info.Metadata)
+
+	err = grpcError(eth.NewAppErr("CUSTOM", "custom"))
+	assert.Equal(t, codes.Unknown, status.Code(err))
+	assert.Equal(t, "CUSTOM", errorReason(t, err))
+
+	assert.Equal(t, "TOO_MANY_DECIMALS", errorReason(t, grpcError(amount.ErrTooManyDecimals)))
+	assert.Equal(t, codes.InvalidArgument, status.Code(grpcError(btc.ErrEnvelopeTampered)))
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(grpcError(context.DeadlineExceeded)))
+	assert.Equal(t, codes.Unknown, status.Code(grpcError(errors.New("node is down"))))
+
+	notFound := status.Error(codes.NotFound, "missing")
+	assert.Equal(t, notFound, grpcError(notFound))
+}
+
+// dialGRPC serves ethSvc and btcSvc in memory and returns a connection to them.
+func dialGRPC(t *testing.T, ethSvc eth.Server, btcSvc btc.Server) *grpc.ClientConn {
+	listener := bufconn.Listen(1 << 20)
+	server := NewGRPCServer(ethSvc, btcSvc)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = conn.Close()
+		server.Stop()
+	})
+	return conn
+}
+
+// getSimulatedService returns a service over an in-memory chain where owner1 and owner2 hold 100 ETH each.
+func getSimulatedService(t *testing.T) (*eth.Service, *eth.SimulatedBackend) {
+	balance, _ := new(big.Int).SetString("100000000000000000000", 10)
+	backend := eth.NewSimulatedBackend(backends.NewSimulatedBackend(core.GenesisAlloc{
+		common.HexToAddress(owner1Addr): {Balance: balance},
+		common.HexToAddress(owner2Addr): {Balance: balance},
+	}, 8000000))
+	t.Cleanup(func() {
+		backend.Close()
+	})
+	return eth.NewService(backend, 0, 1.2), backend
+}
+
+func getBTCService() *btc.Service {
+	// HTTP POST mode, nothing is dialed until a node call
+	client, _ := btc.Dial(btc.Endpoint{Host: "127.0.0.1:18332", User: "user", Password: "password"})
+	return btc.NewClientService(prevTxClient{Client: client})
+}
+
+// prevTxClient knows the previous transaction of the output of getBTCKey, the node is not reached for it.
+type prevTxClient struct {
+	btc.Client
+}
+
+func (c prevTxClient) GetRawTransactionVerbose(ctx context.Context, txHash *chainhash.Hash) (*btcjson.TxRawResult, error) {
+	_, prevTx := btcTestKey()
+	if prevTx.TxHash() != *txHash {
+		return c.Client.GetRawTransactionVerbose(ctx, txHash)
+	}
+
+	var buf bytes.Buffer
+	if err := prevTx.Serialize(&buf); err != nil {
+		return nil, err
+	}
+	return &btcjson.TxRawResult{Txid: txHash.String(), Hex: hex.EncodeToString(buf.Bytes())}, nil
+}
+
+func errorReason(t *testing.T, err error) string {
+	details := status.Convert(err).Details()
+	if len(details) != 1 {
+		t.Fatalf("expected one detail, got %v", details)
+	}
+	return details[0].(*errdetails.ErrorInfo).Reason
+}
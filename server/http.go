@@ -0,0 +1,348 @@
+package server
+
+import (
+	"context"
+	"demo/api"
+	"demo/btc"
+	"demo/eth"
+	"encoding/json"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const maxBodySize = 1 << 20
+
+var (
+	errMethodNotAllowed = &eth.AppErr{Code: "METHOD_NOT_ALLOWED", Message: "the method is not allowed", Status: codes.Unimplemented}
+
+	jsonMarshal   = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+	jsonUnmarshal = protojson.UnmarshalOptions{}
+
+	httpStatus = map[codes.Code]int{
+		codes.OK:                 http.StatusOK,
+		codes.Canceled:           499,
+		codes.InvalidArgument:    http.StatusBadRequest,
+		codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+		codes.NotFound:           http.StatusNotFound,
+		codes.AlreadyExists:      http.StatusConflict,
+		codes.PermissionDenied:   http.StatusForbidden,
+		codes.Unauthenticated:    http.StatusUnauthorized,
+		codes.ResourceExhausted:  http.StatusTooManyRequests,
+		codes.FailedPrecondition: http.StatusBadRequest,
+		codes.OutOfRange:         http.StatusBadRequest,
+		codes.Unimplemented:      http.StatusNotImplemented,
+		codes.Unavailable:        http.StatusServiceUnavailable,
+	}
+)
+
+// handler serves a route. params holds the path parameters of the route.
+type handler func(ctx context.Context, r *http.Request, params map[string]string) (proto.Message, error)
+
+type route struct {
+	method   string
+	segments []string //"{name}" segments are parameters
+	handle   handler
+}
+
+// Gateway serves the EthService and BtcService of api as JSON over HTTP. Messages use the proto field names;
+// amounts are decimal strings, and so are 64 bit integers as in the protobuf JSON mapping. Errors are an AppErr.
+type Gateway struct {
+	routes []route
+}
+
+func NewGateway(ethSvc eth.Server, btcSvc btc.Server) *Gateway {
+	ethSrv, btcSrv := &ethServer{svc: ethSvc}, &btcServer{svc: btcSvc}
+	g := Gateway{}
+
+	g.handle(http.MethodPost, "/v1/eth/addresses", func(ctx context.Context, r *http.Request, params map[string]string) (proto.Message, error) {
+		req := api.CreateAddressRequest{}
+		if err := decodeBody(r, &req); err != nil {
+			return nil, err
+		}
+		return ethSrv.CreateAddress(ctx, &req)
+	})
+	g.handle(http.MethodPost, "/v1/eth/addresses/from-public-key", func(ctx context.Context, r *http.Request, params map[string]string) (proto.Message, error) {
+		req := api.PublicKeyRequest{}
+		if err := decodeBody(r, &req); err != nil {
+			return nil, err
+		}
+		return ethSrv.AddressFromPublicKey(ctx, &req)
+	})
+	g.handle(http.MethodGet, "/v1/eth/addresses/{address}/balance", func(ctx context.Context, r *http.Request, params map[string]string) (proto.Message, error) {
+		return ethSrv.Balance(ctx, &api.EthBalanceRequest{Address: params["address"], TokenAddress: r.URL.Query().Get("token_address")})
+	})
+	g.handle(http.MethodGet, "/v1/eth/addresses/{address}/nonce", func(ctx context.Context, r *http.Request, params map[string]string) (proto.Message, error) {
+		return ethSrv.Nonce(ctx, &api.NonceRequest{Address: params["address"]})
+	})
+	g.handle(http.MethodGet, "/v1/eth/tokens/{address}", func(ctx context.Context, r *http.Request, params map[string]string) (proto.Message, error) {
+		return ethSrv.TokenInfo(ctx, &api.TokenInfoRequest{ContractAddress: params["address"]})
+	})
+	g.handle(http.MethodGet, "/v1/eth/gas-price", func(ctx context.Context, r *http.Request, params map[string]string) (proto.Message, error) {
+		return ethSrv.SuggestGasPrice(ctx, &api.SuggestGasPriceRequest{})
+	})
+	g.handle(http.MethodGet, "/v1/eth/height", func(ctx context.Context, r *http.Request, params map[string]string) (proto.Message, error) {
+		return ethSrv.BlockHeight(ctx, &api.BlockHeightRequest{})
+	})
+	g.handle(http.MethodGet, "/v1/eth/blocks", func(ctx context.Context, r *http.Request, params map[string]string) (proto.Message, error) {
+		req, err := listBlocksRequest(r)
+		if err != nil {
+			return nil, err
+		}
+		return ethSrv.ListBlocks(ctx, req)
+	})
+	g.handle(http.MethodGet, "/v1/eth/blocks/{height}", func(ctx context.Context, r *http.Request, params map[string]string) (proto.Message, error) {
+		height, err := parseUint(params["height"], "height", 64)
+		if err != nil {
+			return nil, err
+		}
+		return ethSrv.Block(ctx, &api.BlockRequest{Height: height})
+	})
+	g.handle(http.MethodGet, "/v1/eth/blocks/{height}/transactions", func(ctx context.Context, r *http.Request, params map[string]string) (proto.Message, error) {
+		req, err := listTransactionsRequest(r, params)
+		if err != nil {
+			return nil, err
+		}
+		return ethSrv.ListTransactions(ctx, req)
+	})
+	g.handle(http.MethodGet, "/v1/eth/transactions/{id}", func(ctx context.Context, r *http.Request, params map[string]string) (proto.Message, error) {
+		return ethSrv.Transaction(ctx, &api.TransactionRequest{Id: params["id"]})
+	})
+	g.handle(http.MethodPost, "/v1/eth/transactions", func(ctx context.Context, r *http.Request, params map[string]string) (proto.Message, error) {
+		req := api.EthCreateTransactionRequest{}
+		if err := decodeBody(r, &req); err != nil {
+			return nil, err
+		}
+		return ethSrv.CreateTransaction(ctx, &req)
+	})
+	g.handle(http.MethodPost, "/v1/eth/transactions/sign", func(ctx context.Context, r *http.Request, params map[string]string) (proto.Message, error) {
+		req := api.EthSignTransactionRequest{}
+		if err := decodeBody(r, &req); err != nil {
+			return nil, err
+		}
+		return ethSrv.SignTransaction(ctx, &req)
+	})
+	g.handle(http.MethodPost, "/v1/eth/transactions/broadcast", func(ctx context.Context, r *http.Request, params map[string]string) (proto.Message, error) {
+		req := api.BroadcastRequest{}
+		if err := decodeBody(r, &req); err != nil {
+			return nil, err
+		}
+		return ethSrv.Broadcast(ctx, &req)
+	})
+
+	g.handle(http.MethodGet, "/v1/btc/addresses/{address}/validate", func(ctx context.Context, r *http.Request, params map[string]string) (proto.Message, error) {
+		return btcSrv.ValidateAddress(ctx, &api.ValidateAddressRequest{Address: params["address"]})
+	})
+	g.handle(http.MethodPost, "/v1/btc/addresses/from-public-key", func(ctx context.Context, r *http.Request, params map[string]string) (proto.Message, error) {
+		req := api.PublicKeyRequest{}
+		if err := decodeBody(r, &req); err != nil {
+			return nil, err
+		}
+		return btcSrv.AddressFromPublicKey(ctx, &req)
+	})
+	g.handle(http.MethodGet, "/v1/btc/height", func(ctx context.Context, r *http.Request, params map[string]string) (proto.Message, error) {
+		return btcSrv.BlockHeight(ctx, &api.BlockHeightRequest{})
+	})
+	g.handle(http.MethodGet, "/v1/btc/blocks", func(ctx context.Context, r *http.Request, params map[string]string) (proto.Message, error) {
+		req, err := listBlocksRequest(r)
+		if err != nil {
+			return nil, err
+		}
+		return btcSrv.ListBlocks(ctx, req)
+	})
+	g.handle(http.MethodGet, "/v1/btc/blocks/{height}", func(ctx context.Context, r *http.Request, params map[string]string) (proto.Message, error) {
+		height, err := parseUint(params["height"], "height", 64)
+		if err != nil {
+			return nil, err
+		}
+		return btcSrv.Block(ctx, &api.BlockRequest{Height: height})
+	})
+	g.handle(http.MethodGet, "/v1/btc/blocks/{height}/transactions", func(ctx context.Context, r *http.Request, params map[string]string) (proto.Message, error) {
+		req, err := listTransactionsRequest(r, params)
+		if err != nil {
+			return nil, err
+		}
+		return btcSrv.ListTransactions(ctx, req)
+	})
+	g.handle(http.MethodGet, "/v1/btc/transactions/{id}", func(ctx context.Context, r *http.Request, params map[string]string) (proto.Message, error) {
+		return btcSrv.Transaction(ctx, &api.TransactionRequest{Id: params["id"]})
+	})
+	g.handle(http.MethodPost, "/v1/btc/transactions", func(ctx context.Context, r *http.Request, params map[string]string) (proto.Message, error) {
+		req := api.BtcCreateTransactionRequest{}
+		if err := decodeBody(r, &req); err != nil {
+			return nil, err
+		}
+		return btcSrv.CreateTransaction(ctx, &req)
+	})
+	g.handle(http.MethodPost, "/v1/btc/transactions/sign", func(ctx context.Context, r *http.Request, params map[string]string) (proto.Message, error) {
+		req := api.BtcSignTransactionRequest{}
+		if err := decodeBody(r, &req); err != nil {
+			return nil, err
+		}
+		return btcSrv.SignTransaction(ctx, &req)
+	})
+	g.handle(http.MethodPost, "/v1/btc/transactions/broadcast", func(ctx context.Context, r *http.Request, params map[string]string) (proto.Message, error) {
+		req := api.BroadcastRequest{}
+		if err := decodeBody(r, &req); err != nil {
+			return nil, err
+		}
+		return btcSrv.Broadcast(ctx, &req)
+	})
+	return &g
+}
+
+func (g *Gateway) handle(method, path string, handle handler) {
+	g.routes = append(g.routes, route{method: method, segments: splitPath(path), handle: handle})
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := splitPath(r.URL.Path)
+	var allowed []string
+	for _, route := range g.routes {
+		params, ok := route.match(segments)
+		if !ok {
+			continue
+		}
+
+		if route.method != r.Method {
+			allowed = append(allowed, route.method)
+			continue
+		}
+
+		resp, err := route.handle(r.Context(), r, params)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		data, err := jsonMarshal.Marshal(resp)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+		return
+	}
+
+	if len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeJSONError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	writeError(w, eth.ErrNotFound)
+}
+
+func (rt route) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(rt.segments) {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	for i, segment := range rt.segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			params[segment[1:len(segment)-1]] = segments[i]
+		} else if segment != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// decodeBody reads the JSON body of r into req, rejecting unknown fields.
+func decodeBody(r *http.Request, req proto.Message) error {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil || len(data) > maxBodySize {
+		return invalidInput("body")
+	}
+
+	if err = jsonUnmarshal.Unmarshal(data, req); err != nil {
+		return invalidInput("body")
+	}
+	return nil
+}
+
+func listBlocksRequest(r *http.Request) (*api.ListBlocksRequest, error) {
+	query := r.URL.Query()
+	size, err := parseUint(query.Get("page_size"), "page_size", 32)
+	if err != nil {
+		return nil, err
+	}
+	return &api.ListBlocksRequest{PageSize: uint32(size), PageToken: query.Get("page_token")}, nil
+}
+
+func listTransactionsRequest(r *http.Request, params map[string]string) (*api.ListTransactionsRequest, error) {
+	height, err := parseUint(params["height"], "height", 64)
+	if err != nil {
+		return nil, err
+	}
+
+	query := r.URL.Query()
+	size, err := parseUint(query.Get("page_size"), "page_size", 32)
+	if err != nil {
+		return nil, err
+	}
+	return &api.ListTransactionsRequest{Height: height, PageSize: uint32(size), PageToken: query.Get("page_token")}, nil
+}
+
+// parseUint parses the request parameter name, 0 when it is empty.
+func parseUint(value, name string, bitSize int) (uint64, error) {
+	if len(value) == 0 {
+		return 0, nil
+	}
+
+	result, err := strconv.ParseUint(value, 10, bitSize)
+	if err != nil {
+		return 0, invalidInput(name)
+	}
+	return result, nil
+}
+
+// writeError writes err as an AppErr. Errors that are not an AppErr get the name of their grpc code, see grpcError.
+func writeError(w http.ResponseWriter, err error) {
+	st := status.Convert(grpcError(err))
+	appErr := eth.AppErr{Code: codeName(st.Code()), Message: st.Message(), Status: st.Code(), Details: map[string]interface{}{}}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			appErr.Code = info.Reason
+			for key, value := range info.Metadata {
+				appErr.Details[key] = value
+			}
+		}
+	}
+
+	code, ok := httpStatus[st.Code()]
+	if !ok {
+		code = http.StatusInternalServerError
+	}
+	writeJSONError(w, code, &appErr)
+}
+
+func writeJSONError(w http.ResponseWriter, code int, appErr *eth.AppErr) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(appErr)
+}
+
+// codeName turns a grpc code into the style of AppErr codes, e.g. DEADLINE_EXCEEDED.
+func codeName(code codes.Code) string {
+	var name strings.Builder
+	for i, r := range code.String() {
+		if i > 0 && unicode.IsUpper(r) {
+			name.WriteByte('_')
+		}
+		name.WriteRune(unicode.ToUpper(r))
+	}
+	return name.String()
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"demo/eth"
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_GatewayTransfer(t *testing.T) {
+	svc, backend := getSimulatedService(t)
+	server := httptest.NewServer(NewGateway(svc, getBTCService()))
+	defer server.Close()
+
+	var unsigned map[string]interface{}
+	status := doJSON(t, server, http.MethodPost, "/v1/eth/transactions",
+		fmt.Sprintf(`{"from":%q,"to":%q,"amount":"0.5"}`, owner1Addr, owner2Addr), &unsigned)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, unsigned["summary"], "send 0.5 ETH")
+
+	var signed map[string]interface{}
+	status = doJSON(t, server, http.MethodPost, "/v1/eth/transactions/sign",
+		fmt.Sprintf(`{"raw_tx":%q,"private_key":%q}`, unsigned["raw_tx"], owner1PrivateKey), &signed)
+	assert.Equal(t, http.StatusOK, status)
+
+	var broadcast map[string]interface{}
+	status = doJSON(t, server, http.MethodPost, "/v1/eth/transactions/broadcast", fmt.Sprintf(`{"raw_tx":%q}`, signed["raw_tx"]), &broadcast)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, signed["id"], broadcast["id"])
+	backend.Commit()
+
+	var balance map[string]interface{}
+	status = doJSON(t, server, http.MethodGet, "/v1/eth/addresses/"+owner2Addr+"/balance", "", &balance)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]interface{}{"amount": "100.5"}, balance)
+
+	var nonce map[string]interface{}
+	doJSON(t, server, http.MethodGet, "/v1/eth/addresses/"+owner1Addr+"/nonce", "", &nonce)
+	assert.Equal(t, "1", nonce["nonce"])
+
+	var transaction map[string]interface{}
+	status = doJSON(t, server, http.MethodGet, fmt.Sprintf("/v1/eth/transactions/%s", broadcast["id"]), "", &transaction)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "0.5", transaction["amount"])
+	assert.Equal(t, "TRANSACTION_STATE_PENDING", transaction["state"])
+	assert.Equal(t, "", transaction["revert_reason"])
+}
+
+func Test_GatewayPagination(t *testing.T) {
+	ctx := context.Background()
+	svc, backend := getSimulatedService(t)
+	server := httptest.NewServer(NewGateway(svc, getBTCService()))
+	defer server.Close()
+
+	// three transfers in block 1, then four empty blocks
+	privateKey, _ := crypto.HexToECDSA(owner1PrivateKey[2:])
+	to := common.HexToAddress(owner2Addr)
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		tx, _ := types.SignTx(types.NewTransaction(nonce, to, big.NewInt(1), 21000, big.NewInt(2*params.GWei), nil),
+			types.NewLondonSigner(big.NewInt(1337)), privateKey)
+		assert.NoError(t, backend.SendTransaction(ctx, tx))
+	}
+	for i := 0; i < 5; i++ {
+		backend.Commit()
+	}
+
+	var blocks struct {
+		Blocks []struct {
+			Height       string        `json:"height"`
+			Transactions []interface{} `json:"transactions"`
+		} `json:"blocks"`
+		NextPageToken string `json:"next_page_token"`
+	}
+	status := doJSON(t, server, http.MethodGet, "/v1/eth/blocks?page_size=2", "", &blocks)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, blocks.Blocks, 2)
+	assert.Equal(t, "5", blocks.Blocks[0].Height)
+	assert.Empty(t, blocks.Blocks[0].Transactions)
+	assert.Equal(t, "3", blocks.NextPageToken)
+
+	var heights []string
+	for token := "5"; token != ""; token = blocks.NextPageToken {
+		doJSON(t, server, http.MethodGet, "/v1/eth/blocks?page_size=4&page_token="+token, "", &blocks)
+		for _, block := range blocks.Blocks {
+			heights = append(heights, block.Height)
+		}
+	}
+	assert.Equal(t, []string{"5", "4", "3", "2", "1", "0"}, heights)
+
+	var transactions struct {
+		Transactions []struct {
+			ID string `json:"id"`
+		} `json:"transactions"`
+		NextPageToken string `json:"next_page_token"`
+	}
+	doJSON(t, server, http.MethodGet, "/v1/eth/blocks/1/transactions?page_size=2", "", &transactions)
+	assert.Len(t, transactions.Transactions, 2)
+	assert.Equal(t, "2", transactions.NextPageToken)
+	doJSON(t, server, http.MethodGet, "/v1/eth/blocks/1/transactions?page_size=2&page_token=2", "", &transactions)
+	assert.Len(t, transactions.Transactions, 1)
+	assert.Empty(t, transactions.NextPageToken)
+}
+
+func Test_GatewayErrors(t *testing.T) {
+	svc, _ := getSimulatedService(t)
+	server := httptest.NewServer(NewGateway(svc, getBTCService()))
+	defer server.Close()
+
+	cases := []struct {
+		method, path, body string
+		status             int
+		code               string
+		details            map[string]interface{}
+	}{
+		{http.MethodGet, "/v1/eth/addresses/0x12/balance", "", http.StatusBadRequest, "INVALID_INPUT", map[string]interface{}{"field": "address"}},
+		{http.MethodGet, "/v1/eth/blocks?page_size=-1", "", http.StatusBadRequest, "INVALID_INPUT", map[string]interface{}{"field": "page_size"}},
+		{http.MethodGet, "/v1/eth/blocks?page_token=9", "", http.StatusBadRequest, "INVALID_INPUT", map[string]interface{}{"field": "page_token"}},
+		{http.MethodGet, "/v1/eth/blocks/x", "", http.StatusBadRequest, "INVALID_INPUT", map[string]interface{}{"field": "height"}},
+		{http.MethodPost, "/v1/eth/transactions", `{"from":`, http.StatusBadRequest, "INVALID_INPUT", map[string]interface{}{"field": "body"}},
+		{http.MethodPost, "/v1/eth/transactions", `{"sender":"0x1"}`, http.StatusBadRequest, "INVALID_INPUT", map[string]interface{}{"field": "body"}},
+		{http.MethodPost, "/v1/eth/transactions", fmt.Sprintf(`{"from":%q,"to":%q,"amount":"0.0000000000000000001"}`, owner1Addr, owner2Addr),
+			http.StatusBadRequest, "TOO_MANY_DECIMALS", map[string]interface{}{}},
+		{http.MethodGet, "/v1/eth/blocks/99", "", http.StatusInternalServerError, "UNKNOWN", map[string]interface{}{}},
+		{http.MethodGet, "/v1/sol/height", "", http.StatusNotFound, "NOT_FOUND", map[string]interface{}{}},
+		{http.MethodDelete, "/v1/eth/height", "", http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", nil},
+		{http.MethodPost, "/v1/btc/transactions/sign", `{"psbt":"cHNidP8=","wif":"key"}`, http.StatusBadRequest, "INVALID_INPUT", map[string]interface{}{"field": "wif"}},
+	}
+	for _, c := range cases {
+		appErr := eth.AppErr{}
+		status := doJSON(t, server, c.method, c.path, c.body, &appErr)
+		assert.Equal(t, c.status, status, c.path)
+		assert.Equal(t, c.code, appErr.Code, c.path)
+		assert.NotEmpty(t, appErr.Message, c.path)
+		assert.Equal(t, c.details, appErr.Details, c.path)
+	}
+}
+
+func Test_GatewayBtc(t *testing.T) {
+	svc, _ := getSimulatedService(t)
+	server := httptest.NewServer(NewGateway(svc, getBTCService()))
+	defer server.Close()
+	wif, from, utxo := getBTCKey(t)
+
+	var valid map[string]interface{}
+	doJSON(t, server, http.MethodGet, "/v1/btc/addresses/"+from+"/validate", "", &valid)
+	assert.Equal(t, true, valid["valid"])
+	doJSON(t, server, http.MethodGet, "/v1/btc/addresses/"+from+"x/validate", "", &valid)
+	assert.Equal(t, false, valid["valid"])
+
+	var unsigned map[string]interface{}
+	status := doJSON(t, server, http.MethodPost, "/v1/btc/transactions", fmt.Sprintf(
+		`{"from":%q,"to":"mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn","amount":"0.004","fee":"0.0001","utxo":{"tx_id":%q,"n":1,"value":"0.01","script":%q}}`,
+		from, utxo.TxId, utxo.Script), &unsigned)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "", unsigned["raw_tx"])
+
+	var signed map[string]interface{}
+	status = doJSON(t, server, http.MethodPost, "/v1/btc/transactions/sign", fmt.Sprintf(`{"psbt":%q,"wif":%q}`, unsigned["psbt"], wif), &signed)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, signed["id"], 64)
+}
+
+// doJSON sends body to path and decodes the JSON response into resp, returning the status code.
+func doJSON(t *testing.T, server *httptest.Server, method, path, body string, resp interface{}) int {
+	req, err := http.NewRequest(method, server.URL+path, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+	if err = json.NewDecoder(res.Body).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode
+}
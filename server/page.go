@@ -0,0 +1,79 @@
+package server
+
+import (
+	"strconv"
+)
+
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+)
+
+// page is a validated page of a listing: start is the first item, size the number of items to return.
+type page struct {
+	start uint64
+	size  uint64
+}
+
+// pageSize returns size, or the default when it is 0, capped at maxPageSize.
+func pageSize(size uint32) uint64 {
+	if size == 0 {
+		return defaultPageSize
+	}
+
+	if size > maxPageSize {
+		return maxPageSize
+	}
+	return uint64(size)
+}
+
+// blockPage returns the page of blocks from token down to height 0. An empty token starts at height, the newest
+// block; tokens above height are invalid.
+func blockPage(token string, size uint32, height uint64) (page, error) {
+	p := page{start: height, size: pageSize(size)}
+	if len(token) > 0 {
+		start, err := strconv.ParseUint(token, 10, 64)
+		if err != nil || start > height {
+			return p, invalidInput("page_token")
+		}
+		p.start = start
+	}
+
+	if p.size > p.start+1 {
+		p.size = p.start + 1
+	}
+	return p, nil
+}
+
+// nextBlock is the token of the block page after p, empty once block 0 is on p.
+func (p page) nextBlock() string {
+	if p.start < p.size {
+		return ""
+	}
+	return strconv.FormatUint(p.start-p.size, 10)
+}
+
+// itemPage returns the page starting at the offset in token of a listing of total items.
+func itemPage(token string, size uint32, total int) (page, error) {
+	p := page{size: pageSize(size)}
+	if len(token) > 0 {
+		start, err := strconv.ParseUint(token, 10, 64)
+		if err != nil || start >= uint64(total) {
+			return p, invalidInput("page_token")
+		}
+		p.start = start
+	}
+
+	if p.start+p.size > uint64(total) {
+		p.size = uint64(total) - p.start
+	}
+	return p, nil
+}
+
+// nextItem is the token of the item page after p, empty when p reaches the end of total items.
+func (p page) nextItem(total int) string {
+	if p.start+p.size >= uint64(total) {
+		return ""
+	}
+	return strconv.FormatUint(p.start+p.size, 10)
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_BlockPage(t *testing.T) {
+	p, err := blockPage("", 0, 25)
+	assert.NoError(t, err)
+	assert.Equal(t, page{start: 25, size: 10}, p)
+	assert.Equal(t, "15", p.nextBlock())
+
+	p, err = blockPage("4", 10, 25)
+	assert.NoError(t, err)
+	assert.Equal(t, page{start: 4, size: 5}, p)
+	assert.Empty(t, p.nextBlock())
+
+	p, _ = blockPage("", 1000, 500)
+	assert.Equal(t, uint64(maxPageSize), p.size)
+
+	_, err = blockPage("26", 10, 25)
+	assert.Error(t, err)
+	_, err = blockPage("abc", 10, 25)
+	assert.Error(t, err)
+}
+
+func Test_ItemPage(t *testing.T) {
+	p, err := itemPage("", 2, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, page{start: 0, size: 2}, p)
+	assert.Equal(t, "2", p.nextItem(5))
+
+	p, err = itemPage("4", 2, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, page{start: 4, size: 1}, p)
+	assert.Empty(t, p.nextItem(5))
+
+	p, err = itemPage("", 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), p.size)
+	assert.Empty(t, p.nextItem(0))
+
+	_, err = itemPage("5", 2, 5)
+	assert.Error(t, err)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"demo/amount"
+	"demo/btc"
+	"demo/config"
+	"demo/eth"
+	"demo/metrics"
+	"demo/server"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"gopkg.in/urfave/cli.v1"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const (
+	simulatedGasLimit   = 8000000
+	configWatchInterval = 2 * time.Second
+	nodeWatchInterval   = 15 * time.Second
+)
+
+var simulateCommand = cli.Command{
+	Name:  "simulate",
+	Usage: "run an in-memory eth chain behind the REST gateway and the gRPC server",
+	Description: "The chain is lost on exit. Blocks are committed every --block-time. Without --fund a throwaway " +
+		"account is created and its key printed, as development chains do; btc calls go to --btc-host. " +
+		"Prometheus metrics are served at /metrics of the REST address.",
+	ArgsUsage: " ",
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "http", Usage: "REST gateway listen address", Value: "localhost:8080"},
+		cli.StringFlag{Name: "grpc", Usage: "gRPC listen address", Value: "localhost:9090"},
+		cli.StringSliceFlag{Name: "fund", Usage: "address funded in the genesis block"},
+		cli.StringFlag{Name: "balance", Usage: "ETH of each funded address", Value: "100"},
+		cli.DurationFlag{Name: "block-time", Usage: "interval between blocks", Value: time.Second},
+	},
+	Action: simulate,
+}
+
+func simulate(ctx *cli.Context) error {
+	balance, err := amount.Parse(ctx.String("balance"), amount.Ether)
+	if err != nil || balance.Sign() <= 0 {
+		return errors.New("invalid --balance")
+	}
+
+	if ctx.Duration("block-time") <= 0 {
+		return errors.New("invalid --block-time")
+	}
+
+	addresses := ctx.StringSlice("fund")
+	if len(addresses) == 0 {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			return err
+		}
+		address := crypto.PubkeyToAddress(key.PublicKey).Hex()
+		fmt.Fprintf(ctx.App.ErrWriter, "funded %s, private key %s\n", address, hexutil.Encode(crypto.FromECDSA(key)))
+		addresses = []string{address}
+	}
+
+	alloc, err := simulatedGenesis(addresses, balance)
+	if err != nil {
+		return err
+	}
+
+	backend := eth.NewSimulatedBackend(backends.NewSimulatedBackend(alloc, simulatedGasLimit))
+	defer backend.Close()
+	store := configStore(ctx)
+	settings := store.Config()
+	ethSvc := eth.NewService(backend, settings.ETH.Confirmations, settings.ETH.Fee.GasMultiplier)
+	ethSvc.SetLogger(appLogger(ctx))
+	btcSvc, err := btcService(ctx)
+	if err != nil {
+		return err
+	}
+	measurements := metrics.New()
+	ethSvc.SetInstrumentation(measurements.Chain("eth"))
+	btcSvc.SetInstrumentation(measurements.Chain("btc"))
+	store.Subscribe(func(c *config.Config) {
+		ethSvc.SetConfirmations(c.ETH.Confirmations)
+		ethSvc.SetGasMultiplier(c.ETH.Fee.GasMultiplier)
+		btcSvc.SetConfirmations(c.BTC.Confirmations)
+		fmt.Fprintln(ctx.App.ErrWriter, "reloaded the configuration")
+	})
+
+	grpcListener, err := net.Listen("tcp", ctx.String("grpc"))
+	if err != nil {
+		return err
+	}
+	grpcServer := server.NewGRPCServer(ethSvc, btcSvc)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", measurements)
+	mux.Handle("/", server.NewGateway(ethSvc, btcSvc))
+	httpServer := &http.Server{Addr: ctx.String("http"), Handler: mux}
+
+	errs := make(chan error, 2)
+	go func() {
+		errs <- grpcServer.Serve(grpcListener)
+	}()
+	go func() {
+		errs <- httpServer.ListenAndServe()
+	}()
+	fmt.Fprintf(ctx.App.ErrWriter, "serving REST on %s and gRPC on %s\n", httpServer.Addr, grpcListener.Addr())
+
+	stop, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	reportReload := func(err error) {
+		fmt.Fprintf(ctx.App.ErrWriter, "keeping the previous configuration: %v\n", err)
+	}
+	go store.Watch(stop, configWatchInterval, reportReload)
+	if pool, ok := btcSvc.Client().(*btc.Pool); ok {
+		go pool.Watch(stop, nodeWatchInterval)
+	}
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	ticker := time.NewTicker(ctx.Duration("block-time"))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			backend.Commit()
+		case <-hangup:
+			if err := store.Reload(); err != nil {
+				reportReload(err)
+			}
+		case err = <-errs:
+		case <-stop.Done():
+		}
+
+		if err != nil || stop.Err() != nil {
+			break
+		}
+	}
+
+	grpcServer.Stop()
+	_ = httpServer.Close()
+	return err
+}
+
+// simulatedGenesis gives each of addresses balance wei.
+func simulatedGenesis(addresses []string, balance *big.Int) (core.GenesisAlloc, error) {
+	alloc := core.GenesisAlloc{}
+	for _, address := range addresses {
+		if !common.IsHexAddress(address) {
+			return nil, fmt.Errorf("invalid --fund %q", address)
+		}
+		alloc[common.HexToAddress(address)] = core.GenesisAccount{Balance: balance}
+	}
+	return alloc, nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+)
+
+func Test_SimulatedGenesis(t *testing.T) {
+	alloc, err := simulatedGenesis([]string{owner1Addr, owner2Addr}, big.NewInt(7))
+	assert.NoError(t, err)
+	assert.Len(t, alloc, 2)
+	assert.Equal(t, big.NewInt(7), alloc[common.HexToAddress(owner2Addr)].Balance)
+
+	_, err = simulatedGenesis([]string{"0x12"}, big.NewInt(7))
+	assert.Error(t, err)
+}
+
+func Test_SimulateFlags(t *testing.T) {
+	_, err := runApp(t, nil, "simulate", "--balance", "0")
+	assert.EqualError(t, err, "invalid --balance")
+	_, err = runApp(t, nil, "simulate", "--balance", "0.0000000000000000001")
+	assert.EqualError(t, err, "invalid --balance")
+	_, err = runApp(t, nil, "simulate", "--block-time", "0s")
+	assert.EqualError(t, err, "invalid --block-time")
+	_, err = runApp(t, nil, "simulate", "--fund", "0x12", "--grpc", "127.0.0.1:0", "--http", "127.0.0.1:0")
+	assert.EqualError(t, err, `invalid --fund "0x12"`)
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"demo/chain"
+	"demo/failover"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"gopkg.in/urfave/cli.v1"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	mnemonicFileFlag = cli.StringFlag{
+		Name:   "mnemonic-file",
+		Usage:  "file holding the BIP-39 mnemonic; defaults to $" + mnemonicEnv,
+		EnvVar: "WALLET_MNEMONIC_FILE",
+	}
+	inFlag = cli.StringFlag{
+		Name:  "in",
+		Usage: "file holding the transaction JSON, - for stdin",
+		Value: "-",
+	}
+)
+
+var addressCommand = cli.Command{
+	Name:      "address",
+	Usage:     "derive an address from a public key, or from the mnemonic at --index (eth only)",
+	ArgsUsage: " ",
+	Flags: []cli.Flag{
+		chainFlag,
+		cli.StringFlag{Name: "public-key", Usage: "0x prefixed compressed public key"},
+		cli.UintFlag{Name: "index", Usage: "account index of the m/44'/60'/0'/0 path"},
+		mnemonicFileFlag,
+	},
+	Action: func(ctx *cli.Context) error {
+		w, err := wallet(ctx, ctx.String(chainFlag.Name))
+		if err != nil {
+			return err
+		}
+
+		var address string
+		if publicKey := ctx.String("public-key"); len(publicKey) > 0 {
+			address, err = w.AddressFromPublicKey(nodeContext(ctx), publicKey)
+		} else {
+			address, err = mnemonicAddress(ctx, w, uint32(ctx.Uint("index")))
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(ctx.App.Writer, address)
+		return nil
+	},
+}
+
+var balanceCommand = cli.Command{
+	Name:      "balance",
+	Usage:     "print the balance of an address in the native coin or in a token",
+	ArgsUsage: "ADDRESS",
+	Flags: []cli.Flag{
+		chainFlag,
+		cli.StringFlag{Name: "token", Usage: "ERC-20 contract address or symbol of eth.tokens"},
+	},
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() != 1 {
+			return errors.New("balance takes one ADDRESS")
+		}
+
+		w, err := wallet(ctx, ctx.String(chainFlag.Name))
+		if err != nil {
+			return err
+		}
+
+		token, err := tokenAddress(ctx)
+		if err != nil {
+			return err
+		}
+
+		balance, err := w.Balance(nodeContext(ctx), ctx.Args().First(), token)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(ctx.App.Writer, balance.String())
+		return nil
+	},
+}
+
+var heightCommand = cli.Command{
+	Name:      "height",
+	Usage:     "print the height of the newest block",
+	ArgsUsage: " ",
+	Flags:     []cli.Flag{chainFlag},
+	Action: func(ctx *cli.Context) error {
+		w, err := wallet(ctx, ctx.String(chainFlag.Name))
+		if err != nil {
+			return err
+		}
+
+		height, err := w.BlockHeight(nodeContext(ctx))
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(ctx.App.Writer, height)
+		return nil
+	},
+}
+
+var blockCommand = cli.Command{
+	Name:      "block",
+	Usage:     "print a block and its transactions as JSON",
+	ArgsUsage: "HEIGHT",
+	Flags:     []cli.Flag{chainFlag},
+	Action: func(ctx *cli.Context) error {
+		height, err := strconv.ParseUint(ctx.Args().First(), 10, 64)
+		if err != nil || ctx.NArg() != 1 {
+			return errors.New("block takes one HEIGHT")
+		}
+
+		w, err := wallet(ctx, ctx.String(chainFlag.Name))
+		if err != nil {
+			return err
+		}
+
+		block, err := w.Block(nodeContext(ctx), height)
+		if err != nil {
+			return err
+		}
+		return printJSON(ctx, block)
+	},
+}
+
+var transactionCommand = cli.Command{
+	Name:      "tx",
+	Usage:     "print a transaction as JSON",
+	ArgsUsage: "ID",
+	Flags:     []cli.Flag{chainFlag},
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() != 1 {
+			return errors.New("tx takes one ID")
+		}
+
+		w, err := wallet(ctx, ctx.String(chainFlag.Name))
+		if err != nil {
+			return err
+		}
+
+		transaction, err := w.Transaction(nodeContext(ctx), ctx.Args().First())
+		if err != nil {
+			return err
+		}
+		return printJSON(ctx, transaction)
+	},
+}
+
+// transferCommand splits a transfer in the steps of an offline signer: build and broadcast run next to a node, sign
+// only needs the key. The transaction travels between them as JSON.
+var transferCommand = cli.Command{
+	Name:  "transfer",
+	Usage: "build, sign and broadcast transfers",
+	Subcommands: []cli.Command{
+		{
+			Name:      "build",
+			Usage:     "print the unsigned transaction of a transfer",
+			ArgsUsage: " ",
+			Flags: []cli.Flag{
+				chainFlag,
+				cli.StringFlag{Name: "from", Usage: "sender address"},
+				cli.StringFlag{Name: "to", Usage: "recipient address"},
+				cli.StringFlag{Name: "amount", Usage: "amount in ETH, BTC or the token"},
+				cli.StringFlag{Name: "token", Usage: "ERC-20 contract address or symbol of eth.tokens"},
+				cli.StringFlag{Name: "fee", Usage: "btc: the whole fee; eth: max fee per gas in ETH, suggested by the node when empty"},
+				cli.StringSliceFlag{Name: "utxo", Usage: "btc output to spend as TXID:N:VALUE:SCRIPT"},
+			},
+			Action: buildTransfer,
+		},
+		{
+			Name:      "sign",
+			Usage:     "sign a built transaction with the key of --key-file or $" + privateKeyEnv,
+			ArgsUsage: " ",
+			Flags:     []cli.Flag{inFlag},
+			Action: func(ctx *cli.Context) error {
+				tx, err := readTx(ctx)
+				if err != nil {
+					return err
+				}
+
+				w, err := wallet(ctx, tx.Chain)
+				if err != nil {
+					return err
+				}
+
+				key, err := privateKey(ctx)
+				if err != nil {
+					return err
+				}
+
+				if err = w.Sign(nodeContext(ctx), tx, key); err != nil {
+					return err
+				}
+				return printJSON(ctx, tx)
+			},
+		},
+		{
+			Name:      "broadcast",
+			Usage:     "send a signed transaction and print its ID",
+			ArgsUsage: " ",
+			Flags:     []cli.Flag{inFlag},
+			Action: func(ctx *cli.Context) error {
+				tx, err := readTx(ctx)
+				if err != nil {
+					return err
+				}
+
+				w, err := wallet(ctx, tx.Chain)
+				if err != nil {
+					return err
+				}
+
+				id, err := w.Broadcast(nodeContext(ctx), tx)
+				if err != nil {
+					return err
+				}
+
+				fmt.Fprintln(ctx.App.Writer, id)
+				return nil
+			},
+		},
+	},
+}
+
+var tokensCommand = cli.Command{
+	Name:      "tokens",
+	Usage:     "print the ERC-20 tokens of the configuration",
+	ArgsUsage: " ",
+	Action: func(ctx *cli.Context) error {
+		for _, token := range configStore(ctx).Config().ETH.Tokens {
+			fmt.Fprintf(ctx.App.Writer, "%s\t%s\n", token.Symbol, token.Address)
+		}
+		return nil
+	},
+}
+
+var nodesCommand = cli.Command{
+	Name:      "nodes",
+	Usage:     "print the height, latency and health of every endpoint of a chain",
+	ArgsUsage: " ",
+	Flags:     []cli.Flag{chainFlag},
+	Action: func(ctx *cli.Context) error {
+		settings := configStore(ctx).Config()
+		var pool *failover.Pool
+		switch name := ctx.String(chainFlag.Name); name {
+		case "eth":
+			p, err := ethPool(nodeContext(ctx), settings.ETH)
+			if err != nil {
+				return err
+			}
+			pool = p.Pool
+		case "btc":
+			p, err := btcPool(nodeContext(ctx), settings.BTC)
+			if err != nil {
+				return err
+			}
+			pool = p.Pool
+		default:
+			return fmt.Errorf("%w: %s", chain.ErrUnknownChain, name)
+		}
+
+		pool.Check(nodeContext(ctx))
+		statuses := pool.Status()
+
+		for _, status := range statuses {
+			state := "in sync"
+			switch {
+			case !status.Healthy:
+				state = "down: " + status.Error
+			case status.Lagging:
+				state = "lagging"
+			}
+			fmt.Fprintf(ctx.App.Writer, "%s\t%d\t%s\t%s\n", status.Name, status.Height,
+				status.Latency.Round(time.Millisecond), state)
+		}
+		return nil
+	},
+}
+
+// tokenAddress resolves the --token of an eth command through the token registry. Other chains get the flag as is.
+func tokenAddress(ctx *cli.Context) (string, error) {
+	token := ctx.String("token")
+	if len(token) == 0 || ctx.String(chainFlag.Name) != "eth" {
+		return token, nil
+	}
+	return configStore(ctx).Config().ETH.TokenAddress(token)
+}
+
+func buildTransfer(ctx *cli.Context) error {
+	w, err := wallet(ctx, ctx.String(chainFlag.Name))
+	if err != nil {
+		return err
+	}
+
+	request := chain.TransferRequest{From: ctx.String("from"), To: ctx.String("to")}
+	if request.Asset, err = tokenAddress(ctx); err != nil {
+		return err
+	}
+
+	if request.Amount, err = decimal.NewFromString(ctx.String("amount")); err != nil {
+		return fmt.Errorf("invalid --amount: %w", err)
+	}
+
+	if fee := ctx.String("fee"); len(fee) > 0 {
+		if request.Fee, err = decimal.NewFromString(fee); err != nil {
+			return fmt.Errorf("invalid --fee: %w", err)
+		}
+	}
+
+	for _, value := range ctx.StringSlice("utxo") {
+		utxo, err := parseUTXO(value)
+		if err != nil {
+			return err
+		}
+		request.UTXOs = append(request.UTXOs, utxo)
+	}
+
+	tx, err := w.BuildTransfer(nodeContext(ctx), request)
+	if err != nil {
+		return err
+	}
+	return printJSON(ctx, tx)
+}
+
+// parseUTXO parses TXID:N:VALUE:SCRIPT, VALUE in BTC and SCRIPT the hex pkScript.
+func parseUTXO(value string) (chain.UTXO, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 4 {
+		return chain.UTXO{}, fmt.Errorf("invalid --utxo %q, want TXID:N:VALUE:SCRIPT", value)
+	}
+
+	n, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return chain.UTXO{}, fmt.Errorf("invalid --utxo %q: %w", value, err)
+	}
+
+	amount, err := decimal.NewFromString(parts[2])
+	if err != nil {
+		return chain.UTXO{}, fmt.Errorf("invalid --utxo %q: %w", value, err)
+	}
+	return chain.UTXO{TxID: parts[0], N: uint32(n), Value: amount, Script: parts[3]}, nil
+}
+
+func mnemonicAddress(ctx *cli.Context, w chain.Chain, index uint32) (string, error) {
+	if w.Name() != "eth" {
+		return "", chain.ErrNotSupported
+	}
+
+	words, err := mnemonic(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	svc, err := ethService(ctx)
+	if err != nil {
+		return "", err
+	}
+	return svc.CreateAddress(nodeContext(ctx), words, index)
+}
+
+func readTx(ctx *cli.Context) (*chain.Tx, error) {
+	var in io.Reader = os.Stdin
+	if path := ctx.String(inFlag.Name); path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	var tx chain.Tx
+	if err := json.NewDecoder(in).Decode(&tx); err != nil {
+		return nil, fmt.Errorf("invalid transaction: %w", err)
+	}
+	return &tx, nil
+}
+
+func printJSON(ctx *cli.Context, v interface{}) error {
+	encoder := json.NewEncoder(ctx.App.Writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"demo/chain"
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func Test_Transfer(t *testing.T) {
+	svc, backend := getSimulatedService(t)
+	keyFile := writeFile(t, "key", owner1PrivateKey+"\n")
+
+	out, err := runApp(t, svc, "transfer", "build", "--from", owner1Addr, "--to", owner2Addr, "--amount", "0.5")
+	assert.NoError(t, err)
+	var unsigned chain.Tx
+	assert.NoError(t, json.Unmarshal([]byte(out), &unsigned))
+	assert.Equal(t, "eth", unsigned.Chain)
+	assert.Contains(t, unsigned.Summary, "send 0.5 ETH")
+
+	_, err = runApp(t, svc, "transfer", "sign", "--in", writeFile(t, "unsigned.json", out))
+	assert.Equal(t, errNoPrivateKey, err)
+
+	out, err = runApp(t, svc, "--key-file", keyFile, "transfer", "sign", "--in", writeFile(t, "unsigned.json", out))
+	assert.NoError(t, err)
+	var signed chain.Tx
+	assert.NoError(t, json.Unmarshal([]byte(out), &signed))
+	assert.Len(t, signed.ID, 66)
+
+	out, err = runApp(t, svc, "transfer", "broadcast", "--in", writeFile(t, "signed.json", out))
+	assert.NoError(t, err)
+	assert.Equal(t, signed.ID+"\n", out)
+	backend.Commit()
+
+	out, err = runApp(t, svc, "balance", owner2Addr)
+	assert.NoError(t, err)
+	assert.Equal(t, "100.5\n", out)
+
+	out, err = runApp(t, svc, "tx", signed.ID)
+	assert.NoError(t, err)
+	var transaction chain.Transaction
+	assert.NoError(t, json.Unmarshal([]byte(out), &transaction))
+	assert.Equal(t, uint64(1), transaction.BlockHeight)
+	assert.True(t, decimal.RequireFromString("0.5").Equal(transaction.Transfers[0].Amount))
+
+	out, err = runApp(t, svc, "block", "1")
+	assert.NoError(t, err)
+	var block chain.Block
+	assert.NoError(t, json.Unmarshal([]byte(out), &block))
+	assert.Equal(t, signed.ID, block.Transactions[0].ID)
+
+	_, err = runApp(t, svc, "block", "one")
+	assert.Error(t, err)
+	_, err = runApp(t, svc, "transfer", "build", "--from", owner1Addr, "--to", owner2Addr, "--amount", "half")
+	assert.Error(t, err)
+}
+
+func Test_Address(t *testing.T) {
+	svc, _ := getSimulatedService(t)
+	privateKey, _ := crypto.HexToECDSA(owner1PrivateKey[2:])
+	out, err := runApp(t, svc, "address", "--public-key", hexutil.Encode(crypto.CompressPubkey(&privateKey.PublicKey)))
+	assert.NoError(t, err)
+	assert.Equal(t, owner1Addr+"\n", out)
+
+	words := "tag volcano eight thank tide danger coast health above argue embrace heavy"
+	expected, _ := svc.CreateAddress(context.Background(), words, 3)
+	out, err = runApp(t, svc, "address", "--index", "3", "--mnemonic-file", writeFile(t, "mnemonic", words))
+	assert.NoError(t, err)
+	assert.Equal(t, expected+"\n", out)
+
+	os.Setenv(mnemonicEnv, words)
+	defer os.Unsetenv(mnemonicEnv)
+	out, _ = runApp(t, svc, "address", "--index", "3")
+	assert.Equal(t, expected+"\n", out)
+
+	_, err = runApp(t, svc, "address", "--chain", "btc", "--index", "3")
+	assert.ErrorIs(t, err, chain.ErrNotSupported)
+}
+
+func Test_ParseUTXO(t *testing.T) {
+	utxo, err := parseUTXO("92499d8de25472a191660e3a7374ded8ed78f8d7360aa8485e92ca90b75b306f:1:0.01:76a914")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), utxo.N)
+	assert.Equal(t, "0.01", utxo.Value.String())
+	assert.Equal(t, "76a914", utxo.Script)
+
+	_, err = parseUTXO("92499d8d:1:0.01")
+	assert.Error(t, err)
+	_, err = parseUTXO("92499d8d:x:0.01:76a914")
+	assert.Error(t, err)
+}
+
+func Test_Nodes(t *testing.T) {
+	node := func(height string) string {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var request struct {
+				ID json.RawMessage `json:"id"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&request)
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":"%s"}`, request.ID, height)
+		}))
+		t.Cleanup(server.Close)
+		return server.URL
+	}
+	synced, lagging := node("0x64"), node("0x50")
+	file := writeFile(t, "wallet.yaml", "eth:\n  endpoints: ["+synced+", "+lagging+", http://127.0.0.1:1]\n")
+
+	out, err := runApp(t, nil, "--config", file, "nodes")
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	assert.Len(t, lines, 3)
+	assert.Regexp(t, "^"+synced+"\t100\t.*\tin sync$", lines[0])
+	assert.Regexp(t, "^"+lagging+"\t80\t.*\tlagging$", lines[1])
+	assert.Regexp(t, "^http://127.0.0.1:1\t0\t0s\tdown: ", lines[2])
+
+	_, err = runApp(t, nil, "nodes", "--chain", "sol")
+	assert.ErrorIs(t, err, chain.ErrUnknownChain)
+}